@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/GDSources/claude-code-review-agent/pkg/cli"
+	"github.com/GDSources/claude-code-review-agent/pkg/feedback"
 	"github.com/GDSources/claude-code-review-agent/pkg/github"
+	"github.com/GDSources/claude-code-review-agent/pkg/history"
 	"github.com/GDSources/claude-code-review-agent/pkg/llm"
 	"github.com/GDSources/claude-code-review-agent/pkg/review"
 	"github.com/GDSources/claude-code-review-agent/pkg/webhook"
@@ -24,6 +30,24 @@ type Config struct {
 	ClaudeAPIKey  string
 	ClaudeModel   string
 	WebhookSecret string
+	HistoryFile   string
+	LLMCache      string
+	LLMCacheTTL   string
+	RepoCache     string
+	RepoQuota     string
+	IncludePaths  string
+	ExcludePaths  string
+
+	CommentThreshold   string
+	ShowPossibleIssues bool
+	TokenBudget        int
+	BudgetPerReview    float64
+	BudgetPerDay       float64
+	ModelRouting       bool
+
+	Autofix         bool
+	AutofixVerify   string
+	AutofixPushToPR bool
 }
 
 type ServerConfig struct {
@@ -31,7 +55,30 @@ type ServerConfig struct {
 	ClaudeAPIKey  string
 	ClaudeModel   string
 	WebhookSecret string
-	Port          int
+	HistoryFile   string
+	LLMCache      string
+	LLMCacheTTL   string
+	RepoCache     string
+	RepoQuota     string
+	IncludePaths  string
+	ExcludePaths  string
+
+	CommentThreshold   string
+	ShowPossibleIssues bool
+	TokenBudget        int
+	BudgetPerReview    float64
+	BudgetPerDay       float64
+	ModelRouting       bool
+
+	Autofix          bool
+	AutofixVerify    string
+	AutofixPushToPR  bool
+	FeedbackFile     string
+	FeedbackInterval string
+	WorkspaceTTL     string
+	WorkspaceQuota   string
+	WorkspaceWait    string
+	Port             int
 }
 
 func main() {
@@ -53,6 +100,10 @@ func main() {
 		runVersion(os.Args[2:])
 	case "action":
 		runAction(os.Args[2:])
+	case "history":
+		runHistory(os.Args[2:])
+	case "feedback":
+		runFeedback(os.Args[2:])
 	case "--help", "-h", "help":
 		printUsage()
 	default:
@@ -73,6 +124,8 @@ Commands:
   server      Start webhook server for automated reviews
   init        Create a sample .env file for configuration
   version     Show version information
+  history     Show past review runs recorded locally
+  feedback    Collect reactions on posted comments and report precision
   action      Run in GitHub Action mode (internal use)
   help        Show this help message
 
@@ -84,7 +137,7 @@ func runReview(args []string) {
 	fs := flag.NewFlagSet("review", flag.ExitOnError)
 
 	config := &Config{}
-	var owner, repo string
+	var owner, repo, outputFile string
 	var prNumber int
 
 	fs.StringVar(&config.GitHubToken, "github-token", "", "GitHub API token")
@@ -93,6 +146,23 @@ func runReview(args []string) {
 	fs.StringVar(&owner, "owner", "", "Repository owner/organization")
 	fs.StringVar(&repo, "repo", "", "Repository name")
 	fs.IntVar(&prNumber, "pr", 0, "Pull request number")
+	fs.StringVar(&outputFile, "output-file", "", "Write the full review result as JSON to this file")
+	fs.StringVar(&config.HistoryFile, "history-file", "", "Review history file (\"off\" to disable)")
+	fs.StringVar(&config.LLMCache, "llm-cache", "", "Reuse LLM results for unchanged files: \"memory\", \"disk\" or a cache directory")
+	fs.StringVar(&config.LLMCacheTTL, "llm-cache-ttl", "", "How long cached LLM results are reused, e.g. 24h (default 168h)")
+	fs.StringVar(&config.RepoCache, "repo-cache", "", "Check out pull requests from persistent repository mirrors: \"default\" or a cache directory")
+	fs.StringVar(&config.RepoQuota, "repo-cache-quota", "", "Disk space for repository mirrors before the least recently used are evicted, e.g. 20GB")
+	fs.StringVar(&config.IncludePaths, "include-paths", "", "Comma-separated globs of paths to review")
+	fs.StringVar(&config.ExcludePaths, "exclude-paths", "", "Comma-separated globs of paths to exclude from review")
+	fs.StringVar(&config.CommentThreshold, "comment-threshold", "", "Minimum confidence (0-1) for posting a comment")
+	fs.BoolVar(&config.ShowPossibleIssues, "show-possible-issues", false, "List low-confidence findings in the review summary")
+	fs.IntVar(&config.TokenBudget, "token-budget", 0, "Estimated diff tokens to review before leaving out low-risk files")
+	fs.Float64Var(&config.BudgetPerReview, "budget-per-review", 0, "Most a single review may spend on the LLM, in US dollars")
+	fs.Float64Var(&config.BudgetPerDay, "budget-per-day", 0, "Most all reviews may spend on the LLM per UTC day, in US dollars")
+	fs.BoolVar(&config.ModelRouting, "model-routing", false, "Pick the model from the size, languages and paths of the diff")
+	fs.BoolVar(&config.Autofix, "autofix", false, "Push suggested fixes to a branch instead of commenting them")
+	fs.StringVar(&config.AutofixVerify, "autofix-verify", "", "Command that must succeed after applying fixes, e.g. \"go build ./...\"; it executes pull request code")
+	fs.BoolVar(&config.AutofixPushToPR, "autofix-push-to-pr", false, "Push fixes to the pull request branch instead of review-agent/fixes-<pr>")
 
 	fs.Usage = func() {
 		fmt.Print(`Review a specific pull request
@@ -107,6 +177,23 @@ Flags:
   --owner           Repository owner/organization (required)
   --repo            Repository name (required)
   --pr              Pull request number (required)
+  --output-file     Write the full review result as JSON to this file
+  --history-file    Review history file (or set REVIEW_HISTORY_FILE env var, default: ~/.config/review-agent/history.jsonl, "off" to disable)
+  --llm-cache       Reuse LLM results for unchanged files: "disk" (~/.cache/review-agent/llm) or a directory (or set REVIEW_LLM_CACHE env var)
+  --llm-cache-ttl   How long cached LLM results are reused (or set REVIEW_LLM_CACHE_TTL env var, default: 168h)
+  --repo-cache      Check out from persistent repository mirrors: "default" (~/.cache/review-agent/repos) or a directory (or set REVIEW_REPO_CACHE env var)
+  --repo-cache-quota Disk space for repository mirrors (or set REVIEW_REPO_CACHE_QUOTA env var, default: 20GB)
+  --include-paths   Comma-separated globs of paths to review (or set REVIEW_INCLUDE_PATHS env var)
+  --exclude-paths   Comma-separated globs of paths to exclude (or set REVIEW_EXCLUDE_PATHS env var, e.g. "vendor/**,*.lock")
+  --comment-threshold     Minimum confidence (0-1) for posting a comment (or set REVIEW_COMMENT_THRESHOLD env var)
+  --show-possible-issues  List low-confidence findings in the review summary (or set REVIEW_SHOW_POSSIBLE_ISSUES=true)
+  --token-budget          Estimated diff tokens to review; lower-risk files beyond it are skipped (or set REVIEW_TOKEN_BUDGET env var, default: 100000)
+  --budget-per-review     Most a review may spend in US dollars; over it a cheaper model, less context or no review is used (or set REVIEW_BUDGET_PER_REVIEW env var)
+  --budget-per-day        Most all reviews may spend per UTC day in US dollars, counted from the review history (or set REVIEW_BUDGET_PER_DAY env var)
+  --model-routing         Send small or docs-only diffs to a fast model and large or security-sensitive ones to the most capable (or set REVIEW_MODEL_ROUTING=true)
+  --autofix               Push suggested fixes to review-agent/fixes-<pr> instead of commenting them (or set REVIEW_AUTOFIX=true)
+  --autofix-verify        Command that must succeed after applying fixes, e.g. "go build ./..."; it executes pull request code (or set REVIEW_AUTOFIX_VERIFY env var)
+  --autofix-push-to-pr    Push fixes to the pull request branch when it is in the same repository (or set REVIEW_AUTOFIX_PUSH_TO_PR=true)
 
 Available Claude Models:
   claude-3-5-haiku-20241022     Fast and cost-effective, good for simple reviews
@@ -132,6 +219,9 @@ Examples:
 
   # Use command line flags
   review-agent review --github-token xxx --claude-key yyy --owner myorg --repo myrepo --pr 123
+
+  # Save the full review result (comments, tokens, timings, warnings) as JSON
+  review-agent review --owner myorg --repo myrepo --pr 123 --output-file review-result.json
 `)
 	}
 
@@ -151,6 +241,14 @@ Examples:
 	}
 
 	result, err := executeReview(config, owner, repo, prNumber)
+
+	// Write the full result even for failed reviews so callers can inspect warnings
+	if outputFile != "" && result != nil {
+		if writeErr := review.WriteResultFile(outputFile, result); writeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", writeErr)
+		}
+	}
+
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "❌ Review failed: %v\n", err)
 		os.Exit(1)
@@ -233,6 +331,60 @@ func loadEnvConfig(config *Config) error {
 	if config.WebhookSecret == "" {
 		config.WebhookSecret = os.Getenv("WEBHOOK_SECRET")
 	}
+	if config.HistoryFile == "" {
+		config.HistoryFile = os.Getenv("REVIEW_HISTORY_FILE")
+	}
+	if config.LLMCache == "" {
+		config.LLMCache = os.Getenv("REVIEW_LLM_CACHE")
+	}
+	if config.LLMCacheTTL == "" {
+		config.LLMCacheTTL = os.Getenv("REVIEW_LLM_CACHE_TTL")
+	}
+	if config.RepoCache == "" {
+		config.RepoCache = os.Getenv("REVIEW_REPO_CACHE")
+	}
+	if config.RepoQuota == "" {
+		config.RepoQuota = os.Getenv("REVIEW_REPO_CACHE_QUOTA")
+	}
+	if config.IncludePaths == "" {
+		config.IncludePaths = os.Getenv("REVIEW_INCLUDE_PATHS")
+	}
+	if config.ExcludePaths == "" {
+		config.ExcludePaths = os.Getenv("REVIEW_EXCLUDE_PATHS")
+	}
+	if config.CommentThreshold == "" {
+		config.CommentThreshold = os.Getenv("REVIEW_COMMENT_THRESHOLD")
+	}
+	if !config.ShowPossibleIssues {
+		config.ShowPossibleIssues = os.Getenv("REVIEW_SHOW_POSSIBLE_ISSUES") == "true"
+	}
+	if config.TokenBudget == 0 {
+		if budget, err := strconv.Atoi(os.Getenv("REVIEW_TOKEN_BUDGET")); err == nil {
+			config.TokenBudget = budget
+		}
+	}
+	if config.BudgetPerReview == 0 {
+		if budget, err := strconv.ParseFloat(os.Getenv("REVIEW_BUDGET_PER_REVIEW"), 64); err == nil {
+			config.BudgetPerReview = budget
+		}
+	}
+	if config.BudgetPerDay == 0 {
+		if budget, err := strconv.ParseFloat(os.Getenv("REVIEW_BUDGET_PER_DAY"), 64); err == nil {
+			config.BudgetPerDay = budget
+		}
+	}
+	if !config.ModelRouting {
+		config.ModelRouting = os.Getenv("REVIEW_MODEL_ROUTING") == "true"
+	}
+	if !config.Autofix {
+		config.Autofix = os.Getenv("REVIEW_AUTOFIX") == "true"
+	}
+	if config.AutofixVerify == "" {
+		config.AutofixVerify = os.Getenv("REVIEW_AUTOFIX_VERIFY")
+	}
+	if !config.AutofixPushToPR {
+		config.AutofixPushToPR = os.Getenv("REVIEW_AUTOFIX_PUSH_TO_PR") == "true"
+	}
 
 	return nil
 }
@@ -253,18 +405,42 @@ func validateReviewConfig(config *Config, owner, repo string, prNumber int) erro
 	if prNumber <= 0 {
 		return fmt.Errorf("valid pull request number is required (set --pr flag)")
 	}
+	if _, err := parseCommentThreshold(config.CommentThreshold); err != nil {
+		return err
+	}
 	return nil
 }
 
 func executeReview(config *Config, owner, repo string, prNumber int) (*review.ReviewResult, error) {
 	fmt.Printf("🔍 Starting review for PR #%d in %s/%s...\n", prNumber, owner, repo)
 
+	// Delete workspaces left behind by crashed runs, as the server's janitor would
+	cli.SweepStaleWorkspaces()
+
 	// Create reviewer with configuration
 	reviewConfig := &cli.ReviewConfig{
 		GitHubToken:  config.GitHubToken,
 		ClaudeAPIKey: config.ClaudeAPIKey,
 		ClaudeModel:  config.ClaudeModel,
+		HistoryFile:  config.HistoryFile,
+		LLMCache:     config.LLMCache,
+		LLMCacheTTL:  config.LLMCacheTTL,
+		RepoCache:    config.RepoCache,
+		RepoQuota:    config.RepoQuota,
+		IncludePaths: splitPathList(config.IncludePaths),
+		ExcludePaths: splitPathList(config.ExcludePaths),
+
+		ShowPossibleIssues: config.ShowPossibleIssues,
+		TokenBudget:        config.TokenBudget,
+		BudgetPerReview:    config.BudgetPerReview,
+		BudgetPerDay:       config.BudgetPerDay,
+		ModelRouting:       config.ModelRouting,
+
+		Autofix:         config.Autofix,
+		AutofixVerify:   config.AutofixVerify,
+		AutofixPushToPR: config.AutofixPushToPR,
 	}
+	reviewConfig.CommentThreshold, _ = parseCommentThreshold(config.CommentThreshold)
 
 	reviewer := cli.NewPRReviewer(reviewConfig)
 
@@ -325,6 +501,27 @@ func runServer(args []string) {
 	fs.StringVar(&serverConfig.ClaudeAPIKey, "claude-key", "", "Claude API key")
 	fs.StringVar(&serverConfig.ClaudeModel, "claude-model", "", "Claude model to use")
 	fs.StringVar(&serverConfig.WebhookSecret, "webhook-secret", "", "GitHub webhook secret")
+	fs.StringVar(&serverConfig.HistoryFile, "history-file", "", "Review history file (\"off\" to disable)")
+	fs.StringVar(&serverConfig.LLMCache, "llm-cache", "", "Reuse LLM results for unchanged files: \"memory\", \"disk\" or a cache directory")
+	fs.StringVar(&serverConfig.LLMCacheTTL, "llm-cache-ttl", "", "How long cached LLM results are reused, e.g. 24h (default 168h)")
+	fs.StringVar(&serverConfig.RepoCache, "repo-cache", "", "Check out pull requests from persistent repository mirrors: \"default\" or a cache directory")
+	fs.StringVar(&serverConfig.RepoQuota, "repo-cache-quota", "", "Disk space for repository mirrors before the least recently used are evicted, e.g. 20GB")
+	fs.StringVar(&serverConfig.IncludePaths, "include-paths", "", "Comma-separated globs of paths to review")
+	fs.StringVar(&serverConfig.ExcludePaths, "exclude-paths", "", "Comma-separated globs of paths to exclude from review")
+	fs.StringVar(&serverConfig.CommentThreshold, "comment-threshold", "", "Minimum confidence (0-1) for posting a comment")
+	fs.BoolVar(&serverConfig.ShowPossibleIssues, "show-possible-issues", false, "List low-confidence findings in the review summary")
+	fs.IntVar(&serverConfig.TokenBudget, "token-budget", 0, "Estimated diff tokens to review before leaving out low-risk files")
+	fs.Float64Var(&serverConfig.BudgetPerReview, "budget-per-review", 0, "Most a single review may spend on the LLM, in US dollars")
+	fs.Float64Var(&serverConfig.BudgetPerDay, "budget-per-day", 0, "Most all reviews may spend on the LLM per UTC day, in US dollars")
+	fs.BoolVar(&serverConfig.ModelRouting, "model-routing", false, "Pick the model from the size, languages and paths of the diff")
+	fs.BoolVar(&serverConfig.Autofix, "autofix", false, "Push suggested fixes to a branch instead of commenting them")
+	fs.StringVar(&serverConfig.AutofixVerify, "autofix-verify", "", "Command that must succeed after applying fixes, e.g. \"go build ./...\"; it executes pull request code")
+	fs.BoolVar(&serverConfig.AutofixPushToPR, "autofix-push-to-pr", false, "Push fixes to the pull request branch instead of review-agent/fixes-<pr>")
+	fs.StringVar(&serverConfig.FeedbackFile, "feedback-file", "", "Comment feedback file")
+	fs.StringVar(&serverConfig.FeedbackInterval, "feedback-interval", "", "How often to collect feedback on posted comments, e.g. 6h")
+	fs.StringVar(&serverConfig.WorkspaceTTL, "workspace-ttl", "", "Age after which left-behind review workspaces are deleted, e.g. 6h")
+	fs.StringVar(&serverConfig.WorkspaceQuota, "workspace-quota", "", "Disk space all review workspaces may use together, e.g. 50GB")
+	fs.StringVar(&serverConfig.WorkspaceWait, "workspace-quota-wait", "", "How long a review waits for workspace disk space before it is rejected, e.g. 10m")
 	fs.IntVar(&serverConfig.Port, "port", 8080, "Server port")
 
 	fs.Usage = func() {
@@ -338,6 +535,27 @@ Flags:
   --claude-key       Claude API key (or set CLAUDE_API_KEY env var)
   --claude-model     Claude model to use (or set CLAUDE_MODEL env var, default: claude-sonnet-4-20250514)
   --webhook-secret   GitHub webhook secret (or set WEBHOOK_SECRET env var)
+  --history-file     Review history file (or set REVIEW_HISTORY_FILE env var, "off" to disable)
+  --llm-cache        Reuse LLM results for unchanged files: "memory", "disk" (~/.cache/review-agent/llm) or a directory (or set REVIEW_LLM_CACHE env var)
+  --llm-cache-ttl    How long cached LLM results are reused (or set REVIEW_LLM_CACHE_TTL env var, default: 168h)
+  --repo-cache       Check out from persistent repository mirrors: "default" (~/.cache/review-agent/repos) or a directory (or set REVIEW_REPO_CACHE env var)
+  --repo-cache-quota Disk space for repository mirrors (or set REVIEW_REPO_CACHE_QUOTA env var, default: 20GB)
+  --include-paths    Comma-separated globs of paths to review (or set REVIEW_INCLUDE_PATHS env var)
+  --exclude-paths    Comma-separated globs of paths to exclude (or set REVIEW_EXCLUDE_PATHS env var)
+  --comment-threshold     Minimum confidence (0-1) for posting a comment (or set REVIEW_COMMENT_THRESHOLD env var)
+  --show-possible-issues  List low-confidence findings in the review summary (or set REVIEW_SHOW_POSSIBLE_ISSUES=true)
+  --token-budget          Estimated diff tokens to review; lower-risk files beyond it are skipped (or set REVIEW_TOKEN_BUDGET env var, default: 100000)
+  --budget-per-review     Most a review may spend in US dollars; over it a cheaper model, less context or no review is used (or set REVIEW_BUDGET_PER_REVIEW env var)
+  --budget-per-day        Most all reviews may spend per UTC day in US dollars, counted from the review history (or set REVIEW_BUDGET_PER_DAY env var)
+  --model-routing         Send small or docs-only diffs to a fast model and large or security-sensitive ones to the most capable (or set REVIEW_MODEL_ROUTING=true)
+  --autofix               Push suggested fixes to review-agent/fixes-<pr> instead of commenting them (or set REVIEW_AUTOFIX=true)
+  --autofix-verify        Command that must succeed after applying fixes, e.g. "go build ./..."; it executes pull request code (or set REVIEW_AUTOFIX_VERIFY env var)
+  --autofix-push-to-pr    Push fixes to the pull request branch when it is in the same repository (or set REVIEW_AUTOFIX_PUSH_TO_PR=true)
+  --feedback-file         Comment feedback file (or set REVIEW_FEEDBACK_FILE env var, default: ~/.config/review-agent/feedback.jsonl)
+  --feedback-interval     Collect reactions on posted comments at this interval, e.g. 6h (or set REVIEW_FEEDBACK_INTERVAL env var, default: off)
+  --workspace-ttl         Delete review workspaces left behind for longer than this (or set REVIEW_WORKSPACE_TTL env var, default: 6h)
+  --workspace-quota       Disk space all review workspaces may use together, e.g. 50GB (or set REVIEW_WORKSPACE_QUOTA env var, default: unlimited)
+  --workspace-quota-wait  How long a review waits for disk space over the quota before it is rejected (or set REVIEW_WORKSPACE_QUOTA_WAIT env var, default: 0)
   --port             Server port (default: 8080)
 
 Available Claude Models:
@@ -408,6 +626,75 @@ func loadServerConfig(config *ServerConfig) error {
 	if config.WebhookSecret == "" {
 		config.WebhookSecret = os.Getenv("WEBHOOK_SECRET")
 	}
+	if config.HistoryFile == "" {
+		config.HistoryFile = os.Getenv("REVIEW_HISTORY_FILE")
+	}
+	if config.LLMCache == "" {
+		config.LLMCache = os.Getenv("REVIEW_LLM_CACHE")
+	}
+	if config.LLMCacheTTL == "" {
+		config.LLMCacheTTL = os.Getenv("REVIEW_LLM_CACHE_TTL")
+	}
+	if config.RepoCache == "" {
+		config.RepoCache = os.Getenv("REVIEW_REPO_CACHE")
+	}
+	if config.RepoQuota == "" {
+		config.RepoQuota = os.Getenv("REVIEW_REPO_CACHE_QUOTA")
+	}
+	if config.IncludePaths == "" {
+		config.IncludePaths = os.Getenv("REVIEW_INCLUDE_PATHS")
+	}
+	if config.ExcludePaths == "" {
+		config.ExcludePaths = os.Getenv("REVIEW_EXCLUDE_PATHS")
+	}
+	if config.CommentThreshold == "" {
+		config.CommentThreshold = os.Getenv("REVIEW_COMMENT_THRESHOLD")
+	}
+	if !config.ShowPossibleIssues {
+		config.ShowPossibleIssues = os.Getenv("REVIEW_SHOW_POSSIBLE_ISSUES") == "true"
+	}
+	if config.TokenBudget == 0 {
+		if budget, err := strconv.Atoi(os.Getenv("REVIEW_TOKEN_BUDGET")); err == nil {
+			config.TokenBudget = budget
+		}
+	}
+	if config.BudgetPerReview == 0 {
+		if budget, err := strconv.ParseFloat(os.Getenv("REVIEW_BUDGET_PER_REVIEW"), 64); err == nil {
+			config.BudgetPerReview = budget
+		}
+	}
+	if config.BudgetPerDay == 0 {
+		if budget, err := strconv.ParseFloat(os.Getenv("REVIEW_BUDGET_PER_DAY"), 64); err == nil {
+			config.BudgetPerDay = budget
+		}
+	}
+	if !config.ModelRouting {
+		config.ModelRouting = os.Getenv("REVIEW_MODEL_ROUTING") == "true"
+	}
+	if !config.Autofix {
+		config.Autofix = os.Getenv("REVIEW_AUTOFIX") == "true"
+	}
+	if config.AutofixVerify == "" {
+		config.AutofixVerify = os.Getenv("REVIEW_AUTOFIX_VERIFY")
+	}
+	if !config.AutofixPushToPR {
+		config.AutofixPushToPR = os.Getenv("REVIEW_AUTOFIX_PUSH_TO_PR") == "true"
+	}
+	if config.FeedbackFile == "" {
+		config.FeedbackFile = os.Getenv("REVIEW_FEEDBACK_FILE")
+	}
+	if config.FeedbackInterval == "" {
+		config.FeedbackInterval = os.Getenv("REVIEW_FEEDBACK_INTERVAL")
+	}
+	if config.WorkspaceTTL == "" {
+		config.WorkspaceTTL = os.Getenv("REVIEW_WORKSPACE_TTL")
+	}
+	if config.WorkspaceQuota == "" {
+		config.WorkspaceQuota = os.Getenv("REVIEW_WORKSPACE_QUOTA")
+	}
+	if config.WorkspaceWait == "" {
+		config.WorkspaceWait = os.Getenv("REVIEW_WORKSPACE_QUOTA_WAIT")
+	}
 
 	// Port can also come from env var
 	if portStr := os.Getenv("PORT"); portStr != "" && config.Port == 8080 { // Only override default
@@ -449,6 +736,25 @@ func startWebhookServer(config *ServerConfig) error {
 
 	// Create workspace manager
 	workspaceManager := review.NewDefaultWorkspaceManager(cloner, fsManager)
+	// Read single files through the API so the repository is cloned only when a stage needs it
+	workspaceManager.SetContentReader(githubClient)
+
+	// Check out pull requests from a persistent mirror instead of cloning them every time
+	repoCache, err := cli.OpenRepoCache(config.RepoCache, config.RepoQuota)
+	if err != nil {
+		return fmt.Errorf("failed to open repository cache: %w", err)
+	}
+	if repoCache != nil {
+		workspaceManager.SetMirrorCache(repoCache)
+	}
+
+	// Delete workspaces left behind by crashed reviews and keep all workspaces within the disk quota
+	janitor, err := cli.NewWorkspaceJanitor(config.WorkspaceTTL, config.WorkspaceQuota, config.WorkspaceWait)
+	if err != nil {
+		return err
+	}
+	workspaceManager.SetJanitor(janitor)
+	go janitor.Run(context.Background(), review.DefaultJanitorInterval)
 
 	// Create diff fetcher
 	diffFetcher := review.NewGitHubDiffFetcherFromClient(githubClient)
@@ -473,11 +779,19 @@ func startWebhookServer(config *ServerConfig) error {
 			Timeout:     llm.DefaultTimeoutSeconds,
 		}
 
-		var err error
-		claudeClient, err = llm.NewClaudeClient(claudeConfig)
+		client, err := llm.NewClaudeClient(claudeConfig)
 		if err != nil {
 			fmt.Printf("Warning: Failed to create Claude client: %v\n", err)
-			claudeClient = nil
+		} else {
+			// Reuse comments for files reviewed before with the same model, prompts and diff
+			cache, err := cli.OpenResultCache(config.LLMCache, config.LLMCacheTTL)
+			if err != nil {
+				return fmt.Errorf("failed to open LLM result cache: %w", err)
+			}
+			if cache != nil {
+				client.SetCache(cache)
+			}
+			claudeClient = client
 		}
 	} else {
 		fmt.Printf("Warning: CLAUDE_API_KEY not provided, LLM reviews will be skipped\n")
@@ -486,6 +800,44 @@ func startWebhookServer(config *ServerConfig) error {
 	// Create review orchestrator with LLM and comment posting integration
 	orchestrator := review.NewReviewOrchestratorWithComments(workspaceManager, diffFetcher, codeAnalyzer, claudeClient, githubClient)
 
+	// Read per-repository settings from .review-agent.yml on the base branch
+	orchestrator.SetRepoConfigReader(githubClient)
+	// Read the base revision of changed files to find the declarations a pull request removes
+	orchestrator.SetBaseFileReader(githubClient)
+	orchestrator.SetPathFilters(splitPathList(config.IncludePaths), splitPathList(config.ExcludePaths))
+	// Report workspace sizes at /metrics
+	metrics := review.NewWorkspaceMetrics()
+	orchestrator.SetMetrics(metrics)
+
+	commentThreshold, err := parseCommentThreshold(config.CommentThreshold)
+	if err != nil {
+		return err
+	}
+	orchestrator.SetConfidenceThreshold(commentThreshold, config.ShowPossibleIssues)
+	orchestrator.SetTokenBudget(config.TokenBudget)
+	orchestrator.SetBudget(review.BudgetSettings{PerReview: config.BudgetPerReview, PerDay: config.BudgetPerDay})
+	if config.ModelRouting {
+		orchestrator.SetModelRouting(review.DefaultRouting())
+	}
+	orchestrator.SetAutofix(review.AutofixSettings{
+		Enabled:        config.Autofix,
+		VerifyCommand:  config.AutofixVerify,
+		PushToPRBranch: config.AutofixPushToPR,
+	})
+
+	// Record every review run in the local history store
+	historyStore, err := cli.OpenHistoryStore(config.HistoryFile)
+	if err != nil {
+		fmt.Printf("Warning: Failed to open review history: %v\n", err)
+	} else if historyStore != nil {
+		orchestrator.SetHistoryStore(historyStore)
+		fmt.Printf("📚 Recording review history to %s\n", historyStore.Path())
+
+		if err := startFeedbackCollector(githubClient, historyStore, config.FeedbackFile, config.FeedbackInterval); err != nil {
+			fmt.Printf("Warning: Feedback collection disabled: %v\n", err)
+		}
+	}
+
 	// Create adapter to bridge between review and webhook types
 	adapter := &OrchestratorAdapter{orchestrator: orchestrator}
 
@@ -500,6 +852,7 @@ func startWebhookServer(config *ServerConfig) error {
 
 	// Set up HTTP routes
 	http.Handle("/webhook", handler)
+	http.Handle("/metrics", metrics)
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("OK"))
@@ -509,10 +862,331 @@ func startWebhookServer(config *ServerConfig) error {
 	fmt.Printf("✓ Server listening on %s\n", addr)
 	fmt.Printf("📥 Webhook endpoint: http://localhost%s/webhook\n", addr)
 	fmt.Printf("🔍 Health check: http://localhost%s/health\n", addr)
+	fmt.Printf("📊 Metrics: http://localhost%s/metrics\n", addr)
 
 	return http.ListenAndServe(addr, nil)
 }
 
+// startFeedbackCollector periodically collects feedback on the comments posted in the last 30 days
+func startFeedbackCollector(githubClient *github.Client, historyStore history.Store, feedbackFile, interval string) error {
+	if strings.TrimSpace(interval) == "" {
+		return nil
+	}
+
+	every, err := time.ParseDuration(strings.TrimSpace(interval))
+	if err != nil || every <= 0 {
+		return fmt.Errorf("invalid feedback interval %q", interval)
+	}
+
+	store, err := cli.OpenFeedbackStore(feedbackFile)
+	if err != nil {
+		return err
+	}
+
+	collector := feedback.NewCollector(githubClient, historyStore, store)
+	go collector.Run(context.Background(), every, 30*24*time.Hour)
+	fmt.Printf("👍 Collecting comment feedback every %s into %s\n", every, store.Path())
+	return nil
+}
+
+// splitPathList splits a comma-separated list of globs, dropping empty entries
+func splitPathList(value string) []string {
+	var paths []string
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// parseCommentThreshold parses a confidence threshold between 0 and 1; empty disables it
+func parseCommentThreshold(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil || threshold < 0 || threshold > 1 {
+		return 0, fmt.Errorf("comment threshold must be a number between 0 and 1, got %q", value)
+	}
+	return threshold, nil
+}
+
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+
+	var historyFile, repository, since, until string
+	var prNumber, limit int
+	var asJSON bool
+
+	fs.StringVar(&historyFile, "history-file", "", "Review history file")
+	fs.StringVar(&repository, "repo", "", "Repository in owner/repo format")
+	fs.IntVar(&prNumber, "pr", 0, "Pull request number")
+	fs.StringVar(&since, "since", "", "Only show reviews on or after this date (YYYY-MM-DD)")
+	fs.StringVar(&until, "until", "", "Only show reviews before this date (YYYY-MM-DD)")
+	fs.IntVar(&limit, "limit", 20, "Maximum number of reviews to show (0 for all)")
+	fs.BoolVar(&asJSON, "json", false, "Output records as JSON")
+
+	fs.Usage = func() {
+		fmt.Print(`Show past review runs recorded locally
+
+Usage:
+  review-agent history [flags]
+
+Flags:
+  --history-file   Review history file (or set REVIEW_HISTORY_FILE env var, default: ~/.config/review-agent/history.jsonl)
+  --repo           Repository in owner/repo format
+  --pr             Pull request number
+  --since          Only show reviews on or after this date (YYYY-MM-DD)
+  --until          Only show reviews before this date (YYYY-MM-DD)
+  --limit          Maximum number of reviews to show (default: 20, 0 for all)
+  --json           Output records as JSON
+
+Examples:
+  review-agent history --repo myorg/myrepo
+  review-agent history --repo myorg/myrepo --pr 123 --json
+  review-agent history --since 2025-01-01 --until 2025-02-01
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
+		os.Exit(1)
+	}
+
+	filter, err := buildHistoryFilter(repository, prNumber, since, until, limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if historyFile == "" {
+		historyFile = os.Getenv("REVIEW_HISTORY_FILE")
+	}
+	store, err := cli.OpenHistoryStore(historyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open review history: %v\n", err)
+		os.Exit(1)
+	}
+	if store == nil {
+		fmt.Fprintf(os.Stderr, "Review history is disabled\n")
+		os.Exit(1)
+	}
+
+	records, err := store.Query(filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to query review history: %v\n", err)
+		os.Exit(1)
+	}
+
+	if asJSON {
+		fmt.Println(mustMarshalJSON(records))
+		return
+	}
+
+	printHistory(records)
+}
+
+// buildHistoryFilter validates history command flags and converts them into a query filter
+func buildHistoryFilter(repository string, prNumber int, since, until string, limit int) (history.Filter, error) {
+	filter := history.Filter{
+		Repository:  repository,
+		PullRequest: prNumber,
+		Limit:       limit,
+	}
+
+	if repository != "" && !strings.Contains(repository, "/") {
+		return filter, fmt.Errorf("repository must be in owner/repo format: %s", repository)
+	}
+	if prNumber < 0 {
+		return filter, fmt.Errorf("invalid pull request number: %d", prNumber)
+	}
+
+	if since != "" {
+		t, err := time.Parse("2006-01-02", since)
+		if err != nil {
+			return filter, fmt.Errorf("invalid --since date %q (expected YYYY-MM-DD)", since)
+		}
+		filter.Since = t
+	}
+	if until != "" {
+		t, err := time.Parse("2006-01-02", until)
+		if err != nil {
+			return filter, fmt.Errorf("invalid --until date %q (expected YYYY-MM-DD)", until)
+		}
+		filter.Until = t
+	}
+
+	return filter, nil
+}
+
+// printHistory prints review records as a table
+func printHistory(records []history.Record) {
+	if len(records) == 0 {
+		fmt.Println("No reviews found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tREPOSITORY\tPR\tHEAD\tSTATUS\tMODEL\tCOMMENTS\tTOKENS\tCOST\tDURATION")
+	for _, record := range records {
+		head := record.HeadSHA
+		if len(head) > 7 {
+			head = head[:7]
+		}
+		fmt.Fprintf(w, "%s\t%s\t#%d\t%s\t%s\t%s\t%d/%d\t%d\t$%.4f\t%s\n",
+			record.StartedAt.Local().Format("2006-01-02 15:04"),
+			record.Repository,
+			record.PullRequest,
+			head,
+			record.Status,
+			record.Model,
+			record.CommentsPosted,
+			len(record.Comments),
+			record.Tokens.Total,
+			record.CostUSD,
+			review.FormatElapsedTime(time.Duration(record.DurationMs)*time.Millisecond))
+	}
+	_ = w.Flush()
+}
+
+func runFeedback(args []string) {
+	fs := flag.NewFlagSet("feedback", flag.ExitOnError)
+
+	var githubToken, historyFile, feedbackFile, repository, since, until string
+	var noCollect, asJSON bool
+
+	fs.StringVar(&githubToken, "github-token", "", "GitHub API token")
+	fs.StringVar(&historyFile, "history-file", "", "Review history file")
+	fs.StringVar(&feedbackFile, "feedback-file", "", "Comment feedback file")
+	fs.StringVar(&repository, "repo", "", "Repository in owner/repo format")
+	fs.StringVar(&since, "since", "", "Only include comments posted on or after this date (YYYY-MM-DD)")
+	fs.StringVar(&until, "until", "", "Only include comments posted before this date (YYYY-MM-DD)")
+	fs.BoolVar(&noCollect, "no-collect", false, "Report on previously collected feedback without calling GitHub")
+	fs.BoolVar(&asJSON, "json", false, "Output the report as JSON")
+
+	fs.Usage = func() {
+		fmt.Print(`Collect reactions on posted comments and report precision
+
+Reads the comments recorded in the review history, fetches their reactions and
+thread state from GitHub, and reports the share of rated comments that were useful.
+A 👍 counts as useful and a 👎 as not useful. Without reactions, a thread resolved
+without changing the code counts as not useful and a thread whose code changed
+counts as useful.
+
+Usage:
+  review-agent feedback [flags]
+
+Flags:
+  --github-token    GitHub API token (or set GH_TOKEN env var)
+  --history-file    Review history file (or set REVIEW_HISTORY_FILE env var, default: ~/.config/review-agent/history.jsonl)
+  --feedback-file   Comment feedback file (or set REVIEW_FEEDBACK_FILE env var, default: ~/.config/review-agent/feedback.jsonl)
+  --repo            Repository in owner/repo format
+  --since           Only include comments posted on or after this date (YYYY-MM-DD)
+  --until           Only include comments posted before this date (YYYY-MM-DD)
+  --no-collect      Report on previously collected feedback without calling GitHub
+  --json            Output the report as JSON
+
+Examples:
+  review-agent feedback --repo myorg/myrepo
+  review-agent feedback --since 2025-01-01 --json
+  review-agent feedback --no-collect
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
+		os.Exit(1)
+	}
+
+	filter, err := buildHistoryFilter(repository, 0, since, until, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if feedbackFile == "" {
+		feedbackFile = os.Getenv("REVIEW_FEEDBACK_FILE")
+	}
+	store, err := cli.OpenFeedbackStore(feedbackFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open feedback store: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !noCollect {
+		if err := collectFeedback(githubToken, historyFile, store, filter); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to collect feedback: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	signals, err := store.Query(feedback.Filter{Repository: filter.Repository, Since: filter.Since, Until: filter.Until})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to query feedback: %v\n", err)
+		os.Exit(1)
+	}
+
+	report := feedback.BuildReport(signals)
+	if asJSON {
+		fmt.Println(mustMarshalJSON(report))
+		return
+	}
+
+	printFeedbackReport(len(signals), report)
+}
+
+// collectFeedback fetches feedback from GitHub for the comments in the review history
+func collectFeedback(githubToken, historyFile string, store *feedback.FileStore, filter history.Filter) error {
+	if githubToken == "" {
+		githubToken = os.Getenv("GH_TOKEN")
+	}
+	if githubToken == "" {
+		return fmt.Errorf("GitHub token is required (set --github-token flag or GH_TOKEN env var, or use --no-collect)")
+	}
+
+	if historyFile == "" {
+		historyFile = os.Getenv("REVIEW_HISTORY_FILE")
+	}
+	historyStore, err := cli.OpenHistoryStore(historyFile)
+	if err != nil {
+		return fmt.Errorf("failed to open review history: %w", err)
+	}
+	if historyStore == nil {
+		return fmt.Errorf("review history is disabled")
+	}
+
+	collector := feedback.NewCollector(github.NewClient(githubToken), historyStore, store)
+	count, err := collector.Collect(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Collected feedback on %d comments\n", count)
+	return nil
+}
+
+// printFeedbackReport prints precision rows as a table
+func printFeedbackReport(total int, rows []feedback.PrecisionRow) {
+	if total == 0 {
+		fmt.Println("No feedback found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DIMENSION\tVALUE\tPRECISION\tUSEFUL\tNOT USEFUL\tNO SIGNAL")
+	for _, row := range rows {
+		precision := "-"
+		if row.Rated() > 0 {
+			precision = fmt.Sprintf("%.0f%%", row.Precision*100)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\n", row.Dimension, row.Value, precision, row.Useful, row.NotUseful, row.NoSignal)
+	}
+	_ = w.Flush()
+}
+
 func runAction(args []string) {
 	// This is a special mode for running inside GitHub Actions
 	// It uses environment variables set by the action wrapper
@@ -517,3 +517,95 @@ func restoreEnvVar(key, value string) {
 func containsString(s, substr string) bool {
 	return len(s) >= len(substr) && s[:len(substr)] == substr
 }
+
+func TestBuildHistoryFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		repository  string
+		prNumber    int
+		since       string
+		until       string
+		expectError bool
+	}{
+		{name: "no filters"},
+		{name: "repository and PR", repository: "owner/repo", prNumber: 12},
+		{name: "date range", since: "2025-01-01", until: "2025-02-01"},
+		{name: "invalid repository", repository: "repo", expectError: true},
+		{name: "negative PR", prNumber: -1, expectError: true},
+		{name: "invalid since", since: "01/01/2025", expectError: true},
+		{name: "invalid until", until: "yesterday", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := buildHistoryFilter(tt.repository, tt.prNumber, tt.since, tt.until, 20)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if filter.Repository != tt.repository || filter.PullRequest != tt.prNumber || filter.Limit != 20 {
+				t.Errorf("unexpected filter: %+v", filter)
+			}
+			if tt.since != "" && filter.Since.Format("2006-01-02") != tt.since {
+				t.Errorf("expected since %s, got %v", tt.since, filter.Since)
+			}
+		})
+	}
+}
+
+func TestParseCommentThreshold(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    float64
+		expectError bool
+	}{
+		{input: "", expected: 0},
+		{input: "0.7", expected: 0.7},
+		{input: " 1 ", expected: 1},
+		{input: "1.5", expectError: true},
+		{input: "-0.1", expectError: true},
+		{input: "high", expectError: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCommentThreshold(tt.input)
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("parseCommentThreshold(%q): expected error", tt.input)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("parseCommentThreshold(%q): expected %v, got %v (err %v)", tt.input, tt.expected, got, err)
+		}
+	}
+}
+
+func TestSplitPathList(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{input: "", expected: nil},
+		{input: "vendor/**", expected: []string{"vendor/**"}},
+		{input: "vendor/**, *.lock ,,*.sum", expected: []string{"vendor/**", "*.lock", "*.sum"}},
+	}
+
+	for _, tt := range tests {
+		got := splitPathList(tt.input)
+		if len(got) != len(tt.expected) {
+			t.Errorf("splitPathList(%q): expected %v, got %v", tt.input, tt.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("splitPathList(%q): expected %v, got %v", tt.input, tt.expected, got)
+			}
+		}
+	}
+}
@@ -1,3 +1,8 @@
 module github.com/GDSources/claude-code-review-agent
 
 go 1.21
+
+require (
+	github.com/bmatcuk/doublestar/v4 v4.10.2
+	gopkg.in/yaml.v3 v3.0.1
+)
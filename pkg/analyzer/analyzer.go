@@ -3,8 +3,6 @@ package analyzer
 import (
 	"fmt"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
 )
 
@@ -17,12 +15,20 @@ type DiffAnalyzer interface {
 // FileDiff represents changes to a single file
 type FileDiff struct {
 	Filename    string     `json:"filename"`
-	Status      string     `json:"status"`       // "added", "modified", "deleted", "renamed"
-	OldFilename string     `json:"old_filename"` // For renamed files
+	Status      string     `json:"status"`       // "added", "modified", "deleted", "renamed", "copied"
+	OldFilename string     `json:"old_filename"` // Source of a renamed or copied file
 	Hunks       []DiffHunk `json:"hunks"`
 	Additions   int        `json:"additions"`
 	Deletions   int        `json:"deletions"`
 	Language    string     `json:"language"` // Detected programming language
+
+	Binary        bool   `json:"binary,omitempty"`        // Contents are not shown as lines
+	OldMode       string `json:"old_mode,omitempty"`      // File mode before the change, e.g. "100644"
+	NewMode       string `json:"new_mode,omitempty"`      // File mode after the change
+	Similarity    int    `json:"similarity,omitempty"`    // Percentage of a renamed or copied file left unchanged
+	Dissimilarity int    `json:"dissimilarity,omitempty"` // Percentage of a rewritten file that changed
+
+	Classification FileClassification `json:"classification,omitempty"` // Set for generated or vendored files
 }
 
 // DiffHunk represents a contiguous section of changes in a file
@@ -41,6 +47,7 @@ type DiffLine struct {
 	Content   string `json:"content"`
 	OldLineNo int    `json:"old_line_no"`
 	NewLineNo int    `json:"new_line_no"`
+	NoNewline bool   `json:"no_newline,omitempty"` // Last line of its side of the file, without a newline
 }
 
 // ParsedDiff represents the complete parsed diff
@@ -61,6 +68,9 @@ type ContextualDiff struct {
 type FileWithContext struct {
 	FileDiff
 	ContextBlocks []ContextBlock `json:"context_blocks"`
+
+	RiskScore   float64  `json:"risk_score,omitempty"`   // Set by triage; higher deserves a closer review
+	RiskReasons []string `json:"risk_reasons,omitempty"` // Why the file scored high
 }
 
 // ContextBlock represents a block of code with surrounding context
@@ -70,6 +80,31 @@ type ContextBlock struct {
 	Lines       []DiffLine `json:"lines"`
 	ChangeType  string     `json:"change_type"` // "addition", "deletion", "modification"
 	Description string     `json:"description"` // Human-readable description
+
+	// Declarations named on the block's changed lines, for languages with a lexer grammar
+	Declarations []DeclarationChange `json:"declarations,omitempty"`
+	// Function or method the block is in, when the head revision was checked out
+	Enclosing *EnclosingContext `json:"enclosing,omitempty"`
+}
+
+// ModeChanged reports whether the change set a new file mode, such as the executable bit
+func (f FileDiff) ModeChanged() bool {
+	return f.OldMode != "" && f.NewMode != "" && f.OldMode != f.NewMode
+}
+
+// HunkSpanning returns the hunk whose new-file lines cover start..end, or nil when the
+// range is not inside a single hunk (GitHub only anchors multi-line comments within one hunk)
+func (f FileDiff) HunkSpanning(start, end int) *DiffHunk {
+	if start <= 0 || end < start {
+		return nil
+	}
+	for i := range f.Hunks {
+		hunk := &f.Hunks[i]
+		if hunk.NewCount > 0 && start >= hunk.NewStart && end <= hunk.NewStart+hunk.NewCount-1 {
+			return hunk
+		}
+	}
+	return nil
 }
 
 // DefaultDiffAnalyzer implements the DiffAnalyzer interface
@@ -86,108 +121,9 @@ func (d *DefaultDiffAnalyzer) ParseDiff(rawDiff string) (*ParsedDiff, error) {
 		return &ParsedDiff{Files: []FileDiff{}, TotalFiles: 0}, nil
 	}
 
-	lines := strings.Split(rawDiff, "\n")
-	var files []FileDiff
-	var currentFile *FileDiff
-	var currentHunk *DiffHunk
-	var oldLineNo, newLineNo int
-
-	for i, line := range lines {
-		switch {
-		case strings.HasPrefix(line, "diff --git"):
-			// Start of a new file
-			if currentFile != nil {
-				if currentHunk != nil {
-					currentFile.Hunks = append(currentFile.Hunks, *currentHunk)
-				}
-				files = append(files, *currentFile)
-			}
-
-			filename, err := extractFilename(line)
-			if err != nil {
-				return nil, fmt.Errorf("failed to extract filename from line %d: %w", i+1, err)
-			}
-
-			currentFile = &FileDiff{
-				Filename: filename,
-				Status:   "modified",
-				Hunks:    []DiffHunk{},
-				Language: detectLanguage(filename),
-			}
-			currentHunk = nil
-
-		case strings.HasPrefix(line, "new file mode"):
-			if currentFile != nil {
-				currentFile.Status = "added"
-			}
-
-		case strings.HasPrefix(line, "deleted file mode"):
-			if currentFile != nil {
-				currentFile.Status = "deleted"
-			}
-
-		case strings.HasPrefix(line, "rename from"):
-			if currentFile != nil {
-				currentFile.Status = "renamed"
-				currentFile.OldFilename = strings.TrimPrefix(line, "rename from ")
-			}
-
-		case strings.HasPrefix(line, "@@"):
-			// Start of a new hunk
-			if currentFile != nil && currentHunk != nil {
-				currentFile.Hunks = append(currentFile.Hunks, *currentHunk)
-			}
-
-			hunk, err := parseHunkHeader(line)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse hunk header at line %d: %w", i+1, err)
-			}
-
-			currentHunk = hunk
-			oldLineNo = hunk.OldStart
-			newLineNo = hunk.NewStart
-
-		case len(line) > 0 && (line[0] == ' ' || line[0] == '+' || line[0] == '-'):
-			// Diff content line
-			if currentHunk != nil {
-				diffLine := DiffLine{
-					Content: line[1:], // Remove the prefix character
-				}
-
-				switch line[0] {
-				case ' ':
-					diffLine.Type = "context"
-					diffLine.OldLineNo = oldLineNo
-					diffLine.NewLineNo = newLineNo
-					oldLineNo++
-					newLineNo++
-				case '+':
-					diffLine.Type = "added"
-					diffLine.NewLineNo = newLineNo
-					newLineNo++
-					if currentFile != nil {
-						currentFile.Additions++
-					}
-				case '-':
-					diffLine.Type = "removed"
-					diffLine.OldLineNo = oldLineNo
-					oldLineNo++
-					if currentFile != nil {
-						currentFile.Deletions++
-					}
-				}
-
-				currentHunk.Lines = append(currentHunk.Lines, diffLine)
-			}
-		}
-	}
-
-	// Add the last file and hunk
-	if currentFile != nil {
-		if currentHunk != nil {
-			currentFile.Hunks = append(currentFile.Hunks, *currentHunk)
-		}
-		files = append(files, *currentFile)
+	files, err := parseUnifiedDiff(rawDiff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse diff: %w", err)
 	}
 
 	// Calculate totals
@@ -222,6 +158,9 @@ func (d *DefaultDiffAnalyzer) ExtractContext(parsedDiff *ParsedDiff, contextLine
 		// Extract context blocks from each hunk
 		for _, hunk := range file.Hunks {
 			blocks := extractContextBlocks(hunk, contextLines)
+			for j := range blocks {
+				blocks[j].Declarations = ChangedDeclarations(file.Language, blocks[j].Lines)
+			}
 			fileWithContext.ContextBlocks = append(fileWithContext.ContextBlocks, blocks...)
 		}
 
@@ -234,76 +173,6 @@ func (d *DefaultDiffAnalyzer) ExtractContext(parsedDiff *ParsedDiff, contextLine
 	}, nil
 }
 
-// Helper functions
-
-// extractFilename extracts the filename from a "diff --git" line
-func extractFilename(line string) (string, error) {
-	// Format: "diff --git a/path/to/file b/path/to/file"
-	parts := strings.Fields(line)
-	if len(parts) < 4 {
-		return "", fmt.Errorf("invalid diff header format")
-	}
-
-	// Extract filename from "a/path/to/file"
-	aPath := parts[2]
-	if strings.HasPrefix(aPath, "a/") {
-		return aPath[2:], nil
-	}
-
-	return aPath, nil
-}
-
-// parseHunkHeader parses a hunk header line like "@@ -1,4 +1,6 @@"
-func parseHunkHeader(line string) (*DiffHunk, error) {
-	// Regular expression to parse hunk header
-	re := regexp.MustCompile(`@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@(.*)`)
-	matches := re.FindStringSubmatch(line)
-
-	if len(matches) < 4 {
-		return nil, fmt.Errorf("invalid hunk header format: %s", line)
-	}
-
-	oldStart, err := strconv.Atoi(matches[1])
-	if err != nil {
-		return nil, fmt.Errorf("invalid old start line number: %s", matches[1])
-	}
-
-	oldCount := 1
-	if matches[2] != "" {
-		oldCount, err = strconv.Atoi(matches[2])
-		if err != nil {
-			return nil, fmt.Errorf("invalid old line count: %s", matches[2])
-		}
-	}
-
-	newStart, err := strconv.Atoi(matches[3])
-	if err != nil {
-		return nil, fmt.Errorf("invalid new start line number: %s", matches[3])
-	}
-
-	newCount := 1
-	if matches[4] != "" {
-		newCount, err = strconv.Atoi(matches[4])
-		if err != nil {
-			return nil, fmt.Errorf("invalid new line count: %s", matches[4])
-		}
-	}
-
-	header := line
-	if len(matches) > 5 {
-		header += matches[5] // Include any additional context after @@
-	}
-
-	return &DiffHunk{
-		OldStart: oldStart,
-		OldCount: oldCount,
-		NewStart: newStart,
-		NewCount: newCount,
-		Lines:    []DiffLine{},
-		Header:   header,
-	}, nil
-}
-
 // detectLanguage detects programming language from filename
 func detectLanguage(filename string) string {
 	ext := strings.ToLower(filepath.Ext(filename))
@@ -407,6 +276,33 @@ func extractContextBlocks(hunk DiffHunk, contextLines int) []ContextBlock {
 	return blocks
 }
 
+// TrimContext returns a copy of the block with at most contextLines unchanged lines before its
+// first change and after its last. Declarations and enclosing context are kept.
+func (b ContextBlock) TrimContext(contextLines int) ContextBlock {
+	first, last := -1, -1
+	for i, line := range b.Lines {
+		if line.Type == "added" || line.Type == "removed" {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return b
+	}
+
+	end := last + 1 + contextLines
+	if end > len(b.Lines) {
+		end = len(b.Lines)
+	}
+	trimmed := b
+	trimmed.Lines = b.Lines[maxInt(0, first-contextLines):end]
+	trimmed.StartLine, trimmed.EndLine = 0, 0
+	finalizeContextBlock(&trimmed)
+	return trimmed
+}
+
 // Helper functions for context extraction
 
 func maxInt(a, b int) int {
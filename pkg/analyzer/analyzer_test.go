@@ -1,6 +1,9 @@
 package analyzer
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -198,6 +201,47 @@ index 1234567..abcdefg 100644
 	}
 }
 
+func TestExtractContext_Declarations(t *testing.T) {
+	rawDiff := `diff --git a/client.py b/client.py
+index 1234567..abcdefg 100644
+--- a/client.py
++++ b/client.py
+@@ -10,6 +10,7 @@ class Client:
+     def get(self, path):
+         return path
+ 
+-    def close(self):
+-        pass
++    @retry
++    def shutdown(self, timeout):
++        pass
+`
+
+	diffAnalyzer := NewDefaultDiffAnalyzer()
+	parsed, err := diffAnalyzer.ParseDiff(rawDiff)
+	if err != nil {
+		t.Fatalf("failed to parse diff: %v", err)
+	}
+	contextual, err := diffAnalyzer.ExtractContext(parsed, 3)
+	if err != nil {
+		t.Fatalf("failed to extract context: %v", err)
+	}
+
+	blocks := contextual.FilesWithContext[0].ContextBlocks
+	if len(blocks) != 1 {
+		t.Fatalf("expected 1 context block, got %d", len(blocks))
+	}
+	var got []string
+	for _, change := range blocks[0].Declarations {
+		got = append(got, fmt.Sprintf("%s %s %s:%d", change.Change, change.Kind, change.QualifiedName(), change.Line))
+	}
+	// The enclosing class is outside the block, so its methods read as functions
+	expected := []string{"added function shutdown:14", "removed function close:13"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
 func TestDetectLanguage(t *testing.T) {
 	tests := []struct {
 		filename         string
@@ -300,35 +344,77 @@ func TestParseHunkHeader(t *testing.T) {
 	}
 }
 
-func TestExtractFilename(t *testing.T) {
+func TestParseGitDiffPaths(t *testing.T) {
 	tests := []struct {
-		name             string
-		line             string
-		expectedFilename string
-		expectError      bool
+		name        string
+		line        string
+		expectedOld string
+		expectedNew string
+		expectError bool
 	}{
 		{
-			name:             "standard git diff header",
-			line:             "diff --git a/src/main.go b/src/main.go",
-			expectedFilename: "src/main.go",
-			expectError:      false,
+			name:        "standard git diff header",
+			line:        "diff --git a/src/main.go b/src/main.go",
+			expectedOld: "src/main.go",
+			expectedNew: "src/main.go",
 		},
 		{
-			name:             "renamed file",
-			line:             "diff --git a/old/path.go b/new/path.go",
-			expectedFilename: "old/path.go",
-			expectError:      false,
+			name:        "renamed file",
+			line:        "diff --git a/old/path.go b/new/path.go",
+			expectedOld: "old/path.go",
+			expectedNew: "new/path.go",
+		},
+		{
+			name:        "spaces in an unchanged path",
+			line:        "diff --git a/docs/release notes.md b/docs/release notes.md",
+			expectedOld: "docs/release notes.md",
+			expectedNew: "docs/release notes.md",
+		},
+		{
+			name:        "spaces and a directory named b",
+			line:        "diff --git a/x b/y.txt b/x b/y.txt",
+			expectedOld: "x b/y.txt",
+			expectedNew: "x b/y.txt",
+		},
+		{
+			name:        "renamed to a path with spaces",
+			line:        "diff --git a/run.sh b/bin/run me.sh",
+			expectedOld: "run.sh",
+			expectedNew: "bin/run me.sh",
+		},
+		{
+			name:        "quoted paths",
+			line:        `diff --git "a/say \"hi\".txt" "b/t\303\244st\tfile.txt"`,
+			expectedOld: `say "hi".txt`,
+			expectedNew: "t\u00e4st\tfile.txt",
+		},
+		{
+			name:        "only the new path quoted",
+			line:        `diff --git a/plain.txt "b/caf\303\251.txt"`,
+			expectedOld: "plain.txt",
+			expectedNew: "caf\u00e9.txt",
+		},
+		{
+			name:        "paths without prefixes",
+			line:        "diff --git old.go new.go",
+			expectedOld: "old.go",
+			expectedNew: "new.go",
 		},
 		{
 			name:        "invalid header",
 			line:        "diff --git invalid",
 			expectError: true,
 		},
+		{
+			name:        "unterminated quote",
+			line:        `diff --git "a/broken b/broken`,
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			filename, err := extractFilename(tt.line)
+			oldPath, newPath, err := parseGitDiffPaths(tt.line)
 
 			if tt.expectError && err == nil {
 				t.Error("expected error but got none")
@@ -336,8 +422,94 @@ func TestExtractFilename(t *testing.T) {
 			if !tt.expectError && err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
-			if !tt.expectError && filename != tt.expectedFilename {
-				t.Errorf("expected filename '%s', got '%s'", tt.expectedFilename, filename)
+			if !tt.expectError && (oldPath != tt.expectedOld || newPath != tt.expectedNew) {
+				t.Errorf("expected paths %q -> %q, got %q -> %q", tt.expectedOld, tt.expectedNew, oldPath, newPath)
+			}
+		})
+	}
+}
+
+func TestFileDiff_HunkSpanning(t *testing.T) {
+	file := FileDiff{
+		Filename: "main.go",
+		Hunks: []DiffHunk{
+			{NewStart: 10, NewCount: 5},
+			{NewStart: 40, NewCount: 3},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		start, end int
+		expected   int // NewStart of the spanning hunk, 0 for none
+	}{
+		{name: "single line", start: 12, end: 12, expected: 10},
+		{name: "whole hunk", start: 10, end: 14, expected: 10},
+		{name: "second hunk", start: 41, end: 42, expected: 40},
+		{name: "runs past hunk end", start: 13, end: 15, expected: 0},
+		{name: "spans two hunks", start: 12, end: 41, expected: 0},
+		{name: "outside any hunk", start: 20, end: 20, expected: 0},
+		{name: "reversed range", start: 12, end: 11, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunk := file.HunkSpanning(tt.start, tt.end)
+			got := 0
+			if hunk != nil {
+				got = hunk.NewStart
+			}
+			if got != tt.expected {
+				t.Errorf("expected hunk starting at %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestContextBlock_TrimContext(t *testing.T) {
+	block := ContextBlock{
+		Lines: []DiffLine{
+			{Type: "context", Content: "a", OldLineNo: 8, NewLineNo: 8},
+			{Type: "context", Content: "b", OldLineNo: 9, NewLineNo: 9},
+			{Type: "removed", Content: "c", OldLineNo: 10},
+			{Type: "added", Content: "C", NewLineNo: 10},
+			{Type: "context", Content: "d", OldLineNo: 11, NewLineNo: 11},
+			{Type: "added", Content: "e", NewLineNo: 12},
+			{Type: "context", Content: "f", OldLineNo: 12, NewLineNo: 13},
+			{Type: "context", Content: "g", OldLineNo: 13, NewLineNo: 14},
+		},
+		ChangeType:   "modification",
+		Declarations: []DeclarationChange{{Change: "modified"}},
+		Enclosing:    &EnclosingContext{},
+	}
+
+	tests := []struct {
+		name          string
+		contextLines  int
+		expectedLines string
+		expectedStart int
+		expectedEnd   int
+	}{
+		{name: "one line", contextLines: 1, expectedLines: "bcCdef", expectedStart: 9, expectedEnd: 13},
+		{name: "no context", contextLines: 0, expectedLines: "cCde", expectedStart: 10, expectedEnd: 12},
+		{name: "more than the block has", contextLines: 5, expectedLines: "abcCdefg", expectedStart: 8, expectedEnd: 14},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trimmed := block.TrimContext(tt.contextLines)
+			var lines strings.Builder
+			for _, line := range trimmed.Lines {
+				lines.WriteString(line.Content)
+			}
+			if lines.String() != tt.expectedLines {
+				t.Errorf("expected lines %q, got %q", tt.expectedLines, lines.String())
+			}
+			if trimmed.StartLine != tt.expectedStart || trimmed.EndLine != tt.expectedEnd {
+				t.Errorf("expected lines %d-%d, got %d-%d", tt.expectedStart, tt.expectedEnd, trimmed.StartLine, trimmed.EndLine)
+			}
+			if len(trimmed.Declarations) != 1 || trimmed.Enclosing == nil {
+				t.Errorf("expected declarations and enclosing context to be kept, got %+v", trimmed)
 			}
 		})
 	}
@@ -422,3 +422,55 @@ func TestDefaultCodebaseFlattener_ProjectDetection(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultCodebaseFlattener_Symlinks(t *testing.T) {
+	testDir := t.TempDir()
+	outsideDir := t.TempDir()
+
+	secret := filepath.Join(outsideDir, "secret.txt")
+	if err := os.WriteFile(secret, []byte("host secret"), 0644); err != nil {
+		t.Fatalf("Failed to write file outside the workspace: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to write main.go: %v", err)
+	}
+
+	links := map[string]string{
+		"inside.go":   "main.go",
+		"secret.txt":  secret,
+		"escape.txt":  "../" + filepath.Base(outsideDir) + "/secret.txt",
+		"dangling.go": "missing.go",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(testDir, name)); err != nil {
+			t.Skipf("symlinks are not supported: %v", err)
+		}
+	}
+	// A symlinked directory pointing outside is not followed either
+	if err := os.Symlink(outsideDir, filepath.Join(testDir, "outside")); err != nil {
+		t.Fatalf("Failed to create directory symlink: %v", err)
+	}
+
+	flattener := NewDefaultCodebaseFlattener()
+	codebase, err := flattener.FlattenWorkspace(testDir)
+	if err != nil {
+		t.Fatalf("FlattenWorkspace failed: %v", err)
+	}
+
+	found := make(map[string]string)
+	for _, file := range codebase.Files {
+		found[file.RelativePath] = file.Content
+	}
+	if len(found) != 2 || found["main.go"] != "package main" || found["inside.go"] != "package main" {
+		t.Errorf("Expected main.go and the symlink to it, got %v", found)
+	}
+
+	diff := &ParsedDiff{Files: []FileDiff{{Filename: "secret.txt"}, {Filename: "outside/secret.txt"}, {Filename: "inside.go"}}}
+	codebase, err = flattener.FlattenDiff(testDir, diff)
+	if err != nil {
+		t.Fatalf("FlattenDiff failed: %v", err)
+	}
+	if codebase.TotalFiles != 1 || codebase.Files[0].RelativePath != "inside.go" {
+		t.Errorf("Expected only inside.go from the diff, got %+v", codebase.Files)
+	}
+}
@@ -0,0 +1,345 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// Declaration kinds
+const (
+	KindFunction        = "function"
+	KindMethod          = "method"
+	KindClass           = "class"
+	KindInterface       = "interface" // Interfaces and traits of languages declaring them with a keyword
+	KindModule          = "module"
+	KindType            = "type"
+	KindInterfaceMethod = "interface_method"
+	KindField           = "field"
+	KindConst           = "const"
+	KindVar             = "var"
+)
+
+// Declaration is a named top-level or member declaration of a source file
+type Declaration struct {
+	Name       string   `json:"name"`
+	Kind       string   `json:"kind"`
+	Parent     string   `json:"parent,omitempty"` // Receiver of a method, or the type declaring a field or interface method
+	Line       int      `json:"line"`             // Line of the name
+	StartLine  int      `json:"start_line"`       // Includes decorators and annotations
+	EndLine    int      `json:"end_line"`
+	Exported   bool     `json:"exported,omitempty"`   // Visible outside its file, package or class
+	Decorators []string `json:"decorators,omitempty"` // Decorators, annotations or attributes, e.g. "property"
+}
+
+// QualifiedName returns the name of the declaration with its parent type, e.g. "Server.Start"
+func (d Declaration) QualifiedName() string {
+	if d.Parent == "" {
+		return d.Name
+	}
+	return d.Parent + "." + d.Name
+}
+
+// IsMember reports whether the declaration is referenced through a value or type, as in x.Name
+func (d Declaration) IsMember() bool {
+	return d.Parent != ""
+}
+
+// HasDeclarationExtractor reports whether declarations can be extracted from files of a language
+func HasDeclarationExtractor(language string) bool {
+	_, lexed := grammars[language]
+	return language == "go" || lexed
+}
+
+// ExtractDeclarations lists the declarations of a source file. Go files are parsed; Python, Java,
+// Rust, Ruby, TypeScript and JavaScript files are lexed, which tolerates syntax errors and partial
+// source. It returns false for languages without an extractor and for Go files that cannot be parsed.
+func ExtractDeclarations(language, filename string, src []byte) ([]Declaration, bool) {
+	if language == "go" {
+		declarations, err := ExtractGoDeclarations(filename, src)
+		return declarations, err == nil
+	}
+	if g, ok := grammars[language]; ok {
+		return extractLexedDeclarations(g, src), true
+	}
+	return nil, false
+}
+
+// DeletedDeclarations returns the declarations a diff removed from a file: those of the base revision
+// whose name is on a removed line and that the head revision no longer declares. head is nil for
+// deleted files. It returns false when base cannot be analyzed, so callers fall back to heuristics.
+func DeletedDeclarations(file FileDiff, base, head []byte) ([]Declaration, bool) {
+	baseFile := file.Filename
+	if file.OldFilename != "" {
+		baseFile = file.OldFilename
+	}
+	before, ok := ExtractDeclarations(file.Language, baseFile, base)
+	if !ok {
+		return nil, false
+	}
+
+	remaining := make(map[string]bool)
+	if head != nil {
+		// A head revision that no longer parses keeps what could be recovered, so declarations
+		// around a syntax error are not reported as deleted
+		after, _ := ExtractDeclarations(file.Language, file.Filename, head)
+		for _, declaration := range after {
+			remaining[declaration.QualifiedName()] = true
+		}
+	}
+
+	removed := make(map[int]bool)
+	for _, hunk := range file.Hunks {
+		for _, line := range hunk.Lines {
+			if line.Type == "removed" {
+				removed[line.OldLineNo] = true
+			}
+		}
+	}
+
+	deleted := []Declaration{}
+	for _, declaration := range before {
+		if removed[declaration.Line] && !remaining[declaration.QualifiedName()] {
+			deleted = append(deleted, declaration)
+		}
+	}
+	return deleted, true
+}
+
+// DeclarationChange is a declaration that a diff fragment added, removed or modified
+type DeclarationChange struct {
+	Declaration
+	Change string `json:"change"` // "added", "removed", "modified"
+
+	// Uses of a removed or modified declaration in other files, when the workspace was indexed
+	References       int `json:"references,omitempty"`
+	ReferencingFiles int `json:"referencing_files,omitempty"`
+}
+
+// ChangedDeclarations lists the declarations named on the added or removed lines of a diff
+// fragment, such as a context block. Only the fragment is lexed, so it covers the languages with a
+// lexer grammar; Go fragments rarely parse on their own. Line numbers are those of the file.
+func ChangedDeclarations(language string, lines []DiffLine) []DeclarationChange {
+	g, ok := grammars[language]
+	if !ok {
+		return nil
+	}
+	after := fragmentDeclarations(g, lines, "added")
+	before := fragmentDeclarations(g, lines, "removed")
+
+	removed := make(map[string]bool)
+	for _, declaration := range before {
+		removed[declaration.QualifiedName()] = true
+	}
+
+	var changes []DeclarationChange
+	added := make(map[string]bool)
+	for _, declaration := range after {
+		change := "added"
+		if removed[declaration.QualifiedName()] {
+			change = "modified"
+		}
+		added[declaration.QualifiedName()] = true
+		changes = append(changes, DeclarationChange{Declaration: declaration, Change: change})
+	}
+	for _, declaration := range before {
+		if !added[declaration.QualifiedName()] {
+			changes = append(changes, DeclarationChange{Declaration: declaration, Change: "removed"})
+		}
+	}
+	return changes
+}
+
+// AnnotateImpact counts the uses in other files of the declarations the context blocks removed
+// or modified, so the review sees how far a change reaches
+func AnnotateImpact(diff *ContextualDiff, index *SymbolIndex) {
+	if diff == nil || index == nil {
+		return
+	}
+	for i := range diff.FilesWithContext {
+		file := &diff.FilesWithContext[i]
+		for j := range file.ContextBlocks {
+			for k := range file.ContextBlocks[j].Declarations {
+				change := &file.ContextBlocks[j].Declarations[k]
+				if change.Change == "added" {
+					continue
+				}
+				files := make(map[string]bool)
+				change.References = 0
+				for _, reference := range index.ReferencesTo(change.Declaration) {
+					if reference.File != file.Filename {
+						change.References++
+						files[reference.File] = true
+					}
+				}
+				change.ReferencingFiles = len(files)
+			}
+		}
+	}
+}
+
+// fragmentDeclarations lexes one side of a diff fragment, its context lines and the lines of type
+// changed, and keeps the declarations named on a changed line
+func fragmentDeclarations(g grammar, lines []DiffLine, changed string) []Declaration {
+	var src strings.Builder
+	var numbers []int // Line of the file for each line of the fragment
+	changedLines := make(map[int]bool)
+	for _, line := range lines {
+		if line.Type != "context" && line.Type != changed {
+			continue
+		}
+		number := line.NewLineNo
+		if changed == "removed" {
+			number = line.OldLineNo
+		}
+		src.WriteString(line.Content)
+		src.WriteString("\n")
+		numbers = append(numbers, number)
+		if line.Type == changed {
+			changedLines[len(numbers)] = true
+		}
+	}
+
+	fileLine := func(line int) int {
+		return numbers[max(1, min(line, len(numbers)))-1]
+	}
+	var declarations []Declaration
+	for _, declaration := range extractLexedDeclarations(g, []byte(src.String())) {
+		if !changedLines[declaration.Line] {
+			continue
+		}
+		declaration.Line = fileLine(declaration.Line)
+		declaration.StartLine = fileLine(declaration.StartLine)
+		declaration.EndLine = fileLine(declaration.EndLine)
+		declarations = append(declarations, declaration)
+	}
+	return declarations
+}
+
+// ExtractGoDeclarations parses Go source and lists its functions, methods, types, constants and
+// variables, and the fields and methods declared by its struct and interface types. Declarations
+// recovered from source with syntax errors are returned together with the error.
+func ExtractGoDeclarations(filename string, src []byte) ([]Declaration, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution)
+	if file == nil {
+		return nil, err
+	}
+
+	extractor := &goExtractor{fset: fset}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			extractor.function(decl)
+		case *ast.GenDecl:
+			extractor.general(decl)
+		}
+	}
+	return extractor.declarations, err
+}
+
+// goExtractor collects the declarations of a parsed Go file
+type goExtractor struct {
+	fset         *token.FileSet
+	declarations []Declaration
+}
+
+func (e *goExtractor) add(name *ast.Ident, kind, parent string, node ast.Node) {
+	if name == nil || name.Name == "_" {
+		return
+	}
+	e.declarations = append(e.declarations, Declaration{
+		Name:      name.Name,
+		Kind:      kind,
+		Parent:    parent,
+		Line:      e.fset.Position(name.Pos()).Line,
+		StartLine: e.fset.Position(node.Pos()).Line,
+		EndLine:   e.fset.Position(node.End()).Line,
+		Exported:  name.IsExported(),
+	})
+}
+
+func (e *goExtractor) function(decl *ast.FuncDecl) {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		e.add(decl.Name, KindFunction, "", decl)
+		return
+	}
+	e.add(decl.Name, KindMethod, receiverTypeName(decl.Recv.List[0].Type), decl)
+}
+
+func (e *goExtractor) general(decl *ast.GenDecl) {
+	for _, spec := range decl.Specs {
+		// Ungrouped declarations span their keyword; grouped ones span their own spec
+		var node ast.Node = spec
+		if !decl.Lparen.IsValid() {
+			node = decl
+		}
+
+		switch spec := spec.(type) {
+		case *ast.TypeSpec:
+			e.add(spec.Name, KindType, "", node)
+			e.members(spec.Name.Name, spec.Type)
+		case *ast.ValueSpec:
+			kind := KindVar
+			if decl.Tok == token.CONST {
+				kind = KindConst
+			}
+			for _, name := range spec.Names {
+				e.add(name, kind, "", node)
+			}
+		}
+	}
+}
+
+// members adds the fields of a struct type and the methods of an interface type
+func (e *goExtractor) members(typeName string, expr ast.Expr) {
+	switch typ := expr.(type) {
+	case *ast.StructType:
+		for _, field := range typ.Fields.List {
+			if len(field.Names) == 0 {
+				// An embedded field is named after its type
+				e.add(embeddedName(field.Type), KindField, typeName, field)
+				continue
+			}
+			for _, name := range field.Names {
+				e.add(name, KindField, typeName, field)
+			}
+		}
+	case *ast.InterfaceType:
+		for _, method := range typ.Methods.List {
+			// Embedded interfaces and type constraints have no names
+			for _, name := range method.Names {
+				e.add(name, KindInterfaceMethod, typeName, method)
+			}
+		}
+	}
+}
+
+// receiverTypeName returns the type name of a method receiver such as *Server or List[T]
+func receiverTypeName(expr ast.Expr) string {
+	if name := embeddedName(expr); name != nil {
+		return name.Name
+	}
+	return ""
+}
+
+// embeddedName returns the identifier naming a type expression, without pointers, packages and
+// type arguments
+func embeddedName(expr ast.Expr) *ast.Ident {
+	switch typ := expr.(type) {
+	case *ast.Ident:
+		return typ
+	case *ast.StarExpr:
+		return embeddedName(typ.X)
+	case *ast.SelectorExpr:
+		return typ.Sel
+	case *ast.IndexExpr:
+		return embeddedName(typ.X)
+	case *ast.IndexListExpr:
+		return embeddedName(typ.X)
+	case *ast.ParenExpr:
+		return embeddedName(typ.X)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package analyzer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const goDeclarationsSource = `package store
+
+import "context"
+
+// Store persists records
+type Store interface {
+	Save(ctx context.Context, r *Record) error
+	Query(filter Filter) ([]Record, error)
+	fmt.Stringer
+}
+
+type (
+	Record struct {
+		ID, Name string
+		Tags     []string
+		*Metadata
+	}
+
+	Filter func(Record) bool
+)
+
+const (
+	DefaultLimit = 100
+	_            = iota
+	maxRetries   = 3
+)
+
+var ErrNotFound, ErrClosed = errors.New("not found"), errors.New("closed")
+
+func New() *FileStore { return &FileStore{} }
+
+func (s *FileStore) Save(ctx context.Context, r *Record) error {
+	return nil
+}
+
+func (l List[T]) Len() int { return len(l) }
+`
+
+func TestExtractGoDeclarations(t *testing.T) {
+	declarations, err := ExtractGoDeclarations("store.go", []byte(goDeclarationsSource))
+	if err != nil {
+		t.Fatalf("ExtractGoDeclarations failed: %v", err)
+	}
+
+	type entity struct {
+		name string
+		kind string
+		line int
+	}
+	var got []entity
+	for _, declaration := range declarations {
+		got = append(got, entity{declaration.QualifiedName(), declaration.Kind, declaration.Line})
+	}
+
+	expected := []entity{
+		{"Store", KindType, 6},
+		{"Store.Save", KindInterfaceMethod, 7},
+		{"Store.Query", KindInterfaceMethod, 8},
+		{"Record", KindType, 13},
+		{"Record.ID", KindField, 14},
+		{"Record.Name", KindField, 14},
+		{"Record.Tags", KindField, 15},
+		{"Record.Metadata", KindField, 16},
+		{"Filter", KindType, 19},
+		{"DefaultLimit", KindConst, 23},
+		{"maxRetries", KindConst, 25},
+		{"ErrNotFound", KindVar, 28},
+		{"ErrClosed", KindVar, 28},
+		{"New", KindFunction, 30},
+		{"FileStore.Save", KindMethod, 32},
+		{"List.Len", KindMethod, 36},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected declarations\n got: %v\nwant: %v", got, expected)
+	}
+
+	// Grouped declarations span their own spec, ungrouped ones the whole declaration
+	for _, declaration := range declarations {
+		switch declaration.QualifiedName() {
+		case "Record":
+			if declaration.StartLine != 13 || declaration.EndLine != 17 {
+				t.Errorf("expected Record to span lines 13-17, got %d-%d", declaration.StartLine, declaration.EndLine)
+			}
+		case "FileStore.Save":
+			if declaration.StartLine != 32 || declaration.EndLine != 34 {
+				t.Errorf("expected FileStore.Save to span lines 32-34, got %d-%d", declaration.StartLine, declaration.EndLine)
+			}
+		}
+	}
+}
+
+func TestExtractGoDeclarations_SyntaxError(t *testing.T) {
+	src := "package main\n\nfunc Kept() {}\n\nfunc broken( {\n"
+	declarations, err := ExtractGoDeclarations("main.go", []byte(src))
+	if err == nil {
+		t.Fatal("expected a syntax error")
+	}
+	if len(declarations) == 0 || declarations[0].Name != "Kept" {
+		t.Errorf("expected the declarations before the error to be recovered, got %v", declarations)
+	}
+	if _, ok := ExtractDeclarations("go", "main.go", []byte(src)); ok {
+		t.Error("expected ExtractDeclarations to report the file as unparsable")
+	}
+}
+
+// removeLines builds a file diff removing the given 1-based lines of base
+func removeLines(filename, status string, lines ...int) FileDiff {
+	hunk := DiffHunk{}
+	for _, line := range lines {
+		hunk.Lines = append(hunk.Lines, DiffLine{Type: "removed", OldLineNo: line})
+	}
+	return FileDiff{Filename: filename, Status: status, Language: "go", Hunks: []DiffHunk{hunk}}
+}
+
+func TestDeletedDeclarations(t *testing.T) {
+	base := []byte(goDeclarationsSource)
+
+	// Lines of goDeclarationsSource replaced by each head revision
+	replace := func(old, new string) []byte {
+		if !strings.Contains(goDeclarationsSource, old) {
+			t.Fatalf("source does not contain %q", old)
+		}
+		return []byte(strings.Replace(goDeclarationsSource, old, new, 1))
+	}
+
+	tests := []struct {
+		name     string
+		file     FileDiff
+		head     []byte
+		expected []string
+	}{
+		{
+			name:     "method with receiver removed",
+			file:     removeLines("store.go", "modified", 31, 32, 33, 34),
+			head:     replace("func (s *FileStore) Save(ctx context.Context, r *Record) error {\n\treturn nil\n}\n", ""),
+			expected: []string{"FileStore.Save"},
+		},
+		{
+			name:     "signature change keeps the declaration",
+			file:     removeLines("store.go", "modified", 32),
+			head:     replace("func (s *FileStore) Save(ctx context.Context, r *Record) error {", "func (s *FileStore) Save(r *Record) error {"),
+			expected: []string{},
+		},
+		{
+			name:     "interface method and struct field removed",
+			file:     removeLines("store.go", "modified", 8, 15),
+			head:     replace("\tQuery(filter Filter) ([]Record, error)\n", ""),
+			expected: []string{"Store.Query"},
+		},
+		{
+			name:     "grouped constant removed",
+			file:     removeLines("store.go", "modified", 25),
+			head:     replace("\tmaxRetries   = 3\n", ""),
+			expected: []string{"maxRetries"},
+		},
+		{
+			name:     "deleted file removes everything named on removed lines",
+			file:     removeLines("store.go", "deleted", 6, 30),
+			head:     nil,
+			expected: []string{"Store", "New"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			declarations, ok := DeletedDeclarations(tt.file, base, tt.head)
+			if !ok {
+				t.Fatal("expected the file to be analyzed")
+			}
+			names := []string{}
+			for _, declaration := range declarations {
+				names = append(names, declaration.QualifiedName())
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, names)
+			}
+		})
+	}
+
+	if _, ok := DeletedDeclarations(FileDiff{Filename: "index.php", Language: "php"}, []byte("<?php function run() {}"), nil); ok {
+		t.Error("expected languages without an extractor to fall back to heuristics")
+	}
+}
@@ -516,3 +516,65 @@ func UtilityFunction(param string) string {
 		t.Logf("  Reference %d: %s in %s (line %v)", i+1, ref.DeletedEntity, ref.ReferencingFile, ref.ReferencingLines)
 	}
 }
+
+func TestDeletionAnalyzer_ParsedEntities(t *testing.T) {
+	request := &DeletionAnalysisRequest{
+		Codebase: &FlattenedCodebase{
+			Files: []FileContent{
+				{
+					RelativePath: "cmd/main.go",
+					Language:     "go",
+					Content:      "package main\n\nfunc main() {\n\tstore.Close()\n\tlimit := DefaultLimit\n\tlog.Println(limit)\n}\n",
+				},
+			},
+		},
+		DeletedContent: []DeletedCode{
+			{
+				// Heuristics would only see "Close" and miss the grouped constant
+				File:      "store/store.go",
+				Content:   "func (s *FileStore) Close() error {\n\treturn nil\n}\n\tDefaultLimit = 100\n\tmaxRetries = 3",
+				StartLine: 10,
+				EndLine:   14,
+				Language:  "go",
+				Entities: []Declaration{
+					{Name: "Close", Kind: KindMethod, Parent: "FileStore", Line: 10},
+					{Name: "DefaultLimit", Kind: KindConst, Line: 13},
+					{Name: "maxRetries", Kind: KindConst, Line: 14},
+				},
+			},
+		},
+	}
+
+	result, err := NewDefaultDeletionAnalyzer().AnalyzeDeletions(request)
+	if err != nil {
+		t.Fatalf("AnalyzeDeletions failed: %v", err)
+	}
+
+	orphaned := make(map[string]string)
+	for _, ref := range result.OrphanedReferences {
+		orphaned[ref.DeletedEntity] = ref.EntityKind
+	}
+	expected := map[string]string{"FileStore.Close": KindMethod, "DefaultLimit": KindConst}
+	if len(orphaned) != len(expected) {
+		t.Errorf("expected orphaned references %v, got %v", expected, orphaned)
+	}
+	for name, kind := range expected {
+		if orphaned[name] != kind {
+			t.Errorf("expected %s to be orphaned as a %s, got %v", name, kind, orphaned)
+		}
+	}
+
+	if len(result.SafeDeletions) != 1 || result.SafeDeletions[0] != "maxRetries" {
+		t.Errorf("expected only maxRetries to be a safe deletion, got %v", result.SafeDeletions)
+	}
+
+	// Parsing found no declarations, so nothing is guessed from the deleted lines
+	request.DeletedContent[0].Entities = []Declaration{}
+	result, err = NewDefaultDeletionAnalyzer().AnalyzeDeletions(request)
+	if err != nil {
+		t.Fatalf("AnalyzeDeletions failed: %v", err)
+	}
+	if len(result.OrphanedReferences) != 0 || len(result.SafeDeletions) != 0 {
+		t.Errorf("expected no entities, got %v and %v", result.OrphanedReferences, result.SafeDeletions)
+	}
+}
@@ -0,0 +1,222 @@
+package analyzer
+
+import (
+	"strings"
+)
+
+// maxCallees bounds the called functions listed for a change block
+const maxCallees = 10
+
+// maxCalleeDefinitions skips called names declared more often than this, such as Get or String,
+// whose definition cannot be told from the call
+const maxCalleeDefinitions = 3
+
+// EnclosingContext is the function or method around a change block, read from the head checkout
+type EnclosingContext struct {
+	Declaration Declaration `json:"declaration"`
+	StartLine   int         `json:"start_line"` // First line shown, in the head revision
+	EndLine     int         `json:"end_line"`
+	Lines       []string    `json:"lines"`
+	Changed     []int       `json:"changed,omitempty"`   // Lines added by the diff
+	Truncated   bool        `json:"truncated,omitempty"` // Only part of the declaration fits the line budget
+	Callees     []Callee    `json:"callees,omitempty"`
+}
+
+// Callee is a function or method called in an enclosing context and declared in the repository
+type Callee struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Name      string `json:"name"` // Qualified name, e.g. "Store.Get"
+	Signature string `json:"signature"`
+}
+
+// IsChanged reports whether the diff added a line of the enclosing context
+func (e *EnclosingContext) IsChanged(line int) bool {
+	for _, changed := range e.Changed {
+		if changed == line {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpandContext sets the enclosing function or method of each context block from the indexed
+// head revision, showing at most maxLines of it, with the signatures of the repository functions
+// it calls. Blocks outside a function, and files that are not indexed, are left unchanged.
+func ExpandContext(diff *ContextualDiff, index *SymbolIndex, maxLines int) {
+	if diff == nil || index == nil || maxLines <= 0 {
+		return
+	}
+	for i := range diff.FilesWithContext {
+		file := &diff.FilesWithContext[i]
+		if file.Status == "deleted" {
+			continue
+		}
+		declarations := index.Declarations(file.Filename)
+		for j := range file.ContextBlocks {
+			block := &file.ContextBlocks[j]
+			block.Enclosing = nil
+
+			first, last, changed := headRange(block.Lines)
+			enclosing := enclosingFunction(declarations, first, last)
+			if enclosing == nil {
+				continue
+			}
+			start, end := enclosing.StartLine, enclosing.EndLine
+			truncated := end-start+1 > maxLines
+			if truncated {
+				start, end = window(start, end, first, last, maxLines)
+			}
+
+			context := &EnclosingContext{
+				Declaration: *enclosing,
+				StartLine:   start,
+				EndLine:     end,
+				Changed:     changed,
+				Truncated:   truncated,
+			}
+			for line := start; line <= end; line++ {
+				context.Lines = append(context.Lines, index.Line(file.Filename, line))
+			}
+			context.Callees = callees(index, file.Filename, file.Language, *enclosing, context.Lines)
+			block.Enclosing = context
+		}
+	}
+}
+
+// headRange returns the first and last head revision lines changed by a block, and the lines it
+// added. Leading and trailing context lines are left out, so a change to a function's first lines
+// still falls inside it; a removed line is placed at the head line that follows it.
+func headRange(lines []DiffLine) (int, int, []int) {
+	first, last := 0, 0
+	var changed []int
+	next := 0 // Head line of the next line that has one
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i].NewLineNo > 0 {
+			next = lines[i].NewLineNo
+		}
+		var line int
+		switch lines[i].Type {
+		case "added":
+			line = lines[i].NewLineNo
+			changed = append([]int{line}, changed...)
+		case "removed":
+			line = next
+			if line == 0 {
+				line = previousHeadLine(lines[:i]) // Removed at the end of the file
+			}
+		}
+		if line <= 0 {
+			continue
+		}
+		if last == 0 || line > last {
+			last = line
+		}
+		if first == 0 || line < first {
+			first = line
+		}
+	}
+	return first, last, changed
+}
+
+// previousHeadLine returns the last head revision line of lines, or 0 when there is none
+func previousHeadLine(lines []DiffLine) int {
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i].NewLineNo > 0 {
+			return lines[i].NewLineNo
+		}
+	}
+	return 0
+}
+
+// enclosingFunction returns the innermost function or method spanning first..last
+func enclosingFunction(declarations []Declaration, first, last int) *Declaration {
+	if first == 0 {
+		return nil
+	}
+	var enclosing *Declaration
+	for i := range declarations {
+		declaration := &declarations[i]
+		if declaration.Kind != KindFunction && declaration.Kind != KindMethod {
+			continue
+		}
+		if declaration.StartLine > first || declaration.EndLine < last {
+			continue
+		}
+		if enclosing == nil || declaration.StartLine >= enclosing.StartLine && declaration.EndLine <= enclosing.EndLine {
+			enclosing = declaration
+		}
+	}
+	return enclosing
+}
+
+// window returns the maxLines of start..end centred on first..last, or the start of first..last
+// when the block itself is longer
+func window(start, end, first, last, maxLines int) (int, int) {
+	if last-first+1 >= maxLines {
+		return first, first + maxLines - 1
+	}
+	from := first - (maxLines-(last-first+1))/2
+	if from < start {
+		from = start
+	}
+	if from+maxLines-1 > end {
+		from = end - maxLines + 1
+	}
+	return from, from + maxLines - 1
+}
+
+// declarationKeywords introduce a declared name rather than a call
+var declarationKeywords = map[string]bool{"func": true, "def": true, "fn": true, "function": true}
+
+// callees lists the repository functions and methods called on lines, in the order they are first
+// called. A call through a selector, as in x.Name(), may be to any function or method; a plain call
+// is to a function or to a method of the enclosing type.
+func callees(index *SymbolIndex, file, language string, enclosing Declaration, lines []string) []Callee {
+	syntax, ok := indexSyntax(language)
+	if !ok {
+		return nil
+	}
+	tokens := lex([]byte(strings.Join(lines, "\n")), syntax)
+
+	var result []Callee
+	seen := map[string]bool{file + ":" + enclosing.QualifiedName(): true}
+	for i := 0; i+1 < len(tokens) && len(result) < maxCallees; i++ {
+		if tokens[i].kind != tokenIdent || tokens[i+1].text != "(" {
+			continue
+		}
+		if i > 0 && declarationKeywords[tokens[i-1].text] {
+			continue
+		}
+		qualified := i > 0 && tokens[i-1].kind == tokenPunct && qualifiers[tokens[i-1].text]
+
+		var matches []SymbolDefinition
+		for _, definition := range index.Definitions(tokens[i].text) {
+			switch definition.Declaration.Kind {
+			case KindFunction:
+				matches = append(matches, definition)
+			case KindMethod:
+				if qualified || definition.Declaration.Parent == enclosing.Parent && enclosing.Parent != "" {
+					matches = append(matches, definition)
+				}
+			}
+		}
+		if len(matches) > maxCalleeDefinitions {
+			continue
+		}
+		for _, match := range matches {
+			key := match.File + ":" + match.Declaration.QualifiedName()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			result = append(result, Callee{
+				File:      match.File,
+				Line:      match.Declaration.Line,
+				Name:      match.Declaration.QualifiedName(),
+				Signature: index.Signature(match),
+			})
+		}
+	}
+	return result
+}
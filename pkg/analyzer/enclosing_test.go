@@ -0,0 +1,181 @@
+package analyzer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var enclosingFiles = []FileContent{
+	{
+		RelativePath: "store/store.go",
+		Language:     "go",
+		Content: `package store
+
+type Store struct{ records map[string]Record }
+
+func (s *Store) Save(r Record) error {
+	if err := validate(r); err != nil {
+		return err
+	}
+	s.records[r.ID] = r
+	s.index(r)
+	return nil
+}
+
+func (s *Store) index(r Record) {}
+
+func validate(r Record) error {
+	return nil
+}
+`,
+	},
+	{
+		RelativePath: "store/query.py",
+		Language:     "python",
+		Content: `def find(store,
+         key):
+    # lookup(key) is slow
+    result = lookup(key)
+    return result
+
+
+def lookup(key):
+    return None
+`,
+	},
+}
+
+func TestExpandContext(t *testing.T) {
+	diff := &ContextualDiff{
+		FilesWithContext: []FileWithContext{
+			{
+				FileDiff: FileDiff{Filename: "store/store.go", Language: "go"},
+				ContextBlocks: []ContextBlock{
+					{Lines: []DiffLine{
+						{Type: "context", Content: "\ts.records[r.ID] = r", OldLineNo: 9, NewLineNo: 9},
+						{Type: "removed", Content: "\treturn nil", OldLineNo: 10},
+						{Type: "added", Content: "\ts.index(r)", NewLineNo: 10},
+					}},
+					{Lines: []DiffLine{{Type: "added", Content: "type Store struct{ records map[string]Record }", NewLineNo: 3}}},
+					// A signature change, with context lines above the declaration
+					{Lines: []DiffLine{
+						{Type: "context", Content: "type Store struct{ records map[string]Record }", OldLineNo: 3, NewLineNo: 3},
+						{Type: "context", Content: "", OldLineNo: 4, NewLineNo: 4},
+						{Type: "removed", Content: "func (s *Store) Save(r *Record) error {", OldLineNo: 5},
+						{Type: "added", Content: "func (s *Store) Save(r Record) error {", NewLineNo: 5},
+						{Type: "context", Content: "\tif err := validate(r); err != nil {", OldLineNo: 6, NewLineNo: 6},
+					}},
+				},
+			},
+			{
+				// Deleted files have no head revision, even when a file of the same name is indexed
+				FileDiff: FileDiff{Filename: "store/store.go", Language: "go", Status: "deleted"},
+				ContextBlocks: []ContextBlock{
+					{Lines: []DiffLine{{Type: "removed", Content: "\ts.index(r)", OldLineNo: 10}}},
+				},
+			},
+			{
+				FileDiff: FileDiff{Filename: "store/query.py", Language: "python"},
+				ContextBlocks: []ContextBlock{
+					{Lines: []DiffLine{{Type: "added", Content: "    result = lookup(key)", NewLineNo: 4}}},
+				},
+			},
+		},
+	}
+	ExpandContext(diff, NewSymbolIndex(enclosingFiles), 20)
+
+	save := diff.FilesWithContext[0].ContextBlocks[0].Enclosing
+	if save == nil {
+		t.Fatal("expected the enclosing method of the first block")
+	}
+	if save.Declaration.QualifiedName() != "Store.Save" || save.StartLine != 5 || save.EndLine != 12 || save.Truncated {
+		t.Errorf("unexpected enclosing context: %s %d-%d truncated=%v",
+			save.Declaration.QualifiedName(), save.StartLine, save.EndLine, save.Truncated)
+	}
+	if len(save.Lines) != 8 || save.Lines[0] != "func (s *Store) Save(r Record) error {" {
+		t.Errorf("unexpected lines: %q", save.Lines)
+	}
+	if !reflect.DeepEqual(save.Changed, []int{10}) || !save.IsChanged(10) || save.IsChanged(9) {
+		t.Errorf("expected only line 10 to be changed, got %v", save.Changed)
+	}
+	expected := []Callee{
+		{File: "store/store.go", Line: 16, Name: "validate", Signature: "func validate(r Record) error"},
+		{File: "store/store.go", Line: 14, Name: "Store.index", Signature: "func (s *Store) index(r Record) {}"},
+	}
+	if !reflect.DeepEqual(save.Callees, expected) {
+		t.Errorf("expected callees %+v, got %+v", expected, save.Callees)
+	}
+
+	if enclosing := diff.FilesWithContext[0].ContextBlocks[1].Enclosing; enclosing != nil {
+		t.Errorf("expected no enclosing function outside functions, got %+v", enclosing)
+	}
+
+	signature := diff.FilesWithContext[0].ContextBlocks[2].Enclosing
+	if signature == nil {
+		t.Fatal("expected the enclosing method of a signature change")
+	}
+	if signature.Declaration.QualifiedName() != "Store.Save" || !reflect.DeepEqual(signature.Changed, []int{5}) {
+		t.Errorf("unexpected signature change context: %s changed=%v", signature.Declaration.QualifiedName(), signature.Changed)
+	}
+
+	if enclosing := diff.FilesWithContext[1].ContextBlocks[0].Enclosing; enclosing != nil {
+		t.Errorf("expected no enclosing context for a deleted file, got %+v", enclosing)
+	}
+
+	find := diff.FilesWithContext[2].ContextBlocks[0].Enclosing
+	if find == nil {
+		t.Fatal("expected the enclosing function of the python block")
+	}
+	// Calls in comments are not callees
+	if find.Declaration.Name != "find" || len(find.Callees) != 1 || find.Callees[0].Signature != "def lookup(key):" {
+		t.Errorf("unexpected python context: %+v", find)
+	}
+	index := NewSymbolIndex(enclosingFiles)
+	if signature := index.Signature(index.Definitions("find")[0]); signature != "def find(store, key):" {
+		t.Errorf("expected the signature to span its parameter lines, got %q", signature)
+	}
+}
+
+func TestExpandContext_Budget(t *testing.T) {
+	var src strings.Builder
+	src.WriteString("def long():\n")
+	for i := 0; i < 30; i++ {
+		src.WriteString("    step()\n")
+	}
+	index := NewSymbolIndex([]FileContent{{RelativePath: "long.py", Language: "python", Content: src.String()}})
+
+	tests := []struct {
+		name       string
+		lines      []int
+		start, end int
+	}{
+		{name: "centred on the block", lines: []int{15, 16}, start: 12, end: 19},
+		{name: "clamped to the start", lines: []int{2}, start: 1, end: 8},
+		{name: "clamped to the end", lines: []int{31}, start: 24, end: 31},
+		{name: "block longer than the budget", lines: []int{3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, start: 3, end: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []DiffLine
+			for _, line := range tt.lines {
+				lines = append(lines, DiffLine{Type: "added", Content: "    step()", NewLineNo: line})
+			}
+			diff := &ContextualDiff{FilesWithContext: []FileWithContext{{
+				FileDiff:      FileDiff{Filename: "long.py", Language: "python"},
+				ContextBlocks: []ContextBlock{{Lines: lines}},
+			}}}
+			ExpandContext(diff, index, 8)
+
+			enclosing := diff.FilesWithContext[0].ContextBlocks[0].Enclosing
+			if enclosing == nil {
+				t.Fatal("expected an enclosing context")
+			}
+			if enclosing.StartLine != tt.start || enclosing.EndLine != tt.end || !enclosing.Truncated || len(enclosing.Lines) != 8 {
+				t.Errorf("expected truncated lines %d-%d, got %d-%d (truncated=%v, %d lines)",
+					tt.start, tt.end, enclosing.StartLine, enclosing.EndLine, enclosing.Truncated, len(enclosing.Lines))
+			}
+		})
+	}
+}
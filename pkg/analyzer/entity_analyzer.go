@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 )
 
 // FlattenedCodebase represents the entire codebase in a format suitable for AI analysis
@@ -17,6 +18,18 @@ type FlattenedCodebase struct {
 	TotalLines  int           `json:"total_lines"`
 	Languages   []string      `json:"languages"`
 	ProjectInfo ProjectInfo   `json:"project_info"`
+
+	indexOnce sync.Once
+	index     *SymbolIndex
+}
+
+// SymbolIndex returns the identifier index of the codebase, building it on first use so every
+// analysis of a workspace shares one index
+func (c *FlattenedCodebase) SymbolIndex() *SymbolIndex {
+	c.indexOnce.Do(func() {
+		c.index = NewSymbolIndex(c.Files)
+	})
+	return c.index
 }
 
 // FileContent represents a single file's content and metadata
@@ -53,6 +66,10 @@ type DeletedCode struct {
 	EndLine    int    `json:"end_line"`
 	Language   string `json:"language"`
 	ChangeType string `json:"change_type"` // "deleted", "renamed", "moved"
+
+	// Declarations this section removed, from parsing the file; nil when the file's language
+	// has no extractor, in which case entities are guessed from the deleted lines
+	Entities []Declaration `json:"entities,omitempty"`
 }
 
 // DeletionAnalysisResult represents the AI's analysis of code deletions
@@ -67,6 +84,7 @@ type DeletionAnalysisResult struct {
 // OrphanedReference represents a reference to deleted code that may cause issues
 type OrphanedReference struct {
 	DeletedEntity    string `json:"deleted_entity"`
+	EntityKind       string `json:"entity_kind,omitempty"` // Declaration kind when known, e.g. "method"
 	ReferencingFile  string `json:"referencing_file"`
 	ReferencingLines []int  `json:"referencing_lines"`
 	ReferenceType    string `json:"reference_type"` // "function_call", "import", "type_usage", etc.
@@ -151,11 +169,28 @@ func (cf *DefaultCodebaseFlattener) FlattenWorkspace(workspacePath string) (*Fla
 	var totalLines int
 	languageSet := make(map[string]bool)
 
-	err := filepath.Walk(workspacePath, func(path string, info os.FileInfo, err error) error {
+	root, err := filepath.EvalSymlinks(workspacePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workspace: %w", err)
+	}
+
+	err = filepath.Walk(workspacePath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
+		// Symlinks are only followed to files inside the workspace, so a pull request cannot
+		// pull files of the host into the prompt
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, ok := resolveInside(root, path)
+			if !ok {
+				return nil
+			}
+			if info, err = os.Stat(target); err != nil || info.IsDir() {
+				return nil
+			}
+		}
+
 		// Skip directories
 		if info.IsDir() {
 			// Check if this directory should be excluded
@@ -241,6 +276,11 @@ func (cf *DefaultCodebaseFlattener) FlattenDiff(workspacePath string, diff *Pars
 	var totalLines int
 	languageSet := make(map[string]bool)
 
+	root, err := filepath.EvalSymlinks(workspacePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workspace: %w", err)
+	}
+
 	for _, fileDiff := range diff.Files {
 		fullPath := filepath.Join(workspacePath, fileDiff.Filename)
 
@@ -249,6 +289,11 @@ func (cf *DefaultCodebaseFlattener) FlattenDiff(workspacePath string, diff *Pars
 			continue
 		}
 
+		// Skip paths and symlinks that lead outside the workspace
+		if _, ok := resolveInside(root, fullPath); !ok {
+			continue
+		}
+
 		// Read file content
 		content, err := os.ReadFile(fullPath)
 		if err != nil {
@@ -290,6 +335,20 @@ func (cf *DefaultCodebaseFlattener) FlattenDiff(workspacePath string, diff *Pars
 	}, nil
 }
 
+// resolveInside resolves the symlinks of path and reports whether it stays inside root, which must
+// itself be resolved
+func resolveInside(root, path string) (string, bool) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", false
+	}
+	return resolved, true
+}
+
 // detectProjectInfo analyzes the codebase to determine project type and structure
 func (cf *DefaultCodebaseFlattener) detectProjectInfo(workspacePath string, files []FileContent) ProjectInfo {
 	info := ProjectInfo{
@@ -512,41 +571,48 @@ func (da *DefaultDeletionAnalyzer) AnalyzeDeletionsWithContext(ctx context.Conte
 	}, nil
 }
 
-// findPotentialReferences is a simple heuristic-based reference finder
-// In a real implementation, this would be handled by the AI
+// findPotentialReferences looks the deleted entities up in the codebase's symbol index, so only
+// whole identifiers outside comments and strings count as references
 func (da *DefaultDeletionAnalyzer) findPotentialReferences(deleted DeletedCode, codebase *FlattenedCodebase) []OrphanedReference {
 	var references []OrphanedReference
 
-	// Extract simple identifiers from deleted content (very basic)
-	identifiers := da.extractIdentifiers(deleted.Content, deleted.Language)
-
-	// Search for these identifiers in the codebase
-	for _, file := range codebase.Files {
-		if file.RelativePath == deleted.File {
-			continue // Skip the file where content was deleted
-		}
-
-		lines := strings.Split(file.Content, "\n")
-		for lineNum, line := range lines {
-			for _, identifier := range identifiers {
-				if strings.Contains(line, identifier) {
-					references = append(references, OrphanedReference{
-						DeletedEntity:    identifier,
-						ReferencingFile:  file.RelativePath,
-						ReferencingLines: []int{lineNum + 1},
-						ReferenceType:    "potential_usage",
-						Context:          strings.TrimSpace(line),
-						Severity:         "warning",
-						Suggestion:       fmt.Sprintf("Verify if '%s' is still needed after deletion", identifier),
-					})
-				}
+	index := codebase.SymbolIndex()
+	for _, entity := range da.deletedEntities(deleted) {
+		identifier := entity.QualifiedName()
+		for _, reference := range index.ReferencesTo(entity) {
+			if reference.File == deleted.File {
+				continue // Skip the file where content was deleted
 			}
+			references = append(references, OrphanedReference{
+				DeletedEntity:    identifier,
+				EntityKind:       entity.Kind,
+				ReferencingFile:  reference.File,
+				ReferencingLines: []int{reference.Line},
+				ReferenceType:    "potential_usage",
+				Context:          strings.TrimSpace(index.Line(reference.File, reference.Line)),
+				Severity:         "warning",
+				Suggestion:       fmt.Sprintf("Verify if '%s' is still needed after deletion", identifier),
+			})
 		}
 	}
 
 	return references
 }
 
+// deletedEntities returns the declarations removed by a deleted section: the parsed ones when the
+// file could be analyzed, otherwise names guessed from the deleted lines
+func (da *DefaultDeletionAnalyzer) deletedEntities(deleted DeletedCode) []Declaration {
+	if deleted.Entities != nil {
+		return deleted.Entities
+	}
+
+	var entities []Declaration
+	for _, identifier := range da.extractIdentifiers(deleted.Content, deleted.Language) {
+		entities = append(entities, Declaration{Name: identifier})
+	}
+	return entities
+}
+
 // extractIdentifiers extracts potential identifiers from code content
 // This is a very simple implementation - AI would do this much better
 func (da *DefaultDeletionAnalyzer) extractIdentifiers(content string, language string) []string {
@@ -678,10 +744,9 @@ func (da *DefaultDeletionAnalyzer) identifySafeDeletions(deletedContent []Delete
 
 	// Extract entities from deleted content and check if they're referenced
 	for _, deleted := range deletedContent {
-		entities := da.extractIdentifiers(deleted.Content, deleted.Language)
-		for _, entity := range entities {
-			if !orphanedEntities[entity] {
-				safeDeletions = append(safeDeletions, entity)
+		for _, entity := range da.deletedEntities(deleted) {
+			if name := entity.QualifiedName(); !orphanedEntities[name] {
+				safeDeletions = append(safeDeletions, name)
 			}
 		}
 	}
@@ -696,6 +761,18 @@ func (da *DefaultDeletionAnalyzer) generateEnhancedSuggestion(ref OrphanedRefere
 	// Enhance suggestion based on reference type and context
 	switch ref.ReferenceType {
 	case "potential_usage":
+		switch ref.EntityKind {
+		case KindFunction, KindMethod, KindInterfaceMethod:
+			return fmt.Sprintf("Remove the call to '%s' or replace with an alternative function. Consider refactoring the code to handle the missing functionality.", ref.DeletedEntity)
+		case KindType, KindClass, KindInterface:
+			return fmt.Sprintf("Remove references to type '%s' or replace with an alternative type. Update variable declarations and type annotations.", ref.DeletedEntity)
+		case KindModule:
+			return fmt.Sprintf("Remove references to module '%s' or include an alternative module.", ref.DeletedEntity)
+		case KindField:
+			return fmt.Sprintf("Remove uses of field '%s' or move the data it held elsewhere.", ref.DeletedEntity)
+		case KindConst, KindVar:
+			return fmt.Sprintf("Replace uses of '%s' with the value or an alternative %s.", ref.DeletedEntity, ref.EntityKind)
+		}
 		if strings.Contains(deleted.Content, "func ") {
 			return fmt.Sprintf("Remove the call to '%s' or replace with an alternative function. Consider refactoring the code to handle the missing functionality.", ref.DeletedEntity)
 		}
@@ -842,8 +919,17 @@ func (cb *DefaultAIContextBuilder) formatDeletionContext(deletedContent []Delete
 			rangeInfo = fmt.Sprintf("lines %d-%d", deleted.StartLine, deleted.EndLine)
 		}
 
-		deleteSection := fmt.Sprintf("### %d. %s (%s, %s)\n**Type**: %s\n**Location**: %s\n\n```%s\n%s\n```\n\n",
-			i+1, deleted.File, deleted.Language, rangeInfo, deleted.ChangeType, rangeInfo, deleted.Language, deleted.Content)
+		var removed string
+		if len(deleted.Entities) > 0 {
+			names := make([]string, 0, len(deleted.Entities))
+			for _, entity := range deleted.Entities {
+				names = append(names, fmt.Sprintf("%s (%s)", entity.QualifiedName(), entity.Kind))
+			}
+			removed = fmt.Sprintf("**Removed declarations**: %s\n", strings.Join(names, ", "))
+		}
+
+		deleteSection := fmt.Sprintf("### %d. %s (%s, %s)\n**Type**: %s\n**Location**: %s\n%s\n```%s\n%s\n```\n\n",
+			i+1, deleted.File, deleted.Language, rangeInfo, deleted.ChangeType, rangeInfo, removed, deleted.Language, deleted.Content)
 
 		// Check if adding this section would exceed limit
 		if currentLength+len(deleteSection) > cb.maxDeletionLength {
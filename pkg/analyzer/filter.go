@@ -0,0 +1,53 @@
+package analyzer
+
+import (
+	"path"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// FilterParsedDiff keeps files matching at least one include glob (all files when none are given)
+// and no exclude glob. Returns the filtered diff and the names of the excluded files.
+func FilterParsedDiff(parsedDiff *ParsedDiff, include, exclude []string) (*ParsedDiff, []string) {
+	if parsedDiff == nil || (len(include) == 0 && len(exclude) == 0) {
+		return parsedDiff, nil
+	}
+
+	filtered := &ParsedDiff{Files: []FileDiff{}}
+	var excluded []string
+
+	for _, file := range parsedDiff.Files {
+		if (len(include) > 0 && !MatchesAnyGlob(include, file.Filename)) || MatchesAnyGlob(exclude, file.Filename) {
+			excluded = append(excluded, file.Filename)
+			continue
+		}
+
+		filtered.Files = append(filtered.Files, file)
+		filtered.TotalAdded += file.Additions
+		filtered.TotalRemoved += file.Deletions
+	}
+	filtered.TotalFiles = len(filtered.Files)
+
+	return filtered, excluded
+}
+
+// MatchesAnyGlob reports whether the path matches any of the doublestar globs.
+// Globs without a slash also match the file's base name, so "*.lock" matches "web/yarn.lock".
+func MatchesAnyGlob(patterns []string, filename string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if matched, _ := doublestar.Match(pattern, filename); matched {
+			return true
+		}
+		if !strings.Contains(pattern, "/") {
+			if matched, _ := doublestar.Match(pattern, path.Base(filename)); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterParsedDiff(t *testing.T) {
+	parsedDiff := &ParsedDiff{
+		Files: []FileDiff{
+			{Filename: "src/main.go", Additions: 5, Deletions: 1},
+			{Filename: "src/main_test.go", Additions: 3},
+			{Filename: "vendor/lib/lib.go", Additions: 100},
+			{Filename: "web/yarn.lock", Additions: 50, Deletions: 20},
+			{Filename: "go.sum", Additions: 2},
+		},
+		TotalFiles:   5,
+		TotalAdded:   160,
+		TotalRemoved: 21,
+	}
+
+	tests := []struct {
+		name         string
+		include      []string
+		exclude      []string
+		expectedKept []string
+		expectedExcl []string
+		expectedAdds int
+	}{
+		{
+			name:         "no filters keeps everything",
+			expectedKept: []string{"src/main.go", "src/main_test.go", "vendor/lib/lib.go", "web/yarn.lock", "go.sum"},
+			expectedAdds: 160,
+		},
+		{
+			name:         "exclude defaults",
+			exclude:      []string{"vendor/**", "*.lock", "*.sum"},
+			expectedKept: []string{"src/main.go", "src/main_test.go"},
+			expectedExcl: []string{"vendor/lib/lib.go", "web/yarn.lock", "go.sum"},
+			expectedAdds: 8,
+		},
+		{
+			name:         "include restricts to matching paths",
+			include:      []string{"src/**"},
+			exclude:      []string{"**/*_test.go"},
+			expectedKept: []string{"src/main.go"},
+			expectedExcl: []string{"src/main_test.go", "vendor/lib/lib.go", "web/yarn.lock", "go.sum"},
+			expectedAdds: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered, excluded := FilterParsedDiff(parsedDiff, tt.include, tt.exclude)
+
+			var kept []string
+			for _, file := range filtered.Files {
+				kept = append(kept, file.Filename)
+			}
+			if !reflect.DeepEqual(kept, tt.expectedKept) {
+				t.Errorf("expected kept %v, got %v", tt.expectedKept, kept)
+			}
+			if !reflect.DeepEqual(excluded, tt.expectedExcl) {
+				t.Errorf("expected excluded %v, got %v", tt.expectedExcl, excluded)
+			}
+			if filtered.TotalAdded != tt.expectedAdds {
+				t.Errorf("expected %d added lines, got %d", tt.expectedAdds, filtered.TotalAdded)
+			}
+			if filtered.TotalFiles != len(tt.expectedKept) {
+				t.Errorf("expected TotalFiles %d, got %d", len(tt.expectedKept), filtered.TotalFiles)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package analyzer
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// FileClassification marks changed files that should not be reviewed by the LLM
+type FileClassification string
+
+const (
+	ClassificationNone      FileClassification = ""
+	ClassificationGenerated FileClassification = "generated"
+	ClassificationVendored  FileClassification = "vendored"
+)
+
+const (
+	// headerScanLines is how many lines from the top of a file are checked for a generated-code header
+	headerScanLines = 10
+	// minifiedLineLength marks a file as minified when any added line is at least this long
+	minifiedLineLength = 1000
+	// minifiedAverageLength marks a file as minified when its added lines average at least this long
+	minifiedAverageLength = 300
+)
+
+// generatedHeaderPattern matches the conventional generated-code banners, e.g. Go's
+// "// Code generated by protoc-gen-go. DO NOT EDIT." and the "@generated" tag
+var generatedHeaderPattern = regexp.MustCompile(`(?i)(code generated .*do not edit|@generated|auto-?generated.*do not (edit|modify))`)
+
+// knownGeneratedGlobs are well-known generated files, lockfiles and snapshots
+var knownGeneratedGlobs = []string{
+	"package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml", "bun.lockb",
+	"go.sum", "Cargo.lock", "poetry.lock", "Pipfile.lock", "Gemfile.lock", "composer.lock",
+	"Podfile.lock", "mix.lock", "flake.lock", "packages.lock.json",
+	"*.pb.go", "*.pb.gw.go", "*_pb2.py", "*_pb2_grpc.py", "*.pb.cc", "*.pb.h",
+	"*_gen.go", "*_generated.go", "zz_generated.*", "*.generated.*",
+	"*.min.js", "*.min.css", "*.js.map", "*.css.map",
+	"*.snap", "**/__snapshots__/**",
+}
+
+// knownVendoredGlobs are directories holding third-party code
+var knownVendoredGlobs = []string{
+	"vendor/**", "**/vendor/**", "**/node_modules/**", "third_party/**", "**/bower_components/**",
+}
+
+// attributeRule is one linguist attribute setting from a .gitattributes line
+type attributeRule struct {
+	pattern        string
+	classification FileClassification
+	set            bool // false for "-linguist-generated" or "linguist-generated=false"
+}
+
+// FileClassifier detects generated and vendored files in a diff
+type FileClassifier struct {
+	repoPath string
+	rules    []attributeRule
+}
+
+// NewFileClassifier creates a classifier for a checked-out repository, whose files are read for
+// generated headers; repoPath may be empty to classify from the diff alone. gitattributes is the
+// root .gitattributes to honor, which may be empty. It is passed in rather than read from the
+// checkout, so it can come from the base revision a pull request cannot change.
+func NewFileClassifier(repoPath string, gitattributes []byte) *FileClassifier {
+	return &FileClassifier{repoPath: repoPath, rules: parseLinguistAttributes(string(gitattributes))}
+}
+
+// NewFileClassifierFromAttributes creates a classifier without a checkout from the content of the
+// root .gitattributes, which may be empty. File headers are read from the diff.
+func NewFileClassifierFromAttributes(gitattributes []byte) *FileClassifier {
+	return &FileClassifier{rules: parseLinguistAttributes(string(gitattributes))}
+}
+
+// Classify reports whether a changed file is generated or vendored
+func (c *FileClassifier) Classify(file FileDiff) FileClassification {
+	// .gitattributes is authoritative, including explicit opt-outs
+	generated, vendored := c.attributes(file.Filename)
+
+	if vendored == nil {
+		isVendored := MatchesAnyGlob(knownVendoredGlobs, file.Filename)
+		vendored = &isVendored
+	}
+	if *vendored {
+		return ClassificationVendored
+	}
+
+	if generated == nil {
+		isGenerated := MatchesAnyGlob(knownGeneratedGlobs, file.Filename) || c.hasGeneratedHeader(file) || isMinified(file)
+		generated = &isGenerated
+	}
+	if *generated {
+		return ClassificationGenerated
+	}
+
+	return ClassificationNone
+}
+
+// FilterClassified sets the classification of every file and drops generated and vendored ones.
+// Returns the remaining diff and the names of the dropped files by classification.
+func (c *FileClassifier) FilterClassified(parsedDiff *ParsedDiff) (*ParsedDiff, map[FileClassification][]string) {
+	if parsedDiff == nil {
+		return nil, nil
+	}
+
+	filtered := &ParsedDiff{Files: []FileDiff{}}
+	dropped := make(map[FileClassification][]string)
+
+	for _, file := range parsedDiff.Files {
+		file.Classification = c.Classify(file)
+		if file.Classification != ClassificationNone {
+			dropped[file.Classification] = append(dropped[file.Classification], file.Filename)
+			continue
+		}
+
+		filtered.Files = append(filtered.Files, file)
+		filtered.TotalAdded += file.Additions
+		filtered.TotalRemoved += file.Deletions
+	}
+	filtered.TotalFiles = len(filtered.Files)
+
+	return filtered, dropped
+}
+
+// attributes returns the linguist-generated and linguist-vendored values set by .gitattributes,
+// nil when unset. The last matching line wins as in git.
+func (c *FileClassifier) attributes(filename string) (generated, vendored *bool) {
+	for _, rule := range c.rules {
+		if !matchesAttributePattern(rule.pattern, filename) {
+			continue
+		}
+		set := rule.set
+		switch rule.classification {
+		case ClassificationGenerated:
+			generated = &set
+		case ClassificationVendored:
+			vendored = &set
+		}
+	}
+	return generated, vendored
+}
+
+// hasGeneratedHeader checks the top of the file for a generated-code banner, reading the
+// workspace copy when available and falling back to a diff hunk that starts at line 1
+func (c *FileClassifier) hasGeneratedHeader(file FileDiff) bool {
+	for _, line := range c.headerLines(file) {
+		if generatedHeaderPattern.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *FileClassifier) headerLines(file FileDiff) []string {
+	if c.repoPath != "" && file.Status != "deleted" {
+		if f, err := os.Open(filepath.Join(c.repoPath, filepath.FromSlash(file.Filename))); err == nil {
+			defer f.Close()
+
+			var lines []string
+			scanner := bufio.NewScanner(f)
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for len(lines) < headerScanLines && scanner.Scan() {
+				lines = append(lines, scanner.Text())
+			}
+			return lines
+		}
+	}
+
+	var lines []string
+	for _, hunk := range file.Hunks {
+		if hunk.NewStart > 1 && hunk.OldStart > 1 {
+			continue
+		}
+		for _, line := range hunk.Lines {
+			if len(lines) >= headerScanLines {
+				break
+			}
+			lines = append(lines, line.Content)
+		}
+		break
+	}
+	return lines
+}
+
+// isMinified flags files whose added lines are far longer than hand-written code
+func isMinified(file FileDiff) bool {
+	var count, total int
+	for _, hunk := range file.Hunks {
+		for _, line := range hunk.Lines {
+			if line.Type != "added" {
+				continue
+			}
+			if len(line.Content) >= minifiedLineLength {
+				return true
+			}
+			count++
+			total += len(line.Content)
+		}
+	}
+	return count > 0 && total/count >= minifiedAverageLength
+}
+
+// parseLinguistAttributes extracts linguist-generated and linguist-vendored settings from .gitattributes
+func parseLinguistAttributes(content string) []attributeRule {
+	var rules []attributeRule
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		for _, attr := range fields[1:] {
+			if strings.HasPrefix(attr, "!") {
+				continue // Unspecified: fall back to the heuristics
+			}
+			set := true
+			if strings.HasPrefix(attr, "-") {
+				set = false
+				attr = attr[1:]
+			}
+			name, value, hasValue := strings.Cut(attr, "=")
+			if hasValue {
+				set = set && value != "false"
+			}
+
+			var classification FileClassification
+			switch name {
+			case "linguist-generated":
+				classification = ClassificationGenerated
+			case "linguist-vendored":
+				classification = ClassificationVendored
+			default:
+				continue
+			}
+			rules = append(rules, attributeRule{pattern: fields[0], classification: classification, set: set})
+		}
+	}
+	return rules
+}
+
+// matchesAttributePattern matches a .gitattributes pattern: patterns without a slash match
+// the base name at any depth, others are anchored at the repository root
+func matchesAttributePattern(pattern, filename string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	return MatchesAnyGlob([]string{pattern}, filename)
+}
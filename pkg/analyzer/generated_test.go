@@ -0,0 +1,174 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func addedHunk(newStart int, lines ...string) DiffHunk {
+	hunk := DiffHunk{NewStart: newStart, OldStart: newStart}
+	for _, line := range lines {
+		hunk.Lines = append(hunk.Lines, DiffLine{Type: "added", Content: line})
+	}
+	return hunk
+}
+
+func TestFileClassifier_Classify(t *testing.T) {
+	repoPath := t.TempDir()
+	attributes := `# Linguist overrides
+api/*.pb.ts linguist-generated=true
+docs/generated/** linguist-generated
+third_party/ours/** -linguist-vendored
+*.lock -linguist-generated
+`
+	// Only the attributes passed in are honored, not those of the checkout
+	if err := os.WriteFile(filepath.Join(repoPath, ".gitattributes"), []byte("* linguist-generated\n"), 0644); err != nil {
+		t.Fatalf("failed to write .gitattributes: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(repoPath, "internal"), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	header := "// Code generated by mockgen. DO NOT EDIT.\n\npackage internal\n"
+	if err := os.WriteFile(filepath.Join(repoPath, "internal", "mocks.go"), []byte(header), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	classifier := NewFileClassifier(repoPath, []byte(attributes))
+
+	tests := []struct {
+		name     string
+		file     FileDiff
+		expected FileClassification
+	}{
+		{
+			name:     "regular source file",
+			file:     FileDiff{Filename: "internal/service.go", Hunks: []DiffHunk{addedHunk(10, "return nil")}},
+			expected: ClassificationNone,
+		},
+		{
+			name:     "gitattributes linguist-generated=true",
+			file:     FileDiff{Filename: "api/service.pb.ts"},
+			expected: ClassificationGenerated,
+		},
+		{
+			name:     "gitattributes linguist-generated directory",
+			file:     FileDiff{Filename: "docs/generated/api.md"},
+			expected: ClassificationGenerated,
+		},
+		{
+			name:     "gitattributes opt-out of vendored",
+			file:     FileDiff{Filename: "third_party/ours/patch.go"},
+			expected: ClassificationNone,
+		},
+		{
+			name:     "gitattributes opt-out of generated lockfile",
+			file:     FileDiff{Filename: "Cargo.lock"},
+			expected: ClassificationNone,
+		},
+		{
+			name:     "header read from workspace",
+			file:     FileDiff{Filename: "internal/mocks.go", Status: "modified"},
+			expected: ClassificationGenerated,
+		},
+		{
+			name: "header read from diff of deleted file",
+			file: FileDiff{Filename: "internal/old_mocks.go", Status: "deleted", Hunks: []DiffHunk{{
+				OldStart: 1,
+				Lines:    []DiffLine{{Type: "removed", Content: "// Code generated by mockgen. DO NOT EDIT."}},
+			}}},
+			expected: ClassificationGenerated,
+		},
+		{
+			name:     "known lockfile",
+			file:     FileDiff{Filename: "web/package-lock.json"},
+			expected: ClassificationGenerated,
+		},
+		{
+			name:     "protobuf output",
+			file:     FileDiff{Filename: "proto/user.pb.go"},
+			expected: ClassificationGenerated,
+		},
+		{
+			name:     "jest snapshot",
+			file:     FileDiff{Filename: "src/__snapshots__/App.test.js.snap"},
+			expected: ClassificationGenerated,
+		},
+		{
+			name:     "vendor directory",
+			file:     FileDiff{Filename: "vendor/github.com/pkg/errors/errors.go"},
+			expected: ClassificationVendored,
+		},
+		{
+			name:     "nested node_modules",
+			file:     FileDiff{Filename: "web/node_modules/react/index.js"},
+			expected: ClassificationVendored,
+		},
+		{
+			name:     "minified by line length",
+			file:     FileDiff{Filename: "static/app.js", Hunks: []DiffHunk{addedHunk(1, strings.Repeat("a=1;", 300))}},
+			expected: ClassificationGenerated,
+		},
+		{
+			name: "minified by average line length",
+			file: FileDiff{Filename: "static/bundle.js", Hunks: []DiffHunk{
+				addedHunk(1, strings.Repeat("x", 400), strings.Repeat("y", 350)),
+			}},
+			expected: ClassificationGenerated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifier.Classify(tt.file); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewFileClassifierFromAttributes(t *testing.T) {
+	classifier := NewFileClassifierFromAttributes([]byte("api/*.pb.ts linguist-generated\n"))
+
+	if got := classifier.Classify(FileDiff{Filename: "api/service.pb.ts"}); got != ClassificationGenerated {
+		t.Errorf("expected .gitattributes to be honored, got %q", got)
+	}
+
+	header := FileDiff{Filename: "internal/mocks.go", Status: "modified", Hunks: []DiffHunk{addedHunk(1, "// Code generated by mockgen. DO NOT EDIT.")}}
+	if got := classifier.Classify(header); got != ClassificationGenerated {
+		t.Errorf("expected the header to be read from the diff, got %q", got)
+	}
+
+	if got := NewFileClassifierFromAttributes(nil).Classify(FileDiff{Filename: "main.go"}); got != ClassificationNone {
+		t.Errorf("expected no classification without attributes, got %q", got)
+	}
+}
+
+func TestFileClassifier_FilterClassified(t *testing.T) {
+	parsedDiff := &ParsedDiff{
+		Files: []FileDiff{
+			{Filename: "main.go", Additions: 5, Deletions: 1},
+			{Filename: "go.sum", Additions: 20, Deletions: 3},
+			{Filename: "vendor/lib/lib.go", Additions: 100},
+			{Filename: "api/types_gen.go", Additions: 40},
+		},
+		TotalFiles: 4,
+	}
+
+	filtered, dropped := NewFileClassifier("", nil).FilterClassified(parsedDiff)
+
+	if filtered.TotalFiles != 1 || filtered.Files[0].Filename != "main.go" {
+		t.Fatalf("expected only main.go to remain, got %+v", filtered.Files)
+	}
+	if filtered.TotalAdded != 5 || filtered.TotalRemoved != 1 {
+		t.Errorf("expected totals to be recomputed, got +%d -%d", filtered.TotalAdded, filtered.TotalRemoved)
+	}
+	if !reflect.DeepEqual(dropped[ClassificationGenerated], []string{"go.sum", "api/types_gen.go"}) {
+		t.Errorf("unexpected generated files: %v", dropped[ClassificationGenerated])
+	}
+	if !reflect.DeepEqual(dropped[ClassificationVendored], []string{"vendor/lib/lib.go"}) {
+		t.Errorf("unexpected vendored files: %v", dropped[ClassificationVendored])
+	}
+}
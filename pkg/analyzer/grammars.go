@@ -0,0 +1,1253 @@
+package analyzer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// grammar extracts the declarations of one language from its tokens
+type grammar struct {
+	syntax  lexerSyntax
+	extract func(s *tokenStream)
+}
+
+// scriptSyntax is shared by TypeScript and JavaScript
+var scriptSyntax = lexerSyntax{
+	lineComments:  []string{"//"},
+	blockComments: [][2]string{{"/*", "*/"}},
+	quotes:        "\"'`",
+	interpolation: "${",
+	interpolated:  "`",
+	identChars:    "$",
+}
+
+// grammars lists the languages whose declarations are extracted by lexing instead of parsing
+var grammars = map[string]grammar{
+	"python": {
+		syntax:  lexerSyntax{lineComments: []string{"#"}, quotes: `"'`, tripleQuotes: true},
+		extract: (*tokenStream).python,
+	},
+	"java": {
+		syntax:  lexerSyntax{lineComments: []string{"//"}, blockComments: [][2]string{{"/*", "*/"}}, quotes: `"'`, tripleQuotes: true},
+		extract: func(s *tokenStream) { s.javaBody(0, len(s.tokens), "", false) },
+	},
+	"rust": {
+		syntax: lexerSyntax{
+			lineComments:     []string{"//"},
+			blockComments:    [][2]string{{"/*", "*/"}},
+			quotes:           `"`,
+			multilineStrings: true,
+			rustChars:        true,
+		},
+		extract: func(s *tokenStream) { s.rustBody(0, len(s.tokens), "", "", false) },
+	},
+	"ruby": {
+		syntax: lexerSyntax{
+			lineComments:      []string{"#"},
+			quotes:            "\"'`",
+			multilineStrings:  true,
+			interpolation:     "#{",
+			interpolated:      "\"`",
+			rubyBlockComments: true,
+			identSuffixes:     "?!",
+		},
+		extract: (*tokenStream).ruby,
+	},
+	"typescript": {syntax: scriptSyntax, extract: (*tokenStream).typescript},
+	"javascript": {syntax: scriptSyntax, extract: (*tokenStream).typescript},
+}
+
+// extractLexedDeclarations lexes src with the grammar of a language and lists its declarations
+func extractLexedDeclarations(g grammar, src []byte) []Declaration {
+	s := &tokenStream{tokens: lex(src, g.syntax)}
+	g.extract(s)
+	return s.declarations
+}
+
+// tokenStream walks the tokens of a file and collects its declarations
+type tokenStream struct {
+	tokens       []lexeme
+	declarations []Declaration
+}
+
+// is reports whether token i is the keyword or punctuation text; strings never match
+func (s *tokenStream) is(i int, text string) bool {
+	return i >= 0 && i < len(s.tokens) && s.tokens[i].kind != tokenString && s.tokens[i].text == text
+}
+
+func (s *tokenStream) isAny(i int, texts ...string) bool {
+	for _, text := range texts {
+		if s.is(i, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *tokenStream) ident(i int) bool {
+	return i >= 0 && i < len(s.tokens) && s.tokens[i].kind == tokenIdent
+}
+
+func (s *tokenStream) text(i int) string {
+	if i < 0 || i >= len(s.tokens) {
+		return ""
+	}
+	return s.tokens[i].text
+}
+
+func (s *tokenStream) line(i int) int {
+	if len(s.tokens) == 0 {
+		return 0
+	}
+	return s.tokens[max(0, min(i, len(s.tokens)-1))].line
+}
+
+// startsLine reports whether token i is the first on its line
+func (s *tokenStream) startsLine(i int) bool {
+	return i >= 0 && i < len(s.tokens) && s.tokens[i].first
+}
+
+// add records a declaration named by token name, spanning tokens start to end, and returns its index
+func (s *tokenStream) add(name int, kind, parent string, start, end int, exported bool, decorators []string) int {
+	if !s.ident(name) || s.text(name) == "_" {
+		return -1
+	}
+	s.declarations = append(s.declarations, Declaration{
+		Name:       s.text(name),
+		Kind:       kind,
+		Parent:     parent,
+		Line:       s.line(name),
+		StartLine:  s.line(start),
+		EndLine:    s.line(end),
+		Exported:   exported,
+		Decorators: decorators,
+	})
+	return len(s.declarations) - 1
+}
+
+// closing returns the index of the bracket closing the one at i, or the last token when unbalanced
+func (s *tokenStream) closing(i int) int {
+	depth := 0
+	for j := i; j < len(s.tokens); j++ {
+		if s.tokens[j].kind != tokenPunct {
+			continue
+		}
+		switch s.tokens[j].text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(s.tokens) - 1
+}
+
+// skipGroup returns the index after token i, or after the bracketed group it opens
+func (s *tokenStream) skipGroup(i int) int {
+	if s.isAny(i, "(", "[", "{") {
+		return s.closing(i) + 1
+	}
+	return i + 1
+}
+
+// skipAngles returns the index after the type parameters or arguments opened at i
+func (s *tokenStream) skipAngles(i, hi int) int {
+	depth := 0
+	for j := i; j < hi; j++ {
+		switch {
+		case s.is(j, "<"):
+			depth++
+		case s.is(j, "<<"):
+			depth += 2
+		case s.is(j, ">"):
+			depth--
+		case s.is(j, ">>"):
+			depth -= 2
+		case s.isAny(j, ";", "{"):
+			return j
+		case s.isAny(j, "(", "["):
+			j = s.closing(j)
+		}
+		if depth <= 0 {
+			return j + 1
+		}
+	}
+	return hi
+}
+
+// dottedName reads a name such as a.b.C starting at i and returns it with the index after it
+func (s *tokenStream) dottedName(i int) (string, int) {
+	name := s.text(i)
+	j := i + 1
+	for s.is(j, ".") && s.ident(j+1) {
+		name += "." + s.text(j+1)
+		j += 2
+	}
+	return name, j
+}
+
+// semicolonEnd returns the index of the semicolon ending the statement at i
+func (s *tokenStream) semicolonEnd(i, hi int) int {
+	for j := i; j < hi; j++ {
+		if s.is(j, ";") {
+			return j
+		}
+		if s.isAny(j, "(", "[", "{") {
+			j = s.closing(j)
+		}
+	}
+	return hi - 1
+}
+
+// bodyEnd returns the index ending a function or type declared at i: the brace closing its body,
+// or the semicolon or line ending a declaration without one
+func (s *tokenStream) bodyEnd(i, hi int) int {
+	indent := s.tokens[min(i, len(s.tokens)-1)].indent
+	for j := i; j < hi; j++ {
+		if j > i && s.startsLine(j) && s.tokens[j].indent <= indent && !s.isAny(j, "{", "throws", "where", "->", ":") {
+			return j - 1
+		}
+		switch {
+		case s.is(j, "{"):
+			return s.closing(j)
+		case s.is(j, ";"):
+			return j
+		case s.isAny(j, "(", "["):
+			j = s.closing(j)
+		case s.is(j, "<"):
+			j = s.skipAngles(j, hi) - 1
+		}
+	}
+	return hi - 1
+}
+
+// openingBrace returns the index of the { opening the body of a declaration at i, or hi
+func (s *tokenStream) openingBrace(i, hi int) int {
+	for j := i; j < hi; {
+		switch {
+		case s.is(j, "{"):
+			return j
+		case s.is(j, ";"):
+			return hi
+		case s.is(j, "<"):
+			j = s.skipAngles(j, hi)
+		default:
+			j = s.skipGroup(j)
+		}
+	}
+	return hi
+}
+
+// isConstantName reports whether a name is written in upper case, as in MAX_RETRIES
+func isConstantName(name string) bool {
+	letters := false
+	for _, r := range name {
+		if unicode.IsLower(r) {
+			return false
+		}
+		letters = letters || unicode.IsUpper(r)
+	}
+	return letters
+}
+
+var javaModifiers = []string{
+	"protected", "abstract", "default", "synchronized", "native", "transient", "volatile", "strictfp", "sealed",
+}
+
+// javaBody extracts the types and members declared between lo and hi. parent is the enclosing type,
+// empty at file level.
+func (s *tokenStream) javaBody(lo, hi int, parent string, iface bool) {
+	for i := lo; i < hi; {
+		start := i
+		var decorators []string
+		exported, static, final := iface, false, false
+	modifiers:
+		for i < hi {
+			switch {
+			case s.is(i, "@") && s.ident(i+1) && !s.is(i+1, "interface"):
+				var name string
+				name, i = s.dottedName(i + 1)
+				decorators = append(decorators, name)
+				if s.is(i, "(") {
+					i = s.closing(i) + 1
+				}
+			case s.is(i, "public"):
+				exported = true
+				i++
+			case s.is(i, "private"):
+				exported = false
+				i++
+			case s.is(i, "static"):
+				static = true
+				i++
+			case s.is(i, "final"):
+				final = true
+				i++
+			case s.isAny(i, javaModifiers...):
+				i++
+			default:
+				break modifiers
+			}
+		}
+		if i >= hi {
+			break
+		}
+
+		switch {
+		case s.isAny(i, "package", "import"):
+			i = s.semicolonEnd(i, hi) + 1
+			continue
+		case s.isAny(i, "class", "interface", "enum", "record") || s.is(i, "@") && s.is(i+1, "interface"):
+			if s.is(i, "@") {
+				i++
+			}
+			keyword, name := s.text(i), i+1
+			body := s.openingBrace(name+1, hi)
+			if !s.ident(name) || body >= hi {
+				i = name
+				continue
+			}
+			end := s.closing(body)
+			kind := KindClass
+			if keyword == "interface" {
+				kind = KindInterface
+			}
+			s.add(name, kind, parent, start, end, exported, decorators)
+			members := body + 1
+			if keyword == "enum" {
+				members = s.javaEnumConstants(members, end, s.text(name))
+			}
+			s.javaBody(members, end, s.text(name), keyword == "interface")
+			i = end + 1
+			continue
+		case s.isAny(i, "{", ";"):
+			// Initializer blocks and empty declarations
+			i = s.skipGroup(i)
+			continue
+		}
+		if parent == "" {
+			i = s.skipGroup(i)
+			continue
+		}
+
+		// A member: the last identifier before its parameters or initializer names it
+		name, j := -1, i
+		for j < hi && !s.isAny(j, "(", "=", ";", ",", "{", "}") {
+			switch {
+			case s.is(j, "<"):
+				j = s.skipAngles(j, hi)
+				continue
+			case s.is(j, "["):
+				j = s.closing(j) + 1
+				continue
+			case s.ident(j):
+				name = j
+			}
+			j++
+		}
+		if name < 0 || j >= hi || s.is(j, "{") {
+			i = s.skipGroup(j)
+			continue
+		}
+
+		if s.is(j, "(") {
+			end := s.bodyEnd(j, hi)
+			kind := KindMethod
+			if iface {
+				kind = KindInterfaceMethod
+			}
+			// Constructors are not referenced by name
+			if s.text(name) != parent {
+				s.add(name, kind, parent, start, end, exported, decorators)
+			}
+			i = end + 1
+			continue
+		}
+
+		// Fields, possibly several: int a = 1, b;
+		end := s.semicolonEnd(j, hi)
+		kind := KindField
+		if iface || static && final {
+			kind = KindConst
+		}
+		s.add(name, kind, parent, start, end, exported, decorators)
+		for k := j; k < end; k = s.skipGroup(k) {
+			if s.is(k, ",") && s.ident(k+1) && (s.isAny(k+2, "=", ",", "[") || k+2 == end) {
+				s.add(k+1, kind, parent, start, end, exported, decorators)
+			}
+		}
+		i = end + 1
+	}
+}
+
+// javaEnumConstants adds the constants opening an enum body and returns the index after them
+func (s *tokenStream) javaEnumConstants(i, hi int, enum string) int {
+	for i < hi {
+		switch {
+		case s.is(i, ";"):
+			return i + 1
+		case s.is(i, "@") && s.ident(i+1):
+			_, i = s.dottedName(i + 1)
+			if s.is(i, "(") {
+				i = s.closing(i) + 1
+			}
+		case s.ident(i):
+			end := i
+			if s.is(end+1, "(") {
+				end = s.closing(end + 1)
+			}
+			if s.is(end+1, "{") {
+				end = s.closing(end + 1)
+			}
+			s.add(i, KindConst, enum, i, end, true, nil)
+			i = end + 1
+		default:
+			i = s.skipGroup(i)
+		}
+	}
+	return i
+}
+
+// typescript extracts the declarations of TypeScript and JavaScript files
+func (s *tokenStream) typescript() {
+	exports := make(map[string]bool)
+	s.scriptBody(0, len(s.tokens), "", exports)
+	for i := range s.declarations {
+		if s.declarations[i].Parent == "" && exports[s.declarations[i].Name] {
+			s.declarations[i].Exported = true
+		}
+	}
+}
+
+// scriptBody extracts the declarations of a module or namespace body. Names listed by
+// export { ... } statements are collected into exports.
+func (s *tokenStream) scriptBody(lo, hi int, parent string, exports map[string]bool) {
+	for i := lo; i < hi; {
+		start := i
+		var decorators []string
+		exported := false
+	modifiers:
+		for i < hi {
+			switch {
+			case s.is(i, "@") && s.ident(i+1):
+				var name string
+				name, i = s.dottedName(i + 1)
+				decorators = append(decorators, name)
+				if s.is(i, "(") {
+					i = s.closing(i) + 1
+				}
+			case s.is(i, "export"):
+				exported = true
+				i++
+			case s.isAny(i, "default", "declare", "abstract", "async"):
+				i++
+			case s.is(i, "const") && s.is(i+1, "enum"):
+				i++
+			default:
+				break modifiers
+			}
+		}
+		if i >= hi {
+			break
+		}
+
+		switch {
+		case s.is(i, "function"):
+			name := i + 1
+			if s.is(name, "*") {
+				name++
+			}
+			end := s.bodyEnd(name, hi)
+			s.add(name, KindFunction, parent, start, end, exported, decorators)
+			i = end + 1
+		case s.is(i, "class"):
+			body := s.openingBrace(i+1, hi)
+			if body >= hi {
+				i++
+				continue
+			}
+			end := s.closing(body)
+			// Members of anonymous classes cannot be referenced through a class name
+			if s.ident(i + 1) {
+				s.add(i+1, KindClass, parent, start, end, exported, decorators)
+				s.scriptClass(body+1, end, s.text(i+1))
+			}
+			i = end + 1
+		case s.is(i, "interface") && s.ident(i+1):
+			body := s.openingBrace(i+2, hi)
+			if body >= hi {
+				i++
+				continue
+			}
+			end := s.closing(body)
+			s.add(i+1, KindInterface, parent, start, end, exported, decorators)
+			s.scriptInterface(body+1, end, s.text(i+1))
+			i = end + 1
+		case s.is(i, "type") && s.ident(i+1) && s.isAny(i+2, "=", "<"):
+			end := s.statementEnd(i, hi)
+			s.add(i+1, KindType, parent, start, end, exported, decorators)
+			i = end + 1
+		case s.is(i, "enum") && s.ident(i+1):
+			end := s.bodyEnd(i+2, hi)
+			s.add(i+1, KindType, parent, start, end, exported, decorators)
+			i = end + 1
+		case s.isAny(i, "namespace", "module") && s.ident(i+1):
+			name, next := s.dottedName(i + 1)
+			if !s.is(next, "{") {
+				i = next
+				continue
+			}
+			end := s.closing(next)
+			s.scriptBody(next+1, end, name, exports)
+			i = end + 1
+		case s.isAny(i, "const", "let", "var"):
+			i = s.scriptVariables(i, hi, parent, start, exported, decorators)
+		case exported && s.is(i, "{"):
+			// export { a, b as c } exports the local names a and b
+			end := s.closing(i)
+			for k := i + 1; k < end; k++ {
+				if s.ident(k) && s.isAny(k-1, "{", ",") {
+					exports[s.text(k)] = true
+				}
+			}
+			i = s.statementEnd(i, hi) + 1
+		case s.is(i, "import"):
+			i = s.statementEnd(i, hi) + 1
+		default:
+			i = s.skipGroup(i)
+		}
+	}
+}
+
+// scriptVariables adds the variables declared by a const, let or var statement at i and returns
+// the index after it. Variables holding functions or classes are declared as such.
+func (s *tokenStream) scriptVariables(i, hi int, parent string, start int, exported bool, decorators []string) int {
+	kind := KindVar
+	if s.is(i, "const") {
+		kind = KindConst
+	}
+	end := s.statementEnd(i, hi)
+	for k := i + 1; k <= end && s.ident(k); k++ {
+		name := k
+		for k++; k <= end && !s.isAny(k, "=", ",", ";"); {
+			if s.is(k, "<") {
+				k = s.skipAngles(k, hi)
+			} else {
+				k = s.skipGroup(k)
+			}
+		}
+		declared := kind
+		if s.is(k, "=") {
+			declared = s.initializerKind(k+1, kind)
+			for k <= end && !s.isAny(k, ",", ";") {
+				k = s.skipGroup(k)
+			}
+		}
+		s.add(name, declared, parent, start, end, exported, decorators)
+		if !s.is(k, ",") {
+			break
+		}
+	}
+	return end + 1
+}
+
+// initializerKind returns KindFunction or KindClass when the expression at i defines one,
+// otherwise fallback
+func (s *tokenStream) initializerKind(i int, fallback string) string {
+	if s.is(i, "async") {
+		i++
+	}
+	if s.is(i, "<") {
+		i = s.skipAngles(i, len(s.tokens))
+	}
+	switch {
+	case s.is(i, "function"):
+		return KindFunction
+	case s.is(i, "class"):
+		return KindClass
+	case s.ident(i) && s.is(i+1, "=>"):
+		return KindFunction
+	case s.is(i, "(") && s.isAny(s.closing(i)+1, "=>", ":"):
+		return KindFunction
+	}
+	return fallback
+}
+
+var (
+	// continuedBy lists tokens after which a statement continues on the next line
+	continuedBy = map[string]bool{
+		"=": true, ",": true, "(": true, "[": true, "{": true, ".": true, "+": true, "-": true, "*": true,
+		"/": true, "%": true, "&&": true, "||": true, "??": true, "?": true, ":": true, "=>": true, "|": true,
+		"&": true, "<": true, "extends": true, "keyof": true, "typeof": true, "new": true,
+	}
+	// continuing lists tokens that continue the statement of the previous line
+	continuing = map[string]bool{
+		".": true, "?.": true, "|": true, "&": true, "?": true, ":": true, "+": true, "-": true, "*": true,
+		"/": true, "&&": true, "||": true, "??": true, "=>": true, "=": true, ")": true, "]": true, "}": true,
+		"as": true, "extends": true, "instanceof": true, "in": true,
+	}
+)
+
+// statementEnd returns the index of the last token of the statement at i, which ends at a
+// semicolon or, without one, at a line that does not continue it
+func (s *tokenStream) statementEnd(i, hi int) int {
+	indent := s.tokens[min(i, len(s.tokens)-1)].indent
+	for j := i; j < hi; j++ {
+		if s.is(j, ";") {
+			return j
+		}
+		if j > i && s.startsLine(j) && s.tokens[j].indent <= indent &&
+			!continuedBy[s.text(j-1)] && !continuing[s.text(j)] {
+			return j - 1
+		}
+		if s.isAny(j, "(", "[", "{") {
+			j = s.closing(j)
+		}
+	}
+	return hi - 1
+}
+
+// scriptClass extracts the methods and properties of a class body
+func (s *tokenStream) scriptClass(lo, hi int, class string) {
+	for i := lo; i < hi; {
+		start := i
+		var decorators []string
+		exported := true
+	modifiers:
+		for i < hi {
+			switch {
+			case s.is(i, "@") && s.ident(i+1):
+				var name string
+				name, i = s.dottedName(i + 1)
+				decorators = append(decorators, name)
+				if s.is(i, "(") {
+					i = s.closing(i) + 1
+				}
+			case s.isAny(i, "private", "protected", "#"):
+				exported = false
+				i++
+			case s.isAny(i, "get", "set") && !s.isAny(i+1, "(", "=", ":", ";", "?", "!"):
+				i++
+			case s.isAny(i, "public", "static", "readonly", "abstract", "async", "declare", "override", "accessor", "*"):
+				if s.isAny(i+1, "(", "=", ":", ";") {
+					break modifiers
+				}
+				i++
+			default:
+				break modifiers
+			}
+		}
+		if i >= hi {
+			break
+		}
+		if s.is(i, ";") {
+			i++
+			continue
+		}
+		if !s.ident(i) {
+			// Index signatures and computed names
+			i = s.statementEnd(i, hi) + 1
+			continue
+		}
+
+		name, k := i, i+1
+		if s.isAny(k, "?", "!") {
+			k++
+		}
+		if s.isAny(k, "(", "<") {
+			end := s.bodyEnd(k, hi)
+			if s.text(name) != "constructor" {
+				s.add(name, KindMethod, class, start, end, exported, decorators)
+			}
+			i = end + 1
+			continue
+		}
+
+		end := s.statementEnd(i, hi)
+		for k <= end && !s.is(k, "=") && !s.is(k, ";") {
+			k = s.skipGroup(k)
+		}
+		kind := KindField
+		if s.is(k, "=") {
+			kind = s.initializerKind(k+1, KindField)
+			if kind != KindField {
+				kind = KindMethod
+			}
+		}
+		s.add(name, kind, class, start, end, exported, decorators)
+		i = end + 1
+	}
+}
+
+// scriptInterface extracts the members of an interface body
+func (s *tokenStream) scriptInterface(lo, hi int, iface string) {
+	for i := lo; i < hi; {
+		start := i
+		if s.is(i, "readonly") {
+			i++
+		}
+		end := s.statementEnd(i, hi)
+		for j := i; j < end; j = s.skipGroup(j) {
+			if s.is(j, ",") {
+				end = j
+				break
+			}
+		}
+		if s.ident(i) && !s.is(i, "new") {
+			k := i + 1
+			if s.is(k, "?") {
+				k++
+			}
+			kind := KindField
+			if s.isAny(k, "(", "<") {
+				kind = KindInterfaceMethod
+			}
+			s.add(i, kind, iface, start, end, true, nil)
+		}
+		i = end + 1
+	}
+}
+
+// rustBody extracts the items declared between lo and hi. Items of an impl or trait have the
+// implementing type or the trait as parent; container is "impl", "trait impl" or "trait" for them.
+func (s *tokenStream) rustBody(lo, hi int, parent, container string, containerExported bool) {
+	for i := lo; i < hi; {
+		start := i
+		decorators, next := s.rustAttributes(i, hi)
+		i = next
+		exported := container == "trait impl" || container == "trait" && containerExported
+	modifiers:
+		for i < hi {
+			switch {
+			case s.is(i, "pub"):
+				exported = true
+				i++
+				if s.is(i, "(") {
+					i = s.closing(i) + 1
+				}
+			case s.isAny(i, "unsafe", "async", "default", "extern"):
+				i++
+				if i < hi && s.tokens[i].kind == tokenString {
+					i++
+				}
+			case s.is(i, "const") && s.isAny(i+1, "fn", "unsafe", "async", "extern"):
+				i++
+			default:
+				break modifiers
+			}
+		}
+		if i >= hi {
+			break
+		}
+
+		switch {
+		case s.is(i, "fn") && s.ident(i+1):
+			kind := KindFunction
+			switch container {
+			case "impl", "trait impl":
+				kind = KindMethod
+			case "trait":
+				kind = KindInterfaceMethod
+			}
+			end := s.bodyEnd(i+2, hi)
+			s.add(i+1, kind, parent, start, end, exported, decorators)
+			i = end + 1
+		case s.isAny(i, "struct", "union", "enum") && s.ident(i+1):
+			keyword, name := s.text(i), i+1
+			end := s.bodyEnd(name+1, hi)
+			s.add(name, KindType, parent, start, end, exported, decorators)
+			if s.is(end, "}") {
+				if body := s.openingBrace(name+1, end); body < end {
+					if keyword == "enum" {
+						s.rustVariants(body+1, end, s.text(name), exported)
+					} else {
+						s.rustFields(body+1, end, s.text(name))
+					}
+				}
+			}
+			i = end + 1
+		case s.is(i, "trait") && s.ident(i+1):
+			end := s.bodyEnd(i+2, hi)
+			s.add(i+1, KindInterface, parent, start, end, exported, decorators)
+			if body := s.openingBrace(i+2, end); body < end {
+				s.rustBody(body+1, end, s.text(i+1), "trait", exported)
+			}
+			i = end + 1
+		case s.is(i, "impl"):
+			body, typeName, ofTrait := s.rustImplHeader(i+1, hi)
+			if body >= hi {
+				i = body
+				continue
+			}
+			end := s.closing(body)
+			kind := "impl"
+			if ofTrait {
+				kind = "trait impl"
+			}
+			s.rustBody(body+1, end, typeName, kind, false)
+			i = end + 1
+		case s.is(i, "mod") && s.ident(i+1):
+			if !s.is(i+2, "{") {
+				i = s.semicolonEnd(i, hi) + 1
+				continue
+			}
+			end := s.closing(i + 2)
+			s.rustBody(i+3, end, "", "", false)
+			i = end + 1
+		case s.isAny(i, "const", "static") && s.ident(i+1):
+			kind, name := KindConst, i+1
+			if s.is(i, "static") {
+				kind = KindVar
+				if s.is(name, "mut") {
+					name++
+				}
+			}
+			end := s.semicolonEnd(i, hi)
+			s.add(name, kind, parent, start, end, exported, decorators)
+			i = end + 1
+		case s.is(i, "type") && s.ident(i+1):
+			end := s.semicolonEnd(i, hi)
+			s.add(i+1, KindType, parent, start, end, exported, decorators)
+			i = end + 1
+		case s.is(i, "macro_rules") && s.is(i+1, "!") && s.ident(i+2):
+			end := s.skipGroup(i+3) - 1
+			s.add(i+2, KindFunction, parent, start, end, exported, decorators)
+			i = end + 1
+		case s.is(i, "use"):
+			i = s.semicolonEnd(i, hi) + 1
+		default:
+			i = s.skipGroup(i)
+		}
+	}
+}
+
+// rustAttributes reads the attributes at i, such as #[derive(Debug)], returning their names and
+// the index after them. Inner attributes such as #![allow(...)] are skipped.
+func (s *tokenStream) rustAttributes(i, hi int) ([]string, int) {
+	var names []string
+	for i < hi && s.is(i, "#") {
+		switch {
+		case s.is(i+1, "["):
+			if s.ident(i + 2) {
+				names = append(names, s.text(i+2))
+			}
+			i = s.closing(i+1) + 1
+		case s.is(i+1, "!") && s.is(i+2, "["):
+			i = s.closing(i+2) + 1
+		default:
+			return names, i
+		}
+	}
+	return names, i
+}
+
+// rustImplHeader reads an impl header starting after the keyword. It returns the index of the
+// body's brace, the implementing type and whether a trait is implemented.
+func (s *tokenStream) rustImplHeader(i, hi int) (int, string, bool) {
+	name, ofTrait, where := "", false, false
+	for j := i; j < hi; j++ {
+		switch {
+		case s.is(j, "{"):
+			return j, name, ofTrait
+		case s.is(j, ";"):
+			return hi, name, ofTrait
+		case s.is(j, "<"):
+			j = s.skipAngles(j, hi) - 1
+		case s.isAny(j, "(", "["):
+			j = s.closing(j)
+		case s.is(j, "for"):
+			name, ofTrait = "", true
+		case s.is(j, "where"):
+			where = true
+		case s.ident(j) && !where && !s.isAny(j, "dyn", "mut", "impl", "const", "unsafe"):
+			name = s.text(j)
+		}
+	}
+	return hi, name, ofTrait
+}
+
+// untilComma returns the index of the comma ending the list element at i, or hi
+func (s *tokenStream) untilComma(i, hi int) int {
+	for i < hi && !s.is(i, ",") {
+		if s.is(i, "<") {
+			i = s.skipAngles(i, hi)
+		} else {
+			i = s.skipGroup(i)
+		}
+	}
+	return min(i, hi)
+}
+
+// rustFields adds the named fields of a struct or union body
+func (s *tokenStream) rustFields(lo, hi int, parent string) {
+	for i := lo; i < hi; {
+		start := i
+		decorators, next := s.rustAttributes(i, hi)
+		i = next
+		exported := false
+		if s.is(i, "pub") {
+			exported = true
+			i++
+			if s.is(i, "(") {
+				i = s.closing(i) + 1
+			}
+		}
+		if !s.ident(i) || !s.is(i+1, ":") {
+			i = s.skipGroup(i)
+			continue
+		}
+		end := s.untilComma(i, hi)
+		s.add(i, KindField, parent, start, min(end, hi-1), exported, decorators)
+		i = end + 1
+	}
+}
+
+// rustVariants adds the variants of an enum body as its constants
+func (s *tokenStream) rustVariants(lo, hi int, enum string, exported bool) {
+	for i := lo; i < hi; {
+		start := i
+		decorators, next := s.rustAttributes(i, hi)
+		i = next
+		if !s.ident(i) {
+			i = s.skipGroup(i)
+			continue
+		}
+		end := s.untilComma(i, hi)
+		s.add(i, KindConst, enum, start, min(end, hi-1), exported, decorators)
+		i = end + 1
+	}
+}
+
+// pythonKeywords are statement keywords that can be followed by a colon like an annotation
+var pythonKeywords = map[string]bool{
+	"else": true, "try": true, "finally": true, "except": true, "elif": true, "if": true, "for": true,
+	"while": true, "with": true, "lambda": true, "match": true, "case": true, "return": true, "global": true,
+}
+
+// pythonExported reports whether a module or class member is public by convention
+func pythonExported(name string) bool {
+	return !strings.HasPrefix(name, "_") || strings.HasPrefix(name, "__") && strings.HasSuffix(name, "__")
+}
+
+// python extracts the functions, classes, methods, class attributes, instance attributes assigned
+// through self and module-level variables of a Python file, using indentation to find scopes
+func (s *tokenStream) python() {
+	type scope struct {
+		indent      int
+		name        string
+		class       bool
+		declaration int // Index of the scope's declaration, -1 when it is not recorded
+	}
+	var (
+		scopes     []scope
+		decorators []string
+		decorated  = -1 // Token of the first pending decorator
+		pending    []int
+		seen       = make(map[string]bool)
+		all        map[string]bool
+		depth      int
+	)
+
+	// closeScopes ends the scopes at or below indent and the pending assignments on lastLine
+	closeScopes := func(indent, lastLine int) {
+		for _, declaration := range pending {
+			s.declarations[declaration].EndLine = lastLine
+		}
+		pending = pending[:0]
+		for len(scopes) > 0 && scopes[len(scopes)-1].indent >= indent {
+			if declaration := scopes[len(scopes)-1].declaration; declaration >= 0 {
+				s.declarations[declaration].EndLine = lastLine
+			}
+			scopes = scopes[:len(scopes)-1]
+		}
+	}
+
+	for i, t := range s.tokens {
+		if t.first && depth == 0 {
+			closeScopes(t.indent, s.line(i-1))
+
+			var enclosing *scope
+			if len(scopes) > 0 {
+				enclosing = &scopes[len(scopes)-1]
+			}
+			inClass := enclosing != nil && enclosing.class
+			inFunction := enclosing != nil && !enclosing.class
+			start := i
+			if decorated >= 0 {
+				start = decorated
+			}
+
+			switch {
+			case s.is(i, "@") && s.ident(i+1):
+				name, _ := s.dottedName(i + 1)
+				decorators = append(decorators, name)
+				if decorated < 0 {
+					decorated = i
+				}
+			case s.is(i, "def") || s.is(i, "async") && s.is(i+1, "def") || s.is(i, "class"):
+				name := i + 1
+				if s.is(i, "async") {
+					name++
+				}
+				kind, parent := KindFunction, ""
+				if s.is(i, "class") {
+					kind = KindClass
+				}
+				if inClass {
+					parent = enclosing.name
+					if kind == KindFunction {
+						kind = KindMethod
+					}
+				}
+				declaration := -1
+				if !inFunction {
+					declaration = s.add(name, kind, parent, start, i, pythonExported(s.text(name)), decorators)
+				}
+				scopes = append(scopes, scope{indent: t.indent, name: s.text(name), class: kind == KindClass, declaration: declaration})
+			case inFunction:
+				// self.name = ... in a method assigns an instance attribute of its class
+				if len(scopes) < 2 || !scopes[len(scopes)-2].class || !s.is(i, "self") || !s.is(i+1, ".") || !s.ident(i+2) || !s.is(i+3, "=") {
+					break
+				}
+				class := scopes[len(scopes)-2].name
+				if key := class + "." + s.text(i+2); !seen[key] {
+					seen[key] = true
+					if declaration := s.add(i+2, KindField, class, i, i, pythonExported(s.text(i+2)), nil); declaration >= 0 {
+						pending = append(pending, declaration)
+					}
+				}
+			case s.ident(i) && !pythonKeywords[s.text(i)]:
+				// Assignments: a = ..., a: int = ..., a, b = ...
+				targets, k := []int{i}, i+1
+				for s.is(k, ",") && s.ident(k+1) {
+					targets = append(targets, k+1)
+					k += 2
+				}
+				if !s.is(k, "=") && !(len(targets) == 1 && s.is(k, ":")) {
+					break
+				}
+				if s.text(i) == "__all__" && !inClass {
+					all = make(map[string]bool)
+					if s.isAny(k+1, "[", "(") {
+						for j, end := k+2, s.closing(k+1); j < end; j++ {
+							if s.tokens[j].kind == tokenString {
+								all[s.text(j)] = true
+							}
+						}
+					}
+					break
+				}
+				parent := ""
+				if inClass {
+					parent = enclosing.name
+				}
+				for _, target := range targets {
+					name := s.text(target)
+					key := parent + "." + name
+					if seen[key] {
+						continue
+					}
+					seen[key] = true
+					kind := KindVar
+					switch {
+					case isConstantName(name):
+						kind = KindConst
+					case inClass:
+						kind = KindField
+					}
+					if declaration := s.add(target, kind, parent, i, i, pythonExported(name), nil); declaration >= 0 {
+						pending = append(pending, declaration)
+					}
+				}
+			}
+			if !s.is(i, "@") {
+				decorators, decorated = nil, -1
+			}
+		}
+
+		if t.kind == tokenPunct {
+			switch t.text {
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				depth = max(0, depth-1)
+			}
+		}
+	}
+	closeScopes(-1, s.line(len(s.tokens)-1))
+
+	if all != nil {
+		for i := range s.declarations {
+			if s.declarations[i].Parent == "" {
+				s.declarations[i].Exported = all[s.declarations[i].Name]
+			}
+		}
+	}
+}
+
+// rubyKeywords are the keywords that define or open blocks
+var rubyKeywords = map[string]bool{
+	"class": true, "module": true, "def": true, "end": true, "do": true, "if": true, "unless": true,
+	"while": true, "until": true, "case": true, "begin": true, "for": true,
+}
+
+// ruby extracts the classes, modules, methods, constants and attributes of a Ruby file, matching
+// keywords that open blocks with their end
+func (s *tokenStream) ruby() {
+	type block struct {
+		kind        string // "class", "module", "singleton" for class << self, "def", "loop" or ""
+		name        string
+		line        int
+		declaration int
+		private     bool // Methods defined next in the class are private
+	}
+	var (
+		blocks      []block
+		privateNext bool
+		private     = make(map[string]bool)
+	)
+	// namespace returns the innermost class or module, -1 at the top level
+	namespace := func() int {
+		for b := len(blocks) - 1; b >= 0; b-- {
+			if blocks[b].kind == "class" || blocks[b].kind == "module" || blocks[b].kind == "singleton" {
+				return b
+			}
+		}
+		return -1
+	}
+	inDef := func() bool {
+		for _, b := range blocks {
+			if b.kind == "def" {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := 0; i < len(s.tokens); i++ {
+		// Keywords used as method names, symbols or hash keys open nothing
+		if s.isAny(i-1, ".", ":") || s.is(i+1, ":") && rubyKeywords[s.text(i)] {
+			continue
+		}
+		statement := s.startsLine(i) || s.is(i-1, ";")
+		ns := namespace()
+		parent := ""
+		if ns >= 0 {
+			parent = blocks[ns].name
+		}
+
+		switch {
+		case s.is(i, "end"):
+			if len(blocks) == 0 {
+				continue
+			}
+			if declaration := blocks[len(blocks)-1].declaration; declaration >= 0 {
+				s.declarations[declaration].EndLine = s.line(i)
+			}
+			blocks = blocks[:len(blocks)-1]
+		case s.is(i, "class") && s.is(i+1, "<<"):
+			blocks = append(blocks, block{kind: "singleton", name: parent, declaration: -1})
+			i++
+		case s.isAny(i, "class", "module") && s.ident(i+1):
+			kind := KindClass
+			if s.is(i, "module") {
+				kind = KindModule
+			}
+			name := i + 1
+			for s.is(name+1, "::") && s.ident(name+2) {
+				name += 2
+			}
+			declaration := -1
+			if !inDef() {
+				declaration = s.add(name, kind, parent, i, i, true, nil)
+			}
+			blocks = append(blocks, block{kind: s.text(i), name: s.text(name), declaration: declaration})
+			i = name
+		case s.is(i, "def"):
+			name := i + 1
+			if (s.ident(name) || s.is(name, "self")) && s.is(name+1, ".") {
+				// def self.name and def Const.name define singleton methods
+				name += 2
+			}
+			nameText := s.text(name)
+			if s.is(name, "[") && s.is(name+1, "]") {
+				nameText = "[]"
+				name++
+			}
+			if s.is(name+1, "=") && s.is(name+2, "(") {
+				nameText += "="
+				name++
+			}
+			after := name + 1
+			if s.is(after, "(") {
+				after = s.closing(after) + 1
+			}
+			// def name(args) = expr has no end
+			endless := s.is(after, "=")
+
+			declaration := -1
+			if !inDef() && s.ident(i+1) {
+				kind := KindFunction
+				if ns >= 0 {
+					kind = KindMethod
+				}
+				exported := !privateNext && (ns < 0 || !blocks[ns].private)
+				declaration = s.add(name, kind, parent, i, i, exported, nil)
+				if declaration >= 0 {
+					s.declarations[declaration].Name = nameText
+				}
+			}
+			privateNext = false
+			if !endless {
+				blocks = append(blocks, block{kind: "def", declaration: declaration})
+			}
+			i = name
+		case s.isAny(i, "private", "protected", "public") && ns >= 0 && statement:
+			switch {
+			case i+1 >= len(s.tokens) || s.startsLine(i+1) || s.is(i+1, ";"):
+				blocks[ns].private = !s.is(i, "public")
+			case s.is(i+1, "def"):
+				privateNext = !s.is(i, "public")
+			default:
+				// private :a, :b
+				for j := i + 1; s.is(j, ":") && s.ident(j+1); j += 3 {
+					private[parent+"."+s.text(j+1)] = !s.is(i, "public")
+					if !s.is(j+2, ",") {
+						break
+					}
+				}
+			}
+		case statement && s.isAny(i, "attr_reader", "attr_writer", "attr_accessor") && ns >= 0 && !inDef():
+			for j := i + 1; s.is(j, ":") && s.ident(j+1); j += 3 {
+				s.add(j+1, KindField, parent, i, j+1, !blocks[ns].private, nil)
+				if !s.is(j+2, ",") {
+					break
+				}
+			}
+		case statement && s.ident(i) && unicode.IsUpper(rune(s.text(i)[0])) && s.is(i+1, "=") && !inDef():
+			s.add(i, KindConst, parent, i, i, true, nil)
+		case s.isAny(i, "if", "unless", "while", "until", "case", "begin", "for") &&
+			(statement || s.isAny(i-1, "=", "(", ",", "[", "||", "&&")):
+			kind := ""
+			if s.isAny(i, "while", "until", "for") {
+				kind = "loop"
+			}
+			blocks = append(blocks, block{kind: kind, line: s.line(i), declaration: -1})
+		case s.is(i, "do"):
+			// while ... do shares the loop's end
+			if n := len(blocks); n > 0 && blocks[n-1].kind == "loop" && blocks[n-1].line == s.line(i) {
+				continue
+			}
+			blocks = append(blocks, block{line: s.line(i), declaration: -1})
+		}
+	}
+
+	for i := range s.declarations {
+		if hidden, ok := private[s.declarations[i].QualifiedName()]; ok {
+			s.declarations[i].Exported = !hidden
+		}
+	}
+}
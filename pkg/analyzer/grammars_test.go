@@ -0,0 +1,668 @@
+package analyzer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// describeDeclarations renders declarations as "kind QualifiedName:line", followed by their span,
+// "exported" and their decorators, so corpus expectations stay readable
+func describeDeclarations(declarations []Declaration) []string {
+	described := []string{}
+	for _, d := range declarations {
+		description := fmt.Sprintf("%s %s:%d %d-%d", d.Kind, d.QualifiedName(), d.Line, d.StartLine, d.EndLine)
+		if d.Exported {
+			description += " exported"
+		}
+		for _, decorator := range d.Decorators {
+			description += " @" + decorator
+		}
+		described = append(described, description)
+	}
+	return described
+}
+
+type grammarCase struct {
+	name     string
+	src      string
+	expected []string
+}
+
+func runGrammarCases(t *testing.T, language string, tests []grammarCase) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			declarations, ok := ExtractDeclarations(language, "", []byte(strings.TrimPrefix(tt.src, "\n")))
+			if !ok {
+				t.Fatalf("expected %s to have an extractor", language)
+			}
+			got := describeDeclarations(declarations)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("unexpected declarations\n got: %q\nwant: %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExtractDeclarations_Python(t *testing.T) {
+	runGrammarCases(t, "python", []grammarCase{
+		{
+			name: "functions, classes and methods",
+			src: `
+import os
+
+MAX_RETRIES = 3
+cache = {}
+
+def fetch(url):
+    def inner():
+        return url
+    return inner()
+
+class Client(Base):
+    timeout: int = 30
+
+    def __init__(self, url):
+        self.url = url
+        self._session = None
+
+    async def get(self, path):
+        return path
+
+    def _reset(self):
+        self.url = None
+`,
+			expected: []string{
+				"const MAX_RETRIES:3 3-3 exported",
+				"var cache:4 4-4 exported",
+				"function fetch:6 6-9 exported",
+				"class Client:11 11-22 exported",
+				"field Client.timeout:12 12-12 exported",
+				"method Client.__init__:14 14-16 exported",
+				"field Client.url:15 15-15 exported",
+				"field Client._session:16 16-16",
+				"method Client.get:18 18-19 exported",
+				"method Client._reset:21 21-22",
+			},
+		},
+		{
+			name: "decorators are attached and widen the span",
+			src: `
+class View:
+    @property
+    @functools.cache
+    def name(self):
+        return "x"
+
+@app.route("/")
+def index():
+    pass
+`,
+			expected: []string{
+				"class View:1 1-5 exported",
+				"method View.name:4 2-5 exported @property @functools.cache",
+				"function index:8 7-9 exported @app.route",
+			},
+		},
+		{
+			name: "strings, comments and brackets hide declarations",
+			src: `
+"""
+def not_a_function():
+    pass
+"""
+# class NotAClass:
+query = (
+    "def nope()"
+)
+def real(): return 1
+`,
+			expected: []string{
+				"var query:6 6-8 exported",
+				"function real:9 9-9 exported",
+			},
+		},
+		{
+			name: "__all__ decides what is exported",
+			src: `
+__all__ = ["public_api"]
+
+def public_api():
+    pass
+
+def helper():
+    pass
+`,
+			expected: []string{
+				"function public_api:3 3-4 exported",
+				"function helper:6 6-7",
+			},
+		},
+		{
+			name: "fragments with indentation",
+			src: `
+    def save(self):
+        pass
+    else_value = 1
+`,
+			expected: []string{
+				"function save:1 1-2 exported",
+				"var else_value:3 3-3 exported",
+			},
+		},
+	})
+}
+
+func TestExtractDeclarations_Java(t *testing.T) {
+	runGrammarCases(t, "java", []grammarCase{
+		{
+			name: "classes, methods, fields and constants",
+			src: `
+package com.example.store;
+
+import java.util.List;
+
+@Service
+public class UserService extends Base<User> implements Repository {
+    public static final int MAX_USERS = 100;
+    private final Map<String, User> users = new HashMap<>(), cache;
+
+    public UserService(Map<String, User> users) {
+        this.users = users;
+    }
+
+    @Override
+    @Transactional(readOnly = true)
+    public List<User> findAll() throws IOException {
+        return List.of("class Fake {}");
+    }
+
+    private <T> T convert(Object value) { return (T) value; }
+
+    static class Builder {
+        String name;
+    }
+}
+`,
+			expected: []string{
+				"class UserService:6 5-25 exported @Service",
+				"const UserService.MAX_USERS:7 7-7 exported",
+				"field UserService.users:8 8-8",
+				"field UserService.cache:8 8-8",
+				"method UserService.findAll:16 14-18 exported @Override @Transactional",
+				"method UserService.convert:20 20-20",
+				"class UserService.Builder:22 22-24",
+				"field Builder.name:23 23-23",
+			},
+		},
+		{
+			name: "interfaces, enums and records",
+			src: `
+public interface Shape {
+    double PI = 3.14;
+    double area();
+    default String label() { return "shape"; }
+}
+
+enum Color {
+    RED("r"), GREEN("g") { },
+    BLUE;
+
+    private final String code;
+}
+
+public record Point(int x, int y) {
+    public Point { }
+}
+`,
+			expected: []string{
+				"interface Shape:1 1-5 exported",
+				"const Shape.PI:2 2-2 exported",
+				"interface_method Shape.area:3 3-3 exported",
+				"interface_method Shape.label:4 4-4 exported",
+				"class Color:7 7-12",
+				"const Color.RED:8 8-8 exported",
+				"const Color.GREEN:8 8-8 exported",
+				"const Color.BLUE:9 9-9 exported",
+				"field Color.code:11 11-11",
+				"class Point:14 14-16 exported",
+			},
+		},
+		{
+			name: "comments and text blocks",
+			src: `
+class Query {
+    /* void hidden() {} */
+    // int commented;
+    String sql = """
+        class Injected { void nope() {} }
+        """;
+}
+`,
+			expected: []string{
+				"class Query:1 1-7",
+				"field Query.sql:4 4-6",
+			},
+		},
+	})
+}
+
+func TestExtractDeclarations_Rust(t *testing.T) {
+	runGrammarCases(t, "rust", []grammarCase{
+		{
+			name: "items, impls and traits",
+			src: `
+use std::fmt;
+
+pub const MAX: usize = 10;
+static mut COUNTER: u32 = 0;
+
+#[derive(Debug, Clone)]
+pub struct Config<'a> {
+    pub name: &'a str,
+    retries: HashMap<String, u32>,
+}
+
+pub enum State {
+    Idle,
+    Running { pid: u32 },
+    Failed(String),
+}
+
+pub trait Store {
+    const NAME: &'static str;
+    fn get(&self, key: &str) -> Option<String>;
+    fn len(&self) -> usize { 0 }
+}
+
+impl<'a> Config<'a> {
+    pub fn new(name: &'a str) -> Self {
+        let c = 'x';
+        Config { name, retries: HashMap::new() }
+    }
+
+    fn validate(&self) -> bool { true }
+}
+
+impl fmt::Display for State {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        write!(f, "{}", r#"fn fake() {}"#)
+    }
+}
+
+pub(crate) async fn run() {}
+
+type Result<T> = std::result::Result<T, Error>;
+`,
+			expected: []string{
+				"const MAX:3 3-3 exported",
+				"var COUNTER:4 4-4",
+				"type Config:7 6-10 exported @derive",
+				"field Config.name:8 8-8 exported",
+				"field Config.retries:9 9-9",
+				"type State:12 12-16 exported",
+				"const State.Idle:13 13-13 exported",
+				"const State.Running:14 14-14 exported",
+				"const State.Failed:15 15-15 exported",
+				"interface Store:18 18-22 exported",
+				"const Store.NAME:19 19-19 exported",
+				"interface_method Store.get:20 20-20 exported",
+				"interface_method Store.len:21 21-21 exported",
+				"method Config.new:25 25-28 exported",
+				"method Config.validate:30 30-30",
+				"method State.fmt:34 34-36 exported",
+				"function run:39 39-39 exported",
+				"type Result:41 41-41",
+			},
+		},
+		{
+			name: "test modules and attributes",
+			src: `
+#![allow(dead_code)]
+
+#[cfg(test)]
+mod tests {
+    #[test]
+    fn parses() {}
+}
+
+macro_rules! square {
+    ($x:expr) => { $x * $x };
+}
+`,
+			expected: []string{
+				"function parses:6 5-6 @test",
+				"function square:9 9-11",
+			},
+		},
+	})
+}
+
+func TestExtractDeclarations_Ruby(t *testing.T) {
+	runGrammarCases(t, "ruby", []grammarCase{
+		{
+			name: "classes, modules and methods",
+			src: `
+module Billing
+  VERSION = "1.0"
+
+  class Invoice < Base
+    attr_reader :total, :items
+
+    def initialize(items)
+      @items = items
+      if items.empty?
+        raise "empty"
+      end
+      items.each do |item|
+        add(item)
+      end
+    end
+
+    def self.build(attrs) = new(attrs)
+
+    def paid?
+      status == :paid unless draft
+    end
+
+    private
+
+    def recalculate
+      while dirty do
+        compute
+      end
+    end
+  end
+end
+
+def helper; end
+`,
+			expected: []string{
+				"module Billing:1 1-31 exported",
+				"const Billing.VERSION:2 2-2 exported",
+				"class Billing.Invoice:4 4-30 exported",
+				"field Invoice.total:5 5-5 exported",
+				"field Invoice.items:5 5-5 exported",
+				"method Invoice.initialize:7 7-15 exported",
+				"method Invoice.build:17 17-17 exported",
+				"method Invoice.paid?:19 19-21 exported",
+				"method Invoice.recalculate:25 25-29",
+				"function helper:33 33-33 exported",
+			},
+		},
+		{
+			name: "visibility by name and singleton classes",
+			src: `
+class Cache
+  class << self
+    def instance
+      @instance ||= new
+    end
+  end
+
+  def fetch(key) = store[key]
+
+  def store
+    @store ||= {}
+  end
+  private :store
+
+=begin
+  def commented; end
+=end
+end
+`,
+			expected: []string{
+				"class Cache:1 1-18 exported",
+				"method Cache.instance:3 3-5 exported",
+				"method Cache.fetch:8 8-8 exported",
+				"method Cache.store:10 10-12",
+			},
+		},
+	})
+}
+
+func TestExtractDeclarations_TypeScript(t *testing.T) {
+	runGrammarCases(t, "typescript", []grammarCase{
+		{
+			name: "functions, classes and exports",
+			src: `
+import { Injectable } from "@angular/core";
+
+export const MAX_ITEMS = 10, DEFAULT_NAME = "x";
+let counter = 0;
+export const handler = async (event: Event): Promise<void> => {
+  console.log("function fake() {}");
+};
+
+export function parse<T>(input: string): T {
+  return JSON.parse(input);
+}
+
+@Injectable({ providedIn: "root" })
+export class UserService extends Base<User> {
+  private readonly cache = new Map<string, User>();
+  static instance?: UserService;
+  #secret = 1;
+
+  constructor(private http: Http) {
+    super();
+  }
+
+  @Memoize()
+  async find(id: string): Promise<User> {
+    return this.cache.get(id);
+  }
+
+  get size() { return this.cache.size; }
+
+  onClick = () => {
+    this.find("1");
+  };
+}
+`,
+			expected: []string{
+				"const MAX_ITEMS:3 3-3 exported",
+				"const DEFAULT_NAME:3 3-3 exported",
+				"var counter:4 4-4",
+				"function handler:5 5-7 exported",
+				"function parse:9 9-11 exported",
+				"class UserService:14 13-33 exported @Injectable",
+				"field UserService.cache:15 15-15",
+				"field UserService.instance:16 16-16 exported",
+				"field UserService.secret:17 17-17",
+				"method UserService.find:24 23-26 exported @Memoize",
+				"method UserService.size:28 28-28 exported",
+				"method UserService.onClick:30 30-32 exported",
+			},
+		},
+		{
+			name: "interfaces, types, enums and namespaces",
+			src: `
+export interface Repository<T> {
+  readonly name: string
+  find(id: string): Promise<T>;
+  save?(item: T): void,
+}
+
+type Handler =
+  | ((event: string) => void)
+  | null;
+
+enum Color { Red, Green }
+
+namespace Utils {
+  export function slugify(s: string) { return s; }
+}
+
+function helper() {}
+export { helper, Color as Colour };
+`,
+			expected: []string{
+				"interface Repository:1 1-5 exported",
+				"field Repository.name:2 2-2 exported",
+				"interface_method Repository.find:3 3-3 exported",
+				"interface_method Repository.save:4 4-4 exported",
+				"type Handler:7 7-9",
+				"type Color:11 11-11 exported",
+				"function Utils.slugify:14 14-14 exported",
+				"function helper:17 17-17 exported",
+			},
+		},
+		{
+			name: "statements without semicolons and template literals",
+			src:  "const a = 1\nconst b = `${a} function fake() {}`\nconst f = function () {}\nlet x = cond\n  ? 1\n  : 2\nvar y\n",
+			expected: []string{
+				"const a:1 1-1",
+				"const b:2 2-2",
+				"function f:3 3-3",
+				"var x:4 4-6",
+				"var y:7 7-7",
+			},
+		},
+	})
+}
+
+func TestExtractDeclarations_JavaScript(t *testing.T) {
+	runGrammarCases(t, "javascript", []grammarCase{
+		{
+			name: "functions and classes",
+			src: `
+function* ids() {}
+class Widget {
+  render() {
+    return <p>Don't render function fake() {}</p>;
+  }
+}
+module.exports = { ids };
+`,
+			expected: []string{
+				"function ids:1 1-1",
+				"class Widget:2 2-6",
+				"method Widget.render:3 3-5 exported",
+			},
+		},
+	})
+}
+
+func TestChangedDeclarations(t *testing.T) {
+	context := func(content string, old, new int) DiffLine {
+		return DiffLine{Type: "context", Content: content, OldLineNo: old, NewLineNo: new}
+	}
+	added := func(content string, new int) DiffLine {
+		return DiffLine{Type: "added", Content: content, NewLineNo: new}
+	}
+	removed := func(content string, old int) DiffLine {
+		return DiffLine{Type: "removed", Content: content, OldLineNo: old}
+	}
+
+	tests := []struct {
+		name     string
+		language string
+		lines    []DiffLine
+		expected []string
+	}{
+		{
+			name:     "renamed Rust function",
+			language: "rust",
+			lines: []DiffLine{
+				removed("pub fn load(path: &str) -> Config {", 20),
+				added("pub fn load_config(path: &str) -> Config {", 20),
+				context("    parse(path)", 21, 21),
+				context("}", 22, 22),
+			},
+			expected: []string{"added function load_config:20", "removed function load:20"},
+		},
+		{
+			name:     "modified Java method signature",
+			language: "java",
+			lines: []DiffLine{
+				context("public class Repo {", 1, 1),
+				removed("    public User find(int id) {", 2),
+				added("    public User find(long id) {", 2),
+				context("        return null;", 3, 3),
+				context("    }", 4, 4),
+			},
+			expected: []string{"modified method Repo.find:2"},
+		},
+		{
+			name:     "body change names no declaration",
+			language: "typescript",
+			lines: []DiffLine{
+				context("export function total(items: Item[]) {", 5, 5),
+				removed("  return 0;", 6),
+				added("  return items.length;", 6),
+				context("}", 7, 7),
+			},
+			expected: nil,
+		},
+		{
+			name:     "languages without a grammar",
+			language: "go",
+			lines:    []DiffLine{added("func New() {}", 1)},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, change := range ChangedDeclarations(tt.language, tt.lines) {
+				got = append(got, fmt.Sprintf("%s %s %s:%d", change.Change, change.Kind, change.QualifiedName(), change.Line))
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDeletedDeclarations_Lexed(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     FileDiff
+		base     string
+		head     string
+		expected []string
+	}{
+		{
+			name:     "python method removed",
+			file:     FileDiff{Filename: "store.py", Language: "python", Hunks: []DiffHunk{{Lines: []DiffLine{{Type: "removed", OldLineNo: 4}, {Type: "removed", OldLineNo: 5}}}}},
+			base:     "class Store:\n    def save(self):\n        pass\n    def close(self):\n        pass\n",
+			head:     "class Store:\n    def save(self):\n        pass\n",
+			expected: []string{"Store.close"},
+		},
+		{
+			name:     "ruby method moved between classes",
+			file:     FileDiff{Filename: "app.rb", Language: "ruby", Hunks: []DiffHunk{{Lines: []DiffLine{{Type: "removed", OldLineNo: 2}}}}},
+			base:     "class A\n  def run; end\nend\n",
+			head:     "class A\nend\nclass B\n  def run; end\nend\n",
+			expected: []string{"A.run"},
+		},
+		{
+			name:     "rust function kept after a signature change",
+			file:     FileDiff{Filename: "lib.rs", Language: "rust", Hunks: []DiffHunk{{Lines: []DiffLine{{Type: "removed", OldLineNo: 1}}}}},
+			base:     "pub fn run(a: u8) {}\n",
+			head:     "pub fn run(a: u16) {}\n",
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			declarations, ok := DeletedDeclarations(tt.file, []byte(tt.base), []byte(tt.head))
+			if !ok {
+				t.Fatal("expected the file to be analyzed")
+			}
+			names := []string{}
+			for _, declaration := range declarations {
+				names = append(names, declaration.QualifiedName())
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
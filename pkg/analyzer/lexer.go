@@ -0,0 +1,325 @@
+package analyzer
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// tokenKind classifies the tokens declaration grammars look at
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenPunct
+	tokenString
+	tokenNumber
+)
+
+// lexeme is a lexed identifier, punctuation, string or number; comments and whitespace are dropped
+type lexeme struct {
+	kind   tokenKind
+	text   string // The identifier or punctuation; the unquoted content of a string
+	line   int
+	indent int  // Column of the first token on the line
+	first  bool // First token on its line
+}
+
+// lexerSyntax describes the comments and literals of a language, so declarations are never
+// found inside them
+type lexerSyntax struct {
+	lineComments      []string
+	blockComments     [][2]string
+	quotes            string // Characters that delimit strings
+	tripleQuotes      bool   // """ and ''' strings (Python, Java text blocks)
+	multilineStrings  bool   // Quoted strings may span lines
+	interpolation     string // Opens an expression inside a string, e.g. "${"
+	interpolated      string // Quote characters whose strings interpolate expressions
+	rustChars         bool   // ' starts a char literal only when closed, otherwise a lifetime
+	rubyBlockComments bool   // =begin ... =end
+	identSuffixes     string // Characters that may end an identifier, e.g. "?!" in Ruby
+	identChars        string // Characters beyond letters, digits and _ allowed in identifiers
+}
+
+// multiCharPunct lists the operators lexed as one token, longest first
+var multiCharPunct = []string{
+	"===", "!==", "**=", "...",
+	"=>", "::", "->", "==", "!=", "<=", ">=", "+=", "-=", "*=", "/=", "%=", "|=", "&=", "^=",
+	"**", "&&", "||", "<<", ">>", "?.", "??",
+}
+
+// lex splits source into tokens
+func lex(src []byte, syntax lexerSyntax) []lexeme {
+	l := &lexer{src: string(src), syntax: syntax, line: 1, lineStart: true}
+	l.run()
+	return l.tokens
+}
+
+type lexer struct {
+	src    string
+	syntax lexerSyntax
+	pos    int
+	line   int
+	column int
+
+	lineStart bool // No token on the current line yet
+	indent    int
+	tokens    []lexeme
+}
+
+func (l *lexer) run() {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.newline()
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			l.pos++
+			l.column++
+		case c == '\\' && strings.HasPrefix(l.src[l.pos+1:], "\n"):
+			// Line continuation
+			l.pos += 2
+			l.line++
+			l.column = 0
+		case l.skipComment():
+		case l.syntax.tripleQuotes && (strings.HasPrefix(l.src[l.pos:], `"""`) || strings.HasPrefix(l.src[l.pos:], "'''")):
+			l.tripleQuoted(l.src[l.pos : l.pos+3])
+		case c == '\'' && l.syntax.rustChars:
+			l.rustQuote()
+		case strings.IndexByte(l.syntax.quotes, c) >= 0:
+			l.quoted(c)
+		case isIdentStart(c) || strings.IndexByte(l.syntax.identChars, c) >= 0:
+			l.identifier()
+		case c >= '0' && c <= '9':
+			start := l.pos
+			for l.pos < len(l.src) && (isIdentPart(l.src[l.pos]) || l.src[l.pos] == '.') {
+				l.pos++
+			}
+			l.emit(tokenNumber, l.src[start:l.pos], l.line, l.pos-start)
+		default:
+			l.punct()
+		}
+	}
+}
+
+func (l *lexer) newline() {
+	l.line++
+	l.column = 0
+	l.lineStart = true
+}
+
+// emit adds a token that started on line and is width bytes wide
+func (l *lexer) emit(kind tokenKind, text string, line, width int) {
+	first := l.lineStart
+	if first {
+		l.indent = l.column
+		l.lineStart = false
+	}
+	l.tokens = append(l.tokens, lexeme{kind: kind, text: text, line: line, indent: l.indent, first: first})
+	l.column += width
+}
+
+// skipComment skips a comment at the current position and reports whether there was one
+func (l *lexer) skipComment() bool {
+	rest := l.src[l.pos:]
+	if l.syntax.rubyBlockComments && l.lineStart && l.column == 0 && strings.HasPrefix(rest, "=begin") {
+		// The comment ends with the =end line
+		end := len(rest)
+		if closing := strings.Index(rest, "\n=end"); closing >= 0 {
+			if eol := strings.IndexByte(rest[closing+1:], '\n'); eol >= 0 {
+				end = closing + 1 + eol
+			}
+		}
+		l.advance(end)
+		return true
+	}
+	for _, prefix := range l.syntax.lineComments {
+		if strings.HasPrefix(rest, prefix) {
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			l.pos += end
+			return true
+		}
+	}
+	for _, delimiters := range l.syntax.blockComments {
+		if strings.HasPrefix(rest, delimiters[0]) {
+			end := strings.Index(rest[len(delimiters[0]):], delimiters[1])
+			if end < 0 {
+				l.advance(len(rest))
+			} else {
+				l.advance(len(delimiters[0]) + end + len(delimiters[1]))
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// advance skips n bytes, counting the lines they span
+func (l *lexer) advance(n int) {
+	skipped := l.src[l.pos : l.pos+n]
+	if lines := strings.Count(skipped, "\n"); lines > 0 {
+		l.line += lines
+		l.column = len(skipped) - strings.LastIndexByte(skipped, '\n') - 1
+		l.lineStart = true
+	} else {
+		l.column += n
+	}
+	l.pos += n
+}
+
+func (l *lexer) tripleQuoted(delimiter string) {
+	line := l.line
+	end := strings.Index(l.src[l.pos+3:], delimiter)
+	content := ""
+	if end < 0 {
+		content = l.src[l.pos+3:]
+		end = len(l.src) - l.pos
+	} else {
+		content = l.src[l.pos+3 : l.pos+3+end]
+		end += 6
+	}
+	l.emitString(content, line)
+	l.advance(end)
+}
+
+// quoted lexes a string delimited by quote, skipping escapes and interpolated expressions
+func (l *lexer) quoted(quote byte) {
+	line := l.line
+	i := l.pos + 1
+	interpolates := l.syntax.interpolation != "" && strings.IndexByte(l.syntax.interpolated, quote) >= 0
+	for i < len(l.src) {
+		c := l.src[i]
+		if c == '\\' {
+			i += 2
+			continue
+		}
+		if c == quote {
+			break
+		}
+		if c == '\n' && !l.syntax.multilineStrings && quote != '`' {
+			// An unterminated string ends at the line, so an apostrophe in prose cannot swallow code
+			break
+		}
+		if interpolates && strings.HasPrefix(l.src[i:], l.syntax.interpolation) {
+			i = skipInterpolation(l.src, i+len(l.syntax.interpolation))
+			continue
+		}
+		i++
+	}
+	end := min(i, len(l.src))
+	l.emitString(l.src[l.pos+1:end], line)
+	if end < len(l.src) && l.src[end] == quote {
+		end++
+	}
+	l.advance(end - l.pos)
+}
+
+// skipInterpolation returns the position after the } closing an interpolated expression
+func skipInterpolation(src string, i int) int {
+	depth := 1
+	for i < len(src) {
+		switch src[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '"', '\'', '`':
+			quote := src[i]
+			for i++; i < len(src) && src[i] != quote && src[i] != '\n'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+		}
+		i++
+	}
+	return i
+}
+
+// rustQuote lexes a char literal, or a lifetime such as 'a as punctuation and identifier
+func (l *lexer) rustQuote() {
+	rest := l.src[l.pos+1:]
+	if strings.HasPrefix(rest, "\\") {
+		l.quoted('\'')
+		return
+	}
+	if _, size := utf8.DecodeRuneInString(rest); size > 0 && strings.HasPrefix(rest[size:], "'") {
+		l.emitString(rest[:size], l.line)
+		l.advance(size + 2)
+		return
+	}
+	l.emit(tokenPunct, "'", l.line, 1)
+	l.pos++
+}
+
+// emitString adds a string token; the caller advances past the literal
+func (l *lexer) emitString(content string, line int) {
+	l.emit(tokenString, content, line, 0)
+}
+
+func (l *lexer) identifier() {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.src) && (isIdentPart(l.src[l.pos]) || strings.IndexByte(l.syntax.identChars, l.src[l.pos]) >= 0) {
+		l.pos++
+	}
+	if l.pos < len(l.src) && strings.IndexByte(l.syntax.identSuffixes, l.src[l.pos]) >= 0 &&
+		!strings.HasPrefix(l.src[l.pos+1:], "=") {
+		l.pos++
+	}
+	text := l.src[start:l.pos]
+
+	// Rust raw strings: r"...", r#"..."#, br"..."
+	if l.syntax.rustChars && (text == "r" || text == "br") && l.pos < len(l.src) && (l.src[l.pos] == '"' || l.src[l.pos] == '#') {
+		hashes := 0
+		for l.pos+hashes < len(l.src) && l.src[l.pos+hashes] == '#' {
+			hashes++
+		}
+		if l.pos+hashes < len(l.src) && l.src[l.pos+hashes] == '"' {
+			closing := "\"" + strings.Repeat("#", hashes)
+			contentStart := l.pos + hashes + 1
+			end := strings.Index(l.src[contentStart:], closing)
+			if end < 0 {
+				end = len(l.src) - contentStart
+			}
+			line := l.line
+			l.pos = start
+			l.emitString(l.src[contentStart:contentStart+end], line)
+			l.advance(min(contentStart+end+len(closing), len(l.src)) - start)
+			return
+		}
+	}
+
+	l.emit(tokenIdent, text, l.line, l.pos-start)
+}
+
+func (l *lexer) punct() {
+	rest := l.src[l.pos:]
+	for _, op := range multiCharPunct {
+		if strings.HasPrefix(rest, op) {
+			l.emit(tokenPunct, op, l.line, len(op))
+			l.pos += len(op)
+			return
+		}
+	}
+	_, size := utf8.DecodeRuneInString(rest)
+	if size < 1 {
+		size = 1
+	}
+	l.emit(tokenPunct, rest[:size], l.line, size)
+	l.pos += size
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9'
+}
@@ -0,0 +1,191 @@
+package analyzer
+
+import (
+	"strings"
+	"sync"
+)
+
+// SymbolReference is a line of code using an identifier
+type SymbolReference struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Qualified bool   `json:"qualified,omitempty"` // Used through a selector, as in x.Name or Type::Name
+}
+
+// SymbolIndex is an inverted index from identifiers to the lines using them, built once per
+// workspace. Files are lexed for their language, so identifiers in comments and strings are not
+// indexed, and files that are not code, such as documentation and configuration, are skipped.
+type SymbolIndex struct {
+	references map[string][]SymbolReference
+	lines      map[string][]string
+	languages  map[string]string
+	files      []string
+
+	extractOnce  sync.Once
+	declarations map[string][]Declaration
+	definitions  map[string][]SymbolDefinition
+}
+
+// maxSignatureLines bounds the lines joined into a signature whose parameters span several lines
+const maxSignatureLines = 6
+
+// qualifiers are the selectors through which members are used
+var qualifiers = map[string]bool{".": true, "?.": true, "::": true, "->": true}
+
+var (
+	goSyntax = lexerSyntax{
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        "\"'`",
+	}
+	cStyleSyntax = lexerSyntax{
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+	}
+)
+
+// indexSyntax returns the syntax used to lex files of a language for the index
+func indexSyntax(language string) (lexerSyntax, bool) {
+	if g, ok := grammars[language]; ok {
+		return g.syntax, true
+	}
+	switch language {
+	case "go":
+		return goSyntax, true
+	case "c", "cpp", "csharp", "kotlin", "swift", "scala":
+		return cStyleSyntax, true
+	case "php":
+		return lexerSyntax{lineComments: []string{"//", "#"}, blockComments: cStyleSyntax.blockComments, quotes: `"'`}, true
+	case "bash":
+		return lexerSyntax{lineComments: []string{"#"}, quotes: `"'`}, true
+	}
+	return lexerSyntax{}, false
+}
+
+// NewSymbolIndex indexes the identifiers of files
+func NewSymbolIndex(files []FileContent) *SymbolIndex {
+	index := &SymbolIndex{
+		references: make(map[string][]SymbolReference),
+		lines:      make(map[string][]string),
+		languages:  make(map[string]string),
+	}
+	for _, file := range files {
+		index.add(file)
+	}
+	return index
+}
+
+func (ix *SymbolIndex) add(file FileContent) {
+	syntax, ok := indexSyntax(file.Language)
+	if !ok {
+		return
+	}
+	ix.lines[file.RelativePath] = strings.Split(file.Content, "\n")
+	ix.languages[file.RelativePath] = file.Language
+	ix.files = append(ix.files, file.RelativePath)
+
+	tokens := lex([]byte(file.Content), syntax)
+	for i, t := range tokens {
+		if t.kind != tokenIdent {
+			continue
+		}
+		qualified := i > 0 && tokens[i-1].kind == tokenPunct && qualifiers[tokens[i-1].text]
+
+		// One reference per line, qualified when any use on it is
+		references := ix.references[t.text]
+		if n := len(references); n > 0 && references[n-1].File == file.RelativePath && references[n-1].Line == t.line {
+			references[n-1].Qualified = references[n-1].Qualified || qualified
+			continue
+		}
+		ix.references[t.text] = append(references, SymbolReference{File: file.RelativePath, Line: t.line, Qualified: qualified})
+	}
+}
+
+// Files returns the number of indexed files
+func (ix *SymbolIndex) Files() int {
+	return len(ix.lines)
+}
+
+// References returns the lines using an identifier, ordered by file and line
+func (ix *SymbolIndex) References(name string) []SymbolReference {
+	return ix.references[name]
+}
+
+// ReferencesTo returns the lines that may refer to a declaration: those using its name, and for
+// members only those using it through a selector, as in value.Name. The declaring file is included.
+func (ix *SymbolIndex) ReferencesTo(declaration Declaration) []SymbolReference {
+	references := ix.references[declaration.Name]
+	if !declaration.IsMember() {
+		return references
+	}
+	var qualified []SymbolReference
+	for _, reference := range references {
+		if reference.Qualified {
+			qualified = append(qualified, reference)
+		}
+	}
+	return qualified
+}
+
+// Line returns a line of an indexed file, or "" when there is none
+func (ix *SymbolIndex) Line(file string, line int) string {
+	lines := ix.lines[file]
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return lines[line-1]
+}
+
+// SymbolDefinition is a declaration of an indexed file
+type SymbolDefinition struct {
+	File        string      `json:"file"`
+	Declaration Declaration `json:"declaration"`
+}
+
+// Declarations returns the declarations of an indexed file. Declarations are extracted from every
+// indexed file with an extractor the first time they are needed.
+func (ix *SymbolIndex) Declarations(file string) []Declaration {
+	ix.extractOnce.Do(ix.extract)
+	return ix.declarations[file]
+}
+
+// Definitions returns the declarations named name, ordered by file and line
+func (ix *SymbolIndex) Definitions(name string) []SymbolDefinition {
+	ix.extractOnce.Do(ix.extract)
+	return ix.definitions[name]
+}
+
+func (ix *SymbolIndex) extract() {
+	ix.declarations = make(map[string][]Declaration)
+	ix.definitions = make(map[string][]SymbolDefinition)
+	for _, file := range ix.files {
+		declarations, ok := ExtractDeclarations(ix.languages[file], file, []byte(strings.Join(ix.lines[file], "\n")))
+		if !ok {
+			continue
+		}
+		ix.declarations[file] = declarations
+		for _, declaration := range declarations {
+			ix.definitions[declaration.Name] = append(ix.definitions[declaration.Name],
+				SymbolDefinition{File: file, Declaration: declaration})
+		}
+	}
+}
+
+// Signature returns the source of a definition from its name to the end of its parameter list,
+// joined onto one line, e.g. "func (s *Store) Get(id string) Record"
+func (ix *SymbolIndex) Signature(definition SymbolDefinition) string {
+	var parts []string
+	depth := 0
+	for line := definition.Declaration.Line; line <= definition.Declaration.Line+maxSignatureLines-1; line++ {
+		text := strings.TrimSpace(ix.Line(definition.File, line))
+		parts = append(parts, text)
+		depth += strings.Count(text, "(") - strings.Count(text, ")")
+		if depth <= 0 {
+			break
+		}
+	}
+	signature := strings.Join(parts, " ")
+	signature = strings.TrimSpace(strings.TrimSuffix(signature, "{"))
+	return signature
+}
@@ -0,0 +1,123 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+var indexedFiles = []FileContent{
+	{
+		RelativePath: "store/store.go",
+		Language:     "go",
+		Content: "package store\n\n// Get returns a record\nfunc (s *Store) Get(id string) Record {\n" +
+			"\treturn s.GetAll()[0] // Get the first\n}\n",
+	},
+	{
+		RelativePath: "cmd/main.go",
+		Language:     "go",
+		Content:      "package main\n\nfunc main() {\n\tlog.Println(\"Get failed\")\n\tstore.Get(\"a\"); Get()\n\traw := `\nGet\n`\n}\n",
+	},
+	{
+		RelativePath: "app/client.py",
+		Language:     "python",
+		Content:      "# Get is deprecated\nvalue = client.Get()\n\"\"\"\nGet\n\"\"\"\nGet = 1\n",
+	},
+	{
+		RelativePath: "README.md",
+		Language:     "markdown",
+		Content:      "Call Get to fetch a record\n",
+	},
+}
+
+func TestSymbolIndex_References(t *testing.T) {
+	index := NewSymbolIndex(indexedFiles)
+
+	tests := []struct {
+		name     string
+		lookup   func() []SymbolReference
+		expected []SymbolReference
+	}{
+		{
+			name:   "whole identifiers outside comments and strings",
+			lookup: func() []SymbolReference { return index.References("Get") },
+			expected: []SymbolReference{
+				{File: "store/store.go", Line: 4},
+				{File: "cmd/main.go", Line: 5, Qualified: true},
+				{File: "app/client.py", Line: 2, Qualified: true},
+				{File: "app/client.py", Line: 6},
+			},
+		},
+		{
+			name:     "longer identifiers do not match",
+			lookup:   func() []SymbolReference { return index.References("GetAll") },
+			expected: []SymbolReference{{File: "store/store.go", Line: 5, Qualified: true}},
+		},
+		{
+			name: "members are only referenced through selectors",
+			lookup: func() []SymbolReference {
+				return index.ReferencesTo(Declaration{Name: "Get", Kind: KindMethod, Parent: "Store"})
+			},
+			expected: []SymbolReference{
+				{File: "cmd/main.go", Line: 5, Qualified: true},
+				{File: "app/client.py", Line: 2, Qualified: true},
+			},
+		},
+		{
+			name:     "unknown identifiers",
+			lookup:   func() []SymbolReference { return index.References("Missing") },
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.lookup(); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	if index.Files() != 3 {
+		t.Errorf("expected the markdown file not to be indexed, got %d files", index.Files())
+	}
+	if line := index.Line("cmd/main.go", 5); line != "\tstore.Get(\"a\"); Get()" {
+		t.Errorf("unexpected line %q", line)
+	}
+	if line := index.Line("cmd/main.go", 100); line != "" {
+		t.Errorf("expected no line past the end, got %q", line)
+	}
+}
+
+func TestFlattenedCodebase_SymbolIndex(t *testing.T) {
+	codebase := &FlattenedCodebase{Files: indexedFiles}
+	index := codebase.SymbolIndex()
+	if codebase.SymbolIndex() != index {
+		t.Error("expected the index to be built once")
+	}
+}
+
+func TestAnnotateImpact(t *testing.T) {
+	diff := &ContextualDiff{
+		FilesWithContext: []FileWithContext{{
+			FileDiff: FileDiff{Filename: "store/store.go"},
+			ContextBlocks: []ContextBlock{{
+				Declarations: []DeclarationChange{
+					{Declaration: Declaration{Name: "Get", Kind: KindMethod, Parent: "Store"}, Change: "modified"},
+					{Declaration: Declaration{Name: "GetAll", Kind: KindMethod, Parent: "Store"}, Change: "removed"},
+					{Declaration: Declaration{Name: "Get", Kind: KindFunction}, Change: "added"},
+				},
+			}},
+		}},
+	}
+	AnnotateImpact(diff, NewSymbolIndex(indexedFiles))
+
+	var got [][2]int
+	for _, change := range diff.FilesWithContext[0].ContextBlocks[0].Declarations {
+		got = append(got, [2]int{change.References, change.ReferencingFiles})
+	}
+	// GetAll is only used in its own file, and added declarations have no uses yet
+	expected := [][2]int{{2, 2}, {0, 0}, {0, 0}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,141 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// tokensPerChar approximates the tokenizer: 1 token ≈ 4 characters
+const tokensPerChar = 0.25
+
+// fileTokenOverhead covers the per-file headers added to the review prompt
+const fileTokenOverhead = 30
+
+// languageRisk weights files by how likely their changes are to hide real defects
+var languageRisk = map[string]float64{
+	"go": 2, "javascript": 2, "typescript": 2, "python": 2, "java": 2, "c": 2, "cpp": 2,
+	"rust": 2, "ruby": 2, "php": 2, "csharp": 2, "swift": 2, "kotlin": 2, "scala": 2,
+	"bash": 2, "sql": 2,
+	"yaml": 1, "json": 1, "xml": 1,
+	"html": 0.5, "css": 0.5, "scss": 0.5, "sass": 0.5,
+	"markdown": -1, "plaintext": -1,
+}
+
+// securityPathPattern matches path segments that usually hold security-sensitive code
+var securityPathPattern = regexp.MustCompile(`(?i)(auth|login|session|token|crypt|secret|passw|credential|permission|acl|oauth|jwt|saml|sql|query|migration|payment|billing|security|sanitiz|csrf)`)
+
+// testPathPattern matches test files and directories across common conventions
+var testPathPattern = regexp.MustCompile(`(?i)(_test\.go$|(^|/)test_[^/]*\.py$|_test\.py$|\.(spec|test)\.[jt]sx?$|(^|/)(tests?|__tests__|spec)/|Test\.java$|_spec\.rb$)`)
+
+// FileRisk is the triage score of a changed file
+type FileRisk struct {
+	Filename        string   `json:"filename"`
+	Score           float64  `json:"score"`
+	Reasons         []string `json:"reasons,omitempty"`
+	EstimatedTokens int      `json:"estimated_tokens"`
+}
+
+// ScoreFileRisk scores a changed file by language, churn, security-sensitive paths,
+// test vs. source and generated status. Higher scores deserve a closer review.
+func ScoreFileRisk(file FileDiff) FileRisk {
+	risk := FileRisk{Filename: file.Filename}
+
+	language := file.Language
+	if language == "" {
+		language = detectLanguage(file.Filename)
+	}
+	weight, known := languageRisk[language]
+	if !known {
+		weight = 1
+	}
+	score := 1 + weight
+
+	churn := file.Additions + file.Deletions
+	score += math.Min(math.Log2(float64(churn)+1), 8)
+	if churn >= 100 {
+		risk.Reasons = append(risk.Reasons, fmt.Sprintf("%d changed lines", churn))
+	}
+
+	if match := securityPathPattern.FindString(file.Filename); match != "" {
+		score += 5
+		risk.Reasons = append(risk.Reasons, fmt.Sprintf("security-sensitive path (%s)", strings.ToLower(match)))
+	}
+
+	if testPathPattern.MatchString(file.Filename) {
+		score -= 2
+		risk.Reasons = append(risk.Reasons, "test file")
+	}
+	if file.Status == "deleted" {
+		score *= 0.5
+		risk.Reasons = append(risk.Reasons, "deleted file")
+	}
+	if file.Classification != ClassificationNone {
+		score = 0
+		risk.Reasons = append(risk.Reasons, string(file.Classification))
+	}
+
+	risk.Score = math.Round(score*100) / 100
+	return risk
+}
+
+// EstimateFileTokens approximates the prompt tokens needed to review a file
+func EstimateFileTokens(file FileWithContext) int {
+	chars := len(file.Filename)
+	if len(file.ContextBlocks) > 0 {
+		for _, block := range file.ContextBlocks {
+			chars += len(block.Description)
+			for _, line := range block.Lines {
+				chars += len(line.Content) + 2
+			}
+		}
+	} else {
+		for _, hunk := range file.Hunks {
+			for _, line := range hunk.Lines {
+				chars += len(line.Content) + 2
+			}
+		}
+	}
+	return fileTokenOverhead + int(float64(chars)*tokensPerChar)
+}
+
+// TriageFiles orders files from highest to lowest risk and keeps as many as fit in the
+// token budget (0 means unlimited). The riskiest file is always kept so a review never
+// comes back empty. Returns the kept files and the risk of every file that did not fit.
+func TriageFiles(files []FileWithContext, tokenBudget int) ([]FileWithContext, []FileRisk) {
+	type scoredFile struct {
+		file FileWithContext
+		risk FileRisk
+	}
+
+	scored := make([]scoredFile, 0, len(files))
+	for _, file := range files {
+		risk := ScoreFileRisk(file.FileDiff)
+		risk.EstimatedTokens = EstimateFileTokens(file)
+		file.RiskScore = risk.Score
+		file.RiskReasons = risk.Reasons
+		scored = append(scored, scoredFile{file: file, risk: risk})
+	}
+
+	// Stable so equally risky files keep their diff order
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].risk.Score > scored[j].risk.Score
+	})
+
+	var kept []FileWithContext
+	var dropped []FileRisk
+	used := 0
+	for _, candidate := range scored {
+		fits := tokenBudget <= 0 || used+candidate.risk.EstimatedTokens <= tokenBudget
+		if fits || len(kept) == 0 {
+			kept = append(kept, candidate.file)
+			used += candidate.risk.EstimatedTokens
+			continue
+		}
+		dropped = append(dropped, candidate.risk)
+	}
+
+	return kept, dropped
+}
@@ -0,0 +1,135 @@
+package analyzer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScoreFileRisk(t *testing.T) {
+	tests := []struct {
+		name           string
+		higher         FileDiff
+		lower          FileDiff
+		expectedReason string
+	}{
+		{
+			name:           "security-sensitive path outranks ordinary source",
+			higher:         FileDiff{Filename: "internal/auth/session.go", Additions: 10},
+			lower:          FileDiff{Filename: "internal/render/page.go", Additions: 10},
+			expectedReason: "security-sensitive path (auth)",
+		},
+		{
+			name:   "source outranks its test",
+			higher: FileDiff{Filename: "pkg/cache/cache.go", Additions: 20},
+			lower:  FileDiff{Filename: "pkg/cache/cache_test.go", Additions: 20},
+		},
+		{
+			name:           "high churn outranks small change",
+			higher:         FileDiff{Filename: "pkg/cache/store.go", Additions: 150, Deletions: 50},
+			lower:          FileDiff{Filename: "pkg/cache/keys.go", Additions: 2},
+			expectedReason: "200 changed lines",
+		},
+		{
+			name:   "code outranks docs",
+			higher: FileDiff{Filename: "main.py", Additions: 5},
+			lower:  FileDiff{Filename: "README.md", Additions: 5},
+		},
+		{
+			name:   "hand-written outranks generated",
+			higher: FileDiff{Filename: "api/client.go", Additions: 5},
+			lower:  FileDiff{Filename: "api/client.pb.go", Additions: 5, Classification: ClassificationGenerated},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			higher, lower := ScoreFileRisk(tt.higher), ScoreFileRisk(tt.lower)
+			if higher.Score <= lower.Score {
+				t.Errorf("expected %s (%.2f) to score above %s (%.2f)",
+					tt.higher.Filename, higher.Score, tt.lower.Filename, lower.Score)
+			}
+			if tt.expectedReason != "" && !reflect.DeepEqual(higher.Reasons, []string{tt.expectedReason}) {
+				t.Errorf("expected reasons [%s], got %v", tt.expectedReason, higher.Reasons)
+			}
+		})
+	}
+}
+
+func fileWithLines(filename string, additions, lineLength int) FileWithContext {
+	block := ContextBlock{StartLine: 1, EndLine: additions}
+	for i := 0; i < additions; i++ {
+		block.Lines = append(block.Lines, DiffLine{Type: "added", Content: strings.Repeat("x", lineLength)})
+	}
+	return FileWithContext{
+		FileDiff:      FileDiff{Filename: filename, Additions: additions},
+		ContextBlocks: []ContextBlock{block},
+	}
+}
+
+func TestTriageFiles(t *testing.T) {
+	files := []FileWithContext{
+		fileWithLines("docs/guide.md", 10, 40),
+		fileWithLines("pkg/auth/login.go", 10, 40),
+		fileWithLines("pkg/util/strings.go", 10, 40),
+		fileWithLines("pkg/util/strings_test.go", 10, 40),
+	}
+	estimates := make(map[string]int)
+	for _, file := range files {
+		estimates[file.Filename] = EstimateFileTokens(file)
+	}
+
+	tests := []struct {
+		name            string
+		budget          int
+		expectedKept    []string
+		expectedDropped []string
+	}{
+		{
+			name:         "unlimited budget keeps everything ordered by risk",
+			budget:       0,
+			expectedKept: []string{"pkg/auth/login.go", "pkg/util/strings.go", "pkg/util/strings_test.go", "docs/guide.md"},
+		},
+		{
+			name:            "budget keeps the riskiest files",
+			budget:          estimates["pkg/auth/login.go"] + estimates["pkg/util/strings.go"],
+			expectedKept:    []string{"pkg/auth/login.go", "pkg/util/strings.go"},
+			expectedDropped: []string{"pkg/util/strings_test.go", "docs/guide.md"},
+		},
+		{
+			name:            "riskiest file is kept even when it exceeds the budget",
+			budget:          1,
+			expectedKept:    []string{"pkg/auth/login.go"},
+			expectedDropped: []string{"pkg/util/strings.go", "pkg/util/strings_test.go", "docs/guide.md"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, dropped := TriageFiles(files, tt.budget)
+
+			var keptNames, droppedNames []string
+			for _, file := range kept {
+				keptNames = append(keptNames, file.Filename)
+			}
+			for _, risk := range dropped {
+				droppedNames = append(droppedNames, risk.Filename)
+				if risk.EstimatedTokens != estimates[risk.Filename] {
+					t.Errorf("expected %d estimated tokens for %s, got %d", estimates[risk.Filename], risk.Filename, risk.EstimatedTokens)
+				}
+			}
+
+			if !reflect.DeepEqual(keptNames, tt.expectedKept) {
+				t.Errorf("expected kept %v, got %v", tt.expectedKept, keptNames)
+			}
+			if !reflect.DeepEqual(droppedNames, tt.expectedDropped) {
+				t.Errorf("expected dropped %v, got %v", tt.expectedDropped, droppedNames)
+			}
+		})
+	}
+
+	kept, _ := TriageFiles(files, 0)
+	if len(kept[0].RiskReasons) == 0 || kept[0].RiskScore == 0 {
+		t.Errorf("expected kept files to carry their risk, got %+v", kept[0])
+	}
+}
@@ -0,0 +1,397 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// devNull is the path of the missing side of an added or deleted file
+const devNull = "/dev/null"
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@(.*)`)
+
+// diffParser reads a unified diff as produced by git, or by diff -u. Hunks are read by the line
+// counts of their headers, so content such as a removed "-- comment" line is not taken for a file
+// header; lines past the counts are still read, since hand-written diffs often miscount.
+type diffParser struct {
+	lines []string
+	files []FileDiff
+
+	file    *FileDiff
+	git     bool   // The file started with "diff --git", so extended headers follow it
+	oldPath string // Path of the file before the change
+
+	hunk             *DiffHunk
+	oldLine, newLine int // Line numbers of the next hunk line
+	oldLeft, newLeft int // Lines of each side the hunk header has yet to account for
+}
+
+// parseUnifiedDiff splits a unified diff into its files
+func parseUnifiedDiff(rawDiff string) ([]FileDiff, error) {
+	p := &diffParser{lines: strings.Split(strings.TrimSuffix(rawDiff, "\n"), "\n")}
+
+	for i := 0; i < len(p.lines); i++ {
+		line := p.lines[i]
+
+		if p.hunk != nil {
+			if strings.HasPrefix(line, `\`) {
+				// "\ No newline at end of file" describes the line before it
+				if n := len(p.hunk.Lines); n > 0 {
+					p.hunk.Lines[n-1].NoNewline = true
+				}
+				continue
+			}
+			if (p.oldLeft > 0 || p.newLeft > 0) && p.content(line) {
+				continue
+			}
+		}
+
+		var err error
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			err = p.startGitFile(line)
+		case strings.HasPrefix(line, "diff --cc ") || strings.HasPrefix(line, "diff --combined "):
+			err = fmt.Errorf("combined diffs are not supported")
+		case strings.HasPrefix(line, "--- ") && i+1 < len(p.lines) && strings.HasPrefix(p.lines[i+1], "+++ ") && p.hunk == nil:
+			p.fileHeader(line, p.lines[i+1])
+			i++
+		case strings.HasPrefix(line, "--- ") && i+1 < len(p.lines) && strings.HasPrefix(p.lines[i+1], "+++ ") && !p.git:
+			// The next file of a diff without "diff --git" lines
+			p.endFile()
+			p.fileHeader(line, p.lines[i+1])
+			i++
+		case strings.HasPrefix(line, "@@ "):
+			err = p.startHunk(line)
+		case p.file != nil && p.git && p.hunk == nil:
+			err = p.extendedHeader(line)
+		case p.hunk != nil:
+			p.content(line)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+	p.endFile()
+
+	return p.files, nil
+}
+
+// startGitFile starts a file at its "diff --git a/old b/new" line
+func (p *diffParser) startGitFile(line string) error {
+	p.endFile()
+
+	oldPath, newPath, err := parseGitDiffPaths(line)
+	if err != nil {
+		return err
+	}
+	p.file = &FileDiff{Filename: newPath, Status: "modified", Hunks: []DiffHunk{}}
+	p.git = true
+	p.oldPath = oldPath
+	return nil
+}
+
+// fileHeader reads the "--- old" and "+++ new" lines, which start a file in a diff without
+// "diff --git" lines
+func (p *diffParser) fileHeader(oldLine, newLine string) {
+	if p.file == nil {
+		p.file = &FileDiff{Status: "modified", Hunks: []DiffHunk{}}
+		p.git = false
+	}
+
+	oldValue, newValue := strings.TrimPrefix(oldLine, "--- "), strings.TrimPrefix(newLine, "+++ ")
+	oldPath, newPath := headerPath(oldValue, "a/"), headerPath(newValue, "b/")
+	switch {
+	case oldPath == devNull || !p.git && isEpochTimestamp(oldValue):
+		p.file.Status = "added"
+		oldPath = devNull
+	case newPath == devNull || !p.git && isEpochTimestamp(newValue):
+		p.file.Status = "deleted"
+		newPath = devNull
+	}
+	// Unlike the "diff --git" line, these paths are unambiguous
+	if oldPath != devNull {
+		p.oldPath = oldPath
+	}
+	if newPath != devNull {
+		p.file.Filename = newPath
+	} else {
+		p.file.Filename = p.oldPath
+	}
+}
+
+// extendedHeader reads a git extended header line between "diff --git" and the first hunk
+func (p *diffParser) extendedHeader(line string) error {
+	key, value, _ := strings.Cut(line, " ")
+	switch {
+	case strings.HasPrefix(line, "old mode "):
+		p.file.OldMode = strings.TrimPrefix(line, "old mode ")
+	case strings.HasPrefix(line, "new mode "):
+		p.file.NewMode = strings.TrimPrefix(line, "new mode ")
+	case strings.HasPrefix(line, "new file mode "):
+		p.file.Status = "added"
+		p.file.NewMode = strings.TrimPrefix(line, "new file mode ")
+	case strings.HasPrefix(line, "deleted file mode "):
+		p.file.Status = "deleted"
+		p.file.OldMode = strings.TrimPrefix(line, "deleted file mode ")
+	case strings.HasPrefix(line, "rename from "), strings.HasPrefix(line, "rename old "):
+		p.file.Status = "renamed"
+		p.oldPath = extendedHeaderPath(line)
+	case strings.HasPrefix(line, "rename to "), strings.HasPrefix(line, "rename new "):
+		p.file.Status = "renamed"
+		p.file.Filename = extendedHeaderPath(line)
+	case strings.HasPrefix(line, "copy from "):
+		p.file.Status = "copied"
+		p.oldPath = extendedHeaderPath(line)
+	case strings.HasPrefix(line, "copy to "):
+		p.file.Status = "copied"
+		p.file.Filename = extendedHeaderPath(line)
+	case strings.HasPrefix(line, "similarity index "), strings.HasPrefix(line, "dissimilarity index "):
+		percent, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(value, "index "), "%"))
+		if err != nil || percent < 0 || percent > 100 {
+			return fmt.Errorf("invalid %s: %s", key, line)
+		}
+		if key == "similarity" {
+			p.file.Similarity = percent
+		} else {
+			p.file.Dissimilarity = percent
+		}
+	case key == "index":
+		// "index <old>..<new> <mode>" carries the mode of a file whose mode did not change
+		if fields := strings.Fields(value); len(fields) == 2 && p.file.OldMode == "" && p.file.NewMode == "" {
+			p.file.OldMode, p.file.NewMode = fields[1], fields[1]
+		}
+	case strings.HasPrefix(line, "Binary files ") && strings.HasSuffix(line, " differ"), line == "GIT binary patch":
+		// The literal or delta data that follows a binary patch is skipped with the other lines
+		p.file.Binary = true
+	}
+	return nil
+}
+
+// startHunk starts a hunk at its "@@ -old +new @@" header
+func (p *diffParser) startHunk(line string) error {
+	p.endHunk()
+
+	hunk, err := parseHunkHeader(line)
+	if err != nil {
+		return err
+	}
+	p.hunk = hunk
+	p.oldLine, p.newLine = hunk.OldStart, hunk.NewStart
+	p.oldLeft, p.newLeft = hunk.OldCount, hunk.NewCount
+	return nil
+}
+
+// content adds a hunk line, returning false for a line that is not hunk content. An empty line
+// is read as an empty context line while both sides expect more lines, as editors strip the
+// trailing space of one.
+func (p *diffParser) content(line string) bool {
+	var diffLine DiffLine
+	switch {
+	case strings.HasPrefix(line, " ") || line == "" && p.oldLeft > 0 && p.newLeft > 0:
+		diffLine = DiffLine{Type: "context", OldLineNo: p.oldLine, NewLineNo: p.newLine}
+		p.oldLine++
+		p.newLine++
+		p.oldLeft--
+		p.newLeft--
+	case strings.HasPrefix(line, "+"):
+		diffLine = DiffLine{Type: "added", NewLineNo: p.newLine}
+		p.newLine++
+		p.newLeft--
+		if p.file != nil {
+			p.file.Additions++
+		}
+	case strings.HasPrefix(line, "-"):
+		diffLine = DiffLine{Type: "removed", OldLineNo: p.oldLine}
+		p.oldLine++
+		p.oldLeft--
+		if p.file != nil {
+			p.file.Deletions++
+		}
+	default:
+		return false
+	}
+	if line != "" {
+		diffLine.Content = line[1:]
+	}
+	p.hunk.Lines = append(p.hunk.Lines, diffLine)
+	return true
+}
+
+func (p *diffParser) endHunk() {
+	if p.hunk != nil && p.file != nil {
+		p.file.Hunks = append(p.file.Hunks, *p.hunk)
+	}
+	p.hunk = nil
+}
+
+func (p *diffParser) endFile() {
+	p.endHunk()
+	if p.file == nil {
+		return
+	}
+	if p.file.Status == "deleted" || p.file.Filename == "" {
+		p.file.Filename = p.oldPath
+	}
+	if (p.file.Status == "renamed" || p.file.Status == "copied") && p.oldPath != p.file.Filename {
+		p.file.OldFilename = p.oldPath
+	}
+	p.file.Language = detectLanguage(p.file.Filename)
+	p.files = append(p.files, *p.file)
+	p.file = nil
+	p.oldPath = ""
+}
+
+// parseGitDiffPaths returns the old and new paths of a "diff --git a/old b/new" line. Paths with
+// special characters are quoted. Unquoted paths may contain spaces: the line is split in the
+// middle when both paths are the same, and otherwise before " b/"; renames and copies then take
+// their exact paths from the extended headers.
+func parseGitDiffPaths(line string) (string, string, error) {
+	rest := strings.TrimPrefix(line, "diff --git ")
+
+	if strings.HasPrefix(rest, `"`) {
+		oldPath, remaining, err := unquotePath(rest)
+		if err != nil {
+			return "", "", err
+		}
+		remaining = strings.TrimPrefix(remaining, " ")
+		newPath := remaining
+		if strings.HasPrefix(remaining, `"`) {
+			if newPath, _, err = unquotePath(remaining); err != nil {
+				return "", "", err
+			}
+		}
+		if newPath == "" {
+			return "", "", fmt.Errorf("invalid diff header format")
+		}
+		return strings.TrimPrefix(oldPath, "a/"), strings.TrimPrefix(newPath, "b/"), nil
+	}
+
+	if strings.HasSuffix(rest, `"`) {
+		// An unquoted path cannot contain a quote, so the first one starts the new path
+		if j := strings.Index(rest, ` "`); j > 0 {
+			newPath, _, err := unquotePath(rest[j+1:])
+			if err != nil {
+				return "", "", err
+			}
+			return strings.TrimPrefix(rest[:j], "a/"), strings.TrimPrefix(newPath, "b/"), nil
+		}
+	}
+
+	if n := len(rest) / 2; len(rest)%2 == 1 && rest[n] == ' ' {
+		oldPath, newPath := strings.TrimPrefix(rest[:n], "a/"), strings.TrimPrefix(rest[n+1:], "b/")
+		if oldPath == newPath && oldPath != "" {
+			return oldPath, newPath, nil
+		}
+	}
+	if j := strings.Index(rest, " b/"); j > 0 {
+		return strings.TrimPrefix(rest[:j], "a/"), rest[j+3:], nil
+	}
+	if parts := strings.Fields(rest); len(parts) == 2 {
+		return parts[0], parts[1], nil
+	}
+	return "", "", fmt.Errorf("invalid diff header format")
+}
+
+// headerPath returns the path of a "---" or "+++" line without its prefix. Anything after a tab,
+// such as the timestamp of diff -u or the tab git adds after paths with spaces, is dropped.
+func headerPath(value, prefix string) string {
+	if strings.HasPrefix(value, `"`) {
+		if path, _, err := unquotePath(value); err == nil {
+			return strings.TrimPrefix(path, prefix)
+		}
+	}
+	if tab := strings.IndexByte(value, '\t'); tab >= 0 {
+		value = value[:tab]
+	}
+	if value == devNull {
+		return value
+	}
+	return strings.TrimPrefix(value, prefix)
+}
+
+// isEpochTimestamp reports whether a "---" or "+++" line is dated at the Unix epoch, which is how
+// diff -N marks the missing side of an added or deleted file
+func isEpochTimestamp(value string) bool {
+	_, timestamp, ok := strings.Cut(value, "\t")
+	if !ok {
+		return false
+	}
+	parsed, err := time.Parse("2006-01-02 15:04:05.999999999 -0700", timestamp)
+	return err == nil && parsed.Unix() == 0
+}
+
+// extendedHeaderPath returns the path of a rename or copy header such as "rename from old/path",
+// which has no prefix
+func extendedHeaderPath(line string) string {
+	value := strings.SplitN(line, " ", 3)[2]
+	if strings.HasPrefix(value, `"`) {
+		if path, _, err := unquotePath(value); err == nil {
+			return path
+		}
+	}
+	return value
+}
+
+// unquotePath decodes the C-style quoted path at the start of s, as git writes paths with
+// special characters, e.g. "t\303\244st.txt", and returns the rest of s after it
+func unquotePath(s string) (string, string, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			path, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid quoted path %s: %w", s[:i+1], err)
+			}
+			return path, s[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated quoted path: %s", s)
+}
+
+// parseHunkHeader parses a hunk header line like "@@ -1,4 +1,6 @@"
+func parseHunkHeader(line string) (*DiffHunk, error) {
+	matches := hunkHeaderPattern.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid hunk header format: %s", line)
+	}
+
+	oldStart, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid old start line number: %s", matches[1])
+	}
+
+	oldCount := 1
+	if matches[2] != "" {
+		oldCount, err = strconv.Atoi(matches[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid old line count: %s", matches[2])
+		}
+	}
+
+	newStart, err := strconv.Atoi(matches[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid new start line number: %s", matches[3])
+	}
+
+	newCount := 1
+	if matches[4] != "" {
+		newCount, err = strconv.Atoi(matches[4])
+		if err != nil {
+			return nil, fmt.Errorf("invalid new line count: %s", matches[4])
+		}
+	}
+
+	return &DiffHunk{
+		OldStart: oldStart,
+		OldCount: oldCount,
+		NewStart: newStart,
+		NewCount: newCount,
+		Lines:    []DiffLine{},
+		Header:   line,
+	}, nil
+}
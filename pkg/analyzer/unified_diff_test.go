@@ -0,0 +1,301 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// describeFileDiff summarizes a parsed file as
+// "status path [<- old] [binary] [mode old->new] [similarity n%] +added -removed hunks=n [eof -line] [eof +line]"
+func describeFileDiff(file FileDiff) string {
+	parts := []string{file.Status, file.Filename}
+	if file.OldFilename != "" {
+		parts = append(parts, "<- "+file.OldFilename)
+	}
+	if file.Binary {
+		parts = append(parts, "binary")
+	}
+	switch {
+	case file.ModeChanged():
+		parts = append(parts, fmt.Sprintf("mode %s->%s", file.OldMode, file.NewMode))
+	case file.OldMode == "" && file.NewMode != "":
+		parts = append(parts, "mode "+file.NewMode)
+	case file.NewMode == "" && file.OldMode != "":
+		parts = append(parts, "mode "+file.OldMode)
+	}
+	if file.Similarity > 0 {
+		parts = append(parts, fmt.Sprintf("similarity %d%%", file.Similarity))
+	}
+	if file.Dissimilarity > 0 {
+		parts = append(parts, fmt.Sprintf("dissimilarity %d%%", file.Dissimilarity))
+	}
+	parts = append(parts, fmt.Sprintf("+%d -%d hunks=%d", file.Additions, file.Deletions, len(file.Hunks)))
+	for _, hunk := range file.Hunks {
+		for _, line := range hunk.Lines {
+			switch {
+			case line.NoNewline && line.Type == "removed":
+				parts = append(parts, fmt.Sprintf("eof -%d", line.OldLineNo))
+			case line.NoNewline:
+				parts = append(parts, fmt.Sprintf("eof +%d", line.NewLineNo))
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// describeDiffLines lists the lines of a file's hunks as "-old text", "+new text" or " old/new text"
+func describeDiffLines(file FileDiff) []string {
+	var lines []string
+	for _, hunk := range file.Hunks {
+		for _, line := range hunk.Lines {
+			switch line.Type {
+			case "added":
+				lines = append(lines, fmt.Sprintf("+%d %s", line.NewLineNo, line.Content))
+			case "removed":
+				lines = append(lines, fmt.Sprintf("-%d %s", line.OldLineNo, line.Content))
+			default:
+				lines = append(lines, fmt.Sprintf(" %d/%d %s", line.OldLineNo, line.NewLineNo, line.Content))
+			}
+		}
+	}
+	return lines
+}
+
+func parseFixture(t *testing.T, name string) *ParsedDiff {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", "diffs", name+".diff"))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	parsed, err := NewDefaultDiffAnalyzer().ParseDiff(string(raw))
+	if err != nil {
+		t.Fatalf("failed to parse %s: %v", name, err)
+	}
+	return parsed
+}
+
+func TestParseDiff_Fixtures(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		expected []string
+	}{
+		{
+			fixture:  "modified",
+			expected: []string{"modified src/main.go +1 -1 hunks=1"},
+		},
+		{
+			fixture:  "spaces",
+			expected: []string{"modified docs/release notes.md +1 -1 hunks=1"},
+		},
+		{
+			fixture: "quoted",
+			expected: []string{
+				`modified say "hi".txt +1 -1 hunks=1`,
+				"modified tab\tname.txt +1 -1 hunks=1",
+				"added ümlaut renamed.txt mode 100644 +1 -0 hunks=1",
+				"deleted ümlaut.txt mode 100644 +0 -1 hunks=1",
+			},
+		},
+		{
+			fixture: "no_newline",
+			expected: []string{
+				"modified eof.txt +1 -1 hunks=1 eof -1",
+				"modified eof2.txt +1 -1 hunks=1 eof +1",
+			},
+		},
+		{
+			fixture:  "mode_change",
+			expected: []string{"modified run.sh mode 100644->100755 +0 -0 hunks=0"},
+		},
+		{
+			fixture:  "rename_edit",
+			expected: []string{"renamed src/letters.txt <- src/greek.txt similarity 86% +1 -1 hunks=1"},
+		},
+		{
+			fixture: "rename_pure",
+			expected: []string{
+				"renamed bin/run me.sh <- run.sh mode 100755->100644 similarity 100% +0 -0 hunks=0",
+				"renamed docs/ü file.txt <- ümlaut renamed.txt similarity 100% +0 -0 hunks=0",
+			},
+		},
+		{
+			fixture:  "copy",
+			expected: []string{"copied src/util_copy.go <- src/util.go similarity 77% +1 -1 hunks=1"},
+		},
+		{
+			fixture:  "rewrite",
+			expected: []string{"modified src/main.go dissimilarity 100% +4 -6 hunks=1"},
+		},
+		{
+			fixture:  "binary",
+			expected: []string{"modified logo.png binary +0 -0 hunks=0"},
+		},
+		{
+			fixture:  "binary_patch",
+			expected: []string{"modified logo.png binary +0 -0 hunks=0"},
+		},
+		{
+			fixture:  "deleted",
+			expected: []string{"deleted gone.txt mode 100644 +0 -1 hunks=1"},
+		},
+		{
+			fixture: "symlink_empty",
+			expected: []string{
+				"added empty.txt mode 100644 +0 -0 hunks=0",
+				"added link mode 120000 +1 -0 hunks=1 eof +1",
+			},
+		},
+		{
+			fixture: "header_lookalike",
+			expected: []string{
+				"modified q2.sql +1 -1 hunks=1",
+				"modified query.sql +3 -2 hunks=1",
+			},
+		},
+		{
+			fixture:  "plain_unified",
+			expected: []string{"modified notes.txt +2 -1 hunks=1"},
+		},
+		{
+			fixture: "plain_recursive",
+			expected: []string{
+				"modified notes.txt +2 -1 hunks=1",
+				"added sub/added.txt +1 -0 hunks=1",
+				"deleted sub/gone.txt +0 -1 hunks=1",
+			},
+		},
+		{
+			fixture:  "hand_written",
+			expected: []string{"modified main.go +2 -2 hunks=2"},
+		},
+		{
+			fixture: "pull_request",
+			expected: []string{
+				"modified docs/release notes.md +1 -1 hunks=1",
+				"added empty.txt mode 100644 +0 -0 hunks=0",
+				"modified eof.txt +1 -1 hunks=1 eof -1",
+				"modified eof2.txt +1 -1 hunks=1 eof +1",
+				"deleted gone.txt mode 100644 +0 -1 hunks=1",
+				"added link mode 120000 +1 -0 hunks=1 eof +1",
+				"modified logo.png binary +0 -0 hunks=0",
+				"modified query.sql +3 -2 hunks=1",
+				"modified run.sh mode 100644->100755 +0 -0 hunks=0",
+				`modified say "hi".txt +1 -1 hunks=1`,
+				"renamed src/letters.txt <- src/greek.txt similarity 86% +1 -1 hunks=1",
+				"modified src/main.go +1 -1 hunks=1",
+				"copied src/util_copy.go <- src/util.go similarity 77% +1 -1 hunks=1",
+				"modified tab\tname.txt +1 -1 hunks=1",
+				"added ümlaut renamed.txt mode 100644 +1 -0 hunks=1",
+				"deleted ümlaut.txt mode 100644 +0 -1 hunks=1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			parsed := parseFixture(t, tt.fixture)
+
+			var got []string
+			added, removed := 0, 0
+			for _, file := range parsed.Files {
+				got = append(got, describeFileDiff(file))
+				added += file.Additions
+				removed += file.Deletions
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(tt.expected, "\n"), strings.Join(got, "\n"))
+			}
+			if parsed.TotalFiles != len(parsed.Files) || parsed.TotalAdded != added || parsed.TotalRemoved != removed {
+				t.Errorf("totals do not match the files: %d files +%d -%d", parsed.TotalFiles, parsed.TotalAdded, parsed.TotalRemoved)
+			}
+		})
+	}
+}
+
+func TestParseDiff_FixtureLines(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		file     int
+		expected []string
+	}{
+		{
+			// A removed "-- header" and an added "++ header" look like a file header
+			fixture:  "header_lookalike",
+			file:     0,
+			expected: []string{"-1 -- header", "+1 ++ header", " 2/2 -- sql comment", " 3/3 SELECT 1;"},
+		},
+		{
+			fixture: "header_lookalike",
+			file:    1,
+			expected: []string{
+				" 1/1 -- sql comment", "-2 SELECT 1;", "-3 ++ not a header",
+				"+2 --- dropped", "+3 SELECT 2;", "+4 +++ still not a header",
+			},
+		},
+		{
+			// The blank context line lost its space, and the second hunk miscounts its lines
+			fixture: "hand_written",
+			file:    0,
+			expected: []string{
+				" 1/1 package main", " 2/2 ", " 3/3 func main() {", "+4 \tfmt.Println(\"Hello\")", " 4/5 }",
+				"-10 \toldCode := true", "-11 \treturn oldCode", "+11 \treturn false", " 12/12 }",
+			},
+		},
+		{
+			fixture:  "plain_unified",
+			file:     0,
+			expected: []string{" 1/1 one", "-2 two", "+2 2", " 3/3 three", "+4 four"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.fixture, tt.file), func(t *testing.T) {
+			parsed := parseFixture(t, tt.fixture)
+			if got := describeDiffLines(parsed.Files[tt.file]); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(tt.expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestParseDiff_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		rawDiff string
+		message string
+	}{
+		{
+			name:    "invalid hunk header",
+			rawDiff: "diff --git a/x b/x\n--- a/x\n+++ b/x\n@@ -a +b @@\n",
+			message: "line 4: invalid hunk header format",
+		},
+		{
+			name:    "invalid similarity",
+			rawDiff: "diff --git a/x b/y\nsimilarity index lots\nrename from x\nrename to y\n",
+			message: "line 2: invalid similarity",
+		},
+		{
+			name:    "unterminated quoted path",
+			rawDiff: "diff --git \"a/x b/x\n",
+			message: "line 1: unterminated quoted path",
+		},
+		{
+			name:    "combined diff",
+			rawDiff: "diff --cc merged.go\nindex 1,2..3\n@@@ -1,1 -1,1 +1,1 @@@\n",
+			message: "line 1: combined diffs are not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDefaultDiffAnalyzer().ParseDiff(tt.rawDiff)
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("expected an error containing %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
+	"github.com/GDSources/claude-code-review-agent/pkg/repocache"
+)
+
+// LLM cache locations with a special meaning; any other value is a cache directory
+const (
+	CacheDisabled = "off"
+	CacheMemory   = "memory"
+	CacheDisk     = "disk"
+)
+
+// OpenResultCache opens the LLM result cache. An empty location or "off" disables caching and
+// returns nil, "memory" keeps results for the life of the process, "disk" uses the default
+// directory, and anything else is used as the cache directory. An empty TTL uses the default.
+func OpenResultCache(location, ttl string) (llm.ResultCache, error) {
+	location = strings.TrimSpace(location)
+	if location == "" || strings.EqualFold(location, CacheDisabled) {
+		return nil, nil
+	}
+
+	var expiry time.Duration
+	if ttl = strings.TrimSpace(ttl); ttl != "" {
+		parsed, err := time.ParseDuration(ttl)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid cache TTL %q (expected a positive duration such as 24h)", ttl)
+		}
+		expiry = parsed
+	}
+
+	switch strings.ToLower(location) {
+	case CacheMemory:
+		return llm.NewMemoryCache(expiry), nil
+	case CacheDisk:
+		location = llm.DefaultCacheDir()
+	}
+
+	cache, err := llm.NewFileCache(location, expiry)
+	if err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// RepoCacheDefault is the repository cache location that selects the default directory
+const RepoCacheDefault = "default"
+
+// OpenRepoCache opens the repository mirror cache. An empty location or "off" disables it and
+// returns nil, "default" uses the default directory, and anything else is used as the cache
+// directory. The quota is a size such as 20GB; empty uses the default and 0 disables eviction.
+func OpenRepoCache(location, quota string) (*repocache.Cache, error) {
+	location = strings.TrimSpace(location)
+	if location == "" || strings.EqualFold(location, CacheDisabled) {
+		return nil, nil
+	}
+	if strings.EqualFold(location, RepoCacheDefault) {
+		location = repocache.DefaultDir()
+	}
+
+	bytes := int64(-1)
+	if quota = strings.TrimSpace(quota); quota != "" {
+		parsed, err := ParseSize(quota)
+		if err != nil {
+			return nil, fmt.Errorf("invalid repository cache quota: %w", err)
+		}
+		bytes = parsed
+	}
+
+	return repocache.New(location, bytes)
+}
+
+// ParseSize parses a byte count with an optional KB, MB, GB or TB suffix (powers of 1024)
+func ParseSize(size string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("%q is not a size (expected e.g. 500MB or 20GB)", size)
+	}
+	return int64(number * float64(multiplier)), nil
+}
@@ -133,6 +133,63 @@ CLAUDE_API_KEY=your_claude_api_key_here
 
 # Optional: Webhook secret for server mode
 # WEBHOOK_SECRET=your_webhook_secret_here
+
+# Optional: Review history file ("off" to disable)
+# REVIEW_HISTORY_FILE=~/.config/review-agent/history.jsonl
+
+# Optional: Collected feedback on posted comments, and how often the server
+# collects it (empty disables background collection)
+# REVIEW_FEEDBACK_FILE=~/.config/review-agent/feedback.jsonl
+# REVIEW_FEEDBACK_INTERVAL=6h
+
+# Optional: Comma-separated globs of paths to review / exclude
+# REVIEW_INCLUDE_PATHS=src/**
+# REVIEW_EXCLUDE_PATHS=vendor/**,node_modules/**,*.lock,*.sum
+
+# Optional: Minimum confidence (0-1) for posting a comment, and whether to list
+# held-back findings as possible issues in the review summary
+# REVIEW_COMMENT_THRESHOLD=0.7
+# REVIEW_SHOW_POSSIBLE_ISSUES=false
+
+# Optional: Estimated diff tokens reviewed per pull request; on larger pull
+# requests the lowest-risk files are left out and listed in the summary
+# REVIEW_TOKEN_BUDGET=100000
+
+# Optional: LLM spend limits in US dollars. Over budget a review switches to a
+# cheaper model, then sends less context and fewer files, and is skipped last.
+# The daily limit counts every review recorded in the review history.
+# REVIEW_BUDGET_PER_REVIEW=0.50
+# REVIEW_BUDGET_PER_DAY=20
+
+# Optional: Reuse LLM results for files whose contextual diff, model, prompts and
+# instructions are unchanged (re-opened pull requests, retries). Use "memory" in
+# server mode, "disk" for ~/.cache/review-agent/llm, or a directory.
+# REVIEW_LLM_CACHE=disk
+# REVIEW_LLM_CACHE_TTL=168h
+
+# Optional: Keep bare mirrors of reviewed repositories and check pull requests out
+# from them, fetching only new objects. "default" uses ~/.cache/review-agent/repos.
+# The least recently used mirrors are evicted above the quota.
+# REVIEW_REPO_CACHE=default
+# REVIEW_REPO_CACHE_QUOTA=20GB
+
+# Optional (server mode): Delete review workspaces left behind by crashes after the
+# TTL, and limit the disk space of all workspaces. Reviews over the quota wait up to
+# the quota wait for space and are then rejected.
+# REVIEW_WORKSPACE_TTL=6h
+# REVIEW_WORKSPACE_QUOTA=50GB
+# REVIEW_WORKSPACE_QUOTA_WAIT=10m
+
+# Optional: Pick the model from the diff. Small or docs-only changes go to a fast
+# model, large or security-sensitive ones to the most capable, which also
+# re-checks critical findings. A routing section in .review-agent.yml overrides it.
+# REVIEW_MODEL_ROUTING=false
+
+# Optional: Push suggested fixes to review-agent/fixes-<pr> instead of commenting
+# them. The verification command must succeed after the fixes are applied.
+# REVIEW_AUTOFIX=false
+# REVIEW_AUTOFIX_VERIFY=go build ./...
+# REVIEW_AUTOFIX_PUSH_TO_PR=false
 `
 
 	return os.WriteFile(envFile, []byte(content), 0644)
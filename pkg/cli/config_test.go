@@ -375,3 +375,22 @@ func TestEnvLoader_ParseLine(t *testing.T) {
 		})
 	}
 }
+
+func TestOpenHistoryStore(t *testing.T) {
+	store, err := OpenHistoryStore(HistoryDisabled)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store != nil {
+		t.Error("expected nil store when history is disabled")
+	}
+
+	path := filepath.Join(t.TempDir(), "history", "reviews.jsonl")
+	store, err = OpenHistoryStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store == nil || store.Path() != path {
+		t.Errorf("expected store at %s", path)
+	}
+}
@@ -0,0 +1,17 @@
+package cli
+
+import (
+	"strings"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/feedback"
+)
+
+// OpenFeedbackStore opens the comment feedback store at the given path; an empty path selects the default location
+func OpenFeedbackStore(path string) (*feedback.FileStore, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		path = feedback.DefaultPath()
+	}
+
+	return feedback.NewFileStore(path)
+}
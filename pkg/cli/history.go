@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"strings"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/history"
+)
+
+// HistoryDisabled is the history file value that turns off review history recording
+const HistoryDisabled = "off"
+
+// OpenHistoryStore opens the review history store at the given path.
+// An empty path selects the default location; "off" disables history and returns nil.
+func OpenHistoryStore(path string) (*history.FileStore, error) {
+	path = strings.TrimSpace(path)
+	if strings.EqualFold(path, HistoryDisabled) {
+		return nil, nil
+	}
+	if path == "" {
+		path = history.DefaultPath()
+	}
+
+	return history.NewFileStore(path)
+}
@@ -18,6 +18,24 @@ type ReviewConfig struct {
 	GitHubToken  string
 	ClaudeAPIKey string
 	ClaudeModel  string
+	HistoryFile  string   // Review history location; empty uses the default, "off" disables it
+	LLMCache     string   // LLM result cache: "memory", "disk" or a directory; empty disables it
+	LLMCacheTTL  string   // How long cached results are reused, e.g. "24h"; empty uses the default
+	RepoCache    string   // Repository mirror cache: "default" or a directory; empty disables it
+	RepoQuota    string   // Disk space for repository mirrors, e.g. "20GB"; empty uses the default
+	IncludePaths []string // Globs of paths to review; empty reviews everything
+	ExcludePaths []string // Globs of paths never sent for review
+
+	CommentThreshold   float64 // Minimum confidence (0-1) for posting a finding; 0 posts everything
+	ShowPossibleIssues bool    // List held-back findings in the review summary
+	TokenBudget        int     // Estimated diff tokens reviewed per pull request; 0 keeps the default
+	BudgetPerReview    float64 // Most a review may spend on the LLM in US dollars; 0 is unlimited
+	BudgetPerDay       float64 // Most all reviews may spend per UTC day in US dollars; 0 is unlimited
+	ModelRouting       bool    // Pick the model from the diff with the default routing rules
+
+	Autofix         bool   // Push suggested fixes to a branch instead of commenting them
+	AutofixVerify   string // Command that must succeed after applying fixes
+	AutofixPushToPR bool   // Push fixes to the pull request branch when it is in the same repository
 }
 
 type PRReviewer struct {
@@ -38,6 +56,16 @@ func NewPRReviewer(config *ReviewConfig) *PRReviewer {
 
 	// Create workspace manager
 	workspaceManager := review.NewDefaultWorkspaceManager(cloner, fsManager)
+	// Read single files through the API so the repository is cloned only when a stage needs it
+	workspaceManager.SetContentReader(githubClient)
+
+	// Check out pull requests from a persistent mirror instead of cloning them every time
+	repoCache, err := OpenRepoCache(config.RepoCache, config.RepoQuota)
+	if err != nil {
+		fmt.Printf("Warning: Failed to open repository cache: %v\n", err)
+	} else if repoCache != nil {
+		workspaceManager.SetMirrorCache(repoCache)
+	}
 
 	// Create diff fetcher
 	diffFetcher := review.NewGitHubDiffFetcherFromClient(githubClient)
@@ -65,11 +93,44 @@ func NewPRReviewer(config *ReviewConfig) *PRReviewer {
 		// For now, log the error and continue without LLM - in production you might want to fail here
 		fmt.Printf("Warning: Failed to create Claude client: %v\n", err)
 		claudeClient = nil
+	} else {
+		// Reuse comments for files reviewed before with the same model, prompts and diff
+		cache, err := OpenResultCache(config.LLMCache, config.LLMCacheTTL)
+		if err != nil {
+			fmt.Printf("Warning: Failed to open LLM result cache: %v\n", err)
+		} else if cache != nil {
+			claudeClient.SetCache(cache)
+		}
 	}
 
 	// Create review orchestrator with LLM and comment posting integration
 	orchestrator := review.NewReviewOrchestratorWithComments(workspaceManager, diffFetcher, codeAnalyzer, claudeClient, githubClient)
 
+	// Read per-repository settings from .review-agent.yml on the base branch
+	orchestrator.SetRepoConfigReader(githubClient)
+	// Read the base revision of changed files to find the declarations a pull request removes
+	orchestrator.SetBaseFileReader(githubClient)
+	orchestrator.SetPathFilters(config.IncludePaths, config.ExcludePaths)
+	orchestrator.SetConfidenceThreshold(config.CommentThreshold, config.ShowPossibleIssues)
+	orchestrator.SetTokenBudget(config.TokenBudget)
+	orchestrator.SetBudget(review.BudgetSettings{PerReview: config.BudgetPerReview, PerDay: config.BudgetPerDay})
+	if config.ModelRouting {
+		orchestrator.SetModelRouting(review.DefaultRouting())
+	}
+	orchestrator.SetAutofix(review.AutofixSettings{
+		Enabled:        config.Autofix,
+		VerifyCommand:  config.AutofixVerify,
+		PushToPRBranch: config.AutofixPushToPR,
+	})
+
+	// Record every review run in the local history store
+	historyStore, err := OpenHistoryStore(config.HistoryFile)
+	if err != nil {
+		fmt.Printf("Warning: Failed to open review history: %v\n", err)
+	} else if historyStore != nil {
+		orchestrator.SetHistoryStore(historyStore)
+	}
+
 	return &PRReviewer{
 		config:       config,
 		githubClient: githubClient,
@@ -116,10 +177,14 @@ func (r *PRReviewer) fetchPREvent(owner, repo string, prNumber int) (*webhook.Pu
 			ID:     prData.ID,
 			Number: prData.Number,
 			Title:  prData.Title,
+			Body:   prData.Body,
 			State:  prData.State,
+			Draft:  prData.Draft,
+			Labels: prData.Labels,
 			Head: webhook.Branch{
-				Ref: prData.Head.Ref,
-				SHA: prData.Head.SHA,
+				Ref:  prData.Head.Ref,
+				SHA:  prData.Head.SHA,
+				Repo: webhook.Repository{FullName: prData.Head.Repo.FullName},
 			},
 			Base: webhook.Branch{
 				Ref: prData.Base.Ref,
@@ -135,6 +200,8 @@ func (r *PRReviewer) fetchPREvent(owner, repo string, prNumber int) (*webhook.Pu
 			Name:     repo,
 			FullName: fmt.Sprintf("%s/%s", owner, repo),
 			Private:  prData.Repository.Private,
+			Size:     prData.Base.Repo.Size,
+			HTMLURL:  prData.Base.Repo.HTMLURL,
 			Owner: webhook.User{
 				ID:    prData.Repository.Owner.ID,
 				Login: owner,
@@ -150,7 +217,10 @@ type GitHubPRResponse struct {
 	ID         int              `json:"id"`
 	Number     int              `json:"number"`
 	Title      string           `json:"title"`
+	Body       string           `json:"body"`
 	State      string           `json:"state"`
+	Draft      bool             `json:"draft"`
+	Labels     []webhook.Label  `json:"labels"`
 	Head       GitHubBranchRef  `json:"head"`
 	Base       GitHubBranchRef  `json:"base"`
 	User       GitHubUser       `json:"user"`
@@ -158,8 +228,13 @@ type GitHubPRResponse struct {
 }
 
 type GitHubBranchRef struct {
-	Ref string `json:"ref"`
-	SHA string `json:"sha"`
+	Ref  string `json:"ref"`
+	SHA  string `json:"sha"`
+	Repo struct {
+		FullName string `json:"full_name"`
+		Size     int    `json:"size"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repo"`
 }
 
 type GitHubUser struct {
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/review"
+)
+
+// NewWorkspaceJanitor creates the janitor for review workspaces in the system temporary directory.
+// An empty TTL uses the default; the quota is a size such as 50GB and empty leaves workspaces
+// unlimited; the wait is how long a review waits for space, and empty or 0 rejects it at once.
+func NewWorkspaceJanitor(ttl, quota, wait string) (*review.WorkspaceJanitor, error) {
+	var expiry time.Duration
+	if ttl = strings.TrimSpace(ttl); ttl != "" {
+		parsed, err := time.ParseDuration(ttl)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid workspace TTL %q (expected a positive duration such as 6h)", ttl)
+		}
+		expiry = parsed
+	}
+
+	var bytes int64
+	if quota = strings.TrimSpace(quota); quota != "" {
+		parsed, err := ParseSize(quota)
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace quota: %w", err)
+		}
+		bytes = parsed
+	}
+
+	var quotaWait time.Duration
+	if wait = strings.TrimSpace(wait); wait != "" {
+		parsed, err := time.ParseDuration(wait)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid workspace quota wait %q (expected a duration such as 10m)", wait)
+		}
+		quotaWait = parsed
+	}
+
+	return review.NewWorkspaceJanitor("", expiry, bytes, quotaWait), nil
+}
+
+// SweepStaleWorkspaces deletes the workspaces that crashed runs left behind, once. Review and
+// action runs call it since they do not run the server's janitor, so self-hosted runners that keep
+// their temporary directory are cleaned up too.
+func SweepStaleWorkspaces() {
+	removed, freed, err := review.NewWorkspaceJanitor("", 0, 0, 0).Sweep()
+	if err != nil {
+		log.Printf("Warning: failed to sweep stale workspaces: %v", err)
+	}
+	if removed > 0 {
+		log.Printf("Removed %d stale workspaces, freeing %d bytes", removed, freed)
+	}
+}
@@ -0,0 +1,179 @@
+package feedback
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/github"
+	"github.com/GDSources/claude-code-review-agent/pkg/history"
+)
+
+// ThreadClient fetches the review threads of a pull request, including comment reactions
+type ThreadClient interface {
+	GetReviewThreads(ctx context.Context, owner, repo string, prNumber int) ([]github.ReviewThread, error)
+}
+
+// Collector gathers feedback on the comments recorded in the review history
+type Collector struct {
+	client  ThreadClient
+	history history.Store
+	store   *FileStore
+	now     func() time.Time
+}
+
+// NewCollector creates a collector that reads posted comments from the history and saves feedback to the store
+func NewCollector(client ThreadClient, historyStore history.Store, store *FileStore) *Collector {
+	return &Collector{
+		client:  client,
+		history: historyStore,
+		store:   store,
+		now:     time.Now,
+	}
+}
+
+// postedComment is a comment from the review history with the run that posted it
+type postedComment struct {
+	comment  history.Comment
+	model    string
+	postedAt time.Time
+}
+
+// Collect fetches reactions and thread state for every posted comment in the matching review
+// runs and saves them to the store. Returns the number of comments with feedback collected.
+// Pull requests that cannot be read are logged and skipped.
+func (c *Collector) Collect(ctx context.Context, filter history.Filter) (int, error) {
+	records, err := c.history.Query(filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query review history: %w", err)
+	}
+
+	// Group posted comments by pull request; records are newest first so re-posted comments keep the latest run
+	type pullRequestKey struct {
+		repository string
+		number     int
+	}
+	var order []pullRequestKey
+	posted := make(map[pullRequestKey][]postedComment)
+	for _, record := range records {
+		key := pullRequestKey{repository: record.Repository, number: record.PullRequest}
+		for _, comment := range record.Comments {
+			if comment.Status != "posted" {
+				continue
+			}
+			if _, seen := posted[key]; !seen {
+				order = append(order, key)
+			}
+			posted[key] = append(posted[key], postedComment{comment: comment, model: record.Model, postedAt: record.StartedAt})
+		}
+	}
+
+	var signals []Signal
+	failures := 0
+	for _, key := range order {
+		owner, repo, ok := strings.Cut(key.repository, "/")
+		if !ok {
+			continue
+		}
+
+		threads, err := c.client.GetReviewThreads(ctx, owner, repo, key.number)
+		if err != nil {
+			log.Printf("Warning: failed to collect feedback for %s#%d: %v", key.repository, key.number, err)
+			failures++
+			continue
+		}
+
+		signals = append(signals, c.matchSignals(key.repository, key.number, posted[key], threads)...)
+	}
+
+	if failures > 0 && failures == len(order) {
+		return 0, fmt.Errorf("failed to collect feedback for all %d pull requests", failures)
+	}
+
+	if err := c.store.Save(signals); err != nil {
+		return 0, fmt.Errorf("failed to save feedback: %w", err)
+	}
+
+	return len(signals), nil
+}
+
+// matchSignals pairs posted comments with the threads they started. Comments are matched by
+// GitHub ID, or by file and body for runs recorded before IDs were kept.
+func (c *Collector) matchSignals(repository string, number int, comments []postedComment, threads []github.ReviewThread) []Signal {
+	var signals []Signal
+	seen := make(map[int64]bool)
+
+	for _, posted := range comments {
+		thread := findThread(threads, posted.comment)
+		if thread == nil {
+			continue
+		}
+		first := thread.Comments[0]
+		if seen[first.ID] {
+			continue
+		}
+		seen[first.ID] = true
+
+		signals = append(signals, Signal{
+			Repository:  repository,
+			PullRequest: number,
+			CommentID:   first.ID,
+			Filename:    posted.comment.Filename,
+			LineNumber:  posted.comment.LineNumber,
+			Model:       posted.model,
+			ReviewType:  posted.comment.ReviewType,
+			Category:    posted.comment.Category,
+			Severity:    posted.comment.Severity,
+			ThumbsUp:    first.Reactions.ThumbsUp,
+			ThumbsDown:  first.Reactions.ThumbsDown,
+			Resolved:    thread.IsResolved,
+			Outdated:    thread.IsOutdated,
+			PostedAt:    posted.postedAt,
+			CollectedAt: c.now().UTC(),
+		})
+	}
+
+	return signals
+}
+
+// findThread returns the thread started by the posted comment, or nil
+func findThread(threads []github.ReviewThread, comment history.Comment) *github.ReviewThread {
+	for i := range threads {
+		if len(threads[i].Comments) == 0 {
+			continue
+		}
+		first := threads[i].Comments[0]
+		if comment.CommentID != 0 {
+			if first.ID == comment.CommentID {
+				return &threads[i]
+			}
+			continue
+		}
+		if threads[i].Path == comment.Filename && comment.Body != "" && strings.HasPrefix(first.Body, comment.Body) {
+			return &threads[i]
+		}
+	}
+	return nil
+}
+
+// Run collects feedback for the reviews of the last lookback period every interval until the context is done
+func (c *Collector) Run(ctx context.Context, interval, lookback time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := c.Collect(ctx, history.Filter{Since: c.now().Add(-lookback)})
+			if err != nil {
+				log.Printf("Warning: feedback collection failed: %v", err)
+				continue
+			}
+			log.Printf("Collected feedback on %d review comments", count)
+		}
+	}
+}
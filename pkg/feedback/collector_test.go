@@ -0,0 +1,123 @@
+package feedback
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/github"
+	"github.com/GDSources/claude-code-review-agent/pkg/history"
+)
+
+type mockThreadClient struct {
+	threads map[string][]github.ReviewThread
+	err     error
+	calls   []string
+}
+
+func (m *mockThreadClient) GetReviewThreads(ctx context.Context, owner, repo string, prNumber int) ([]github.ReviewThread, error) {
+	key := owner + "/" + repo
+	m.calls = append(m.calls, key)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.threads[key], nil
+}
+
+func agentThread(id int64, path, body string, resolved, outdated bool, reactions github.Reactions) github.ReviewThread {
+	return github.ReviewThread{
+		IsResolved: resolved,
+		IsOutdated: outdated,
+		Path:       path,
+		Comments: []github.PullRequestComment{
+			{ID: id, Body: body + "\n\n<!-- review-agent:review-comment -->", Reactions: reactions},
+			{ID: id + 1000, Body: "thanks"},
+		},
+	}
+}
+
+func newTestCollector(t *testing.T, client ThreadClient, records ...*history.Record) (*Collector, *FileStore) {
+	t.Helper()
+	historyStore, err := history.NewFileStore(filepath.Join(t.TempDir(), "history.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to create history store: %v", err)
+	}
+	for _, record := range records {
+		if err := historyStore.Save(record); err != nil {
+			t.Fatalf("failed to save history record: %v", err)
+		}
+	}
+
+	store := newTestStore(t)
+	collector := NewCollector(client, historyStore, store)
+	collector.now = func() time.Time { return time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC) }
+	return collector, store
+}
+
+func TestCollector_Collect(t *testing.T) {
+	postedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	record := &history.Record{
+		Repository:  "owner/repo",
+		PullRequest: 7,
+		Model:       "sonnet",
+		StartedAt:   postedAt,
+		Comments: []history.Comment{
+			{Filename: "auth.go", LineNumber: 10, Body: "Token is logged", Severity: "critical", Category: "security",
+				ReviewType: "security", Status: "posted", CommentID: 101},
+			{Filename: "util.go", LineNumber: 3, Body: "Unused helper", Severity: "minor", Status: "posted"},
+			{Filename: "util.go", LineNumber: 9, Body: "Held back", Status: "skipped"},
+			{Filename: "gone.go", LineNumber: 1, Body: "Deleted comment", Status: "posted", CommentID: 999},
+		},
+	}
+	client := &mockThreadClient{threads: map[string][]github.ReviewThread{
+		"owner/repo": {
+			agentThread(101, "auth.go", "Token is logged", false, false, github.Reactions{ThumbsUp: 2}),
+			agentThread(202, "util.go", "Unused helper", true, false, github.Reactions{}),
+			{Path: "other.go", Comments: []github.PullRequestComment{{ID: 303, Body: "human comment"}}},
+		},
+	}}
+
+	collector, store := newTestCollector(t, client, record)
+
+	count, err := collector.Collect(context.Background(), history.Filter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected feedback on 2 comments, got %d", count)
+	}
+
+	signals, _ := store.Query(Filter{})
+	byID := make(map[int64]Signal)
+	for _, signal := range signals {
+		byID[signal.CommentID] = signal
+	}
+
+	matchedByID := byID[101]
+	if matchedByID.ThumbsUp != 2 || matchedByID.Model != "sonnet" || matchedByID.ReviewType != "security" ||
+		matchedByID.Severity != "critical" || !matchedByID.PostedAt.Equal(postedAt) || matchedByID.Verdict() != VerdictUseful {
+		t.Errorf("unexpected signal for comment matched by ID: %+v", matchedByID)
+	}
+
+	matchedByBody := byID[202]
+	if matchedByBody.Filename != "util.go" || !matchedByBody.ResolvedWithoutChange() || matchedByBody.Verdict() != VerdictNotUseful {
+		t.Errorf("unexpected signal for comment matched by body: %+v", matchedByBody)
+	}
+}
+
+func TestCollector_CollectErrors(t *testing.T) {
+	record := &history.Record{
+		Repository:  "owner/repo",
+		PullRequest: 7,
+		Comments:    []history.Comment{{Filename: "a.go", Body: "x", Status: "posted", CommentID: 1}},
+	}
+	client := &mockThreadClient{err: errors.New("GitHub API returned status 502")}
+
+	collector, _ := newTestCollector(t, client, record)
+
+	if _, err := collector.Collect(context.Background(), history.Filter{}); err == nil {
+		t.Error("expected an error when no pull request could be read")
+	}
+}
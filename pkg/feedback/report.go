@@ -0,0 +1,85 @@
+package feedback
+
+import "sort"
+
+// Dimensions the precision report is broken down by, in display order
+var Dimensions = []string{"repository", "model", "review_type", "category", "severity"}
+
+// PrecisionRow summarizes the feedback for one value of a dimension
+type PrecisionRow struct {
+	Dimension string  `json:"dimension"`
+	Value     string  `json:"value"`
+	Useful    int     `json:"useful"`
+	NotUseful int     `json:"not_useful"`
+	NoSignal  int     `json:"no_signal"`
+	Precision float64 `json:"precision"` // Useful / (Useful + NotUseful), 0 when no comment was rated
+}
+
+// Rated returns the number of comments with a verdict
+func (r PrecisionRow) Rated() int {
+	return r.Useful + r.NotUseful
+}
+
+// BuildReport computes precision per repository, model, review type, category and severity.
+// Rows are ordered by dimension, then by the number of rated comments.
+func BuildReport(signals []Signal) []PrecisionRow {
+	var rows []PrecisionRow
+
+	for _, dimension := range Dimensions {
+		byValue := make(map[string]*PrecisionRow)
+		for _, signal := range signals {
+			value := dimensionValue(signal, dimension)
+			if value == "" {
+				value = "unknown"
+			}
+
+			row, ok := byValue[value]
+			if !ok {
+				row = &PrecisionRow{Dimension: dimension, Value: value}
+				byValue[value] = row
+			}
+			switch signal.Verdict() {
+			case VerdictUseful:
+				row.Useful++
+			case VerdictNotUseful:
+				row.NotUseful++
+			default:
+				row.NoSignal++
+			}
+		}
+
+		dimensionRows := make([]PrecisionRow, 0, len(byValue))
+		for _, row := range byValue {
+			if row.Rated() > 0 {
+				row.Precision = float64(row.Useful) / float64(row.Rated())
+			}
+			dimensionRows = append(dimensionRows, *row)
+		}
+		sort.Slice(dimensionRows, func(i, j int) bool {
+			if dimensionRows[i].Rated() != dimensionRows[j].Rated() {
+				return dimensionRows[i].Rated() > dimensionRows[j].Rated()
+			}
+			return dimensionRows[i].Value < dimensionRows[j].Value
+		})
+		rows = append(rows, dimensionRows...)
+	}
+
+	return rows
+}
+
+// dimensionValue returns the signal's value for a report dimension
+func dimensionValue(signal Signal, dimension string) string {
+	switch dimension {
+	case "repository":
+		return signal.Repository
+	case "model":
+		return signal.Model
+	case "review_type":
+		return signal.ReviewType
+	case "category":
+		return signal.Category
+	case "severity":
+		return signal.Severity
+	}
+	return ""
+}
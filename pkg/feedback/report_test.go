@@ -0,0 +1,54 @@
+package feedback
+
+import "testing"
+
+func TestBuildReport(t *testing.T) {
+	signals := []Signal{
+		{Repository: "owner/repo", Model: "sonnet", ReviewType: "security", Category: "security", Severity: "critical", ThumbsUp: 1},
+		{Repository: "owner/repo", Model: "sonnet", ReviewType: "security", Category: "security", Severity: "major", Resolved: true},
+		{Repository: "owner/repo", Model: "haiku", ReviewType: "style", Category: "style", Severity: "minor", Outdated: true},
+		{Repository: "owner/other", Model: "haiku", ReviewType: "style", Severity: "minor"},
+	}
+
+	rows := BuildReport(signals)
+
+	find := func(dimension, value string) *PrecisionRow {
+		for i := range rows {
+			if rows[i].Dimension == dimension && rows[i].Value == value {
+				return &rows[i]
+			}
+		}
+		return nil
+	}
+
+	tests := []struct {
+		dimension string
+		value     string
+		expected  PrecisionRow
+	}{
+		{"repository", "owner/repo", PrecisionRow{Useful: 2, NotUseful: 1, Precision: 2.0 / 3}},
+		{"repository", "owner/other", PrecisionRow{NoSignal: 1}},
+		{"model", "sonnet", PrecisionRow{Useful: 1, NotUseful: 1, Precision: 0.5}},
+		{"model", "haiku", PrecisionRow{Useful: 1, NoSignal: 1, Precision: 1}},
+		{"review_type", "security", PrecisionRow{Useful: 1, NotUseful: 1, Precision: 0.5}},
+		{"category", "unknown", PrecisionRow{NoSignal: 1}},
+		{"severity", "minor", PrecisionRow{Useful: 1, NoSignal: 1, Precision: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dimension+"="+tt.value, func(t *testing.T) {
+			row := find(tt.dimension, tt.value)
+			if row == nil {
+				t.Fatalf("expected a row for %s=%s", tt.dimension, tt.value)
+			}
+			if row.Useful != tt.expected.Useful || row.NotUseful != tt.expected.NotUseful ||
+				row.NoSignal != tt.expected.NoSignal || row.Precision != tt.expected.Precision {
+				t.Errorf("expected %+v, got %+v", tt.expected, *row)
+			}
+		})
+	}
+
+	if rows[0].Dimension != "repository" || rows[0].Value != "owner/repo" {
+		t.Errorf("expected rows ordered by dimension then rated count, got first row %+v", rows[0])
+	}
+}
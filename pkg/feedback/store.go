@@ -0,0 +1,234 @@
+package feedback
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Verdicts derived from the feedback on a comment
+const (
+	VerdictUseful    = "useful"
+	VerdictNotUseful = "not_useful"
+	VerdictNoSignal  = "no_signal"
+)
+
+// Signal is the feedback collected for a single comment posted by the agent
+type Signal struct {
+	Repository  string    `json:"repository"`
+	PullRequest int       `json:"pull_request"`
+	CommentID   int64     `json:"comment_id"`
+	Filename    string    `json:"filename"`
+	LineNumber  int       `json:"line_number,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	ReviewType  string    `json:"review_type,omitempty"`
+	Category    string    `json:"category,omitempty"`
+	Severity    string    `json:"severity,omitempty"`
+	ThumbsUp    int       `json:"thumbs_up"`
+	ThumbsDown  int       `json:"thumbs_down"`
+	Resolved    bool      `json:"resolved"`
+	Outdated    bool      `json:"outdated"` // The commented code changed after the comment was posted
+	PostedAt    time.Time `json:"posted_at"`
+	CollectedAt time.Time `json:"collected_at"`
+}
+
+// ResolvedWithoutChange reports whether the thread was resolved while the commented code stayed the same
+func (s Signal) ResolvedWithoutChange() bool {
+	return s.Resolved && !s.Outdated
+}
+
+// Verdict judges whether the comment was useful. Reactions win; otherwise a thread resolved
+// without changing the code counts against the comment and a change to the code counts for it.
+func (s Signal) Verdict() string {
+	switch {
+	case s.ThumbsUp > s.ThumbsDown:
+		return VerdictUseful
+	case s.ThumbsDown > s.ThumbsUp:
+		return VerdictNotUseful
+	case s.ResolvedWithoutChange():
+		return VerdictNotUseful
+	case s.Outdated:
+		return VerdictUseful
+	}
+	return VerdictNoSignal
+}
+
+// key identifies the comment a signal belongs to
+func (s Signal) key() string {
+	return fmt.Sprintf("%s#%d", s.Repository, s.CommentID)
+}
+
+// Filter narrows a feedback query; zero values match everything
+type Filter struct {
+	Repository string
+	Since      time.Time // Comments posted on or after
+	Until      time.Time // Comments posted before
+}
+
+// Matches reports whether the signal satisfies the filter
+func (f Filter) Matches(signal *Signal) bool {
+	if f.Repository != "" && signal.Repository != f.Repository {
+		return false
+	}
+	if !f.Since.IsZero() && signal.PostedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !signal.PostedAt.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// FileStore keeps the latest signal per comment in a local JSON Lines file
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultPath returns the default feedback file location (~/.config/review-agent/feedback.jsonl)
+func DefaultPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "review-feedback.jsonl"
+	}
+	return filepath.Join(homeDir, ".config", "review-agent", "feedback.jsonl")
+}
+
+// NewFileStore creates a file store at the given path, creating parent directories as needed
+func NewFileStore(path string) (*FileStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("feedback file path cannot be empty")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create feedback directory: %w", err)
+	}
+
+	return &FileStore{path: path}, nil
+}
+
+// Path returns the location of the feedback file
+func (s *FileStore) Path() string {
+	return s.path
+}
+
+// Save stores the signals, replacing earlier signals for the same comments
+func (s *FileStore) Save(signals []Signal) error {
+	if len(signals) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, err := s.readAll()
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]int, len(existing))
+	for i, signal := range existing {
+		index[signal.key()] = i
+	}
+	for _, signal := range signals {
+		if i, ok := index[signal.key()]; ok {
+			existing[i] = signal
+			continue
+		}
+		index[signal.key()] = len(existing)
+		existing = append(existing, signal)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated store
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".feedback-*.jsonl")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary feedback file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	for _, signal := range existing {
+		data, err := json.Marshal(signal)
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to marshal feedback signal: %w", err)
+		}
+		writer.Write(append(data, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write feedback file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write feedback file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace feedback file: %w", err)
+	}
+
+	return nil
+}
+
+// Query returns matching signals, most recently posted first
+func (s *FileStore) Query(filter Filter) ([]Signal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+
+	signals := []Signal{}
+	for i := range all {
+		if filter.Matches(&all[i]) {
+			signals = append(signals, all[i])
+		}
+	}
+
+	sort.SliceStable(signals, func(i, j int) bool {
+		return signals[i].PostedAt.After(signals[j].PostedAt)
+	})
+
+	return signals, nil
+}
+
+// readAll loads every signal in the file. Unreadable lines are skipped.
+func (s *FileStore) readAll() ([]Signal, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open feedback file: %w", err)
+	}
+	defer file.Close()
+
+	var signals []Signal
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var signal Signal
+		if err := json.Unmarshal(line, &signal); err != nil {
+			continue // Skip partially written or corrupt lines
+		}
+		signals = append(signals, signal)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading feedback file: %w", err)
+	}
+
+	return signals, nil
+}
@@ -0,0 +1,123 @@
+package feedback
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *FileStore {
+	t.Helper()
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "nested", "feedback.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	return store
+}
+
+func TestSignal_Verdict(t *testing.T) {
+	tests := []struct {
+		name     string
+		signal   Signal
+		expected string
+	}{
+		{name: "thumbs up", signal: Signal{ThumbsUp: 1}, expected: VerdictUseful},
+		{name: "thumbs down", signal: Signal{ThumbsDown: 2, ThumbsUp: 1}, expected: VerdictNotUseful},
+		{name: "reactions outweigh resolution", signal: Signal{ThumbsUp: 1, Resolved: true}, expected: VerdictUseful},
+		{name: "resolved without change", signal: Signal{Resolved: true}, expected: VerdictNotUseful},
+		{name: "code changed after comment", signal: Signal{Outdated: true, Resolved: true}, expected: VerdictUseful},
+		{name: "tied reactions and untouched thread", signal: Signal{ThumbsUp: 1, ThumbsDown: 1}, expected: VerdictNoSignal},
+		{name: "no feedback", signal: Signal{}, expected: VerdictNoSignal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.signal.Verdict(); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFileStore_SaveReplacesAndQueries(t *testing.T) {
+	store := newTestStore(t)
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	initial := []Signal{
+		{Repository: "owner/repo", CommentID: 1, PostedAt: base},
+		{Repository: "owner/repo", CommentID: 2, PostedAt: base.Add(time.Hour)},
+		{Repository: "owner/other", CommentID: 1, PostedAt: base.Add(2 * time.Hour)},
+	}
+	if err := store.Save(initial); err != nil {
+		t.Fatalf("unexpected save error: %v", err)
+	}
+	if err := store.Save([]Signal{{Repository: "owner/repo", CommentID: 1, ThumbsUp: 3, PostedAt: base}}); err != nil {
+		t.Fatalf("unexpected save error: %v", err)
+	}
+
+	all, err := store.Query(Filter{})
+	if err != nil {
+		t.Fatalf("unexpected query error: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected 3 signals after replacing one, got %d", len(all))
+	}
+	if all[2].CommentID != 1 || all[2].ThumbsUp != 3 {
+		t.Errorf("expected the latest signal to replace the earlier one, got %+v", all[2])
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{name: "newest first", filter: Filter{}, expected: []string{"owner/other#1", "owner/repo#2", "owner/repo#1"}},
+		{name: "by repository", filter: Filter{Repository: "owner/repo"}, expected: []string{"owner/repo#2", "owner/repo#1"}},
+		{name: "by date range", filter: Filter{Since: base.Add(time.Minute), Until: base.Add(2 * time.Hour)}, expected: []string{"owner/repo#2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signals, err := store.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("unexpected query error: %v", err)
+			}
+			var keys []string
+			for _, signal := range signals {
+				keys = append(keys, signal.key())
+			}
+			if !reflect.DeepEqual(keys, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, keys)
+			}
+		})
+	}
+}
+
+func TestFileStore_SkipsCorruptLines(t *testing.T) {
+	store := newTestStore(t)
+	content := `{"repository":"owner/repo","comment_id":1}
+{"repository":"owner/re
+`
+	if err := os.WriteFile(store.Path(), []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write feedback file: %v", err)
+	}
+
+	signals, err := store.Query(Filter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(signals) != 1 {
+		t.Errorf("expected 1 readable signal, got %d", len(signals))
+	}
+}
+
+func TestFileStore_MissingFile(t *testing.T) {
+	signals, err := newTestStore(t).Query(Filter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(signals) != 0 {
+		t.Errorf("expected no signals, got %d", len(signals))
+	}
+}
@@ -4,16 +4,31 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/gitsafe"
 )
 
+// ErrNotFound is wrapped by the errors returned for files and API resources that do not exist
+var ErrNotFound = errors.New("not found")
+
+// statusError describes an unsuccessful API response, wrapping ErrNotFound for a 404
+func statusError(statusCode int) error {
+	if statusCode == http.StatusNotFound {
+		return fmt.Errorf("GitHub API returned status %d: %w", statusCode, ErrNotFound)
+	}
+	return fmt.Errorf("GitHub API returned status %d", statusCode)
+}
+
 type CommandExecutor interface {
 	Execute(command string, args ...string) error
 	ExecuteInDir(dir, command string, args ...string) error
@@ -23,20 +38,26 @@ type CommandExecutor interface {
 type defaultCommandExecutor struct{}
 
 func (d *defaultCommandExecutor) Execute(command string, args ...string) error {
-	cmd := exec.Command(command, args...)
-	return cmd.Run()
+	return newCommand("", command, args...).Run()
 }
 
 func (d *defaultCommandExecutor) ExecuteInDir(dir, command string, args ...string) error {
-	cmd := exec.Command(command, args...)
-	cmd.Dir = dir
-	return cmd.Run()
+	return newCommand(dir, command, args...).Run()
 }
 
 func (d *defaultCommandExecutor) ExecuteInDirWithOutput(dir, command string, args ...string) ([]byte, error) {
+	return newCommand(dir, command, args...).Output()
+}
+
+// newCommand creates a command in dir. Git runs hardened, since the repositories hold code from
+// pull requests that must never be executed.
+func newCommand(dir, command string, args ...string) *exec.Cmd {
+	if command == "git" {
+		return gitsafe.Command(context.Background(), dir, args...)
+	}
 	cmd := exec.Command(command, args...)
 	cmd.Dir = dir
-	return cmd.Output()
+	return cmd
 }
 
 type Client struct {
@@ -63,25 +84,34 @@ type User struct {
 
 // CreatePullRequestCommentRequest represents a request to create a PR comment
 type CreatePullRequestCommentRequest struct {
-	Body     string `json:"body"`
-	Path     string `json:"path"`
-	Line     int    `json:"line,omitempty"`
-	Side     string `json:"side,omitempty"`
-	CommitID string `json:"commit_id"`
-}
-
-// PullRequestComment represents a comment on a pull request
-type PullRequestComment struct {
-	ID        int64  `json:"id"`
 	Body      string `json:"body"`
 	Path      string `json:"path"`
+	StartLine int    `json:"start_line,omitempty"` // First line of a multi-line comment
+	StartSide string `json:"start_side,omitempty"`
 	Line      int    `json:"line,omitempty"`
 	Side      string `json:"side,omitempty"`
 	CommitID  string `json:"commit_id"`
-	User      User   `json:"user"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
-	HTMLURL   string `json:"html_url"`
+}
+
+// PullRequestComment represents a comment on a pull request
+type PullRequestComment struct {
+	ID        int64     `json:"id"`
+	Body      string    `json:"body"`
+	Path      string    `json:"path"`
+	Line      int       `json:"line,omitempty"`
+	Side      string    `json:"side,omitempty"`
+	CommitID  string    `json:"commit_id"`
+	User      User      `json:"user"`
+	CreatedAt string    `json:"created_at"`
+	UpdatedAt string    `json:"updated_at"`
+	HTMLURL   string    `json:"html_url"`
+	Reactions Reactions `json:"reactions"`
+}
+
+// Reactions counts the thumbs reactions on a comment
+type Reactions struct {
+	ThumbsUp   int `json:"+1"`
+	ThumbsDown int `json:"-1"`
 }
 
 // CommentPostingResult represents the result of batch comment posting
@@ -136,7 +166,7 @@ func (c *Client) makeRequest(ctx context.Context, method, endpoint string) (*htt
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		resp.Body.Close()
-		return nil, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+		return nil, statusError(resp.StatusCode)
 	}
 
 	return resp, nil
@@ -173,14 +203,17 @@ func (c *Client) GetUser(ctx context.Context, username string) (*User, error) {
 	return &user, nil
 }
 
+// AuthenticatedCloneURL returns the HTTPS URL of a repository with the access token embedded
+func (c *Client) AuthenticatedCloneURL(owner, repo string) string {
+	return fmt.Sprintf("https://x-access-token:%s@github.com/%s/%s.git", c.token, owner, repo)
+}
+
 func (c *Client) CloneRepository(ctx context.Context, owner, repo, destination string) error {
 	if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
 		return fmt.Errorf("failed to create parent directories: %w", err)
 	}
 
-	cloneURL := fmt.Sprintf("https://x-access-token:%s@github.com/%s/%s.git", c.token, owner, repo)
-
-	if err := c.cmdExecutor.Execute("git", "clone", cloneURL, destination); err != nil {
+	if err := c.cmdExecutor.Execute("git", "clone", "--no-recurse-submodules", c.AuthenticatedCloneURL(owner, repo), destination); err != nil {
 		return fmt.Errorf("failed to clone repository %s/%s: %w", owner, repo, err)
 	}
 
@@ -210,6 +243,141 @@ func (c *Client) CheckoutBranch(ctx context.Context, repoPath, branch string) er
 	return nil
 }
 
+// CheckoutPullRequest checks out the head commit of a pull request as branch (detached when branch
+// is empty). The head is fetched from the base repository's refs/pull/<n>/head, so pull requests
+// from forks work too; refs/pull/<n>/merge is fetched as well while GitHub has one. When headSHA is
+// set, exactly that commit is checked out and HEAD is verified against it.
+func (c *Client) CheckoutPullRequest(ctx context.Context, repoPath string, prNumber int, branch, headSHA string) error {
+	if err := c.configureGitAuth(repoPath); err != nil {
+		return fmt.Errorf("failed to configure git authentication: %w", err)
+	}
+
+	headRef := fmt.Sprintf("refs/remotes/origin/pr/%d/head", prNumber)
+	if err := c.cmdExecutor.ExecuteInDir(repoPath, "git", "fetch", "--quiet", "origin",
+		fmt.Sprintf("+refs/pull/%d/head:%s", prNumber, headRef)); err != nil {
+		return fmt.Errorf("failed to fetch pull request #%d: %w", prNumber, err)
+	}
+	// Missing when the pull request has conflicts or is closed
+	_ = c.cmdExecutor.ExecuteInDir(repoPath, "git", "fetch", "--quiet", "origin",
+		fmt.Sprintf("+refs/pull/%d/merge:refs/remotes/origin/pr/%d/merge", prNumber, prNumber))
+
+	target := headSHA
+	if target == "" {
+		target = headRef
+	}
+	args := []string{"checkout", "--quiet", "--detach", target}
+	if branch != "" {
+		args = []string{"checkout", "--quiet", "-B", branch, target}
+	}
+	if err := c.cmdExecutor.ExecuteInDir(repoPath, "git", args...); err != nil {
+		if headSHA == "" {
+			return fmt.Errorf("failed to check out pull request #%d: %w", prNumber, err)
+		}
+		// The head moved since the event was sent; fetch the commit itself
+		if fetchErr := c.cmdExecutor.ExecuteInDir(repoPath, "git", "fetch", "--quiet", "origin", headSHA); fetchErr != nil {
+			return fmt.Errorf("head commit %s of pull request #%d is not available: %w", headSHA, prNumber, fetchErr)
+		}
+		if err := c.cmdExecutor.ExecuteInDir(repoPath, "git", args...); err != nil {
+			return fmt.Errorf("failed to check out %s of pull request #%d: %w", headSHA, prNumber, err)
+		}
+	}
+
+	if headSHA == "" {
+		return nil
+	}
+	output, err := c.cmdExecutor.ExecuteInDirWithOutput(repoPath, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return fmt.Errorf("failed to verify checkout of pull request #%d: %w", prNumber, err)
+	}
+	if head := strings.TrimSpace(string(output)); !strings.HasPrefix(head, headSHA) {
+		return fmt.Errorf("checked out %s but the head of pull request #%d is %s", head, prNumber, headSHA)
+	}
+
+	return nil
+}
+
+// ReadFileAtRef reads a file as it exists at the given ref of a cloned repository. The error
+// wraps ErrNotFound when the ref exists but has no such file.
+func (c *Client) ReadFileAtRef(ctx context.Context, repoPath, ref, path string) ([]byte, error) {
+	output, err := c.cmdExecutor.ExecuteInDirWithOutput(repoPath, "git", "show", ref+":"+path)
+	if err != nil {
+		// git show fails the same way for a missing file and a missing ref; ls-tree tells them apart
+		listing, listErr := c.cmdExecutor.ExecuteInDirWithOutput(repoPath, "git", "ls-tree", "--name-only", ref, "--", path)
+		if listErr == nil && len(bytes.TrimSpace(listing)) == 0 {
+			return nil, fmt.Errorf("failed to read %s at %s: %w", path, ref, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to read %s at %s: %w", path, ref, err)
+	}
+
+	return output, nil
+}
+
+// GetFileContent reads a file of a repository at the given ref through the contents API, without a
+// clone. The error wraps ErrNotFound when the file or ref does not exist.
+func (c *Client) GetFileContent(ctx context.Context, owner, repo, path, ref string) ([]byte, error) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	endpoint := fmt.Sprintf("/repos/%s/%s/contents/%s?ref=%s", owner, repo, strings.Join(segments, "/"), url.QueryEscape(ref))
+
+	resp, err := c.makeRequestWithCustomAccept(ctx, "GET", endpoint, "application/vnd.github.raw")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s at %s: %w", path, ref, err)
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", path, ref, err)
+	}
+
+	return content, nil
+}
+
+// Identity used for commits made by the agent
+const (
+	commitAuthorName  = "review-agent"
+	commitAuthorEmail = "review-agent@users.noreply.github.com"
+)
+
+// CommitAndPush commits the given files of a cloned repository and pushes the commit to branch
+// on origin, returning the new commit SHA. With force set an existing branch is overwritten.
+func (c *Client) CommitAndPush(ctx context.Context, repoPath, branch, message string, files []string, force bool) (string, error) {
+	if len(files) == 0 {
+		return "", fmt.Errorf("no files to commit")
+	}
+
+	addArgs := append([]string{"add", "--"}, files...)
+	if err := c.cmdExecutor.ExecuteInDir(repoPath, "git", addArgs...); err != nil {
+		return "", fmt.Errorf("failed to stage changes: %w", err)
+	}
+
+	if err := c.cmdExecutor.ExecuteInDir(repoPath, "git",
+		"-c", "user.name="+commitAuthorName,
+		"-c", "user.email="+commitAuthorEmail,
+		"commit", "--no-verify", "-m", message); err != nil {
+		return "", fmt.Errorf("failed to commit changes: %w", err)
+	}
+
+	output, err := c.cmdExecutor.ExecuteInDirWithOutput(repoPath, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to read commit SHA: %w", err)
+	}
+	sha := strings.TrimSpace(string(output))
+
+	pushArgs := []string{"push"}
+	if force {
+		pushArgs = append(pushArgs, "--force")
+	}
+	pushArgs = append(pushArgs, "origin", "HEAD:refs/heads/"+branch)
+	if err := c.cmdExecutor.ExecuteInDir(repoPath, "git", pushArgs...); err != nil {
+		return "", fmt.Errorf("failed to push to branch %s: %w", branch, err)
+	}
+
+	return sha, nil
+}
+
 // configureGitAuth configures git authentication for the repository
 // by updating the remote origin URL to include the access token
 func (c *Client) configureGitAuth(repoPath string) error {
@@ -228,8 +396,7 @@ func (c *Client) configureGitAuth(repoPath string) error {
 	}
 
 	// Set the remote URL with authentication token
-	authenticatedURL := fmt.Sprintf("https://x-access-token:%s@github.com/%s/%s.git", c.token, owner, repo)
-	if err := c.cmdExecutor.ExecuteInDir(repoPath, "git", "remote", "set-url", "origin", authenticatedURL); err != nil {
+	if err := c.cmdExecutor.ExecuteInDir(repoPath, "git", "remote", "set-url", "origin", c.AuthenticatedCloneURL(owner, repo)); err != nil {
 		return fmt.Errorf("failed to set authenticated remote URL: %w", err)
 	}
 
@@ -319,7 +486,7 @@ func (c *Client) makeRequestWithCustomAccept(ctx context.Context, method, endpoi
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		resp.Body.Close()
-		return nil, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+		return nil, statusError(resp.StatusCode)
 	}
 
 	return resp, nil
@@ -382,8 +549,11 @@ func (c *Client) GetPullRequestDiffWithFiles(ctx context.Context, owner, repo st
 
 // makeRequestWithBody makes an HTTP request with a JSON body
 func (c *Client) makeRequestWithBody(ctx context.Context, method, endpoint string, body interface{}) (*http.Response, error) {
-	url := c.baseURL + endpoint
+	return c.makeJSONRequest(ctx, method, c.baseURL+endpoint, body)
+}
 
+// makeJSONRequest makes an HTTP request with a JSON body to an absolute URL
+func (c *Client) makeJSONRequest(ctx context.Context, method, url string, body interface{}) (*http.Response, error) {
 	var reqBody *bytes.Buffer
 	if body != nil {
 		jsonBody, err := json.Marshal(body)
@@ -412,7 +582,7 @@ func (c *Client) makeRequestWithBody(ctx context.Context, method, endpoint strin
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		resp.Body.Close()
-		return nil, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+		return nil, statusError(resp.StatusCode)
 	}
 
 	return resp, nil
@@ -481,6 +651,13 @@ type ReviewCommentInput struct {
 	Filename   string
 	LineNumber int
 	Comment    string
+
+	// Suggestion is replacement code for lines StartLine..LineNumber. When Anchored is set the
+	// range lies inside one diff hunk and it is posted as an applicable suggestion block;
+	// otherwise it is shown as a plain code block on LineNumber.
+	Suggestion string
+	StartLine  int
+	Anchored   bool
 }
 
 // ConvertReviewCommentToGitHub converts a ReviewCommentInput to GitHub API format
@@ -490,13 +667,38 @@ func ConvertReviewCommentToGitHub(reviewComment ReviewCommentInput, commitID str
 		return CreatePullRequestCommentRequest{}, false
 	}
 
-	return CreatePullRequestCommentRequest{
+	request := CreatePullRequestCommentRequest{
 		Body:     reviewComment.Comment,
 		Path:     reviewComment.Filename,
 		Line:     reviewComment.LineNumber,
 		Side:     "RIGHT", // Always comment on the new version
 		CommitID: commitID,
-	}, true
+	}
+
+	if strings.TrimSpace(reviewComment.Suggestion) == "" {
+		return request, true
+	}
+
+	if reviewComment.Anchored {
+		request.Body += "\n\n" + fenceCode("suggestion", reviewComment.Suggestion)
+		if reviewComment.StartLine > 0 && reviewComment.StartLine < reviewComment.LineNumber {
+			request.StartLine = reviewComment.StartLine
+			request.StartSide = "RIGHT"
+		}
+	} else {
+		request.Body += "\n\n" + fenceCode("", reviewComment.Suggestion)
+	}
+
+	return request, true
+}
+
+// fenceCode wraps code in a fenced block, using a fence longer than any backtick run in the code
+func fenceCode(info, code string) string {
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + info + "\n" + strings.TrimRight(code, "\n") + "\n" + fence
 }
 
 // CreateIssueComment creates a general comment on an issue/PR
@@ -3,9 +3,11 @@ package github
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -354,7 +356,211 @@ func TestCheckoutBranch(t *testing.T) {
 	}
 }
 
+func TestCheckoutPullRequest(t *testing.T) {
+	const headSHA = "1234567890abcdef1234567890abcdef12345678"
+
+	tests := []struct {
+		name          string
+		head          string
+		missingCommit bool // The first checkout fails until the commit itself is fetched
+		errorContains string
+		expectFetches []string
+	}{
+		{
+			name:          "fork head checked out by SHA",
+			head:          headSHA,
+			expectFetches: []string{"+refs/pull/7/head:refs/remotes/origin/pr/7/head", "+refs/pull/7/merge:refs/remotes/origin/pr/7/merge"},
+		},
+		{
+			name:          "commit fetched when the head moved",
+			head:          headSHA,
+			missingCommit: true,
+			expectFetches: []string{"+refs/pull/7/head:refs/remotes/origin/pr/7/head", "+refs/pull/7/merge:refs/remotes/origin/pr/7/merge", headSHA},
+		},
+		{
+			name:          "checked-out commit must match the event",
+			head:          "ffffffffffffffffffffffffffffffffffffffff",
+			errorContains: "but the head of pull request #7 is ffffffff",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetches []string
+			var checkouts [][]string
+			fetchedCommit := false
+			mockExecutor := &mockCommandExecutor{
+				executeInDirFunc: func(dir, command string, args ...string) error {
+					switch args[0] {
+					case "fetch":
+						fetches = append(fetches, args[len(args)-1])
+						if args[len(args)-1] == headSHA {
+							fetchedCommit = true
+						}
+					case "checkout":
+						checkouts = append(checkouts, args)
+						if tt.missingCommit && !fetchedCommit {
+							return fmt.Errorf("reference is not a tree")
+						}
+					}
+					return nil
+				},
+				executeWithOutputFunc: func(dir, command string, args ...string) ([]byte, error) {
+					if args[0] == "remote" {
+						return []byte("https://github.com/owner/repo.git\n"), nil
+					}
+					return []byte(headSHA + "\n"), nil
+				},
+			}
+
+			client := &Client{token: "test-token", cmdExecutor: mockExecutor}
+			err := client.CheckoutPullRequest(context.Background(), "/repo", 7, "feature", tt.head)
+
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Fatalf("expected error containing %q, got %v", tt.errorContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(fetches, " ") != strings.Join(tt.expectFetches, " ") {
+				t.Errorf("expected fetches %v, got %v", tt.expectFetches, fetches)
+			}
+			last := checkouts[len(checkouts)-1]
+			if strings.Join(last, " ") != "checkout --quiet -B feature "+headSHA {
+				t.Errorf("expected the head SHA on the branch, got %v", last)
+			}
+		})
+	}
+}
+
 // Mock command executor for testing
+func TestReadFileAtRef(t *testing.T) {
+	mockExecutor := &mockCommandExecutor{
+		executeWithOutputFunc: func(dir, command string, args ...string) ([]byte, error) {
+			if dir != "/repo" || command != "git" {
+				return nil, fmt.Errorf("unexpected command: %s %v in %s", command, args, dir)
+			}
+			if args[0] == "ls-tree" {
+				// The ref exists without the file; an unknown ref fails
+				if args[2] != "base123" {
+					return nil, fmt.Errorf("exit status 128")
+				}
+				return nil, nil
+			}
+			if len(args) != 2 || args[0] != "show" {
+				return nil, fmt.Errorf("unexpected command: %s %v in %s", command, args, dir)
+			}
+			if args[1] != "base123:.review-agent.yml" {
+				return nil, fmt.Errorf("exit status 128")
+			}
+			return []byte("severity_threshold: major\n"), nil
+		},
+	}
+
+	client := &Client{
+		token:       "test-token",
+		cmdExecutor: mockExecutor,
+	}
+
+	data, err := client.ReadFileAtRef(context.Background(), "/repo", "base123", ".review-agent.yml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != "severity_threshold: major\n" {
+		t.Errorf("unexpected content: %q", string(data))
+	}
+
+	if _, err := client.ReadFileAtRef(context.Background(), "/repo", "base123", "missing.yml"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a not found error for a missing file, got %v", err)
+	}
+	if _, err := client.ReadFileAtRef(context.Background(), "/repo", "unknown", ".review-agent.yml"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an error other than not found for an unknown ref, got %v", err)
+	}
+}
+
+func TestGetFileContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/repos/owner/repo/contents/dir/my%20file.go" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("ref") != "abc123" {
+			t.Errorf("expected ref abc123, got %q", r.URL.Query().Get("ref"))
+		}
+		if r.Header.Get("Accept") != "application/vnd.github.raw" {
+			t.Errorf("expected the raw media type, got %q", r.Header.Get("Accept"))
+		}
+		_, _ = w.Write([]byte("package main\n"))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-token")
+	client.baseURL = server.URL
+
+	content, err := client.GetFileContent(context.Background(), "owner", "repo", "dir/my file.go", "abc123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(content) != "package main\n" {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	if _, err := client.GetFileContent(context.Background(), "owner", "repo", "missing.go", "abc123"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a not found error for a missing file, got %v", err)
+	}
+}
+
+func TestCommitAndPush(t *testing.T) {
+	tests := []struct {
+		name         string
+		force        bool
+		expectedPush string
+	}{
+		{name: "fixes branch is overwritten", force: true, expectedPush: "push --force origin HEAD:refs/heads/review-agent/fixes-42"},
+		{name: "pull request branch is fast-forwarded", force: false, expectedPush: "push origin HEAD:refs/heads/feature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var commands []string
+			mockExecutor := &mockCommandExecutor{
+				executeInDirFunc: func(dir, command string, args ...string) error {
+					commands = append(commands, strings.Join(args, " "))
+					return nil
+				},
+				executeWithOutputFunc: func(dir, command string, args ...string) ([]byte, error) {
+					return []byte("fedcba987654\n"), nil
+				},
+			}
+			client := &Client{token: "test-token", cmdExecutor: mockExecutor}
+
+			branch := "feature"
+			if tt.force {
+				branch = "review-agent/fixes-42"
+			}
+			sha, err := client.CommitAndPush(context.Background(), "/repo", branch, "Apply fixes", []string{"main.go"}, tt.force)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if sha != "fedcba987654" {
+				t.Errorf("expected trimmed SHA, got %q", sha)
+			}
+
+			expected := []string{
+				"add -- main.go",
+				"-c user.name=review-agent -c user.email=review-agent@users.noreply.github.com commit --no-verify -m Apply fixes",
+				tt.expectedPush,
+			}
+			if !reflect.DeepEqual(commands, expected) {
+				t.Errorf("expected commands %v, got %v", expected, commands)
+			}
+		})
+	}
+}
+
 type mockCommandExecutor struct {
 	executeFunc           func(command string, args ...string) error
 	executeInDirFunc      func(dir, command string, args ...string) error
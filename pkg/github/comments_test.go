@@ -222,6 +222,66 @@ func TestConvertReviewCommentToGitHub(t *testing.T) {
 			},
 			shouldConvert: true,
 		},
+		{
+			name: "anchored multi-line suggestion",
+			reviewComment: ReviewCommentInput{
+				Filename:   "main.go",
+				LineNumber: 12,
+				StartLine:  10,
+				Comment:    "Close the file on every path",
+				Suggestion: "f, err := os.Open(name)\nif err != nil {\n\treturn err\n}\n",
+				Anchored:   true,
+			},
+			commitID: "abc123def456",
+			expectedResult: CreatePullRequestCommentRequest{
+				Body:      "Close the file on every path\n\n```suggestion\nf, err := os.Open(name)\nif err != nil {\n\treturn err\n}\n```",
+				Path:      "main.go",
+				StartLine: 10,
+				StartSide: "RIGHT",
+				Line:      12,
+				Side:      "RIGHT",
+				CommitID:  "abc123def456",
+			},
+			shouldConvert: true,
+		},
+		{
+			name: "anchored single-line suggestion has no start line",
+			reviewComment: ReviewCommentInput{
+				Filename:   "main.go",
+				LineNumber: 7,
+				StartLine:  7,
+				Comment:    "Use the constant",
+				Suggestion: "timeout := defaultTimeout",
+				Anchored:   true,
+			},
+			commitID: "abc123def456",
+			expectedResult: CreatePullRequestCommentRequest{
+				Body:     "Use the constant\n\n```suggestion\ntimeout := defaultTimeout\n```",
+				Path:     "main.go",
+				Line:     7,
+				Side:     "RIGHT",
+				CommitID: "abc123def456",
+			},
+			shouldConvert: true,
+		},
+		{
+			name: "unanchored suggestion falls back to a code block",
+			reviewComment: ReviewCommentInput{
+				Filename:   "main.go",
+				LineNumber: 30,
+				Comment:    "Quote the value",
+				Suggestion: "fmt.Printf(\"```%s```\", v)",
+			},
+			commitID: "abc123def456",
+			expectedResult: CreatePullRequestCommentRequest{
+				Body:     "Quote the value\n\n````\nfmt.Printf(\"```%s```\", v)\n````",
+				Path:     "main.go",
+				Line:     30,
+				Side:     "RIGHT",
+				CommitID: "abc123def456",
+			},
+			shouldConvert: true,
+		},
 		{
 			name: "general file comment (line 0)",
 			reviewComment: ReviewCommentInput{
@@ -265,6 +325,11 @@ func TestConvertReviewCommentToGitHub(t *testing.T) {
 					t.Errorf("expected line %d, got %d", tt.expectedResult.Line, result.Line)
 				}
 
+				if result.StartLine != tt.expectedResult.StartLine || result.StartSide != tt.expectedResult.StartSide {
+					t.Errorf("expected start line %d (%q), got %d (%q)", tt.expectedResult.StartLine, tt.expectedResult.StartSide,
+						result.StartLine, result.StartSide)
+				}
+
 				if result.Side != tt.expectedResult.Side {
 					t.Errorf("expected side '%s', got '%s'", tt.expectedResult.Side, result.Side)
 				}
@@ -0,0 +1,115 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxPullRequestCommits caps how many commits are fetched for review context (GitHub returns at most 250)
+const maxPullRequestCommits = 250
+
+// PullRequestCommit is a commit on a pull request
+type PullRequestCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Message string `json:"message"`
+	} `json:"commit"`
+}
+
+// Issue is a GitHub issue referenced by a pull request
+type Issue struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	State  string `json:"state"`
+}
+
+// IssueReference points at an issue, possibly in another repository
+type IssueReference struct {
+	Owner  string
+	Repo   string
+	Number int
+}
+
+// closingKeywordPattern matches GitHub's closing keywords, e.g. "Fixes #123" or "resolves org/repo#45"
+var closingKeywordPattern = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?)\s*:?\s+(?:([\w.-]+)/([\w.-]+))?#(\d+)\b`)
+
+// GetPullRequestCommits fetches the commits on a pull request, oldest first
+func (c *Client) GetPullRequestCommits(ctx context.Context, owner, repo string, prNumber int) ([]PullRequestCommit, error) {
+	var commits []PullRequestCommit
+	for page := 1; len(commits) < maxPullRequestCommits; page++ {
+		endpoint := fmt.Sprintf("/repos/%s/%s/pulls/%d/commits?per_page=100&page=%d", owner, repo, prNumber, page)
+		resp, err := c.makeRequest(ctx, "GET", endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get PR commits: %w", err)
+		}
+
+		var pageCommits []PullRequestCommit
+		err = json.NewDecoder(resp.Body).Decode(&pageCommits)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode PR commits response: %w", err)
+		}
+
+		commits = append(commits, pageCommits...)
+		if len(pageCommits) < 100 {
+			break
+		}
+	}
+
+	if len(commits) > maxPullRequestCommits {
+		commits = commits[:maxPullRequestCommits]
+	}
+	return commits, nil
+}
+
+// GetIssue fetches a single issue
+func (c *Client) GetIssue(ctx context.Context, owner, repo string, issueNumber int) (*Issue, error) {
+	endpoint := fmt.Sprintf("/repos/%s/%s/issues/%d", owner, repo, issueNumber)
+	resp, err := c.makeRequest(ctx, "GET", endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get issue #%d: %w", issueNumber, err)
+	}
+	defer resp.Body.Close()
+
+	var issue Issue
+	if err := json.NewDecoder(resp.Body).Decode(&issue); err != nil {
+		return nil, fmt.Errorf("failed to decode issue response: %w", err)
+	}
+
+	return &issue, nil
+}
+
+// ParseIssueReferences finds issues closed by closing keywords in the given texts.
+// References without an explicit repository resolve to owner/repo; duplicates are dropped.
+func ParseIssueReferences(owner, repo string, texts ...string) []IssueReference {
+	var refs []IssueReference
+	seen := make(map[string]bool)
+
+	for _, text := range texts {
+		for _, match := range closingKeywordPattern.FindAllStringSubmatch(text, -1) {
+			number, err := strconv.Atoi(match[3])
+			if err != nil || number <= 0 {
+				continue
+			}
+
+			ref := IssueReference{Owner: owner, Repo: repo, Number: number}
+			if match[1] != "" {
+				ref.Owner, ref.Repo = match[1], match[2]
+			}
+
+			key := strings.ToLower(fmt.Sprintf("%s/%s#%d", ref.Owner, ref.Repo, ref.Number))
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	return refs
+}
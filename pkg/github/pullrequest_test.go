@@ -0,0 +1,104 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetPullRequestCommits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/owner/repo/pulls/7/commits" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page") == "1" {
+			// A full page means another page may follow
+			commits := make([]string, 100)
+			for i := range commits {
+				commits[i] = fmt.Sprintf(`{"sha":"sha%d","commit":{"message":"commit %d"}}`, i, i)
+			}
+			_, _ = w.Write([]byte("[" + strings.Join(commits, ",") + "]"))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"sha":"last","commit":{"message":"Fix login redirect\n\nFixes #12"}}]`))
+	}))
+	defer server.Close()
+
+	client := &Client{token: "test-token", baseURL: server.URL, httpClient: &http.Client{}}
+
+	commits, err := client.GetPullRequestCommits(context.Background(), "owner", "repo", 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commits) != 101 {
+		t.Fatalf("expected 101 commits across pages, got %d", len(commits))
+	}
+	if last := commits[100]; last.SHA != "last" || last.Commit.Message != "Fix login redirect\n\nFixes #12" {
+		t.Errorf("unexpected last commit: %+v", last)
+	}
+}
+
+func TestGetIssue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/owner/repo/issues/12" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"number":12,"title":"Login loops","body":"Redirects forever","state":"open"}`))
+	}))
+	defer server.Close()
+
+	client := &Client{token: "test-token", baseURL: server.URL, httpClient: &http.Client{}}
+
+	issue, err := client.GetIssue(context.Background(), "owner", "repo", 12)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if issue.Number != 12 || issue.Title != "Login loops" || issue.Body != "Redirects forever" {
+		t.Errorf("unexpected issue: %+v", issue)
+	}
+}
+
+func TestParseIssueReferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		texts    []string
+		expected []IssueReference
+	}{
+		{
+			name:     "closing keywords",
+			texts:    []string{"Fixes #12 and closes #13.\nResolved: #14"},
+			expected: []IssueReference{{"owner", "repo", 12}, {"owner", "repo", 13}, {"owner", "repo", 14}},
+		},
+		{
+			name:     "other repository",
+			texts:    []string{"fix other-org/other.repo#5"},
+			expected: []IssueReference{{"other-org", "other.repo", 5}},
+		},
+		{
+			name:     "plain mentions are not links",
+			texts:    []string{"Related to #20, see #21", "prefix#22"},
+			expected: nil,
+		},
+		{
+			name:     "duplicates across texts",
+			texts:    []string{"Fixes #12", "fixed #12", "FIXES OWNER/REPO#12"},
+			expected: []IssueReference{{"owner", "repo", 12}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseIssueReferences("owner", "repo", tt.texts...)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReviewThread represents a review thread on a pull request
+type ReviewThread struct {
+	ID           string               `json:"id"`
+	IsResolved   bool                 `json:"is_resolved"`
+	IsOutdated   bool                 `json:"is_outdated"`
+	Path         string               `json:"path"`
+	Line         int                  `json:"line,omitempty"`          // Current line in the head commit, 0 when outdated
+	OriginalLine int                  `json:"original_line,omitempty"` // Line in the commit the thread was created on
+	Comments     []PullRequestComment `json:"comments"`
+}
+
+// graphQLRequest is the body of a GitHub GraphQL API request
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// graphQLError is a single error returned by the GitHub GraphQL API
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+const reviewThreadsQuery = `query($owner: String!, $repo: String!, $number: Int!, $after: String) {
+  repository(owner: $owner, name: $repo) {
+    pullRequest(number: $number) {
+      reviewThreads(first: 100, after: $after) {
+        pageInfo { hasNextPage endCursor }
+        nodes {
+          id
+          isResolved
+          isOutdated
+          path
+          line
+          originalLine
+          comments(first: 50) {
+            nodes {
+              databaseId body author { login } createdAt url
+              reactionGroups { content reactors { totalCount } }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+const resolveReviewThreadMutation = `mutation($threadId: ID!) {
+  resolveReviewThread(input: {threadId: $threadId}) {
+    thread { id isResolved }
+  }
+}`
+
+// graphQLURL returns the GraphQL endpoint for the REST base URL. GitHub Enterprise Server serves
+// the REST API under /api/v3 and GraphQL at /api/graphql.
+func (c *Client) graphQLURL() string {
+	base := strings.TrimSuffix(c.baseURL, "/")
+	if strings.HasSuffix(base, "/api/v3") {
+		return strings.TrimSuffix(base, "/v3") + "/graphql"
+	}
+	return base + "/graphql"
+}
+
+// graphQL executes a GraphQL query and decodes the data field into out
+func (c *Client) graphQL(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	resp, err := c.makeJSONRequest(ctx, "POST", c.graphQLURL(), graphQLRequest{Query: query, Variables: variables})
+	if err != nil {
+		return fmt.Errorf("GraphQL request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read GraphQL response: %w", err)
+	}
+
+	var envelope struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []graphQLError  `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("failed to decode GraphQL response: %w", err)
+	}
+	if len(envelope.Errors) > 0 {
+		return fmt.Errorf("GraphQL error: %s", envelope.Errors[0].Message)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			return fmt.Errorf("failed to decode GraphQL data: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetReviewThreads retrieves all review threads on a pull request
+func (c *Client) GetReviewThreads(ctx context.Context, owner, repo string, prNumber int) ([]ReviewThread, error) {
+	var threads []ReviewThread
+	var cursor *string
+
+	for {
+		var data struct {
+			Repository struct {
+				PullRequest struct {
+					ReviewThreads struct {
+						PageInfo struct {
+							HasNextPage bool   `json:"hasNextPage"`
+							EndCursor   string `json:"endCursor"`
+						} `json:"pageInfo"`
+						Nodes []struct {
+							ID           string `json:"id"`
+							IsResolved   bool   `json:"isResolved"`
+							IsOutdated   bool   `json:"isOutdated"`
+							Path         string `json:"path"`
+							Line         int    `json:"line"`
+							OriginalLine int    `json:"originalLine"`
+							Comments     struct {
+								Nodes []struct {
+									DatabaseID int64  `json:"databaseId"`
+									Body       string `json:"body"`
+									Author     struct {
+										Login string `json:"login"`
+									} `json:"author"`
+									CreatedAt      string `json:"createdAt"`
+									URL            string `json:"url"`
+									ReactionGroups []struct {
+										Content  string `json:"content"`
+										Reactors struct {
+											TotalCount int `json:"totalCount"`
+										} `json:"reactors"`
+									} `json:"reactionGroups"`
+								} `json:"nodes"`
+							} `json:"comments"`
+						} `json:"nodes"`
+					} `json:"reviewThreads"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		}
+
+		variables := map[string]interface{}{
+			"owner":  owner,
+			"repo":   repo,
+			"number": prNumber,
+			"after":  cursor,
+		}
+		if err := c.graphQL(ctx, reviewThreadsQuery, variables, &data); err != nil {
+			return nil, fmt.Errorf("failed to get review threads: %w", err)
+		}
+
+		page := data.Repository.PullRequest.ReviewThreads
+		for _, node := range page.Nodes {
+			thread := ReviewThread{
+				ID:           node.ID,
+				IsResolved:   node.IsResolved,
+				IsOutdated:   node.IsOutdated,
+				Path:         node.Path,
+				Line:         node.Line,
+				OriginalLine: node.OriginalLine,
+			}
+			for _, comment := range node.Comments.Nodes {
+				converted := PullRequestComment{
+					ID:        comment.DatabaseID,
+					Body:      comment.Body,
+					Path:      node.Path,
+					User:      User{Login: comment.Author.Login},
+					CreatedAt: comment.CreatedAt,
+					HTMLURL:   comment.URL,
+				}
+				for _, group := range comment.ReactionGroups {
+					switch group.Content {
+					case "THUMBS_UP":
+						converted.Reactions.ThumbsUp = group.Reactors.TotalCount
+					case "THUMBS_DOWN":
+						converted.Reactions.ThumbsDown = group.Reactors.TotalCount
+					}
+				}
+				thread.Comments = append(thread.Comments, converted)
+			}
+			threads = append(threads, thread)
+		}
+
+		if !page.PageInfo.HasNextPage {
+			break
+		}
+		endCursor := page.PageInfo.EndCursor
+		cursor = &endCursor
+	}
+
+	return threads, nil
+}
+
+// ResolveReviewThread marks a review thread as resolved
+func (c *Client) ResolveReviewThread(ctx context.Context, threadID string) error {
+	if threadID == "" {
+		return fmt.Errorf("thread ID cannot be empty")
+	}
+
+	variables := map[string]interface{}{"threadId": threadID}
+	if err := c.graphQL(ctx, resolveReviewThreadMutation, variables, nil); err != nil {
+		return fmt.Errorf("failed to resolve review thread %s: %w", threadID, err)
+	}
+
+	return nil
+}
+
+// ReplyToPullRequestComment posts a reply in the thread of an existing review comment
+func (c *Client) ReplyToPullRequestComment(ctx context.Context, owner, repo string, prNumber int, commentID int64, body string) (*PullRequestComment, error) {
+	endpoint := fmt.Sprintf("/repos/%s/%s/pulls/%d/comments/%d/replies", owner, repo, prNumber, commentID)
+
+	resp, err := c.makeRequestWithBody(ctx, "POST", endpoint, map[string]string{"body": body})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reply to PR comment %d: %w", commentID, err)
+	}
+	defer resp.Body.Close()
+
+	var reply PullRequestComment
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, fmt.Errorf("failed to decode PR comment reply response: %w", err)
+	}
+
+	return &reply, nil
+}
@@ -0,0 +1,160 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient_GraphQLURL(t *testing.T) {
+	tests := []struct {
+		baseURL  string
+		expected string
+	}{
+		{baseURL: "https://api.github.com", expected: "https://api.github.com/graphql"},
+		{baseURL: "https://github.example.com/api/v3", expected: "https://github.example.com/api/graphql"},
+		{baseURL: "https://github.example.com/api/v3/", expected: "https://github.example.com/api/graphql"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.baseURL, func(t *testing.T) {
+			client := &Client{baseURL: tt.baseURL}
+			if got := client.graphQLURL(); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetReviewThreads(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/graphql" {
+			t.Errorf("expected /graphql path, got %s", r.URL.Path)
+		}
+
+		var req graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		if req.Variables["owner"] != "owner" || req.Variables["repo"] != "repo" {
+			t.Errorf("unexpected variables: %v", req.Variables)
+		}
+		requests++
+
+		w.Header().Set("Content-Type", "application/json")
+		if req.Variables["after"] == nil {
+			_, _ = w.Write([]byte(`{"data":{"repository":{"pullRequest":{"reviewThreads":{
+				"pageInfo":{"hasNextPage":true,"endCursor":"cursor1"},
+				"nodes":[{"id":"T1","isResolved":false,"isOutdated":true,"path":"main.go","line":null,"originalLine":10,
+					"comments":{"nodes":[{"databaseId":11,"body":"first","author":{"login":"bot"},"url":"https://example/11",
+						"reactionGroups":[{"content":"THUMBS_UP","reactors":{"totalCount":2}},{"content":"THUMBS_DOWN","reactors":{"totalCount":1}},
+							{"content":"HEART","reactors":{"totalCount":4}}]}]}}]}}}}}`))
+			return
+		}
+		if req.Variables["after"] != "cursor1" {
+			t.Errorf("expected cursor1, got %v", req.Variables["after"])
+		}
+		_, _ = w.Write([]byte(`{"data":{"repository":{"pullRequest":{"reviewThreads":{
+			"pageInfo":{"hasNextPage":false,"endCursor":""},
+			"nodes":[{"id":"T2","isResolved":true,"isOutdated":false,"path":"util.go","line":5,"originalLine":5,
+				"comments":{"nodes":[]}}]}}}}}`))
+	}))
+	defer server.Close()
+
+	client := &Client{token: "test-token", baseURL: server.URL, httpClient: &http.Client{}}
+
+	threads, err := client.GetReviewThreads(context.Background(), "owner", "repo", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 paginated requests, got %d", requests)
+	}
+	if len(threads) != 2 {
+		t.Fatalf("expected 2 threads, got %d", len(threads))
+	}
+
+	first := threads[0]
+	if first.ID != "T1" || !first.IsOutdated || first.Line != 0 || first.OriginalLine != 10 {
+		t.Errorf("unexpected first thread: %+v", first)
+	}
+	if len(first.Comments) != 1 || first.Comments[0].ID != 11 || first.Comments[0].User.Login != "bot" {
+		t.Errorf("unexpected first thread comments: %+v", first.Comments)
+	}
+	if first.Comments[0].Reactions != (Reactions{ThumbsUp: 2, ThumbsDown: 1}) {
+		t.Errorf("expected thumbs reactions to be counted, got %+v", first.Comments[0].Reactions)
+	}
+	if !threads[1].IsResolved || threads[1].Line != 5 {
+		t.Errorf("unexpected second thread: %+v", threads[1])
+	}
+}
+
+func TestResolveReviewThread(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    string
+		expectError bool
+	}{
+		{
+			name:     "resolved",
+			response: `{"data":{"resolveReviewThread":{"thread":{"id":"T1","isResolved":true}}}}`,
+		},
+		{
+			name:        "GraphQL error",
+			response:    `{"errors":[{"message":"Resource not accessible by integration"}]}`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req graphQLRequest
+				_ = json.NewDecoder(r.Body).Decode(&req)
+				if !strings.Contains(req.Query, "resolveReviewThread") || req.Variables["threadId"] != "T1" {
+					t.Errorf("unexpected request: %+v", req)
+				}
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			client := &Client{token: "test-token", baseURL: server.URL, httpClient: &http.Client{}}
+			err := client.ResolveReviewThread(context.Background(), "T1")
+			if tt.expectError && err == nil {
+				t.Error("expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestReplyToPullRequestComment(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/repos/owner/repo/pulls/7/comments/42/replies" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(PullRequestComment{ID: 43, Body: body["body"]})
+	}))
+	defer server.Close()
+
+	client := &Client{token: "test-token", baseURL: server.URL, httpClient: &http.Client{}}
+
+	reply, err := client.ReplyToPullRequestComment(context.Background(), "owner", "repo", 7, 42, "Addressed in abc123.")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reply.ID != 43 || reply.Body != "Addressed in abc123." {
+		t.Errorf("unexpected reply: %+v", reply)
+	}
+}
@@ -0,0 +1,66 @@
+// Package gitsafe runs git on repositories whose content is untrusted, such as pull requests from
+// external contributors. Checking such a repository out must never run code from it or from the
+// host's git configuration, and must never write files outside the working tree.
+package gitsafe
+
+import (
+	"context"
+	"os"
+	"os/exec"
+)
+
+// config overrides the settings through which a checkout could run commands or leave the working
+// tree. They apply on top of the repository's own configuration.
+var config = []string{
+	// No hooks, including ones a global core.hooksPath points at
+	"core.hooksPath=" + os.DevNull,
+	// No fsmonitor daemon started from configuration
+	"core.fsmonitor=false",
+	// Symlinks are checked out as plain files holding the link target
+	"core.symlinks=false",
+	// No LFS smudge filter, even when .gitattributes asks for one
+	"filter.lfs.smudge=",
+	"filter.lfs.process=",
+	"filter.lfs.required=false",
+	// Submodules are never cloned or updated
+	"submodule.recurse=false",
+	"fetch.recurseSubmodules=false",
+	// No transports that run commands
+	"protocol.ext.allow=never",
+	// Reject paths that alias .git on case-insensitive or NTFS file systems
+	"core.protectHFS=true",
+	"core.protectNTFS=true",
+}
+
+// environment isolates git from the system and user configuration, where filter drivers, hooks,
+// credential helpers and aliases of the host are defined, and keeps it from prompting
+var environment = []string{
+	"GIT_CONFIG_NOSYSTEM=1",
+	"GIT_CONFIG_GLOBAL=" + os.DevNull,
+	"GIT_ATTR_NOSYSTEM=1",
+	"GIT_LFS_SKIP_SMUDGE=1",
+	"GIT_TERMINAL_PROMPT=0",
+}
+
+// Args returns the arguments of a hardened git command: the configuration overrides followed by
+// args, which start with the subcommand
+func Args(args ...string) []string {
+	hardened := make([]string, 0, 2*len(config)+len(args))
+	for _, setting := range config {
+		hardened = append(hardened, "-c", setting)
+	}
+	return append(hardened, args...)
+}
+
+// Env returns the environment of a hardened git command, based on the current environment
+func Env() []string {
+	return append(os.Environ(), environment...)
+}
+
+// Command creates a hardened git command that runs in dir (the current directory when empty)
+func Command(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", Args(args...)...)
+	cmd.Dir = dir
+	cmd.Env = Env()
+	return cmd
+}
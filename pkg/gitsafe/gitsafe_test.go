@@ -0,0 +1,107 @@
+package gitsafe
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// git runs plain, unhardened git as the tests' stand-in for an attacker preparing a repository
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s failed: %v: %s", strings.Join(args, " "), err, output)
+	}
+}
+
+func writeFile(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestCommand_CheckoutRunsNothing(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	marker := filepath.Join(root, "executed")
+	secret := filepath.Join(root, "secret.txt")
+	writeFile(t, secret, "host secret\n", 0644)
+
+	// The host configuration defines a filter driver and a hook, as git-lfs or a hook manager would
+	hooks := filepath.Join(root, "hooks")
+	writeFile(t, filepath.Join(hooks, "post-checkout"), "#!/bin/sh\ntouch '"+marker+"'\n", 0755)
+	globalConfig := filepath.Join(root, "gitconfig")
+	writeFile(t, globalConfig, "[core]\n\thooksPath = "+hooks+"\n"+
+		"[filter \"evil\"]\n\tsmudge = \"touch '"+marker+"'; cat\"\n\trequired = true\n", 0644)
+
+	// The pull request asks for the filter and links to a file of the host
+	remote := filepath.Join(root, "remote")
+	git(t, root, "init", "--quiet", remote)
+	writeFile(t, filepath.Join(remote, ".gitattributes"), "*.txt filter=evil\n", 0644)
+	writeFile(t, filepath.Join(remote, "data.txt"), "data\n", 0644)
+	if err := os.Symlink(secret, filepath.Join(remote, "link")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+	git(t, remote, "add", ".")
+	git(t, remote, "commit", "--quiet", "-m", "Untrusted change")
+
+	t.Setenv("GIT_CONFIG_GLOBAL", globalConfig)
+
+	// Plain git runs the host's filter and hook, which is what hardening prevents
+	git(t, root, "clone", "--quiet", remote, filepath.Join(root, "plain"))
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("expected plain git to run the filter or hook, so the test is meaningful: %v", err)
+	}
+	if err := os.Remove(marker); err != nil {
+		t.Fatalf("failed to reset marker: %v", err)
+	}
+
+	dest := filepath.Join(root, "hardened")
+	if output, err := Command(context.Background(), "", "clone", "--quiet", remote, dest).CombinedOutput(); err != nil {
+		t.Fatalf("hardened clone failed: %v: %s", err, output)
+	}
+
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("expected no filter or hook to run")
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "data.txt")); err != nil || string(data) != "data\n" {
+		t.Errorf("expected data.txt to be checked out unfiltered, got %q (%v)", data, err)
+	}
+	info, err := os.Lstat(filepath.Join(dest, "link"))
+	if err != nil {
+		t.Fatalf("expected the link to be checked out: %v", err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		t.Error("expected the symlink to be checked out as a plain file")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "link")); string(data) != secret {
+		t.Errorf("expected the link to hold its target path, got %q", data)
+	}
+}
+
+func TestArgs(t *testing.T) {
+	args := Args("fetch", "origin")
+	if len(args) != 2*len(config)+2 || args[len(args)-2] != "fetch" || args[len(args)-1] != "origin" {
+		t.Fatalf("expected the configuration before the subcommand, got %v", args)
+	}
+	for i := 0; i < 2*len(config); i += 2 {
+		if args[i] != "-c" {
+			t.Errorf("expected -c at %d, got %q", i, args[i])
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package history
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store persists review runs and answers queries about past reviews
+type Store interface {
+	Save(record *Record) error
+	Query(filter Filter) ([]Record, error)
+}
+
+// Record captures everything known about a single review run
+type Record struct {
+	ID              string         `json:"id"`
+	Repository      string         `json:"repository"`
+	PullRequest     int            `json:"pull_request"`
+	BaseSHA         string         `json:"base_sha,omitempty"`
+	HeadSHA         string         `json:"head_sha"`
+	Model           string         `json:"model,omitempty"`
+	PromptHash      string         `json:"prompt_hash,omitempty"`
+	Status          string         `json:"status"`
+	Summary         string         `json:"summary,omitempty"`
+	CommentsPosted  int            `json:"comments_posted"`
+	HighestSeverity string         `json:"highest_severity,omitempty"`
+	Comments        []Comment      `json:"comments,omitempty"`
+	Tokens          Tokens         `json:"tokens"`
+	CostUSD         float64        `json:"cost_usd,omitempty"`
+	StageDurations  map[string]int `json:"stage_durations_ms,omitempty"`
+	Warnings        []string       `json:"warnings,omitempty"`
+	WorkspaceBytes  int64          `json:"workspace_bytes,omitempty"`
+	StartedAt       time.Time      `json:"started_at"`
+	DurationMs      int64          `json:"duration_ms"`
+}
+
+// Comment is a single generated review comment and what happened to it
+type Comment struct {
+	Filename   string   `json:"filename"`
+	LineNumber int      `json:"line_number,omitempty"`
+	Body       string   `json:"body"`
+	Severity   string   `json:"severity,omitempty"`
+	Category   string   `json:"category,omitempty"`
+	ReviewType string   `json:"review_type,omitempty"`
+	Confidence *float64 `json:"confidence,omitempty"`
+	Status     string   `json:"status"`
+	Reason     string   `json:"reason,omitempty"`
+	CommentID  int64    `json:"comment_id,omitempty"` // GitHub ID of the posted comment
+}
+
+// Tokens records LLM token usage for a review run
+type Tokens struct {
+	Input  int `json:"input"`
+	Output int `json:"output"`
+	Total  int `json:"total"`
+}
+
+// Filter narrows a history query; zero values match everything
+type Filter struct {
+	Repository  string
+	PullRequest int
+	Since       time.Time
+	Until       time.Time
+	Limit       int
+}
+
+// Matches reports whether the record satisfies the filter
+func (f Filter) Matches(record *Record) bool {
+	if f.Repository != "" && record.Repository != f.Repository {
+		return false
+	}
+	if f.PullRequest > 0 && record.PullRequest != f.PullRequest {
+		return false
+	}
+	if !f.Since.IsZero() && record.StartedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !record.StartedAt.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// FileStore is an append-only JSON Lines store kept in a single local file
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultPath returns the default history file location (~/.config/review-agent/history.jsonl)
+func DefaultPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "review-history.jsonl"
+	}
+	return filepath.Join(homeDir, ".config", "review-agent", "history.jsonl")
+}
+
+// NewFileStore creates a file store at the given path, creating parent directories as needed
+func NewFileStore(path string) (*FileStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("history file path cannot be empty")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	return &FileStore{path: path}, nil
+}
+
+// Path returns the location of the history file
+func (s *FileStore) Path() string {
+	return s.path
+}
+
+// Save appends a record to the history file, assigning an ID if it has none
+func (s *FileStore) Save(record *Record) error {
+	if record == nil {
+		return fmt.Errorf("record cannot be nil")
+	}
+	if record.ID == "" {
+		record.ID = newRecordID()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history record: %w", err)
+	}
+
+	return nil
+}
+
+// Query returns matching records, newest first. Unreadable lines are skipped.
+func (s *FileStore) Query(filter Filter) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Record{}, nil
+		}
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	records := []Record{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			continue // Skip partially written or corrupt lines
+		}
+
+		if filter.Matches(&record) {
+			records = append(records, record)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history file: %w", err)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].StartedAt.After(records[j].StartedAt)
+	})
+
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+
+	return records, nil
+}
+
+// newRecordID generates a random identifier for a history record
+func newRecordID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,132 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *FileStore {
+	t.Helper()
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "nested", "history.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	return store
+}
+
+func TestFileStore_SaveAndQuery(t *testing.T) {
+	store := newTestStore(t)
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	records := []*Record{
+		{Repository: "owner/repo", PullRequest: 1, HeadSHA: "aaa", Status: "completed", StartedAt: base},
+		{Repository: "owner/repo", PullRequest: 2, HeadSHA: "bbb", Status: "failed", StartedAt: base.Add(24 * time.Hour)},
+		{Repository: "owner/other", PullRequest: 1, HeadSHA: "ccc", Status: "completed", StartedAt: base.Add(48 * time.Hour)},
+	}
+	for _, record := range records {
+		if err := store.Save(record); err != nil {
+			t.Fatalf("unexpected save error: %v", err)
+		}
+		if record.ID == "" {
+			t.Error("expected record ID to be assigned")
+		}
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{
+			name:     "all records newest first",
+			filter:   Filter{},
+			expected: []string{"ccc", "bbb", "aaa"},
+		},
+		{
+			name:     "filter by repository",
+			filter:   Filter{Repository: "owner/repo"},
+			expected: []string{"bbb", "aaa"},
+		},
+		{
+			name:     "filter by repository and pull request",
+			filter:   Filter{Repository: "owner/repo", PullRequest: 1},
+			expected: []string{"aaa"},
+		},
+		{
+			name:     "filter by date range",
+			filter:   Filter{Since: base.Add(time.Hour), Until: base.Add(48 * time.Hour)},
+			expected: []string{"bbb"},
+		},
+		{
+			name:     "limit",
+			filter:   Filter{Limit: 2},
+			expected: []string{"ccc", "bbb"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("unexpected query error: %v", err)
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %d records, got %d", len(tt.expected), len(got))
+			}
+			for i, sha := range tt.expected {
+				if got[i].HeadSHA != sha {
+					t.Errorf("record %d: expected head SHA %s, got %s", i, sha, got[i].HeadSHA)
+				}
+			}
+		})
+	}
+}
+
+func TestFileStore_QueryMissingFile(t *testing.T) {
+	store := newTestStore(t)
+
+	records, err := store.Query(Filter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("expected no records, got %d", len(records))
+	}
+}
+
+func TestFileStore_QuerySkipsCorruptLines(t *testing.T) {
+	store := newTestStore(t)
+
+	if err := store.Save(&Record{Repository: "owner/repo", PullRequest: 1, HeadSHA: "aaa"}); err != nil {
+		t.Fatalf("unexpected save error: %v", err)
+	}
+
+	file, err := os.OpenFile(store.Path(), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open history file: %v", err)
+	}
+	if _, err := file.WriteString("{\"repository\": \"owner/re\n\n"); err != nil {
+		t.Fatalf("failed to write corrupt line: %v", err)
+	}
+	file.Close()
+
+	if err := store.Save(&Record{Repository: "owner/repo", PullRequest: 2, HeadSHA: "bbb"}); err != nil {
+		t.Fatalf("unexpected save error: %v", err)
+	}
+
+	records, err := store.Query(Filter{})
+	if err != nil {
+		t.Fatalf("unexpected query error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Errorf("expected 2 records, got %d", len(records))
+	}
+}
+
+func TestNewFileStore_EmptyPath(t *testing.T) {
+	if _, err := NewFileStore(""); err == nil {
+		t.Error("expected error for empty path")
+	}
+}
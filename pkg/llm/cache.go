@@ -0,0 +1,189 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/analyzer"
+)
+
+// PromptTemplateVersion identifies the review prompts; bump it whenever they change so cached
+// results produced by older prompts are not reused
+const PromptTemplateVersion = "2"
+
+// DefaultCacheTTL is how long cached review comments are reused
+const DefaultCacheTTL = 7 * 24 * time.Hour
+
+// ResultCache stores the review comments produced for a single file
+type ResultCache interface {
+	Get(key string) ([]ReviewComment, bool)
+	Put(key string, comments []ReviewComment) error
+}
+
+// CacheKey fingerprints the review of one file: the model, prompt template version and review
+// type, the pull request header with its instructions and stated intent, and the file's
+// contextual diff, each as it appears in the prompt
+func CacheKey(model string, request *ReviewRequest, file analyzer.FileWithContext) string {
+	var header, section strings.Builder
+	writePullRequestHeader(&header, request)
+	writeFileSection(&section, file)
+
+	hasher := sha256.New()
+	for _, part := range []string{model, PromptTemplateVersion, string(request.ReviewType), header.String(), section.String()} {
+		hasher.Write([]byte(part))
+		hasher.Write([]byte{0})
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// cacheEntry is a cached result with its expiry
+type cacheEntry struct {
+	Comments  []ReviewComment `json:"comments"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// copyComments returns a copy of the comments so callers cannot change cached results
+func copyComments(comments []ReviewComment) []ReviewComment {
+	return append([]ReviewComment{}, comments...)
+}
+
+// MemoryCache keeps cached results in memory for the life of the process
+type MemoryCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	now     func() time.Time
+}
+
+// NewMemoryCache creates an in-memory cache; a TTL of 0 uses DefaultCacheTTL
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &MemoryCache{ttl: ttl, entries: make(map[string]cacheEntry), now: time.Now}
+}
+
+// Get returns the cached comments for a key, dropping the entry when it has expired
+func (c *MemoryCache) Get(key string) ([]ReviewComment, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.ExpiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return copyComments(entry.Comments), true
+}
+
+// Put caches the comments for a key
+func (c *MemoryCache) Put(key string, comments []ReviewComment) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = cacheEntry{Comments: copyComments(comments), ExpiresAt: c.now().Add(c.ttl)}
+	return nil
+}
+
+// FileCache keeps cached results as one JSON file per key, so they survive restarts
+type FileCache struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+}
+
+// DefaultCacheDir returns the default cache location (~/.cache/review-agent/llm)
+func DefaultCacheDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "review-agent-llm-cache")
+	}
+	return filepath.Join(cacheDir, "review-agent", "llm")
+}
+
+// NewFileCache creates a cache in the given directory; a TTL of 0 uses DefaultCacheTTL
+func NewFileCache(dir string, ttl time.Duration) (*FileCache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("cache directory cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	return &FileCache{dir: dir, ttl: ttl, now: time.Now}, nil
+}
+
+// Dir returns the cache directory
+func (c *FileCache) Dir() string {
+	return c.dir
+}
+
+// Get returns the cached comments for a key. Expired entries are removed; unreadable ones are misses.
+func (c *FileCache) Get(key string) ([]ReviewComment, bool) {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	if !c.now().Before(entry.ExpiresAt) {
+		os.Remove(path)
+		return nil, false
+	}
+	return entry.Comments, true
+}
+
+// Put writes the comments for a key, replacing the entry atomically
+func (c *FileCache) Put(key string, comments []ReviewComment) error {
+	data, err := json.Marshal(cacheEntry{Comments: comments, ExpiresAt: c.now().Add(c.ttl)})
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), ".entry-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save cache entry: %w", err)
+	}
+	return nil
+}
+
+// path spreads entries over subdirectories named after the first two characters of the key
+func (c *FileCache) path(key string) string {
+	if len(key) < 2 {
+		return filepath.Join(c.dir, key+".json")
+	}
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
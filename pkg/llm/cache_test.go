@@ -0,0 +1,156 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/analyzer"
+)
+
+func cacheTestFile(filename, content string) analyzer.FileWithContext {
+	return analyzer.FileWithContext{
+		FileDiff: analyzer.FileDiff{Filename: filename, Status: "modified", Language: "go"},
+		ContextBlocks: []analyzer.ContextBlock{{
+			StartLine: 1,
+			EndLine:   1,
+			Lines:     []analyzer.DiffLine{{Type: "added", Content: content}},
+		}},
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	request := func(change func(*ReviewRequest)) *ReviewRequest {
+		request := &ReviewRequest{
+			ReviewType:      ReviewTypeGeneral,
+			PullRequestInfo: PullRequestInfo{Number: 7, Title: "Add login", Description: "Adds a login form"},
+		}
+		if change != nil {
+			change(request)
+		}
+		return request
+	}
+	file := cacheTestFile("main.go", "x := 1")
+	base := CacheKey(ClaudeSonnet4, request(nil), file)
+
+	if again := CacheKey(ClaudeSonnet4, request(nil), cacheTestFile("main.go", "x := 1")); again != base {
+		t.Errorf("expected the same key for the same review, got %s and %s", base, again)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{name: "model", key: CacheKey(ClaudeHaiku35, request(nil), file)},
+		{name: "review type", key: CacheKey(ClaudeSonnet4, request(func(r *ReviewRequest) { r.ReviewType = ReviewTypeSecurity }), file)},
+		{name: "instructions", key: CacheKey(ClaudeSonnet4, request(func(r *ReviewRequest) { r.Instructions = "Check SQL" }), file)},
+		{name: "description", key: CacheKey(ClaudeSonnet4, request(func(r *ReviewRequest) { r.PullRequestInfo.Description = "Adds a signup form" }), file)},
+		{name: "labels", key: CacheKey(ClaudeSonnet4, request(func(r *ReviewRequest) { r.PullRequestInfo.Labels = []string{"security"} }), file)},
+		{name: "commits", key: CacheKey(ClaudeSonnet4, request(func(r *ReviewRequest) { r.PullRequestInfo.CommitMessages = []string{"Fix login"} }), file)},
+		{name: "linked issues", key: CacheKey(ClaudeSonnet4, request(func(r *ReviewRequest) {
+			r.PullRequestInfo.LinkedIssues = []LinkedIssue{{Reference: "#3", Title: "Login is broken"}}
+		}), file)},
+		{name: "pull request", key: CacheKey(ClaudeSonnet4, request(func(r *ReviewRequest) { r.PullRequestInfo.Number = 8 }), file)},
+		{name: "diff", key: CacheKey(ClaudeSonnet4, request(nil), cacheTestFile("main.go", "x := 2"))},
+		{name: "filename", key: CacheKey(ClaudeSonnet4, request(nil), cacheTestFile("other.go", "x := 1"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.key == base {
+				t.Errorf("expected a different %s to change the key", tt.name)
+			}
+		})
+	}
+}
+
+func TestResultCaches(t *testing.T) {
+	fileCache, err := NewFileCache(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create file cache: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		cache ResultCache
+	}{
+		{name: "memory", cache: NewMemoryCache(time.Hour)},
+		{name: "file", cache: fileCache},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			switch cache := tt.cache.(type) {
+			case *MemoryCache:
+				cache.now = func() time.Time { return now }
+			case *FileCache:
+				cache.now = func() time.Time { return now }
+			}
+
+			if _, ok := tt.cache.Get("missing"); ok {
+				t.Error("expected a miss for an unknown key")
+			}
+
+			comments := []ReviewComment{{Filename: "main.go", LineNumber: 3, Comment: "Nil dereference", Severity: SeverityMajor}}
+			if err := tt.cache.Put("abcdef", comments); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if err := tt.cache.Put("clean", nil); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+
+			cached, ok := tt.cache.Get("abcdef")
+			if !ok || len(cached) != 1 || cached[0].Comment != "Nil dereference" {
+				t.Fatalf("expected the cached comment, got %v (hit %v)", cached, ok)
+			}
+			cached[0].Severity = SeverityCritical
+			if again, _ := tt.cache.Get("abcdef"); again[0].Severity != SeverityMajor {
+				t.Error("expected changes to returned comments not to affect the cache")
+			}
+			if cached, ok := tt.cache.Get("clean"); !ok || len(cached) != 0 {
+				t.Errorf("expected a cached empty result, got %v (hit %v)", cached, ok)
+			}
+
+			now = now.Add(2 * time.Hour)
+			if _, ok := tt.cache.Get("abcdef"); ok {
+				t.Error("expected the entry to expire after the TTL")
+			}
+		})
+	}
+}
+
+func TestFileCache_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	first, err := NewFileCache(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create file cache: %v", err)
+	}
+	if err := first.Put("abcdef", []ReviewComment{{Filename: "main.go", Comment: "Leak"}}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	second, err := NewFileCache(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to reopen file cache: %v", err)
+	}
+	if cached, ok := second.Get("abcdef"); !ok || len(cached) != 1 {
+		t.Errorf("expected the entry to survive reopening, got %v (hit %v)", cached, ok)
+	}
+
+	// A corrupt entry is a miss and is replaced by the next Put
+	path := filepath.Join(dir, "ab", "abcdef.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatalf("failed to corrupt entry: %v", err)
+	}
+	if _, ok := second.Get("abcdef"); ok {
+		t.Error("expected a corrupt entry to be a miss")
+	}
+	if err := second.Put("abcdef", nil); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, ok := second.Get("abcdef"); !ok {
+		t.Error("expected the rewritten entry to be a hit")
+	}
+}
@@ -21,16 +21,26 @@ type ReviewRequest struct {
 	ContextualDiff  *analyzer.ContextualDiff `json:"contextual_diff"`
 	ReviewType      ReviewType               `json:"review_type"`
 	Instructions    string                   `json:"instructions,omitempty"`
+	Model           string                   `json:"model,omitempty"`        // Overrides the client's configured model
+	MaxCostUSD      float64                  `json:"max_cost_usd,omitempty"` // Chunks that could exceed this spend are not sent (0 is unlimited)
 }
 
 // ReviewResponse contains the LLM's code review results
 type ReviewResponse struct {
-	Comments    []ReviewComment `json:"comments"`
-	Summary     string          `json:"summary"`
-	ModelUsed   string          `json:"model_used"`
-	TokensUsed  TokenUsage      `json:"tokens_used"`
-	ReviewID    string          `json:"review_id"`
-	GeneratedAt string          `json:"generated_at"`
+	Comments   []ReviewComment `json:"comments"`
+	Summary    string          `json:"summary"`
+	ModelUsed  string          `json:"model_used"`
+	TokensUsed TokenUsage      `json:"tokens_used"`
+	CostUSD    float64         `json:"cost_usd"`
+	// SkippedChunks counts the chunks not sent because of MaxCostUSD
+	SkippedChunks int `json:"skipped_chunks,omitempty"`
+	// UnreviewedFiles lists the files whose diff was not fully sent because chunks were skipped
+	UnreviewedFiles []string `json:"unreviewed_files,omitempty"`
+	// CachedFiles counts the files whose comments were reused from the cache instead of sent
+	CachedFiles int    `json:"cached_files,omitempty"`
+	ReviewID    string `json:"review_id"`
+	GeneratedAt string `json:"generated_at"`
+	PromptHash  string `json:"prompt_hash,omitempty"`
 }
 
 // ReviewComment represents a single review comment
@@ -43,6 +53,21 @@ type ReviewComment struct {
 	Type       CommentType `json:"type"`
 	Suggestion string      `json:"suggestion,omitempty"`
 	Category   string      `json:"category,omitempty"`
+	Confidence *float64    `json:"confidence,omitempty"`  // 0-1, nil when the model did not report one
+	Evidence   string      `json:"evidence,omitempty"`    // Short quote from the diff supporting the finding
+	ReviewType ReviewType  `json:"review_type,omitempty"` // Review pass that produced the finding
+}
+
+// MaxEvidenceLength is the longest evidence quote kept on a review comment
+const MaxEvidenceLength = 300
+
+// ConfidenceBelow reports whether the comment has a confidence lower than the threshold.
+// Comments without a reported confidence are below any positive threshold.
+func (c ReviewComment) ConfidenceBelow(threshold float64) bool {
+	if threshold <= 0 {
+		return false
+	}
+	return c.Confidence == nil || *c.Confidence < threshold
 }
 
 // Supporting data structures
@@ -54,6 +79,18 @@ type PullRequestInfo struct {
 	Description string `json:"description,omitempty"`
 	BaseBranch  string `json:"base_branch"`
 	HeadBranch  string `json:"head_branch"`
+
+	Labels         []string      `json:"labels,omitempty"`
+	Draft          bool          `json:"draft,omitempty"`
+	CommitMessages []string      `json:"commit_messages,omitempty"`
+	LinkedIssues   []LinkedIssue `json:"linked_issues,omitempty"`
+}
+
+// LinkedIssue is an issue the pull request says it fixes
+type LinkedIssue struct {
+	Reference string `json:"reference"` // "#123", or "owner/repo#123" for other repositories
+	Title     string `json:"title"`
+	Body      string `json:"body,omitempty"`
 }
 
 type LineRange struct {
@@ -97,6 +134,22 @@ const (
 	SeverityCritical Severity = "critical"
 )
 
+// Rank returns the ordinal weight of a severity, with unknown values ranked lowest
+func (s Severity) Rank() int {
+	switch s {
+	case SeverityInfo:
+		return 1
+	case SeverityMinor:
+		return 2
+	case SeverityMajor:
+		return 3
+	case SeverityCritical:
+		return 4
+	default:
+		return 0
+	}
+}
+
 type CommentType string
 
 const (
@@ -117,15 +170,19 @@ type ClaudeConfig struct {
 	Temperature float64 `json:"temperature"`
 	BaseURL     string  `json:"base_url"`
 	Timeout     int     `json:"timeout_seconds"`
+
+	// MaxInputTokens is the largest prompt sent in one request; bigger reviews are chunked
+	MaxInputTokens int `json:"max_input_tokens"`
 }
 
 // Default configurations
 const (
-	DefaultClaudeModel       = "claude-sonnet-4-20250514"
-	DefaultClaudeMaxTokens   = 4000
-	DefaultClaudeTemperature = 0.1
-	DefaultClaudeBaseURL     = "https://api.anthropic.com"
-	DefaultTimeoutSeconds    = 120
+	DefaultClaudeModel          = "claude-sonnet-4-20250514"
+	DefaultClaudeMaxTokens      = 4000
+	DefaultClaudeMaxInputTokens = 150000
+	DefaultClaudeTemperature    = 0.1
+	DefaultClaudeBaseURL        = "https://api.anthropic.com"
+	DefaultTimeoutSeconds       = 120
 )
 
 // Available Claude models
@@ -0,0 +1,64 @@
+package llm
+
+// ModelPricing is the list price of a model in US dollars per million tokens
+type ModelPricing struct {
+	InputPerMTok  float64 `json:"input_per_mtok"`
+	OutputPerMTok float64 `json:"output_per_mtok"`
+}
+
+// Cost returns the price of the given number of input and output tokens
+func (p ModelPricing) Cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.InputPerMTok + float64(outputTokens)*p.OutputPerMTok) / 1_000_000
+}
+
+// ModelPrices holds the pricing of every supported model
+var ModelPrices = map[string]ModelPricing{
+	ClaudeHaiku35:        {InputPerMTok: 0.80, OutputPerMTok: 4},
+	ClaudeSonnet35:       {InputPerMTok: 3, OutputPerMTok: 15},
+	ClaudeSonnet35Latest: {InputPerMTok: 3, OutputPerMTok: 15},
+	ClaudeHaiku37:        {InputPerMTok: 0.80, OutputPerMTok: 4},
+	ClaudeSonnet37:       {InputPerMTok: 3, OutputPerMTok: 15},
+	ClaudeSonnet4:        {InputPerMTok: 3, OutputPerMTok: 15},
+}
+
+// CostOf returns the price of the token usage on a model, or 0 for models without pricing
+func CostOf(model string, usage TokenUsage) float64 {
+	return ModelPrices[model].Cost(usage.InputTokens, usage.OutputTokens)
+}
+
+// DowngradeModels are the models a review may be switched to for cost, most expensive first.
+// Only model IDs known to be served by the API are listed, so a downgrade never fails the review.
+var DowngradeModels = []string{ClaudeHaiku35}
+
+// CheaperModels returns the downgrade models priced below the given model, most expensive first
+func CheaperModels(model string) []string {
+	pricing, ok := ModelPrices[model]
+	if !ok {
+		return nil
+	}
+
+	var cheaper []string
+	for _, candidate := range DowngradeModels {
+		if price, ok := ModelPrices[candidate]; ok && price.OutputPerMTok < pricing.OutputPerMTok {
+			cheaper = append(cheaper, candidate)
+		}
+	}
+	return cheaper
+}
+
+// TokenEstimate is the pre-flight size of a review request
+type TokenEstimate struct {
+	InputTokens     int `json:"input_tokens"`
+	MaxOutputTokens int `json:"max_output_tokens"` // Output limit summed over every request
+	Requests        int `json:"requests"`          // Number of API requests after chunking
+}
+
+// Cost returns the most the estimated requests can cost on a model
+func (e TokenEstimate) Cost(model string) float64 {
+	return ModelPrices[model].Cost(e.InputTokens, e.MaxOutputTokens)
+}
+
+// CostEstimator is implemented by reviewers that can size a request before sending it
+type CostEstimator interface {
+	EstimateTokens(request *ReviewRequest) TokenEstimate
+}
@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestCostOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		usage    TokenUsage
+		expected float64
+	}{
+		{"sonnet", ClaudeSonnet4, TokenUsage{InputTokens: 1_000_000, OutputTokens: 100_000}, 4.5},
+		{"haiku", ClaudeHaiku35, TokenUsage{InputTokens: 500_000, OutputTokens: 250_000}, 1.4},
+		{"unknown model", "gpt-4", TokenUsage{InputTokens: 1_000_000}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if cost := CostOf(tt.model, tt.usage); math.Abs(cost-tt.expected) > 1e-9 {
+				t.Errorf("expected $%.4f, got $%.4f", tt.expected, cost)
+			}
+		})
+	}
+}
+
+func TestCheaperModels(t *testing.T) {
+	tests := []struct {
+		model    string
+		expected []string
+	}{
+		{ClaudeSonnet4, []string{ClaudeHaiku35}},
+		{ClaudeSonnet35, []string{ClaudeHaiku35}},
+		{ClaudeSonnet37, []string{ClaudeHaiku35}},
+		{ClaudeHaiku37, nil},
+		{ClaudeHaiku35, nil},
+		{"unknown", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			if cheaper := CheaperModels(tt.model); !reflect.DeepEqual(cheaper, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, cheaper)
+			}
+		})
+	}
+}
+
+func TestCheaperModels_OnlyKnownModels(t *testing.T) {
+	known := map[string]bool{ClaudeHaiku35: true}
+	for _, model := range AvailableClaudeModels {
+		for _, candidate := range CheaperModels(model) {
+			if !known[candidate] {
+				t.Errorf("downgrade from %s to %s, which is not a known model ID", model, candidate)
+			}
+		}
+	}
+}
+
+func TestTokenEstimate_Cost(t *testing.T) {
+	estimate := TokenEstimate{InputTokens: 200_000, MaxOutputTokens: 8_000, Requests: 2}
+
+	if cost := estimate.Cost(ClaudeSonnet4); math.Abs(cost-0.72) > 1e-9 {
+		t.Errorf("expected $0.72, got $%.4f", cost)
+	}
+}
@@ -0,0 +1,398 @@
+// Package repocache keeps a persistent bare mirror of each reviewed repository. Reviews get a
+// working copy that shares objects with the mirror, so only new commits are downloaded.
+package repocache
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/gitsafe"
+)
+
+// DefaultQuota is the disk space mirrors may use before the least recently used are evicted
+const DefaultQuota int64 = 20 << 30
+
+// Files kept next to each mirror
+const (
+	updateLockSuffix = ".lock" // Held exclusively while the mirror is created, fetched or evicted
+	useLockSuffix    = ".use"  // Held shared by working copies, exclusively to evict the mirror
+	usedMarkerSuffix = ".used" // Modification time records when the mirror was last used
+)
+
+// Refs copied into a mirror
+var mirrorRefspecs = []string{"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"}
+
+// Cache manages the mirrors kept in a directory, one per repository
+type Cache struct {
+	dir   string
+	quota int64 // Bytes; 0 disables eviction
+	now   func() time.Time
+}
+
+// DefaultDir returns the default cache location (~/.cache/review-agent/repos)
+func DefaultDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "review-agent-repos")
+	}
+	return filepath.Join(cacheDir, "review-agent", "repos")
+}
+
+// New creates a cache in the given directory; a negative quota uses DefaultQuota and 0 disables eviction
+func New(dir string, quota int64) (*Cache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("repository cache directory cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create repository cache directory: %w", err)
+	}
+	if quota < 0 {
+		quota = DefaultQuota
+	}
+
+	return &Cache{dir: dir, quota: quota, now: time.Now}, nil
+}
+
+// Dir returns the cache directory
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// CreateWorktree updates the mirror of owner/repo from remoteURL and creates a working copy at
+// destination that shares the mirror's objects, with ref checked out as branch (detached when
+// branch is empty). Extra refs, such as refs/pull/<n>/head for a pull request from a fork, are
+// fetched into the mirror too. When ref is a commit SHA, the checked-out HEAD is verified against
+// it. The working copy's origin is remoteURL. The mirror cannot be evicted until release is
+// called, which must happen after the working copy has been removed.
+func (c *Cache) CreateWorktree(ctx context.Context, owner, repo, remoteURL, destination, ref, branch string, extraRefs ...string) (release func(), err error) {
+	mirror := c.mirrorPath(owner, repo)
+	if err := os.MkdirAll(filepath.Dir(mirror), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create repository cache directory: %w", err)
+	}
+
+	useLock, err := c.updateMirror(ctx, mirror, remoteURL, extraRefs)
+	if err != nil {
+		return nil, err
+	}
+	release = func() { unlockFile(useLock) }
+
+	if err := checkoutShared(ctx, mirror, remoteURL, destination, ref, branch); err != nil {
+		release()
+		return nil, err
+	}
+
+	c.evict(mirror)
+	return release, nil
+}
+
+// updateMirror creates or fetches the mirror and returns its use lock, held shared.
+// A mirror that cannot be fetched and fails a connectivity check is recreated.
+func (c *Cache) updateMirror(ctx context.Context, mirror, remoteURL string, extraRefs []string) (*os.File, error) {
+	updateLock, err := lockFile(mirror+updateLockSuffix, true, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockFile(updateLock)
+
+	if _, err := os.Stat(mirror); err == nil {
+		fetchErr := fetchMirror(ctx, mirror, remoteURL, extraRefs)
+		if fetchErr == nil {
+			return c.useMirror(mirror)
+		}
+		if ctx.Err() != nil || verifyMirror(ctx, mirror) == nil {
+			return nil, fetchErr // The mirror is fine, the remote is not
+		}
+
+		log.Printf("Warning: repository mirror %s is corrupt, recreating it: %v", mirror, fetchErr)
+		if err := removeUnused(mirror); err != nil {
+			return nil, fmt.Errorf("failed to recreate corrupt mirror: %w", err)
+		}
+	}
+
+	if err := createMirror(ctx, mirror, remoteURL, extraRefs); err != nil {
+		return nil, err
+	}
+	return c.useMirror(mirror)
+}
+
+// useMirror takes the mirror's use lock and records the use for eviction
+func (c *Cache) useMirror(mirror string) (*os.File, error) {
+	useLock, err := lockFile(mirror+useLockSuffix, false, true)
+	if err != nil {
+		return nil, err
+	}
+
+	marker := mirror + usedMarkerSuffix
+	now := c.now()
+	if err := os.Chtimes(marker, now, now); err != nil {
+		if err := os.WriteFile(marker, nil, 0644); err != nil {
+			log.Printf("Warning: failed to record use of repository mirror %s: %v", mirror, err)
+		}
+	}
+	return useLock, nil
+}
+
+// createMirror fetches a new mirror into a temporary directory and moves it into place, so
+// an interrupted clone never leaves a partial mirror behind
+func createMirror(ctx context.Context, mirror, remoteURL string, extraRefs []string) error {
+	temp := fmt.Sprintf("%s.tmp-%s", mirror, randomSuffix())
+	defer os.RemoveAll(temp)
+
+	if _, err := runGit(ctx, "", "init", "--quiet", "--bare", temp); err != nil {
+		return err
+	}
+	// Working copies borrow objects from the mirror, so it must never be pruned behind their back
+	if _, err := runGit(ctx, temp, "config", "gc.auto", "0"); err != nil {
+		return err
+	}
+	if err := fetchMirror(ctx, temp, remoteURL, extraRefs); err != nil {
+		return err
+	}
+
+	if err := os.Rename(temp, mirror); err != nil {
+		return fmt.Errorf("failed to move repository mirror into place: %w", err)
+	}
+	return nil
+}
+
+// fetchMirror brings the mirror's branches, tags and extra refs up to date with the remote
+func fetchMirror(ctx context.Context, mirror, remoteURL string, extraRefs []string) error {
+	args := append([]string{"fetch", "--quiet", "--prune", "--no-write-fetch-head", remoteURL}, mirrorRefspecs...)
+	for _, ref := range extraRefs {
+		args = append(args, "+"+ref+":"+ref)
+	}
+	if _, err := runGit(ctx, mirror, args...); err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", redactURL(remoteURL), redactError(err, remoteURL))
+	}
+	return nil
+}
+
+// verifyMirror checks that every ref of the mirror points at a complete history
+func verifyMirror(ctx context.Context, mirror string) error {
+	_, err := runGit(ctx, mirror, "fsck", "--connectivity-only", "--no-dangling", "--no-progress")
+	return err
+}
+
+// checkoutShared clones the mirror to destination without copying objects and checks out ref
+func checkoutShared(ctx context.Context, mirror, remoteURL, destination, ref, branch string) error {
+	if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return fmt.Errorf("failed to create parent directories: %w", err)
+	}
+	if _, err := runGit(ctx, "", "clone", "--quiet", "--shared", "--no-checkout", mirror, destination); err != nil {
+		return fmt.Errorf("failed to create working copy: %w", err)
+	}
+	if _, err := runGit(ctx, destination, "remote", "set-url", "origin", remoteURL); err != nil {
+		return fmt.Errorf("failed to set working copy remote: %w", redactError(err, remoteURL))
+	}
+
+	// Branches of the mirror are remote-tracking branches of the working copy
+	target := ref
+	if _, err := runGit(ctx, destination, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		target = "origin/" + ref
+	}
+
+	args := []string{"checkout", "--quiet", "--detach", target}
+	if branch != "" {
+		args = []string{"checkout", "--quiet", "-B", branch, target}
+	}
+	if _, err := runGit(ctx, destination, args...); err != nil {
+		return fmt.Errorf("failed to check out %s: %w", ref, err)
+	}
+
+	if !isCommitSHA(ref) {
+		return nil
+	}
+	output, err := runGit(ctx, destination, "rev-parse", "HEAD")
+	if err != nil {
+		return fmt.Errorf("failed to verify checkout: %w", err)
+	}
+	if head := strings.TrimSpace(string(output)); !strings.HasPrefix(head, ref) {
+		return fmt.Errorf("checked out %s instead of %s", head, ref)
+	}
+	return nil
+}
+
+// isCommitSHA reports whether ref looks like a full or abbreviated commit SHA
+func isCommitSHA(ref string) bool {
+	if len(ref) < 7 || len(ref) > 64 {
+		return false
+	}
+	for _, r := range ref {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// mirrorInfo describes a mirror considered for eviction
+type mirrorInfo struct {
+	path     string
+	size     int64
+	lastUsed time.Time
+}
+
+// evict removes the least recently used mirrors until the cache fits its quota. Mirrors in
+// use and the mirror just used are kept.
+func (c *Cache) evict(current string) {
+	if c.quota <= 0 {
+		return
+	}
+
+	mirrors, err := c.listMirrors()
+	if err != nil {
+		log.Printf("Warning: failed to list repository mirrors: %v", err)
+		return
+	}
+
+	var total int64
+	for _, mirror := range mirrors {
+		total += mirror.size
+	}
+	sort.Slice(mirrors, func(i, j int) bool { return mirrors[i].lastUsed.Before(mirrors[j].lastUsed) })
+
+	for _, mirror := range mirrors {
+		if total <= c.quota {
+			return
+		}
+		if mirror.path == current {
+			continue
+		}
+		if err := c.evictMirror(mirror.path); err != nil {
+			if !errors.Is(err, errLocked) {
+				log.Printf("Warning: failed to evict repository mirror %s: %v", mirror.path, err)
+			}
+			continue
+		}
+		log.Printf("Evicted repository mirror %s (%d MB) to stay within the cache quota", mirror.path, mirror.size>>20)
+		total -= mirror.size
+	}
+}
+
+// evictMirror removes a mirror unless it is being updated or used
+func (c *Cache) evictMirror(mirror string) error {
+	updateLock, err := lockFile(mirror+updateLockSuffix, true, false)
+	if err != nil {
+		return err
+	}
+	defer unlockFile(updateLock)
+
+	if err := removeUnused(mirror); err != nil {
+		return err
+	}
+	os.Remove(mirror + usedMarkerSuffix)
+	return nil
+}
+
+// removeUnused deletes a mirror unless a working copy holds its use lock. The caller holds the update lock.
+func removeUnused(mirror string) error {
+	useLock, err := lockFile(mirror+useLockSuffix, true, false)
+	if err != nil {
+		return err
+	}
+	defer unlockFile(useLock)
+
+	return os.RemoveAll(mirror)
+}
+
+// listMirrors returns every mirror in the cache with its size and last use
+func (c *Cache) listMirrors() ([]mirrorInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(c.dir, "*", "*.git"))
+	if err != nil {
+		return nil, err
+	}
+
+	mirrors := make([]mirrorInfo, 0, len(paths))
+	for _, path := range paths {
+		info := mirrorInfo{path: path, size: dirSize(path)}
+		if stat, err := os.Stat(path + usedMarkerSuffix); err == nil {
+			info.lastUsed = stat.ModTime()
+		}
+		mirrors = append(mirrors, info)
+	}
+	return mirrors, nil
+}
+
+// mirrorPath returns where the mirror of a repository is kept
+func (c *Cache) mirrorPath(owner, repo string) string {
+	return filepath.Join(c.dir, sanitizePathElement(owner), sanitizePathElement(repo)+".git")
+}
+
+// sanitizePathElement keeps a repository owner or name from escaping the cache directory
+func sanitizePathElement(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	if name == "" || name == "." || name == ".." {
+		return "_" + name
+	}
+	return name
+}
+
+// dirSize returns the total size of the regular files under a directory
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// runGit runs a hardened git command without prompting for credentials and returns its output
+func runGit(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := gitsafe.Command(ctx, dir, args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("git %s: %w: %s", args[0], err, message)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return output, nil
+}
+
+// redactURL removes credentials from a remote URL
+func redactURL(remoteURL string) string {
+	parsed, err := url.Parse(remoteURL)
+	if err != nil || parsed.User == nil {
+		return remoteURL
+	}
+	parsed.User = nil
+	return parsed.String()
+}
+
+// redactError removes the credentials of a remote URL from an error message
+func redactError(err error, remoteURL string) error {
+	if err == nil || redactURL(remoteURL) == remoteURL {
+		return err
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), remoteURL, redactURL(remoteURL)))
+}
+
+// randomSuffix returns a short random string for temporary names
+func randomSuffix() string {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
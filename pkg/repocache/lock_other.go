@@ -0,0 +1,29 @@
+//go:build !unix
+
+package repocache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// errLocked is returned by a non-blocking lock attempt on a file locked elsewhere
+var errLocked = errors.New("locked by another review")
+
+// lockFile opens the lock file without locking it; file locks are only supported on Unix,
+// so a cache must not be shared between concurrent reviews on other platforms
+func lockFile(path string, exclusive, wait bool) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	return file, nil
+}
+
+// unlockFile releases a lock taken with lockFile
+func unlockFile(file *os.File) {
+	if file != nil {
+		file.Close()
+	}
+}
@@ -0,0 +1,55 @@
+//go:build unix
+
+package repocache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// errLocked is returned by a non-blocking lock attempt on a file locked elsewhere
+var errLocked = errors.New("locked by another review")
+
+// lockFile takes an advisory lock on the file at path, creating it if needed. Locks are held
+// per open file, so they also exclude other goroutines of this process. With wait unset a
+// held lock fails with errLocked instead of blocking.
+func lockFile(path string, exclusive, wait bool) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if !wait {
+		how |= syscall.LOCK_NB
+	}
+
+	for {
+		err = syscall.Flock(int(file.Fd()), how)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errLocked
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// unlockFile releases a lock taken with lockFile
+func unlockFile(file *os.File) {
+	if file == nil {
+		return
+	}
+	_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	file.Close()
+}
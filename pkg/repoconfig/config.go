@@ -0,0 +1,312 @@
+package repoconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/github"
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
+	"github.com/bmatcuk/doublestar/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// FileNames are the locations checked for a repository config, in order
+var FileNames = []string{".review-agent.yml", ".review-agent.yaml"}
+
+const (
+	// MaxContextLines is the largest accepted context_lines value
+	MaxContextLines = 50
+	// MaxEnclosingContextLines is the largest accepted enclosing_context_lines value
+	MaxEnclosingContextLines = 500
+	// MaxInstructionsLength is the longest accepted instructions text
+	MaxInstructionsLength = 8000
+)
+
+// Config holds per-repository review settings. Unset fields keep the global defaults.
+type Config struct {
+	ReviewTypes       []string `yaml:"review_types" json:"review_types,omitempty"`
+	Instructions      string   `yaml:"instructions" json:"instructions,omitempty"`
+	Include           []string `yaml:"include" json:"include,omitempty"`
+	Exclude           []string `yaml:"exclude" json:"exclude,omitempty"`
+	SeverityThreshold string   `yaml:"severity_threshold" json:"severity_threshold,omitempty"`
+	// Findings below this confidence (0-1) are held back instead of posted
+	ConfidenceThreshold *float64 `yaml:"confidence_threshold" json:"confidence_threshold,omitempty"`
+	// ShowPossibleIssues lists held-back findings in a collapsed section of the summary
+	ShowPossibleIssues *bool  `yaml:"show_possible_issues" json:"show_possible_issues,omitempty"`
+	Model              string `yaml:"model" json:"model,omitempty"`
+	ContextLines       *int   `yaml:"context_lines" json:"context_lines,omitempty"`
+	DeletionAnalysis   *bool  `yaml:"deletion_analysis" json:"deletion_analysis,omitempty"`
+	// EnclosingContextLines bounds the lines shown of the function around each change (0 disables it)
+	EnclosingContextLines *int `yaml:"enclosing_context_lines" json:"enclosing_context_lines,omitempty"`
+	// TokenBudget caps the estimated diff tokens reviewed; the riskiest files are kept (0 is unlimited)
+	TokenBudget *int `yaml:"token_budget" json:"token_budget,omitempty"`
+	// Routing picks the model from the size, languages and paths of the diff; ignored when model is set
+	Routing *RoutingConfig `yaml:"routing" json:"routing,omitempty"`
+
+	// Source is the file the config was loaded from
+	Source string `yaml:"-" json:"source,omitempty"`
+}
+
+// RoutingConfig chooses the model for each pull request. The first matching rule wins; when
+// none matches the default model is used.
+type RoutingConfig struct {
+	Rules []RoutingRule `yaml:"rules" json:"rules,omitempty"`
+	// SecondOpinion re-checks severe findings with another model
+	SecondOpinion *SecondOpinion `yaml:"second_opinion" json:"second_opinion,omitempty"`
+}
+
+// RoutingRule sends pull requests matching every set condition to a model
+type RoutingRule struct {
+	Name  string           `yaml:"name" json:"name"`
+	Model string           `yaml:"model" json:"model"`
+	When  RoutingCondition `yaml:"when" json:"when"`
+}
+
+// RoutingCondition is matched against the reviewed files; unset fields match everything
+type RoutingCondition struct {
+	MinChangedLines *int     `yaml:"min_changed_lines" json:"min_changed_lines,omitempty"`
+	MaxChangedLines *int     `yaml:"max_changed_lines" json:"max_changed_lines,omitempty"`
+	MinFiles        *int     `yaml:"min_files" json:"min_files,omitempty"`
+	MaxFiles        *int     `yaml:"max_files" json:"max_files,omitempty"`
+	Languages       []string `yaml:"languages" json:"languages,omitempty"`           // At least one file is in one of these languages
+	OnlyLanguages   []string `yaml:"only_languages" json:"only_languages,omitempty"` // Every file is in one of these languages
+	Paths           []string `yaml:"paths" json:"paths,omitempty"`                   // At least one file matches one of these globs
+	OnlyPaths       []string `yaml:"only_paths" json:"only_paths,omitempty"`         // Every file matches one of these globs
+}
+
+// SecondOpinion asks another model to confirm findings at or above a severity
+type SecondOpinion struct {
+	Model       string `yaml:"model" json:"model"`
+	MinSeverity string `yaml:"min_severity" json:"min_severity,omitempty"` // Defaults to critical
+}
+
+// ValidationError lists every problem found in a config file
+type ValidationError struct {
+	File     string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.File, strings.Join(e.Problems, "; "))
+}
+
+// FileReader reads a file as it exists at a given git ref of a checked-out repository. A file
+// missing at the ref is reported with an error wrapping github.ErrNotFound.
+type FileReader interface {
+	ReadFileAtRef(ctx context.Context, repoPath, ref, path string) ([]byte, error)
+}
+
+// Load reads the repository config from the first ref that has one.
+// Reading from the base ref keeps pull request authors from weakening the config.
+// Returns nil without error when no config file exists, and the error of any other failed read,
+// so a config that could not be read is never silently replaced by the defaults.
+func Load(ctx context.Context, reader FileReader, repoPath string, refs []string) (*Config, error) {
+	for _, ref := range refs {
+		if ref == "" {
+			continue
+		}
+		for _, name := range FileNames {
+			data, err := reader.ReadFileAtRef(ctx, repoPath, ref, name)
+			if errors.Is(err, github.ErrNotFound) {
+				continue // Missing at this ref
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", name, err)
+			}
+
+			config, err := Parse(data, name)
+			if err != nil {
+				return nil, err
+			}
+			return config, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Parse decodes and validates a config file
+func Parse(data []byte, source string) (*Config, error) {
+	config := &Config{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, &ValidationError{File: source, Problems: []string{err.Error()}}
+	}
+
+	config.Source = source
+	if problems := config.Validate(); len(problems) > 0 {
+		return nil, &ValidationError{File: source, Problems: problems}
+	}
+
+	return config, nil
+}
+
+// Validate checks the config against the schema and returns every problem found
+func (c *Config) Validate() []string {
+	var problems []string
+
+	seen := make(map[string]bool)
+	for _, reviewType := range c.ReviewTypes {
+		if !isValidReviewType(reviewType) {
+			problems = append(problems, fmt.Sprintf("review_types: unknown review type %q (expected one of %s)",
+				reviewType, strings.Join(reviewTypeNames(), ", ")))
+		} else if seen[reviewType] {
+			problems = append(problems, fmt.Sprintf("review_types: %q listed more than once", reviewType))
+		}
+		seen[reviewType] = true
+	}
+
+	if len(c.Instructions) > MaxInstructionsLength {
+		problems = append(problems, fmt.Sprintf("instructions: must be at most %d characters, got %d",
+			MaxInstructionsLength, len(c.Instructions)))
+	}
+
+	for _, pattern := range c.Include {
+		if !doublestar.ValidatePattern(pattern) {
+			problems = append(problems, fmt.Sprintf("include: invalid glob %q", pattern))
+		}
+	}
+	for _, pattern := range c.Exclude {
+		if !doublestar.ValidatePattern(pattern) {
+			problems = append(problems, fmt.Sprintf("exclude: invalid glob %q", pattern))
+		}
+	}
+
+	if c.SeverityThreshold != "" && llm.Severity(c.SeverityThreshold).Rank() == 0 {
+		problems = append(problems, fmt.Sprintf("severity_threshold: unknown severity %q (expected info, minor, major or critical)",
+			c.SeverityThreshold))
+	}
+
+	if c.ConfidenceThreshold != nil && (*c.ConfidenceThreshold < 0 || *c.ConfidenceThreshold > 1) {
+		problems = append(problems, fmt.Sprintf("confidence_threshold: must be between 0 and 1, got %g",
+			*c.ConfidenceThreshold))
+	}
+
+	if c.Model != "" && !isValidModel(c.Model) {
+		problems = append(problems, fmt.Sprintf("model: unsupported model %q (expected one of %s)",
+			c.Model, strings.Join(llm.AvailableClaudeModels, ", ")))
+	}
+
+	if c.ContextLines != nil && (*c.ContextLines < 0 || *c.ContextLines > MaxContextLines) {
+		problems = append(problems, fmt.Sprintf("context_lines: must be between 0 and %d, got %d",
+			MaxContextLines, *c.ContextLines))
+	}
+
+	if c.EnclosingContextLines != nil && (*c.EnclosingContextLines < 0 || *c.EnclosingContextLines > MaxEnclosingContextLines) {
+		problems = append(problems, fmt.Sprintf("enclosing_context_lines: must be between 0 and %d, got %d",
+			MaxEnclosingContextLines, *c.EnclosingContextLines))
+	}
+
+	if c.TokenBudget != nil && *c.TokenBudget < 0 {
+		problems = append(problems, fmt.Sprintf("token_budget: must not be negative, got %d", *c.TokenBudget))
+	}
+
+	if c.Routing != nil {
+		problems = append(problems, c.Routing.validate()...)
+	}
+
+	return problems
+}
+
+// validate checks the routing rules and second opinion settings
+func (r *RoutingConfig) validate() []string {
+	var problems []string
+
+	for i, rule := range r.Rules {
+		field := fmt.Sprintf("routing.rules[%d]", i)
+		if rule.Name != "" {
+			field = fmt.Sprintf("routing.rules[%s]", rule.Name)
+		}
+
+		if rule.Model == "" {
+			problems = append(problems, fmt.Sprintf("%s.model: is required", field))
+		} else if !isValidModel(rule.Model) {
+			problems = append(problems, fmt.Sprintf("%s.model: unsupported model %q (expected one of %s)",
+				field, rule.Model, strings.Join(llm.AvailableClaudeModels, ", ")))
+		}
+
+		when := rule.When
+		limits := []struct {
+			name  string
+			value *int
+		}{
+			{"min_changed_lines", when.MinChangedLines},
+			{"max_changed_lines", when.MaxChangedLines},
+			{"min_files", when.MinFiles},
+			{"max_files", when.MaxFiles},
+		}
+		for _, limit := range limits {
+			if limit.value != nil && *limit.value < 0 {
+				problems = append(problems, fmt.Sprintf("%s.when.%s: must not be negative, got %d", field, limit.name, *limit.value))
+			}
+		}
+		for _, pattern := range append(append([]string{}, when.Paths...), when.OnlyPaths...) {
+			if !doublestar.ValidatePattern(pattern) {
+				problems = append(problems, fmt.Sprintf("%s.when: invalid glob %q", field, pattern))
+			}
+		}
+	}
+
+	if second := r.SecondOpinion; second != nil {
+		if !isValidModel(second.Model) {
+			problems = append(problems, fmt.Sprintf("routing.second_opinion.model: unsupported model %q (expected one of %s)",
+				second.Model, strings.Join(llm.AvailableClaudeModels, ", ")))
+		}
+		if second.MinSeverity != "" && llm.Severity(second.MinSeverity).Rank() == 0 {
+			problems = append(problems, fmt.Sprintf("routing.second_opinion.min_severity: unknown severity %q (expected info, minor, major or critical)",
+				second.MinSeverity))
+		}
+	}
+
+	return problems
+}
+
+// ReviewTypeList returns the configured review types, or nil when none are set
+func (c *Config) ReviewTypeList() []llm.ReviewType {
+	var reviewTypes []llm.ReviewType
+	for _, reviewType := range c.ReviewTypes {
+		reviewTypes = append(reviewTypes, llm.ReviewType(reviewType))
+	}
+	return reviewTypes
+}
+
+var knownReviewTypes = []llm.ReviewType{
+	llm.ReviewTypeGeneral,
+	llm.ReviewTypeSecurity,
+	llm.ReviewTypePerformance,
+	llm.ReviewTypeStyle,
+	llm.ReviewTypeBugs,
+	llm.ReviewTypeTests,
+}
+
+func isValidReviewType(reviewType string) bool {
+	for _, known := range knownReviewTypes {
+		if string(known) == reviewType {
+			return true
+		}
+	}
+	return false
+}
+
+func reviewTypeNames() []string {
+	names := make([]string, 0, len(knownReviewTypes))
+	for _, known := range knownReviewTypes {
+		names = append(names, string(known))
+	}
+	return names
+}
+
+func isValidModel(model string) bool {
+	for _, available := range llm.AvailableClaudeModels {
+		if model == available {
+			return true
+		}
+	}
+	return false
+}
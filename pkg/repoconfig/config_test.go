@@ -0,0 +1,274 @@
+package repoconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/github"
+)
+
+func TestParse_Valid(t *testing.T) {
+	data := []byte(`
+review_types: [security, bugs]
+instructions: |
+  Focus on SQL injection.
+include:
+  - "src/**"
+exclude:
+  - "**/*_test.go"
+  - "*.lock"
+severity_threshold: major
+model: claude-sonnet-4-20250514
+context_lines: 10
+deletion_analysis: false
+`)
+
+	config, err := Parse(data, ".review-agent.yml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(config.ReviewTypes) != 2 || config.ReviewTypes[0] != "security" {
+		t.Errorf("unexpected review types: %v", config.ReviewTypes)
+	}
+	if !strings.Contains(config.Instructions, "SQL injection") {
+		t.Errorf("unexpected instructions: %q", config.Instructions)
+	}
+	if len(config.Include) != 1 || len(config.Exclude) != 2 {
+		t.Errorf("unexpected globs: include=%v exclude=%v", config.Include, config.Exclude)
+	}
+	if config.SeverityThreshold != "major" || config.Model != "claude-sonnet-4-20250514" {
+		t.Errorf("unexpected threshold/model: %s/%s", config.SeverityThreshold, config.Model)
+	}
+	if config.ContextLines == nil || *config.ContextLines != 10 {
+		t.Errorf("expected context_lines 10, got %v", config.ContextLines)
+	}
+	if config.DeletionAnalysis == nil || *config.DeletionAnalysis {
+		t.Errorf("expected deletion_analysis false, got %v", config.DeletionAnalysis)
+	}
+	if config.Source != ".review-agent.yml" {
+		t.Errorf("expected source to be recorded, got %q", config.Source)
+	}
+}
+
+func TestParse_Routing(t *testing.T) {
+	data := []byte(`
+routing:
+  rules:
+    - name: docs-only
+      model: claude-3-5-haiku-20241022
+      when:
+        only_paths: ["*.md", "docs/**"]
+    - name: large
+      model: claude-sonnet-4-20250514
+      when:
+        min_changed_lines: 500
+        languages: [go]
+  second_opinion:
+    model: claude-sonnet-4-20250514
+`)
+
+	config, err := Parse(data, ".review-agent.yml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	routing := config.Routing
+	if routing == nil || len(routing.Rules) != 2 {
+		t.Fatalf("expected 2 routing rules, got %+v", routing)
+	}
+	if routing.Rules[0].Name != "docs-only" || len(routing.Rules[0].When.OnlyPaths) != 2 {
+		t.Errorf("unexpected first rule: %+v", routing.Rules[0])
+	}
+	large := routing.Rules[1].When
+	if large.MinChangedLines == nil || *large.MinChangedLines != 500 || len(large.Languages) != 1 {
+		t.Errorf("unexpected second rule conditions: %+v", large)
+	}
+	if routing.SecondOpinion == nil || routing.SecondOpinion.Model != "claude-sonnet-4-20250514" {
+		t.Errorf("unexpected second opinion: %+v", routing.SecondOpinion)
+	}
+}
+
+func TestParse_Empty(t *testing.T) {
+	config, err := Parse([]byte(""), ".review-agent.yml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.ContextLines != nil || config.DeletionAnalysis != nil || len(config.ReviewTypes) != 0 {
+		t.Errorf("expected empty config, got %+v", config)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		problems []string
+	}{
+		{
+			name:     "unknown field",
+			data:     "review_type: security\n",
+			problems: []string{"review_type"},
+		},
+		{
+			name:     "malformed yaml",
+			data:     "review_types: [security\n",
+			problems: []string{"yaml"},
+		},
+		{
+			name:     "unknown review type",
+			data:     "review_types: [security, vibes]\n",
+			problems: []string{`unknown review type "vibes"`},
+		},
+		{
+			name:     "duplicate review type",
+			data:     "review_types: [bugs, bugs]\n",
+			problems: []string{"listed more than once"},
+		},
+		{
+			name:     "invalid glob",
+			data:     "exclude: [\"src/[\"]\n",
+			problems: []string{`exclude: invalid glob "src/["`},
+		},
+		{
+			name:     "unknown severity",
+			data:     "severity_threshold: urgent\n",
+			problems: []string{`unknown severity "urgent"`},
+		},
+		{
+			name:     "confidence threshold out of range",
+			data:     "confidence_threshold: 1.2\n",
+			problems: []string{"confidence_threshold: must be between 0 and 1, got 1.2"},
+		},
+		{
+			name:     "negative token budget",
+			data:     "token_budget: -1\n",
+			problems: []string{"token_budget: must not be negative"},
+		},
+		{
+			name:     "unsupported model",
+			data:     "model: gpt-4\n",
+			problems: []string{`unsupported model "gpt-4"`},
+		},
+		{
+			name:     "context lines out of range",
+			data:     "context_lines: 500\n",
+			problems: []string{"context_lines: must be between 0 and 50"},
+		},
+		{
+			name:     "enclosing context lines out of range",
+			data:     "enclosing_context_lines: 1000\n",
+			problems: []string{"enclosing_context_lines: must be between 0 and 500"},
+		},
+		{
+			name:     "routing rule without a model",
+			data:     "routing:\n  rules:\n    - name: docs\n      when: {only_paths: [\"*.md\"]}\n",
+			problems: []string{"routing.rules[docs].model: is required"},
+		},
+		{
+			name:     "routing rule with bad conditions",
+			data:     "routing:\n  rules:\n    - model: claude-3-5-haiku-20241022\n      when: {max_files: -1, paths: [\"[\"]}\n",
+			problems: []string{"routing.rules[0].when.max_files: must not be negative", `routing.rules[0].when: invalid glob "["`},
+		},
+		{
+			name:     "unknown routing condition",
+			data:     "routing:\n  rules:\n    - model: claude-3-5-haiku-20241022\n      when: {max_lines: 10}\n",
+			problems: []string{"field max_lines not found"},
+		},
+		{
+			name:     "invalid second opinion",
+			data:     "routing:\n  second_opinion: {model: gpt-4, min_severity: urgent}\n",
+			problems: []string{`routing.second_opinion.model: unsupported model "gpt-4"`, `min_severity: unknown severity "urgent"`},
+		},
+		{
+			name:     "multiple problems reported together",
+			data:     "severity_threshold: urgent\ncontext_lines: -1\n",
+			problems: []string{"severity_threshold", "context_lines"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data), ".review-agent.yml")
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected ValidationError, got %v", err)
+			}
+			message := strings.Join(validationErr.Problems, "\n")
+			for _, problem := range tt.problems {
+				if !strings.Contains(message, problem) {
+					t.Errorf("expected problem containing %q, got:\n%s", problem, message)
+				}
+			}
+		})
+	}
+}
+
+type mockFileReader struct {
+	files map[string]string // "ref:path" -> content
+	reads []string
+}
+
+func (m *mockFileReader) ReadFileAtRef(ctx context.Context, repoPath, ref, path string) ([]byte, error) {
+	m.reads = append(m.reads, ref+":"+path)
+	content, ok := m.files[ref+":"+path]
+	if !ok {
+		return nil, fmt.Errorf("path '%s' does not exist in '%s': %w", path, ref, github.ErrNotFound)
+	}
+	return []byte(content), nil
+}
+
+type failingFileReader struct {
+	err error
+}
+
+func (f *failingFileReader) ReadFileAtRef(ctx context.Context, repoPath, ref, path string) ([]byte, error) {
+	return nil, f.err
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("reads from the first ref that has a config", func(t *testing.T) {
+		reader := &mockFileReader{files: map[string]string{
+			"origin/main:.review-agent.yaml": "severity_threshold: major\n",
+		}}
+
+		config, err := Load(context.Background(), reader, "/repo", []string{"base123", "origin/main"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if config == nil || config.SeverityThreshold != "major" || config.Source != ".review-agent.yaml" {
+			t.Errorf("unexpected config: %+v", config)
+		}
+	})
+
+	t.Run("missing config", func(t *testing.T) {
+		config, err := Load(context.Background(), &mockFileReader{}, "/repo", []string{"base123"})
+		if err != nil || config != nil {
+			t.Errorf("expected no config and no error, got %+v, %v", config, err)
+		}
+	})
+
+	t.Run("read failures are reported", func(t *testing.T) {
+		reader := &failingFileReader{err: errors.New("GitHub API returned status 502")}
+		config, err := Load(context.Background(), reader, "/repo", []string{"base123", "origin/main"})
+		if err == nil || config != nil || !strings.Contains(err.Error(), "status 502") {
+			t.Errorf("expected the read error, got %+v, %v", config, err)
+		}
+	})
+
+	t.Run("invalid config is reported", func(t *testing.T) {
+		reader := &mockFileReader{files: map[string]string{
+			"base123:.review-agent.yml": "model: gpt-4\n",
+		}}
+
+		_, err := Load(context.Background(), reader, "/repo", []string{"base123"})
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("expected ValidationError, got %v", err)
+		}
+	})
+}
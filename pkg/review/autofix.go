@@ -0,0 +1,367 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/analyzer"
+	"github.com/GDSources/claude-code-review-agent/pkg/gitsafe"
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
+)
+
+// DefaultAutofixVerifyTimeout bounds how long the verification command may run
+const DefaultAutofixVerifyTimeout = 10 * time.Minute
+
+// verifyEnvironment lists the variables passed on to the verification command, which runs code
+// from the pull request. Everything else, including the GitHub token and the Anthropic API key,
+// is withheld.
+var verifyEnvironment = []string{"PATH", "HOME", "USER", "LANG", "LC_ALL", "TMPDIR"}
+
+// AutofixSettings configures pushing suggested fixes to a branch instead of commenting them
+type AutofixSettings struct {
+	Enabled        bool
+	VerifyCommand  string        // Run in the workspace after applying fixes, e.g. "go build ./..."; fixes are dropped when it fails. It executes pull request code.
+	VerifyTimeout  time.Duration // 0 uses DefaultAutofixVerifyTimeout
+	PushToPRBranch bool          // Push to the pull request branch when it lives in the same repository
+}
+
+// FixPublisher commits workspace changes and pushes them to a branch
+type FixPublisher interface {
+	CommitAndPush(ctx context.Context, repoPath, branch, message string, files []string, force bool) (string, error)
+}
+
+// AutofixResult describes the commit of suggested fixes pushed for a review
+type AutofixResult struct {
+	Branch    string `json:"branch"`
+	CommitSHA string `json:"commit_sha"`
+	URL       string `json:"url"`
+	Applied   int    `json:"applied"`
+}
+
+// SetAutofix enables applying validated suggestions to the workspace and pushing them to a branch
+func (r *DefaultReviewOrchestrator) SetAutofix(settings AutofixSettings) {
+	r.autofix = settings
+}
+
+// autofixBranch returns the branch fixes are pushed to and whether it may be overwritten
+func (r *DefaultReviewOrchestrator) autofixBranch(event *PullRequestEvent) (string, bool) {
+	head := event.PullRequest.Head
+	if r.autofix.PushToPRBranch && head.Repo.FullName != "" && strings.EqualFold(head.Repo.FullName, event.Repository.FullName) {
+		return head.Ref, false
+	}
+	return fmt.Sprintf("review-agent/fixes-%d", event.Number), true
+}
+
+// applyAutofixes applies the comments' suggestions to the workspace, verifies the result and pushes
+// it. It returns the comments that still need to be posted and those that were fixed. Suggestions
+// that cannot be applied, and all of them when verification or pushing fails, stay as comments.
+func (r *DefaultReviewOrchestrator) applyAutofixes(ctx context.Context, reviewData *ReviewData, comments []llm.ReviewComment) ([]llm.ReviewComment, []llm.ReviewComment, *AutofixResult, error) {
+	publisher, ok := r.githubClient.(FixPublisher)
+	if !ok {
+		return comments, nil, nil, fmt.Errorf("GitHub client cannot push commits")
+	}
+	if reviewData.Workspace == nil {
+		return comments, nil, nil, fmt.Errorf("no workspace")
+	}
+	// The fixes commit holds the head commits, which must not land in the base repository from a
+	// fork, where a push would run its workflows with the repository's secrets
+	head := reviewData.Event.PullRequest.Head.Repo.FullName
+	if head == "" || !strings.EqualFold(head, reviewData.Event.Repository.FullName) {
+		return comments, nil, nil, fmt.Errorf("autofix is not available for pull requests from forks")
+	}
+	// Group fixable comments by file, remembering their position in comments
+	byFile := make(map[string][]int)
+	var filenames []string
+	for i, comment := range comments {
+		if _, _, anchored := anchorSuggestion(reviewData.ContextualDiff, comment); !anchored {
+			continue
+		}
+		if byFile[comment.Filename] == nil {
+			filenames = append(filenames, comment.Filename)
+		}
+		byFile[comment.Filename] = append(byFile[comment.Filename], i)
+	}
+	if len(filenames) == 0 {
+		return comments, nil, nil, nil
+	}
+	repoPath, err := reviewData.Workspace.Checkout(ctx)
+	if err != nil {
+		return comments, nil, nil, fmt.Errorf("failed to check out repository: %w", err)
+	}
+
+	fixed := make(map[int]bool)
+	originals := make(map[string][]byte)
+	for _, filename := range filenames {
+		original, applied, err := applyFileSuggestions(repoPath, fileDiff(reviewData.ContextualDiff, filename), comments, byFile[filename])
+		if err != nil {
+			log.Printf("Warning: could not apply suggestions to %s: %v", filename, err)
+			continue
+		}
+		if len(applied) == 0 {
+			continue
+		}
+		originals[filename] = original
+		for _, idx := range applied {
+			fixed[idx] = true
+		}
+	}
+	if len(fixed) == 0 {
+		return comments, nil, nil, nil
+	}
+
+	restore := func() {
+		for filename, content := range originals {
+			if err := os.WriteFile(filepath.Join(repoPath, filename), content, 0644); err != nil {
+				log.Printf("Warning: failed to restore %s after autofix: %v", filename, err)
+			}
+		}
+	}
+
+	if r.autofix.VerifyCommand != "" {
+		if output, err := r.runVerifyCommand(ctx, repoPath); err != nil {
+			restore()
+			log.Printf("Autofix verification failed for PR #%d: %v\n%s", reviewData.Event.Number, err, output)
+			return comments, nil, nil, fmt.Errorf("verification command %q failed: %w", r.autofix.VerifyCommand, err)
+		}
+	}
+
+	var remaining, applied []llm.ReviewComment
+	for i, comment := range comments {
+		if fixed[i] {
+			applied = append(applied, comment)
+		} else {
+			remaining = append(remaining, comment)
+		}
+	}
+
+	files := make([]string, 0, len(originals))
+	for filename := range originals {
+		files = append(files, filename)
+	}
+	sort.Strings(files)
+
+	branch, force := r.autofixBranch(reviewData.Event)
+	sha, err := publisher.CommitAndPush(ctx, repoPath, branch, autofixCommitMessage(reviewData.Event.Number, applied), files, force)
+	if err != nil {
+		restore()
+		return comments, nil, nil, fmt.Errorf("failed to push fixes: %w", err)
+	}
+
+	return remaining, applied, &AutofixResult{
+		Branch:    branch,
+		CommitSHA: sha,
+		URL:       commitURL(reviewData.Event.Repository, sha),
+		Applied:   len(applied),
+	}, nil
+}
+
+// commitURL links to a commit on the repository's web host, which may be GitHub Enterprise
+// Server. Returns "" when the event did not carry the repository's html_url.
+func commitURL(repository Repository, sha string) string {
+	if repository.HTMLURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(repository.HTMLURL, "/") + "/commit/" + sha
+}
+
+// runVerifyCommand runs the configured verification command in the workspace. The command
+// executes the pull request's code, so it gets no credentials: only the variables of
+// verifyEnvironment, and a working copy whose origin URL carries no token.
+func (r *DefaultReviewOrchestrator) runVerifyCommand(ctx context.Context, repoPath string) ([]byte, error) {
+	timeout := r.autofix.VerifyTimeout
+	if timeout <= 0 {
+		timeout = DefaultAutofixVerifyTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	restore, err := removeRemoteCredentials(ctx, repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove credentials from the workspace: %w", err)
+	}
+	defer restore()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", r.autofix.VerifyCommand)
+	cmd.Dir = repoPath
+	cmd.Env = []string{"GIT_TERMINAL_PROMPT=0"}
+	for _, name := range verifyEnvironment {
+		if value, ok := os.LookupEnv(name); ok {
+			cmd.Env = append(cmd.Env, name+"="+value)
+		}
+	}
+	return cmd.CombinedOutput()
+}
+
+// removeRemoteCredentials strips the token from the origin URL of the working copy at repoPath.
+// The returned function restores the repository config as it was, which also discards any change
+// made to it in the meantime. A directory that is not a git working copy is left as it is.
+func removeRemoteCredentials(ctx context.Context, repoPath string) (func(), error) {
+	if _, err := os.Lstat(filepath.Join(repoPath, ".git")); err != nil {
+		return func() {}, nil
+	}
+
+	output, err := gitsafe.Command(ctx, repoPath, "rev-parse", "--git-path", "config").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate the repository config: %w", err)
+	}
+	configPath := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(configPath) {
+		configPath = filepath.Join(repoPath, configPath)
+	}
+	saved, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the repository config: %w", err)
+	}
+	restore := func() {
+		if err := os.WriteFile(configPath, saved, 0644); err != nil {
+			log.Printf("Warning: failed to restore the repository config of %s: %v", repoPath, err)
+		}
+	}
+
+	output, err = gitsafe.Command(ctx, repoPath, "remote", "get-url", "origin").Output()
+	if err != nil {
+		return restore, nil // No origin to take credentials from
+	}
+	remote, err := url.Parse(strings.TrimSpace(string(output)))
+	if err != nil || remote.User == nil {
+		return restore, nil
+	}
+	remote.User = nil
+	if err := gitsafe.Command(ctx, repoPath, "remote", "set-url", "origin", remote.String()).Run(); err != nil {
+		restore()
+		return nil, fmt.Errorf("failed to set the origin URL: %w", err)
+	}
+	return restore, nil
+}
+
+// fileDiff returns the diff of a single file, or nil when it is not part of the review
+func fileDiff(diff *analyzer.ContextualDiff, filename string) *analyzer.FileDiff {
+	if diff == nil || diff.ParsedDiff == nil {
+		return nil
+	}
+	for i := range diff.Files {
+		if diff.Files[i].Filename == filename {
+			return &diff.Files[i]
+		}
+	}
+	return nil
+}
+
+// applyFileSuggestions replaces the suggested line ranges of a workspace file, bottom to top so
+// earlier ranges keep their line numbers. A suggestion is only applied when the lines it replaces
+// still match the diff and do not overlap another applied suggestion. Returns the original file
+// content and the indexes of the applied comments.
+func applyFileSuggestions(repoPath string, file *analyzer.FileDiff, comments []llm.ReviewComment, indexes []int) ([]byte, []int, error) {
+	if file == nil {
+		return nil, nil, fmt.Errorf("file is not part of the diff")
+	}
+	path := filepath.Join(repoPath, file.Filename)
+	if rel, err := filepath.Rel(repoPath, path); err != nil || strings.HasPrefix(rel, "..") {
+		return nil, nil, fmt.Errorf("path is outside the workspace")
+	}
+
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	content := string(original)
+	trailingNewline := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+
+	type fix struct {
+		index      int
+		start, end int
+	}
+	var fixes []fix
+	for _, idx := range indexes {
+		start, end := suggestionRange(comments[idx])
+		fixes = append(fixes, fix{index: idx, start: start, end: end})
+	}
+	sort.SliceStable(fixes, func(i, j int) bool { return fixes[i].start > fixes[j].start })
+
+	var applied []int
+	lowestApplied := len(lines) + 1
+	for _, candidate := range fixes {
+		if candidate.end >= lowestApplied || candidate.end > len(lines) {
+			continue
+		}
+		if !linesMatchHunk(file.HunkSpanning(candidate.start, candidate.end), lines, candidate.start, candidate.end) {
+			continue
+		}
+
+		replacement := strings.Split(strings.TrimSuffix(comments[candidate.index].Suggestion, "\n"), "\n")
+		updated := append([]string{}, lines[:candidate.start-1]...)
+		updated = append(updated, replacement...)
+		lines = append(updated, lines[candidate.end:]...)
+
+		lowestApplied = candidate.start
+		applied = append(applied, candidate.index)
+	}
+	if len(applied) == 0 {
+		return original, nil, nil
+	}
+
+	updated := strings.Join(lines, "\n")
+	if trailingNewline {
+		updated += "\n"
+	}
+	if err := os.WriteFile(path, []byte(updated), 0644); err != nil {
+		return nil, nil, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return original, applied, nil
+}
+
+// linesMatchHunk reports whether the workspace lines start..end are what the hunk shows for them
+func linesMatchHunk(hunk *analyzer.DiffHunk, lines []string, start, end int) bool {
+	if hunk == nil {
+		return false
+	}
+
+	lineNumber := hunk.NewStart
+	matched := 0
+	for _, line := range hunk.Lines {
+		if line.Type == "removed" {
+			continue
+		}
+		if lineNumber >= start && lineNumber <= end {
+			if lines[lineNumber-1] != line.Content {
+				return false
+			}
+			matched++
+		}
+		lineNumber++
+	}
+	return matched == end-start+1
+}
+
+// autofixCommitMessage describes the applied fixes
+func autofixCommitMessage(prNumber int, applied []llm.ReviewComment) string {
+	var builder strings.Builder
+	if len(applied) == 1 {
+		builder.WriteString(fmt.Sprintf("Apply 1 suggested fix for #%d\n\n", prNumber))
+	} else {
+		builder.WriteString(fmt.Sprintf("Apply %d suggested fixes for #%d\n\n", len(applied), prNumber))
+	}
+	for _, comment := range applied {
+		summary := strings.TrimSpace(strings.SplitN(comment.Comment, "\n", 2)[0])
+		builder.WriteString(fmt.Sprintf("- %s:%d: %s\n", comment.Filename, comment.LineNumber, summary))
+	}
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+// shortSHA abbreviates a commit SHA for display
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,329 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/analyzer"
+	"github.com/GDSources/claude-code-review-agent/pkg/history"
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
+)
+
+// BudgetSettings caps LLM spend in US dollars; 0 leaves a limit off
+type BudgetSettings struct {
+	PerReview float64
+	PerDay    float64 // Per UTC day, across every review run by this process and recorded in the history
+}
+
+// Actions taken when a review's estimated cost is over budget
+const (
+	BudgetActionDowngraded     = "downgraded_model"
+	BudgetActionReducedContext = "reduced_context"
+	BudgetActionSkipped        = "skipped"
+)
+
+// BudgetReport describes the pre-flight cost check of a review
+type BudgetReport struct {
+	EstimatedUSD float64  `json:"estimated_usd"`        // Most the review could cost as sent
+	LimitUSD     float64  `json:"limit_usd"`            // What the review was allowed to spend
+	Model        string   `json:"model,omitempty"`      // Model the review was sent to
+	Actions      []string `json:"actions,omitempty"`    // Steps taken to fit the budget
+	Reason       string   `json:"reason,omitempty"`     // Why the review was skipped
+	FileCount    int      `json:"file_count,omitempty"` // Files reviewed after reducing context
+
+	reservation spendReservation // Held against the daily budget until the review's cost is known
+}
+
+// Skipped reports whether the review was skipped for being over budget
+func (b *BudgetReport) Skipped() bool {
+	return b != nil && b.hasAction(BudgetActionSkipped)
+}
+
+func (b *BudgetReport) hasAction(action string) bool {
+	for _, taken := range b.Actions {
+		if taken == action {
+			return true
+		}
+	}
+	return false
+}
+
+// HistoryQuerier is implemented by history stores that can be searched
+type HistoryQuerier interface {
+	Query(filter history.Filter) ([]history.Record, error)
+}
+
+// spendLedger tracks LLM spend for the current UTC day
+type spendLedger struct {
+	mu        sync.Mutex
+	preflight sync.Mutex // Held from a review's daily budget check until its estimate is reserved
+	day       string
+	spent     float64
+	reserved  float64 // Estimated cost of the reviews sent and not yet finished
+	loaded    bool    // Spend recorded in the history before this process started has been added
+}
+
+// spendReservation is the estimated cost a review holds against the daily budget while it runs
+type spendReservation struct {
+	day    string
+	amount float64
+}
+
+// SetBudget enables the per-review and per-day cost limits
+func (r *DefaultReviewOrchestrator) SetBudget(settings BudgetSettings) {
+	r.budget = settings
+}
+
+// spentToday returns the LLM spend of the current UTC day, including the estimates reserved by
+// running reviews, reading the history once per day
+func (r *DefaultReviewOrchestrator) spentToday(now time.Time) float64 {
+	r.spend.mu.Lock()
+	defer r.spend.mu.Unlock()
+
+	r.rollSpendDay(now)
+	if !r.spend.loaded {
+		r.spend.loaded = true
+		if querier, ok := r.historyStore.(HistoryQuerier); ok {
+			midnight := now.UTC().Truncate(24 * time.Hour)
+			records, err := querier.Query(history.Filter{Since: midnight})
+			if err != nil {
+				log.Printf("Warning: failed to read today's spend from the review history: %v", err)
+			}
+			for _, record := range records {
+				r.spend.spent += record.CostUSD
+			}
+		}
+	}
+	return r.spend.spent + r.spend.reserved
+}
+
+// reserveSpend holds an estimated cost against today's budget until settleSpend is called
+func (r *DefaultReviewOrchestrator) reserveSpend(now time.Time, amount float64) spendReservation {
+	r.spend.mu.Lock()
+	defer r.spend.mu.Unlock()
+
+	r.rollSpendDay(now)
+	r.spend.reserved += amount
+	return spendReservation{day: r.spend.day, amount: amount}
+}
+
+// settleSpend replaces a review's reservation, which may be empty, with what it actually cost
+func (r *DefaultReviewOrchestrator) settleSpend(now time.Time, reservation spendReservation, cost float64) {
+	r.spend.mu.Lock()
+	defer r.spend.mu.Unlock()
+
+	r.rollSpendDay(now)
+	if reservation.day == r.spend.day {
+		r.spend.reserved -= reservation.amount
+		if r.spend.reserved < 0 {
+			r.spend.reserved = 0
+		}
+	}
+	r.spend.spent += cost
+}
+
+// rollSpendDay resets the ledger when the UTC day changes; the caller holds the lock
+func (r *DefaultReviewOrchestrator) rollSpendDay(now time.Time) {
+	day := now.UTC().Format("2006-01-02")
+	if r.spend.day != day {
+		r.spend.day = day
+		r.spend.spent = 0
+		r.spend.reserved = 0
+		r.spend.loaded = false
+	}
+}
+
+// applyBudget checks the estimated cost of the review against the budget before anything is
+// sent. Over budget it first switches to a cheaper model, then sends less context and only the
+// riskiest files, and finally skips the review. Settings and the review data are updated in
+// place; files left out are returned. Returns a nil report when no budget applies.
+//
+// With a daily budget, reviews are checked one at a time and a review that is sent reserves its
+// estimate, so concurrent reviews cannot all pass the check and overspend together. The report
+// holds the reservation for settleSpend.
+func (r *DefaultReviewOrchestrator) applyBudget(ctx context.Context, reviewData *ReviewData, settings *ReviewSettings) (*BudgetReport, []SkippedFile) {
+	if r.budget.PerReview <= 0 && r.budget.PerDay <= 0 {
+		return nil, nil
+	}
+	if r.budget.PerDay <= 0 {
+		return r.planBudget(ctx, reviewData, settings)
+	}
+
+	r.pullRequestInfo(ctx, reviewData) // Fetched before other reviews have to wait
+	r.spend.preflight.Lock()
+	defer r.spend.preflight.Unlock()
+
+	report, skipped := r.planBudget(ctx, reviewData, settings)
+	if report != nil && !report.Skipped() {
+		report.reservation = r.reserveSpend(time.Now(), report.EstimatedUSD)
+	}
+	return report, skipped
+}
+
+// reserveSecondOpinion checks the estimated cost of a second opinion against what is left of the
+// per-review limit and of the daily budget, and adds it to the review's reservation. spent is
+// what the first pass cost.
+func (r *DefaultReviewOrchestrator) reserveSecondOpinion(ctx context.Context, reviewData *ReviewData, settings ReviewSettings, spent float64, reservation *spendReservation) error {
+	estimator, ok := r.llmClient.(llm.CostEstimator)
+	if !ok || (settings.MaxCostUSD <= 0 && r.budget.PerDay <= 0) {
+		return nil
+	}
+	estimate := r.estimateReviewCost(ctx, estimator, reviewData, settings, settings.Model, reviewData.ContextualDiff.FilesWithContext)
+	if settings.MaxCostUSD > 0 && estimate > settings.MaxCostUSD {
+		return fmt.Errorf("estimated cost of %s is over the %s left of the review budget",
+			formatUSD(estimate), formatUSD(settings.MaxCostUSD))
+	}
+	if r.budget.PerDay <= 0 || reservation == nil {
+		return nil
+	}
+
+	r.spend.preflight.Lock()
+	defer r.spend.preflight.Unlock()
+
+	// What the first pass did not spend of its reservation is still this review's to use
+	remaining := r.budget.PerDay - r.spentToday(time.Now())
+	if unused := reservation.amount - spent; unused > 0 {
+		remaining += unused
+	}
+	if estimate > remaining {
+		return fmt.Errorf("estimated cost of %s is over the %s left of the daily budget",
+			formatUSD(estimate), formatUSD(remaining))
+	}
+	extra := r.reserveSpend(time.Now(), estimate)
+	if extra.day == reservation.day {
+		reservation.amount += extra.amount
+	} else {
+		*reservation = extra // The first pass's reservation was dropped with the previous day
+	}
+	return nil
+}
+
+// planBudget fits the review to the per-review limit and what remains of the daily budget
+func (r *DefaultReviewOrchestrator) planBudget(ctx context.Context, reviewData *ReviewData, settings *ReviewSettings) (*BudgetReport, []SkippedFile) {
+	estimator, ok := r.llmClient.(llm.CostEstimator)
+	if !ok || reviewData.ContextualDiff == nil {
+		return nil, nil
+	}
+
+	model := settings.Model
+	if model == "" {
+		model = r.llmClient.GetModelInfo().Name
+	}
+	report := &BudgetReport{Model: model, LimitUSD: r.budget.PerReview}
+
+	if r.budget.PerDay > 0 {
+		remaining := r.budget.PerDay - r.spentToday(time.Now())
+		if remaining <= 0 {
+			report.LimitUSD = 0
+			report.Actions = []string{BudgetActionSkipped}
+			report.Reason = fmt.Sprintf("the daily budget of %s is spent", formatUSD(r.budget.PerDay))
+			return report, nil
+		}
+		if report.LimitUSD <= 0 || remaining < report.LimitUSD {
+			report.LimitUSD = remaining
+		}
+	}
+	settings.MaxCostUSD = report.LimitUSD
+
+	files := reviewData.ContextualDiff.FilesWithContext
+	estimate := r.estimateReviewCost(ctx, estimator, reviewData, *settings, model, files)
+	report.EstimatedUSD = estimate
+	if estimate <= report.LimitUSD {
+		return report, nil
+	}
+	initialEstimate := estimate
+
+	// Try cheaper models, most capable first
+	cheaper := llm.CheaperModels(model)
+	for _, candidate := range cheaper {
+		cost := r.estimateReviewCost(ctx, estimator, reviewData, *settings, candidate, files)
+		if cost <= report.LimitUSD {
+			log.Printf("Estimated review cost %s is over the %s budget, switching from %s to %s",
+				formatUSD(estimate), formatUSD(report.LimitUSD), model, candidate)
+			settings.Model = candidate
+			report.Model = candidate
+			report.EstimatedUSD = cost
+			report.Actions = append(report.Actions, BudgetActionDowngraded)
+			return report, nil
+		}
+	}
+	if len(cheaper) > 0 {
+		model = cheaper[len(cheaper)-1]
+		report.Actions = append(report.Actions, BudgetActionDowngraded)
+	}
+
+	// Send one line of context and keep the riskiest files that fit
+	files = reducedContextFiles(files)
+	kept := sort.Search(len(files), func(n int) bool {
+		return r.estimateReviewCost(ctx, estimator, reviewData, *settings, model, files[:n+1]) > report.LimitUSD
+	})
+	if kept == 0 {
+		report.Model = model
+		if len(files) > 0 {
+			report.EstimatedUSD = r.estimateReviewCost(ctx, estimator, reviewData, *settings, model, files[:1])
+		}
+		report.Actions = append(report.Actions, BudgetActionSkipped)
+		report.Reason = fmt.Sprintf("the estimated cost of %s is over the remaining budget of %s",
+			formatUSD(initialEstimate), formatUSD(report.LimitUSD))
+		return report, nil
+	}
+
+	var skipped []SkippedFile
+	for _, file := range files[kept:] {
+		skipped = append(skipped, SkippedFile{Filename: file.Filename, Reason: SkipReasonCostBudget})
+	}
+	log.Printf("Estimated review cost %s is over the %s budget, reviewing %d of %d files with %s and less context",
+		formatUSD(initialEstimate), formatUSD(report.LimitUSD), kept, len(files), model)
+
+	reviewData.ContextualDiff.FilesWithContext = files[:kept]
+	settings.Model = model
+	report.Model = model
+	report.FileCount = kept
+	report.EstimatedUSD = r.estimateReviewCost(ctx, estimator, reviewData, *settings, model, files[:kept])
+	report.Actions = append(report.Actions, BudgetActionReducedContext)
+	return report, skipped
+}
+
+// reducedContextFiles trims the files' context blocks to a single line of context, riskiest first.
+// Enclosing functions, callees and impact annotations already added to the blocks are kept.
+func reducedContextFiles(files []analyzer.FileWithContext) []analyzer.FileWithContext {
+	ordered, _ := analyzer.TriageFiles(files, 0)
+	result := make([]analyzer.FileWithContext, 0, len(ordered))
+	for _, file := range ordered {
+		blocks := make([]analyzer.ContextBlock, len(file.ContextBlocks))
+		for i, block := range file.ContextBlocks {
+			blocks[i] = block.TrimContext(1)
+		}
+		file.ContextBlocks = blocks
+		result = append(result, file)
+	}
+	return result
+}
+
+// estimateReviewCost returns the most a review of the files could cost on the model, summed over every review pass
+func (r *DefaultReviewOrchestrator) estimateReviewCost(ctx context.Context, estimator llm.CostEstimator, reviewData *ReviewData, settings ReviewSettings, model string, files []analyzer.FileWithContext) float64 {
+	r.pullRequestInfo(ctx, reviewData) // Fetch once before the review data is copied
+
+	diff := *reviewData.ContextualDiff
+	diff.FilesWithContext = files
+	estimateData := *reviewData
+	estimateData.ContextualDiff = &diff
+
+	var cost float64
+	for _, request := range r.reviewRequests(ctx, &estimateData, settings) {
+		cost += estimator.EstimateTokens(request).Cost(model)
+	}
+	return cost
+}
+
+// formatUSD formats a dollar amount for logs and comments
+func formatUSD(amount float64) string {
+	if amount > 0 && amount < 0.01 {
+		return fmt.Sprintf("$%.4f", amount)
+	}
+	return fmt.Sprintf("$%.2f", amount)
+}
@@ -0,0 +1,290 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/analyzer"
+	"github.com/GDSources/claude-code-review-agent/pkg/history"
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
+)
+
+// mockEstimatingLLMClient sizes every request at 1,000 tokens plus 10,000 per file
+type mockEstimatingLLMClient struct {
+	mockLLMClientWithComments
+}
+
+func (m *mockEstimatingLLMClient) GetModelInfo() llm.ModelInfo {
+	return llm.ModelInfo{Name: llm.ClaudeSonnet4}
+}
+
+func (m *mockEstimatingLLMClient) EstimateTokens(request *llm.ReviewRequest) llm.TokenEstimate {
+	return llm.TokenEstimate{
+		InputTokens:     1000 + 10000*len(request.ContextualDiff.FilesWithContext),
+		MaxOutputTokens: 4000,
+		Requests:        1,
+	}
+}
+
+// mockQueryableHistoryStore is a history store that can report earlier runs
+type mockQueryableHistoryStore struct {
+	mockHistoryStore
+}
+
+func (m *mockQueryableHistoryStore) Query(filter history.Filter) ([]history.Record, error) {
+	var records []history.Record
+	for _, record := range m.records {
+		if !record.StartedAt.Before(filter.Since) {
+			records = append(records, *record)
+		}
+	}
+	return records, nil
+}
+
+func budgetReviewData(fileCount int) *ReviewData {
+	files := make([]analyzer.FileWithContext, fileCount)
+	for i := range files {
+		files[i] = analyzer.FileWithContext{FileDiff: analyzer.FileDiff{Filename: fmt.Sprintf("file%d.go", i)}}
+	}
+	return &ReviewData{
+		Event:          createTestPullRequestEvent(),
+		ContextualDiff: &analyzer.ContextualDiff{FilesWithContext: files},
+	}
+}
+
+func TestDefaultReviewOrchestrator_ApplyBudget(t *testing.T) {
+	// Three files cost $0.153 on Sonnet and $0.0408 on Haiku; on Haiku two files cost $0.0328
+	tests := []struct {
+		name            string
+		budget          BudgetSettings
+		spentToday      float64
+		expectedModel   string
+		expectedActions []string
+		expectedFiles   int
+		expectedReason  string
+	}{
+		{
+			name:          "within budget",
+			budget:        BudgetSettings{PerReview: 0.20},
+			expectedModel: "",
+			expectedFiles: 3,
+		},
+		{
+			name:            "downgrades to a cheaper model",
+			budget:          BudgetSettings{PerReview: 0.05},
+			expectedModel:   llm.ClaudeHaiku35,
+			expectedActions: []string{BudgetActionDowngraded},
+			expectedFiles:   3,
+		},
+		{
+			name:            "reduces the files reviewed",
+			budget:          BudgetSettings{PerReview: 0.035},
+			expectedModel:   llm.ClaudeHaiku35,
+			expectedActions: []string{BudgetActionDowngraded, BudgetActionReducedContext},
+			expectedFiles:   2,
+		},
+		{
+			name:            "skips when not even one file fits",
+			budget:          BudgetSettings{PerReview: 0.02},
+			expectedActions: []string{BudgetActionDowngraded, BudgetActionSkipped},
+			expectedFiles:   3,
+			expectedReason:  "the estimated cost of $0.15 is over the remaining budget of $0.02",
+		},
+		{
+			name:            "remaining daily budget lowers the limit",
+			budget:          BudgetSettings{PerReview: 0.20, PerDay: 1},
+			spentToday:      0.95,
+			expectedModel:   llm.ClaudeHaiku35,
+			expectedActions: []string{BudgetActionDowngraded},
+			expectedFiles:   3,
+		},
+		{
+			name:            "skips when the daily budget is spent",
+			budget:          BudgetSettings{PerDay: 1},
+			spentToday:      1.2,
+			expectedActions: []string{BudgetActionSkipped},
+			expectedFiles:   3,
+			expectedReason:  "the daily budget of $1.00 is spent",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockQueryableHistoryStore{}
+			store.records = []*history.Record{
+				{StartedAt: time.Now().Add(-48 * time.Hour), CostUSD: 5},
+				{StartedAt: time.Now(), CostUSD: tt.spentToday},
+			}
+			orchestrator := &DefaultReviewOrchestrator{llmClient: &mockEstimatingLLMClient{}, historyStore: store}
+			orchestrator.SetBudget(tt.budget)
+
+			reviewData := budgetReviewData(3)
+			settings := DefaultReviewSettings()
+			report, skipped := orchestrator.applyBudget(context.Background(), reviewData, &settings)
+
+			if report == nil {
+				t.Fatal("expected a budget report")
+			}
+			if !reflect.DeepEqual(report.Actions, tt.expectedActions) {
+				t.Errorf("expected actions %v, got %v", tt.expectedActions, report.Actions)
+			}
+			if settings.Model != tt.expectedModel {
+				t.Errorf("expected model %q, got %q", tt.expectedModel, settings.Model)
+			}
+			if files := len(reviewData.ContextualDiff.FilesWithContext); files != tt.expectedFiles {
+				t.Errorf("expected %d files to review, got %d", tt.expectedFiles, files)
+			}
+			if len(skipped) != 3-tt.expectedFiles {
+				t.Errorf("expected %d files over the cost budget, got %v", 3-tt.expectedFiles, skipped)
+			}
+			for _, file := range skipped {
+				if file.Reason != SkipReasonCostBudget {
+					t.Errorf("unexpected skip reason %q", file.Reason)
+				}
+			}
+			if report.Reason != tt.expectedReason {
+				t.Errorf("expected reason %q, got %q", tt.expectedReason, report.Reason)
+			}
+			if !report.Skipped() && report.EstimatedUSD > report.LimitUSD {
+				t.Errorf("expected the estimate $%.4f to fit the limit $%.4f", report.EstimatedUSD, report.LimitUSD)
+			}
+		})
+	}
+}
+
+func TestDefaultReviewOrchestrator_ApplyBudget_Disabled(t *testing.T) {
+	orchestrator := &DefaultReviewOrchestrator{llmClient: &mockEstimatingLLMClient{}}
+	settings := DefaultReviewSettings()
+
+	if report, _ := orchestrator.applyBudget(context.Background(), budgetReviewData(3), &settings); report != nil {
+		t.Errorf("expected no budget report without limits, got %+v", report)
+	}
+}
+
+func TestDefaultReviewOrchestrator_ApplyBudget_ReservesDailySpend(t *testing.T) {
+	orchestrator := &DefaultReviewOrchestrator{llmClient: &mockEstimatingLLMClient{}, historyStore: &mockQueryableHistoryStore{}}
+	orchestrator.SetBudget(BudgetSettings{PerDay: 0.20})
+
+	settings := DefaultReviewSettings()
+	first, _ := orchestrator.applyBudget(context.Background(), budgetReviewData(3), &settings)
+	if first.Skipped() || len(first.Actions) != 0 {
+		t.Fatalf("expected the first review to fit the daily budget, got %+v", first)
+	}
+
+	// The first review is still running, so its estimate counts against the second
+	settings = DefaultReviewSettings()
+	second, _ := orchestrator.applyBudget(context.Background(), budgetReviewData(3), &settings)
+	if settings.Model != llm.ClaudeHaiku35 {
+		t.Errorf("expected the second review to be downgraded, got model %q and actions %v", settings.Model, second.Actions)
+	}
+
+	now := time.Now()
+	orchestrator.settleSpend(now, first.reservation, 0.1)
+	orchestrator.settleSpend(now, second.reservation, 0)
+	if spent := orchestrator.spentToday(now); spent < 0.0999 || spent > 0.1001 {
+		t.Errorf("expected $0.10 spent once both reviews settled, got $%.4f", spent)
+	}
+}
+
+func TestDefaultReviewOrchestrator_ReserveSecondOpinion(t *testing.T) {
+	// A second opinion on one file costs $0.093 on Sonnet; the first pass reserved $0.153 and cost $0.15
+	tests := []struct {
+		name        string
+		maxCostUSD  float64
+		perDay      float64
+		expectError bool
+		expectHeld  float64
+	}{
+		{name: "no budget", expectHeld: 0.153},
+		{name: "within the review limit", maxCostUSD: 0.10, expectHeld: 0.153},
+		{name: "over the review limit", maxCostUSD: 0.05, expectError: true, expectHeld: 0.153},
+		{name: "within the daily budget", perDay: 0.30, expectHeld: 0.246},
+		{name: "over the daily budget", perDay: 0.20, expectError: true, expectHeld: 0.153},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orchestrator := &DefaultReviewOrchestrator{llmClient: &mockEstimatingLLMClient{}, historyStore: &mockQueryableHistoryStore{}}
+			orchestrator.SetBudget(BudgetSettings{PerDay: tt.perDay})
+			reservation := orchestrator.reserveSpend(time.Now(), 0.153)
+
+			settings := DefaultReviewSettings()
+			settings.Model = llm.ClaudeSonnet4
+			settings.MaxCostUSD = tt.maxCostUSD
+			err := orchestrator.reserveSecondOpinion(context.Background(), budgetReviewData(1), settings, 0.15, &reservation)
+			if tt.expectError != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.expectError, err)
+			}
+			if reservation.amount < tt.expectHeld-0.0001 || reservation.amount > tt.expectHeld+0.0001 {
+				t.Errorf("expected $%.4f reserved, got $%.4f", tt.expectHeld, reservation.amount)
+			}
+			if spent := orchestrator.spentToday(time.Now()); spent < tt.expectHeld-0.0001 || spent > tt.expectHeld+0.0001 {
+				t.Errorf("expected $%.4f held against the day, got $%.4f", tt.expectHeld, spent)
+			}
+		})
+	}
+}
+
+func TestDefaultReviewOrchestrator_SpentToday(t *testing.T) {
+	store := &mockQueryableHistoryStore{}
+	store.records = []*history.Record{
+		{StartedAt: time.Now().Add(-48 * time.Hour), CostUSD: 5},
+		{StartedAt: time.Now(), CostUSD: 0.25},
+	}
+	orchestrator := &DefaultReviewOrchestrator{historyStore: store}
+	now := time.Now()
+
+	if spent := orchestrator.spentToday(now); spent != 0.25 {
+		t.Errorf("expected $0.25 spent from the history, got $%.2f", spent)
+	}
+
+	// Spend is tracked in memory once the history has been read
+	orchestrator.settleSpend(now, spendReservation{}, 0.5)
+	store.records = append(store.records, &history.Record{StartedAt: now, CostUSD: 0.5})
+	if spent := orchestrator.spentToday(now); spent != 0.75 {
+		t.Errorf("expected $0.75 spent, got $%.2f", spent)
+	}
+
+	if spent := orchestrator.spentToday(now.Add(24 * time.Hour)); spent != 0 {
+		t.Errorf("expected spend to reset the next day, got $%.2f", spent)
+	}
+}
+
+func TestGenerateProgressComment_Budget(t *testing.T) {
+	tests := []struct {
+		name     string
+		budget   *BudgetReport
+		expected string
+	}{
+		{
+			name:     "downgraded",
+			budget:   &BudgetReport{LimitUSD: 0.5, Model: llm.ClaudeHaiku35, Actions: []string{BudgetActionDowngraded}},
+			expected: "💰 To stay within the $0.50 cost budget, this review used `claude-3-5-haiku-20241022`.",
+		},
+		{
+			name: "downgraded and reduced",
+			budget: &BudgetReport{LimitUSD: 0.5, Model: llm.ClaudeHaiku35, FileCount: 1,
+				Actions: []string{BudgetActionDowngraded, BudgetActionReducedContext}},
+			expected: "this review used `claude-3-5-haiku-20241022` and reviewed the 1 riskiest file with less context.",
+		},
+		{
+			name:     "skipped",
+			budget:   &BudgetReport{Actions: []string{BudgetActionSkipped}, Reason: "the daily budget of $1.00 is spent"},
+			expected: "💰 The review was skipped because the daily budget of $1.00 is spent.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			progress := &ReviewProgress{Stage: "completed", StartTime: time.Now(), LastUpdated: time.Now(), Budget: tt.budget}
+
+			if comment := GenerateProgressComment(progress); !strings.Contains(comment, tt.expected) {
+				t.Errorf("expected comment to contain %q, got:\n%s", tt.expected, comment)
+			}
+		})
+	}
+}
@@ -135,8 +135,8 @@ func TestCommentPostingWorkflow_EndToEnd(t *testing.T) {
 	if firstCall.prNumber != 123 {
 		t.Errorf("expected PR number 123, got %d", firstCall.prNumber)
 	}
-	if firstCall.comment.Body != "Consider adding documentation" {
-		t.Errorf("expected first comment body 'Consider adding documentation', got '%s'", firstCall.comment.Body)
+	if firstCall.comment.Body != withReviewCommentMarker("Consider adding documentation") {
+		t.Errorf("expected first comment body 'Consider adding documentation' with marker, got '%s'", firstCall.comment.Body)
 	}
 	if firstCall.comment.Path != "main.go" {
 		t.Errorf("expected first comment path 'main.go', got '%s'", firstCall.comment.Path)
@@ -150,8 +150,8 @@ func TestCommentPostingWorkflow_EndToEnd(t *testing.T) {
 
 	// Verify second comment
 	secondCall := mockGitHub.createCommentCalls[1]
-	if secondCall.comment.Body != "Potential memory leak here" {
-		t.Errorf("expected second comment body 'Potential memory leak here', got '%s'", secondCall.comment.Body)
+	if secondCall.comment.Body != withReviewCommentMarker("Potential memory leak here") {
+		t.Errorf("expected second comment body 'Potential memory leak here' with marker, got '%s'", secondCall.comment.Body)
 	}
 	if secondCall.comment.Line != 20 {
 		t.Errorf("expected second comment line 20, got %d", secondCall.comment.Line)
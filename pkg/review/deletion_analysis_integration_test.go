@@ -3,6 +3,8 @@ package review
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/GDSources/claude-code-review-agent/pkg/analyzer"
@@ -514,3 +516,78 @@ func (m *integrationMockGitHubCommentClient) UpdateIssueComment(ctx context.Cont
 func (m *integrationMockGitHubCommentClient) FindProgressComment(ctx context.Context, owner, repo string, issueNumber int) (*github.IssueComment, error) {
 	return nil, nil // No existing progress comment found
 }
+
+func TestDefaultReviewOrchestrator_AnnotateDeletedDeclarations(t *testing.T) {
+	base := "package store\n\ntype Store struct {\n\tpath string\n}\n\nfunc (s *Store) Close() error {\n\treturn nil\n}\n\nfunc (s *Store) Path() string { return s.path }\n"
+	head := "package store\n\ntype Store struct {\n\tpath string\n}\n\nfunc (s *Store) Path() string { return s.path }\n"
+
+	repoPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoPath, "store.go"), []byte(head), 0644); err != nil {
+		t.Fatalf("failed to write head revision: %v", err)
+	}
+	workspace := &Workspace{Path: repoPath}
+
+	orchestrator := &DefaultReviewOrchestrator{}
+	orchestrator.SetBaseFileReader(&mockRepoConfigReader{files: map[string]string{
+		"base123:store.go": base,
+		"base123:gone.go":  "package store\n\nconst Limit = 10\n",
+	}})
+
+	parsedDiff := &analyzer.ParsedDiff{Files: []analyzer.FileDiff{
+		{
+			Filename: "store.go", Status: "modified", Language: "go",
+			Hunks: []analyzer.DiffHunk{{Lines: []analyzer.DiffLine{
+				{Type: "removed", Content: "func (s *Store) Close() error {", OldLineNo: 7},
+				{Type: "removed", Content: "\treturn nil", OldLineNo: 8},
+				{Type: "removed", Content: "}", OldLineNo: 9},
+				{Type: "removed", Content: "", OldLineNo: 10},
+			}}},
+		},
+		{
+			Filename: "gone.go", Status: "deleted", Language: "go",
+			Hunks: []analyzer.DiffHunk{{Lines: []analyzer.DiffLine{
+				{Type: "removed", Content: "package store", OldLineNo: 1},
+				{Type: "removed", Content: "", OldLineNo: 2},
+				{Type: "removed", Content: "const Limit = 10", OldLineNo: 3},
+			}}},
+		},
+		{
+			// The copy drops Close, but store.go still declares it
+			Filename: "store_copy.go", Status: "copied", OldFilename: "store.go", Language: "go",
+			Hunks: []analyzer.DiffHunk{{Lines: []analyzer.DiffLine{
+				{Type: "removed", Content: "func (s *Store) Close() error {", OldLineNo: 7},
+			}}},
+		},
+	}}
+	deletedContent := extractDeletedContent(parsedDiff)
+	if len(deletedContent) != 2 {
+		t.Fatalf("expected deletions in store.go and gone.go only, got %d", len(deletedContent))
+	}
+
+	event := &PullRequestEvent{PullRequest: PullRequest{Base: Branch{SHA: "base123"}}}
+	orchestrator.annotateDeletedDeclarations(context.Background(), event, workspace, repoPath, parsedDiff, deletedContent)
+
+	expected := map[string][]string{"store.go": {"Store.Close"}, "gone.go": {"Limit"}}
+	for _, deleted := range deletedContent {
+		var names []string
+		for _, entity := range deleted.Entities {
+			names = append(names, entity.QualifiedName())
+		}
+		if fmt.Sprint(names) != fmt.Sprint(expected[deleted.File]) {
+			t.Errorf("expected %s to remove %v, got %v", deleted.File, expected[deleted.File], names)
+		}
+	}
+
+	// Without a base file reader, the base revision is read through the workspace's content reader
+	contents := &mockContentReader{files: map[string]string{"base123:store.go": base}}
+	workspace.contents = contents
+	event.Repository = Repository{Name: "repo", Owner: User{Login: "owner"}}
+	deletedContent = extractDeletedContent(parsedDiff)
+	(&DefaultReviewOrchestrator{}).annotateDeletedDeclarations(context.Background(), event, workspace, repoPath, parsedDiff, deletedContent)
+	if len(deletedContent[0].Entities) != 1 || deletedContent[0].Entities[0].QualifiedName() != "Store.Close" {
+		t.Errorf("expected Store.Close from the content reader, got %+v", deletedContent[0].Entities)
+	}
+	if len(contents.reads) == 0 || contents.reads[0] != "owner/repo:store.go@base123" {
+		t.Errorf("expected store.go to be read at the base commit, got %v", contents.reads)
+	}
+}
@@ -30,6 +30,23 @@ func (g *GitHubClonerAdapter) CheckoutBranch(ctx context.Context, repoPath, bran
 	return g.client.CheckoutBranch(ctx, repoPath, branch)
 }
 
+// CheckoutPullRequest checks out the pull request head commit, or the head branch when the client
+// cannot check out pull requests
+func (g *GitHubClonerAdapter) CheckoutPullRequest(ctx context.Context, repoPath string, prNumber int, branch, headSHA string) error {
+	if checkout, ok := g.client.(PullRequestCheckout); ok {
+		return checkout.CheckoutPullRequest(ctx, repoPath, prNumber, branch, headSHA)
+	}
+	return g.client.CheckoutBranch(ctx, repoPath, branch)
+}
+
+// AuthenticatedCloneURL returns the repository URL with credentials, or "" when the client cannot provide it
+func (g *GitHubClonerAdapter) AuthenticatedCloneURL(owner, repo string) string {
+	if provider, ok := g.client.(RemoteURLProvider); ok {
+		return provider.AuthenticatedCloneURL(owner, repo)
+	}
+	return ""
+}
+
 func NewGitHubClonerAdapterFromClient(client *github.Client) *GitHubClonerAdapter {
 	return &GitHubClonerAdapter{
 		client: client,
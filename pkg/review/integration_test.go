@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -47,20 +48,33 @@ func TestReviewOrchestrator_Integration(t *testing.T) {
 		name          string
 		clonerFail    bool
 		clonerError   error
+		eager         bool
 		expectError   bool
+		expectClone   bool
 		errorContains string
 	}{
 		{
 			name:        "successful end-to-end review flow",
+			eager:       true,
 			expectError: false,
+			expectClone: true,
 		},
 		{
 			name:          "clone failure propagates through layers",
 			clonerFail:    true,
 			clonerError:   fmt.Errorf("repository access denied"),
+			eager:         true,
 			expectError:   true,
+			expectClone:   true,
 			errorContains: "failed to create workspace",
 		},
+		{
+			name:        "lazy workspace is not cloned when no stage needs files",
+			clonerFail:  true,
+			clonerError: fmt.Errorf("repository access denied"),
+			expectError: false,
+			expectClone: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -75,7 +89,10 @@ func TestReviewOrchestrator_Integration(t *testing.T) {
 			}
 
 			// Create workspace manager with real FS and mock cloner
-			workspaceManager := NewDefaultWorkspaceManager(mockCloner, fsManager)
+			var workspaceManager WorkspaceManager = NewDefaultWorkspaceManager(mockCloner, fsManager)
+			if tt.eager {
+				workspaceManager = eagerWorkspaceManager{workspaceManager}
+			}
 
 			// Create orchestrator
 			orchestrator := NewDefaultReviewOrchestratorLegacy(workspaceManager)
@@ -109,6 +126,10 @@ func TestReviewOrchestrator_Integration(t *testing.T) {
 				t.Errorf("expected error to contain '%s', got: %v", tt.errorContains, err)
 			}
 
+			if cloned := len(mockCloner.clonedRepos) > 0; cloned != tt.expectClone {
+				t.Errorf("expected clone %v, got %v", tt.expectClone, cloned)
+			}
+
 			// Verify cloner was called with correct parameters
 			if !tt.clonerFail && len(mockCloner.clonedRepos) == 1 {
 				expectedRepo := fmt.Sprintf("%s/%s", event.Repository.Owner.Login, event.Repository.Name)
@@ -125,7 +146,7 @@ func TestReviewOrchestrator_Integration(t *testing.T) {
 				t.Logf("Warning: could not read temp dir: %v", err)
 			} else {
 				for _, entry := range entries {
-					if entry.IsDir() && entry.Name()[:min(len(entry.Name()), 13)] == "review-agent-" {
+					if entry.IsDir() && strings.HasPrefix(entry.Name(), WorkspacePrefix) {
 						t.Errorf("found leftover temp directory: %s", entry.Name())
 					}
 				}
@@ -134,6 +155,11 @@ func TestReviewOrchestrator_Integration(t *testing.T) {
 	}
 }
 
+// eagerWorkspaceManager hides OpenWorkspace so the orchestrator clones up front
+type eagerWorkspaceManager struct {
+	WorkspaceManager
+}
+
 type mockClonerWithFileCreation struct {
 	*mockGitHubCloner
 }
@@ -231,7 +257,7 @@ func TestReviewOrchestrator_ErrorHandling(t *testing.T) {
 			error:      fmt.Errorf("git clone failed: repository not found"),
 		}
 		fsManager := NewDefaultFileSystemManager()
-		workspaceManager := NewDefaultWorkspaceManager(mockCloner, fsManager)
+		workspaceManager := eagerWorkspaceManager{NewDefaultWorkspaceManager(mockCloner, fsManager)}
 		orchestrator := NewDefaultReviewOrchestratorLegacy(workspaceManager)
 
 		event := createTestEvent()
@@ -251,7 +277,7 @@ func TestReviewOrchestrator_ErrorHandling(t *testing.T) {
 		tempDir := os.TempDir()
 		entries, _ := os.ReadDir(tempDir)
 		for _, entry := range entries {
-			if entry.IsDir() && len(entry.Name()) >= 13 && entry.Name()[:13] == "review-agent-" {
+			if entry.IsDir() && strings.HasPrefix(entry.Name(), WorkspacePrefix) {
 				t.Errorf("found leftover temp directory after error: %s", entry.Name())
 			}
 		}
@@ -0,0 +1,97 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/github"
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
+)
+
+// Limits on the author-provided text sent with a review, to keep the prompt focused on the code
+const (
+	maxDescriptionLength   = 4000
+	maxCommitMessages      = 50
+	maxCommitMessageLength = 500
+	maxLinkedIssues        = 5
+	maxIssueBodyLength     = 2000
+)
+
+// PullRequestContextClient fetches the commits and linked issues that describe a pull request's intent
+type PullRequestContextClient interface {
+	GetPullRequestCommits(ctx context.Context, owner, repo string, prNumber int) ([]github.PullRequestCommit, error)
+	GetIssue(ctx context.Context, owner, repo string, issueNumber int) (*github.Issue, error)
+}
+
+// buildPullRequestInfo collects the pull request details sent to the LLM: title, description,
+// labels, draft flag, commit messages and the issues it says it fixes. Failures to fetch
+// commits or issues are logged and leave those parts out.
+func (r *DefaultReviewOrchestrator) buildPullRequestInfo(ctx context.Context, event *PullRequestEvent) llm.PullRequestInfo {
+	pr := event.PullRequest
+	info := llm.PullRequestInfo{
+		Number:      event.Number,
+		Title:       pr.Title,
+		Author:      pr.User.Login,
+		Description: truncateText(strings.TrimSpace(pr.Body), maxDescriptionLength),
+		BaseBranch:  pr.Base.Ref,
+		HeadBranch:  pr.Head.Ref,
+		Draft:       pr.Draft,
+	}
+	for _, label := range pr.Labels {
+		info.Labels = append(info.Labels, label.Name)
+	}
+
+	contextClient, ok := r.githubClient.(PullRequestContextClient)
+	if !ok {
+		return info
+	}
+
+	owner, repo := event.Repository.Owner.Login, event.Repository.Name
+
+	commits, err := contextClient.GetPullRequestCommits(ctx, owner, repo, event.Number)
+	if err != nil {
+		log.Printf("Warning: failed to fetch commits for PR #%d: %v", event.Number, err)
+	}
+	if len(commits) > maxCommitMessages {
+		commits = commits[len(commits)-maxCommitMessages:] // The latest commits describe the final state best
+	}
+	for _, commit := range commits {
+		info.CommitMessages = append(info.CommitMessages, truncateText(strings.TrimSpace(commit.Commit.Message), maxCommitMessageLength))
+	}
+
+	// Issues are only linked from the text the model is shown
+	refs := github.ParseIssueReferences(owner, repo, append([]string{pr.Body}, info.CommitMessages...)...)
+	if len(refs) > maxLinkedIssues {
+		refs = refs[:maxLinkedIssues]
+	}
+	for _, ref := range refs {
+		issue, err := contextClient.GetIssue(ctx, ref.Owner, ref.Repo, ref.Number)
+		if err != nil {
+			log.Printf("Warning: failed to fetch linked issue %s/%s#%d: %v", ref.Owner, ref.Repo, ref.Number, err)
+			continue
+		}
+
+		reference := fmt.Sprintf("#%d", ref.Number)
+		if !strings.EqualFold(ref.Owner, owner) || !strings.EqualFold(ref.Repo, repo) {
+			reference = fmt.Sprintf("%s/%s#%d", ref.Owner, ref.Repo, ref.Number)
+		}
+		info.LinkedIssues = append(info.LinkedIssues, llm.LinkedIssue{
+			Reference: reference,
+			Title:     issue.Title,
+			Body:      truncateText(strings.TrimSpace(issue.Body), maxIssueBodyLength),
+		})
+	}
+
+	return info
+}
+
+// truncateText caps text at maxLength characters, marking the cut
+func truncateText(text string, maxLength int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLength {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:maxLength])) + "\n[truncated]"
+}
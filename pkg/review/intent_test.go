@@ -0,0 +1,149 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/analyzer"
+	"github.com/GDSources/claude-code-review-agent/pkg/github"
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
+)
+
+// mockIntentClient serves commits and issues in addition to the comment API
+type mockIntentClient struct {
+	mockGitHubCommentClient
+	commits       []github.PullRequestCommit
+	commitsErr    error
+	issues        map[string]*github.Issue
+	fetchedIssues []string
+}
+
+func (m *mockIntentClient) GetPullRequestCommits(ctx context.Context, owner, repo string, prNumber int) ([]github.PullRequestCommit, error) {
+	return m.commits, m.commitsErr
+}
+
+func (m *mockIntentClient) GetIssue(ctx context.Context, owner, repo string, issueNumber int) (*github.Issue, error) {
+	key := fmt.Sprintf("%s/%s#%d", owner, repo, issueNumber)
+	m.fetchedIssues = append(m.fetchedIssues, key)
+	if issue, ok := m.issues[key]; ok {
+		return issue, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func commitWithMessage(message string) github.PullRequestCommit {
+	var commit github.PullRequestCommit
+	commit.Commit.Message = message
+	return commit
+}
+
+func TestBuildPullRequestInfo(t *testing.T) {
+	event := createTestPullRequestEvent()
+	event.PullRequest.Body = "Stops the login redirect loop.\n\nFixes #12"
+	event.PullRequest.Draft = true
+	event.PullRequest.Labels = []Label{{Name: "bug"}, {Name: "auth"}}
+
+	client := &mockIntentClient{
+		commits: []github.PullRequestCommit{
+			commitWithMessage("Guard redirect target"),
+			commitWithMessage("Add test\n\nCloses other/lib#3, fixes #99"),
+		},
+		issues: map[string]*github.Issue{
+			"company/test-repo#12": {Number: 12, Title: "Login loops", Body: "After SSO the page redirects forever"},
+			"other/lib#3":          {Number: 3, Title: "Upstream redirect bug"},
+		},
+	}
+	orchestrator := &DefaultReviewOrchestrator{githubClient: client}
+
+	info := orchestrator.buildPullRequestInfo(context.Background(), event)
+
+	if info.Title != "Add amazing feature" || info.Author != "developer" || info.Description != event.PullRequest.Body {
+		t.Errorf("unexpected basic info: %+v", info)
+	}
+	if !info.Draft || !reflect.DeepEqual(info.Labels, []string{"bug", "auth"}) {
+		t.Errorf("expected draft flag and labels, got draft=%v labels=%v", info.Draft, info.Labels)
+	}
+	if !reflect.DeepEqual(info.CommitMessages, []string{"Guard redirect target", "Add test\n\nCloses other/lib#3, fixes #99"}) {
+		t.Errorf("unexpected commit messages: %v", info.CommitMessages)
+	}
+
+	// Issue #99 does not exist and is left out
+	if !reflect.DeepEqual(client.fetchedIssues, []string{"company/test-repo#12", "other/lib#3", "company/test-repo#99"}) {
+		t.Errorf("unexpected issues fetched: %v", client.fetchedIssues)
+	}
+	expected := []llm.LinkedIssue{
+		{Reference: "#12", Title: "Login loops", Body: "After SSO the page redirects forever"},
+		{Reference: "other/lib#3", Title: "Upstream redirect bug"},
+	}
+	if !reflect.DeepEqual(info.LinkedIssues, expected) {
+		t.Errorf("unexpected linked issues: %+v", info.LinkedIssues)
+	}
+}
+
+func TestBuildPullRequestInfo_Limits(t *testing.T) {
+	event := createTestPullRequestEvent()
+	event.PullRequest.Body = strings.Repeat("d", maxDescriptionLength+10)
+
+	var commits []github.PullRequestCommit
+	for i := 0; i < maxCommitMessages+5; i++ {
+		commits = append(commits, commitWithMessage(fmt.Sprintf("commit %d", i)))
+	}
+	// Issues referenced by commits that are left out are not linked
+	commits[0] = commitWithMessage("commit 0, fixes #7")
+	client := &mockIntentClient{commits: commits}
+	orchestrator := &DefaultReviewOrchestrator{githubClient: client}
+
+	info := orchestrator.buildPullRequestInfo(context.Background(), event)
+
+	if !strings.HasSuffix(info.Description, "[truncated]") || len(info.Description) > maxDescriptionLength+20 {
+		t.Errorf("expected description to be truncated, got %d characters", len(info.Description))
+	}
+	if len(info.CommitMessages) != maxCommitMessages || info.CommitMessages[0] != "commit 5" {
+		t.Errorf("expected the latest %d commits, got %d starting with %q",
+			maxCommitMessages, len(info.CommitMessages), info.CommitMessages[0])
+	}
+	if len(client.fetchedIssues) != 0 {
+		t.Errorf("expected no issues from dropped commits, got %v", client.fetchedIssues)
+	}
+
+	// Commit fetch failures only drop the commits
+	client.commitsErr = errors.New("rate limited")
+	client.commits = nil
+	info = orchestrator.buildPullRequestInfo(context.Background(), event)
+	if len(info.CommitMessages) != 0 || info.Description == "" {
+		t.Errorf("expected description without commits, got %+v", info)
+	}
+}
+
+func TestDefaultReviewOrchestrator_SendsPullRequestIntent(t *testing.T) {
+	mockLLM := &mockLLMClientWithComments{reviewResponse: &llm.ReviewResponse{}}
+	client := &mockIntentClient{commits: []github.PullRequestCommit{commitWithMessage("Add feature")}}
+
+	orchestrator := &DefaultReviewOrchestrator{
+		workspaceManager: &mockWorkspaceManager{},
+		diffFetcher:      &mockDiffFetcher{diffResult: &github.DiffResult{RawDiff: "test diff", TotalFiles: 1}},
+		codeAnalyzer: &mockCodeAnalyzer{
+			contextualDiff: &analyzer.ContextualDiff{ParsedDiff: &analyzer.ParsedDiff{TotalFiles: 1}},
+		},
+		llmClient:    mockLLM,
+		githubClient: client,
+	}
+
+	event := createTestPullRequestEvent()
+	event.PullRequest.Body = "Adds the amazing feature"
+	if _, err := orchestrator.HandlePullRequest(event); err != nil {
+		t.Fatalf("HandlePullRequest failed: %v", err)
+	}
+
+	if len(mockLLM.requests) != 1 {
+		t.Fatalf("expected 1 LLM request, got %d", len(mockLLM.requests))
+	}
+	info := mockLLM.requests[0].PullRequestInfo
+	if info.Description != "Adds the amazing feature" || !reflect.DeepEqual(info.CommitMessages, []string{"Add feature"}) {
+		t.Errorf("expected description and commits in the request, got %+v", info)
+	}
+}
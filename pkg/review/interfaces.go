@@ -2,9 +2,13 @@ package review
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/GDSources/claude-code-review-agent/pkg/analyzer"
 	"github.com/GDSources/claude-code-review-agent/pkg/github"
+	"github.com/GDSources/claude-code-review-agent/pkg/history"
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
 	"github.com/GDSources/claude-code-review-agent/pkg/webhook"
 )
 
@@ -13,6 +17,23 @@ type GitHubCloner interface {
 	CheckoutBranch(ctx context.Context, repoPath, branch string) error
 }
 
+// PullRequestCheckout checks out the exact head commit of a pull request, including one from a fork
+type PullRequestCheckout interface {
+	CheckoutPullRequest(ctx context.Context, repoPath string, prNumber int, branch, headSHA string) error
+}
+
+// MirrorCache creates working copies that share objects with a persistent mirror of the repository.
+// Extra refs such as refs/pull/<n>/head are fetched into the mirror besides branches and tags.
+// Release must be called once the working copy has been removed.
+type MirrorCache interface {
+	CreateWorktree(ctx context.Context, owner, repo, remoteURL, destination, ref, branch string, extraRefs ...string) (release func(), err error)
+}
+
+// RemoteURLProvider is implemented by cloners that can give the authenticated URL of a repository
+type RemoteURLProvider interface {
+	AuthenticatedCloneURL(owner, repo string) string
+}
+
 type FileSystemManager interface {
 	CreateTempDir(prefix string) (string, error)
 	RemoveAll(path string) error
@@ -25,9 +46,31 @@ type ReviewOrchestrator interface {
 
 // ReviewResult contains the outcome of a review operation
 type ReviewResult struct {
-	CommentsPosted int    `json:"comments_posted"`
-	Status         string `json:"status"`
-	Summary        string `json:"summary,omitempty"`
+	CommentsPosted   int                              `json:"comments_posted"`
+	ThreadsResolved  int                              `json:"threads_resolved,omitempty"`
+	Status           string                           `json:"status"`
+	Summary          string                           `json:"summary,omitempty"`
+	Repository       string                           `json:"repository,omitempty"`
+	PullRequest      int                              `json:"pull_request,omitempty"`
+	BaseSHA          string                           `json:"base_sha,omitempty"`
+	HeadSHA          string                           `json:"head_sha,omitempty"`
+	Model            string                           `json:"model,omitempty"`
+	PromptHash       string                           `json:"prompt_hash,omitempty"`
+	RepoConfig       string                           `json:"repo_config,omitempty"`
+	TokensUsed       llm.TokenUsage                   `json:"tokens_used"`
+	CostUSD          float64                          `json:"cost_usd"`
+	Budget           *BudgetReport                    `json:"budget,omitempty"`
+	Route            *RouteDecision                   `json:"route,omitempty"`
+	HighestSeverity  string                           `json:"highest_severity,omitempty"`
+	Comments         []CommentOutcome                 `json:"comments,omitempty"`
+	SkippedFiles     []SkippedFile                    `json:"skipped_files,omitempty"`
+	Stages           []StageTiming                    `json:"stages,omitempty"`
+	DeletionAnalysis *analyzer.DeletionAnalysisResult `json:"deletion_analysis,omitempty"`
+	Autofix          *AutofixResult                   `json:"autofix,omitempty"`
+	Warnings         []string                         `json:"warnings,omitempty"`
+	WorkspaceBytes   int64                            `json:"workspace_bytes,omitempty"` // Disk space of the checkout; 0 when not cloned
+	StartedAt        time.Time                        `json:"started_at"`
+	DurationMs       int64                            `json:"duration_ms"`
 }
 
 type PullRequestEvent = webhook.PullRequestEvent
@@ -35,11 +78,21 @@ type PullRequest = webhook.PullRequest
 type Repository = webhook.Repository
 type Branch = webhook.Branch
 type User = webhook.User
+type Label = webhook.Label
 
+// Workspace is the pull request's repository. A lazy workspace is only cloned when a stage calls
+// Checkout; until then files are read at the head commit through its file provider.
 type Workspace struct {
-	Path        string
+	Path        string // Checkout location; empty until the repository is checked out
 	Repository  *Repository
 	PullRequest *PullRequest
+
+	release  func()                                         // Lets the repository mirror backing the workspace be evicted again
+	checkout func(ctx context.Context, ws *Workspace) error // Clones the repository on the first Checkout of a lazy workspace
+	files    FileProvider                                   // Reads files at the head commit without a checkout
+	contents RepositoryContentReader                        // Reads files of the repository at other refs without a checkout
+	mu       sync.Mutex
+	err      error // Why the checkout failed, so it is not retried
 }
 
 type WorkspaceManager interface {
@@ -47,6 +100,27 @@ type WorkspaceManager interface {
 	CleanupWorkspace(workspace *Workspace) error
 }
 
+// LazyWorkspaceManager opens workspaces that clone the repository only when a stage needs it
+type LazyWorkspaceManager interface {
+	WorkspaceManager
+	OpenWorkspace(ctx context.Context, event *PullRequestEvent) (*Workspace, error)
+}
+
+// FileProvider reads repository files at a fixed commit
+type FileProvider interface {
+	ReadFile(ctx context.Context, path string) ([]byte, error)
+}
+
+// BaseFileReader reads a file as it exists at a commit of a checked-out repository
+type BaseFileReader interface {
+	ReadFileAtRef(ctx context.Context, repoPath, ref, path string) ([]byte, error)
+}
+
+// RepositoryContentReader reads a file of a repository at a ref without cloning it
+type RepositoryContentReader interface {
+	GetFileContent(ctx context.Context, owner, repo, path, ref string) ([]byte, error)
+}
+
 // DiffFetcher fetches PR diffs from GitHub API
 type DiffFetcher interface {
 	GetPullRequestDiffWithFiles(ctx context.Context, owner, repo string, prNumber int) (*github.DiffResult, error)
@@ -69,6 +143,11 @@ type CodebaseFlattener interface {
 	FlattenDiff(workspacePath string, diff *analyzer.ParsedDiff) (*analyzer.FlattenedCodebase, error)
 }
 
+// HistoryStore persists a record of every review run
+type HistoryStore interface {
+	Save(record *history.Record) error
+}
+
 // ReviewData contains all information needed for LLM analysis
 type ReviewData struct {
 	Event             *PullRequestEvent                `json:"event"`
@@ -77,4 +156,5 @@ type ReviewData struct {
 	ContextualDiff    *analyzer.ContextualDiff         `json:"contextual_diff"`
 	FlattenedCodebase *analyzer.FlattenedCodebase      `json:"flattened_codebase,omitempty"`
 	DeletionAnalysis  *analyzer.DeletionAnalysisResult `json:"deletion_analysis,omitempty"`
+	PullRequestInfo   *llm.PullRequestInfo             `json:"pull_request_info,omitempty"` // Fetched once, see pullRequestInfo
 }
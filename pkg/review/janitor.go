@@ -0,0 +1,317 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WorkspacePrefix names the temporary directories review workspaces are created in. It does not
+// match the fallback repository and result cache directories, review-agent-repos and
+// review-agent-llm-cache, which share the temporary directory.
+const WorkspacePrefix = "review-agent-ws-"
+
+// legacyWorkspacePrefix named workspaces before WorkspacePrefix. Directories named with it and
+// the random number os.MkdirTemp appends are still swept, so those left behind by earlier
+// versions are cleaned up. Remove after the next release.
+const legacyWorkspacePrefix = "review-agent-"
+
+// Workspace janitor defaults
+const (
+	DefaultWorkspaceTTL    = 6 * time.Hour    // Age after which a workspace is considered left behind
+	DefaultJanitorInterval = 15 * time.Minute // How often the server sweeps stale workspaces
+)
+
+// ErrWorkspaceQuota is returned when a review cannot get disk space for its workspace
+var ErrWorkspaceQuota = errors.New("workspace disk quota exceeded")
+
+// WorkspaceJanitor removes workspaces left behind by crashed reviews and limits the disk space
+// that all workspaces may use together
+type WorkspaceJanitor struct {
+	root      string
+	ttl       time.Duration
+	quota     int64         // Bytes; 0 is unlimited
+	quotaWait time.Duration // How long a review waits for space; 0 rejects it at once
+
+	mu           sync.Mutex
+	active       map[string]bool // Workspaces of reviews running in this process, never swept
+	reservations map[*SpaceReservation]bool
+	now          func() time.Time
+	poll         time.Duration
+}
+
+// SpaceReservation is the disk space a review holds within the quota from the quota check until
+// its workspace is released, so concurrent reviews cannot all pass the check before any has cloned
+type SpaceReservation struct {
+	bytes int64
+	dir   string // Workspace directory, once it exists
+}
+
+// NewWorkspaceJanitor creates a janitor for the workspaces under root (the system temporary
+// directory when empty). A TTL of 0 uses DefaultWorkspaceTTL and a quota of 0 is unlimited.
+func NewWorkspaceJanitor(root string, ttl time.Duration, quota int64, quotaWait time.Duration) *WorkspaceJanitor {
+	if root == "" {
+		root = os.TempDir()
+	}
+	if ttl <= 0 {
+		ttl = DefaultWorkspaceTTL
+	}
+	return &WorkspaceJanitor{
+		root:         root,
+		ttl:          ttl,
+		quota:        quota,
+		quotaWait:    quotaWait,
+		active:       make(map[string]bool),
+		reservations: make(map[*SpaceReservation]bool),
+		now:          time.Now,
+		poll:         5 * time.Second,
+	}
+}
+
+// Run sweeps stale workspaces now and then at every interval until ctx is done
+func (j *WorkspaceJanitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		j.sweepAndLog()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *WorkspaceJanitor) sweepAndLog() {
+	removed, freed, err := j.Sweep()
+	if err != nil {
+		log.Printf("Warning: failed to sweep stale workspaces: %v", err)
+	}
+	if removed > 0 {
+		log.Printf("Removed %d stale workspaces, freeing %s", removed, formatBytes(freed))
+	}
+}
+
+// Sweep removes the workspaces older than the TTL that no review in this process uses. It
+// returns how many were removed and the disk space freed.
+func (j *WorkspaceJanitor) Sweep() (int, int64, error) {
+	dirs, err := j.workspaceDirs()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	cutoff := j.now().Add(-j.ttl)
+	removed, freed := 0, int64(0)
+	var errs []error
+	for _, dir := range dirs {
+		info, err := os.Stat(dir)
+		if err != nil || !info.ModTime().Before(cutoff) || j.isActive(dir) {
+			continue
+		}
+
+		size := directorySize(dir)
+		if err := os.RemoveAll(dir); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+		freed += size
+	}
+	return removed, freed, errors.Join(errs...)
+}
+
+// Usage returns the disk space used by all workspaces
+func (j *WorkspaceJanitor) Usage() int64 {
+	dirs, err := j.workspaceDirs()
+	if err != nil {
+		return 0
+	}
+
+	var total int64
+	for _, dir := range dirs {
+		total += directorySize(dir)
+	}
+	return total
+}
+
+// WaitForSpace returns once a workspace of the expected size fits within the quota, and reserves
+// that space until the workspace is released. Stale workspaces are swept first; after that it
+// waits up to the quota wait for running reviews to finish, and returns ErrWorkspaceQuota when
+// there is still no room. Without a quota it reserves nothing and returns nil.
+func (j *WorkspaceJanitor) WaitForSpace(ctx context.Context, expected int64) (*SpaceReservation, error) {
+	if j.quota <= 0 {
+		return nil, nil
+	}
+	if reservation, _ := j.reserve(expected); reservation != nil {
+		return reservation, nil
+	}
+	j.sweepAndLog()
+
+	deadline := j.now().Add(j.quotaWait)
+	for {
+		reservation, usage := j.reserve(expected)
+		if reservation != nil {
+			return reservation, nil
+		}
+		if !j.now().Before(deadline) {
+			return nil, fmt.Errorf("%w: workspaces use %s of %s", ErrWorkspaceQuota, formatBytes(usage), formatBytes(j.quota))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(j.poll):
+		}
+	}
+}
+
+// reserve reserves space for a workspace of the expected size when it fits within the quota.
+// Otherwise it returns nil and the space used and reserved by the workspaces. A workspace that
+// is bigger than the quota on its own still gets space once there are no others.
+func (j *WorkspaceJanitor) reserve(expected int64) (*SpaceReservation, int64) {
+	sizes := make(map[string]int64)
+	if dirs, err := j.workspaceDirs(); err == nil {
+		for _, dir := range dirs {
+			sizes[filepath.Clean(dir)] = directorySize(dir)
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var usage int64
+	for _, size := range sizes {
+		usage += size
+	}
+	// A reservation counts for the part of the expected size its workspace does not use yet
+	for reservation := range j.reservations {
+		if remaining := reservation.bytes - sizes[reservation.dir]; remaining > 0 {
+			usage += remaining
+		}
+	}
+
+	if usage >= j.quota || usage > 0 && usage+expected > j.quota {
+		return nil, usage
+	}
+	reservation := &SpaceReservation{bytes: expected}
+	j.reservations[reservation] = true
+	return reservation, usage
+}
+
+// assign ties a reservation to the workspace directory it was made for
+func (j *WorkspaceJanitor) assign(reservation *SpaceReservation, dir string) {
+	if reservation == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	reservation.dir = filepath.Clean(dir)
+}
+
+// cancel gives back a reservation whose workspace was never created
+func (j *WorkspaceJanitor) cancel(reservation *SpaceReservation) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.reservations, reservation)
+}
+
+// Track marks a workspace directory as used by a running review so it is never swept
+func (j *WorkspaceJanitor) Track(dir string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.active[filepath.Clean(dir)] = true
+}
+
+// Release marks a workspace directory as no longer used and gives back the space reserved for it
+func (j *WorkspaceJanitor) Release(dir string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	dir = filepath.Clean(dir)
+	delete(j.active, dir)
+	for reservation := range j.reservations {
+		if reservation.dir == dir {
+			delete(j.reservations, reservation)
+		}
+	}
+}
+
+func (j *WorkspaceJanitor) isActive(dir string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.active[filepath.Clean(dir)]
+}
+
+// workspaceDirs lists the workspace directories under the root
+func (j *WorkspaceJanitor) workspaceDirs() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(j.root, WorkspacePrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := filepath.Glob(filepath.Join(j.root, legacyWorkspacePrefix+"[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, match := range legacy {
+		if isDigits(strings.TrimPrefix(filepath.Base(match), legacyWorkspacePrefix)) {
+			matches = append(matches, match)
+		}
+	}
+
+	dirs := matches[:0]
+	for _, match := range matches {
+		if info, err := os.Lstat(match); err == nil && info.IsDir() {
+			dirs = append(dirs, match)
+		}
+	}
+	return dirs, nil
+}
+
+// isDigits reports whether s is a non-empty string of decimal digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// directorySize returns the total size of the regular files under a directory
+func directorySize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// formatBytes formats a byte count with a binary unit, e.g. "1.5 GB"
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	value, exponent := float64(bytes)/unit, 0
+	for value >= unit && exponent < 4 {
+		value /= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGTP"[exponent])
+}
@@ -0,0 +1,200 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// makeWorkspaceDir creates a workspace directory holding size bytes, last modified age ago
+func makeWorkspaceDir(t *testing.T, root, name string, size int, age time.Duration) string {
+	t.Helper()
+	dir := filepath.Join(root, WorkspacePrefix+name)
+	if err := os.MkdirAll(filepath.Join(dir, "repo"), 0755); err != nil {
+		t.Fatalf("failed to create workspace: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "repo", "data"), make([]byte, size), 0644); err != nil {
+		t.Fatalf("failed to write workspace file: %v", err)
+	}
+	modified := time.Now().Add(-age)
+	if err := os.Chtimes(dir, modified, modified); err != nil {
+		t.Fatalf("failed to age workspace: %v", err)
+	}
+	return dir
+}
+
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestWorkspaceJanitor_Sweep(t *testing.T) {
+	root := t.TempDir()
+	stale := makeWorkspaceDir(t, root, "stale", 100, 2*time.Hour)
+	fresh := makeWorkspaceDir(t, root, "fresh", 100, time.Minute)
+	running := makeWorkspaceDir(t, root, "running", 100, 2*time.Hour)
+
+	// Directories without the workspace prefix, such as the fallback caches, are never touched
+	var others []string
+	for _, name := range []string{"other", "review-agent-repos", "review-agent-llm-cache"} {
+		other := filepath.Join(root, name)
+		if err := os.Mkdir(other, 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		modified := time.Now().Add(-2 * time.Hour)
+		_ = os.Chtimes(other, modified, modified)
+		others = append(others, other)
+	}
+
+	// Workspaces named by earlier versions are swept too
+	legacy := filepath.Join(root, "review-agent-123456789")
+	if err := os.Mkdir(legacy, 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	modified := time.Now().Add(-2 * time.Hour)
+	_ = os.Chtimes(legacy, modified, modified)
+
+	janitor := NewWorkspaceJanitor(root, time.Hour, 0, 0)
+	janitor.Track(running)
+
+	removed, freed, err := janitor.Sweep()
+	if err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	if removed != 2 || freed != 100 {
+		t.Errorf("expected 2 workspaces of 100 bytes removed, got %d of %d bytes", removed, freed)
+	}
+	if pathExists(stale) || pathExists(legacy) {
+		t.Error("expected the stale workspaces to be removed")
+	}
+	for _, dir := range append([]string{fresh, running}, others...) {
+		if !pathExists(dir) {
+			t.Errorf("expected %s to be kept", filepath.Base(dir))
+		}
+	}
+
+	// A released workspace is swept once it is stale
+	janitor.Release(running)
+	if removed, _, _ := janitor.Sweep(); removed != 1 || pathExists(running) {
+		t.Error("expected the released workspace to be removed")
+	}
+}
+
+func TestWorkspaceJanitor_WaitForSpace(t *testing.T) {
+	tests := []struct {
+		name      string
+		quota     int64
+		quotaWait time.Duration
+		release   bool // Remove the running workspace while the review waits
+		expectErr bool
+	}{
+		{name: "unlimited", quota: 0},
+		{name: "under quota", quota: 1000},
+		{name: "over quota is rejected without a wait", quota: 500, expectErr: true},
+		{name: "over quota times out", quota: 500, quotaWait: 30 * time.Millisecond, expectErr: true},
+		{name: "space freed while waiting", quota: 500, quotaWait: time.Minute, release: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			running := makeWorkspaceDir(t, root, "running", 600, 2*time.Hour)
+			stale := makeWorkspaceDir(t, root, "stale", 300, 2*time.Hour)
+
+			janitor := NewWorkspaceJanitor(root, time.Hour, tt.quota, tt.quotaWait)
+			janitor.poll = 5 * time.Millisecond
+			janitor.Track(running)
+
+			if tt.release {
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					_ = os.RemoveAll(running)
+				}()
+			}
+
+			_, err := janitor.WaitForSpace(context.Background(), 0)
+			if tt.expectErr {
+				if !errors.Is(err, ErrWorkspaceQuota) {
+					t.Errorf("expected ErrWorkspaceQuota, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("expected space, got %v", err)
+			}
+
+			// Stale workspaces are swept before waiting, running ones are kept
+			if tt.quota > 0 && tt.quota < 900 && pathExists(stale) {
+				t.Error("expected the stale workspace to be swept over the quota")
+			}
+			if !tt.release && !pathExists(running) {
+				t.Error("expected the running workspace to be kept")
+			}
+		})
+	}
+}
+
+func TestWorkspaceJanitor_WaitForSpaceCancelled(t *testing.T) {
+	root := t.TempDir()
+	janitor := NewWorkspaceJanitor(root, time.Hour, 10, time.Minute)
+	janitor.Track(makeWorkspaceDir(t, root, "running", 100, 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := janitor.WaitForSpace(ctx, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the wait to end with the context, got %v", err)
+	}
+}
+
+func TestWorkspaceJanitor_ReservesSpace(t *testing.T) {
+	root := t.TempDir()
+	janitor := NewWorkspaceJanitor(root, time.Hour, 1000, 0)
+
+	// Neither review has cloned yet, so only the first reservation fits
+	first, err := janitor.WaitForSpace(context.Background(), 600)
+	if err != nil {
+		t.Fatalf("expected space for the first review, got %v", err)
+	}
+	if _, err := janitor.WaitForSpace(context.Background(), 600); !errors.Is(err, ErrWorkspaceQuota) {
+		t.Fatalf("expected the reserved space to count against the second review, got %v", err)
+	}
+
+	// A cloned workspace counts once, as the larger of its size and its reservation
+	running := makeWorkspaceDir(t, root, "running", 500, 0)
+	janitor.Track(running)
+	janitor.assign(first, running)
+	if _, err := janitor.WaitForSpace(context.Background(), 500); !errors.Is(err, ErrWorkspaceQuota) {
+		t.Errorf("expected 600 reserved bytes and 500 more to exceed the quota, got %v", err)
+	}
+	second, err := janitor.WaitForSpace(context.Background(), 400)
+	if err != nil {
+		t.Fatalf("expected 400 bytes to fit next to the 600 reserved, got %v", err)
+	}
+
+	// Releasing a workspace, or cancelling a reservation, gives the space back
+	janitor.Release(running)
+	_ = os.RemoveAll(running)
+	janitor.cancel(second)
+	if _, err := janitor.WaitForSpace(context.Background(), 1000); err != nil {
+		t.Errorf("expected space once the reservations were given back, got %v", err)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		bytes    int64
+		expected string
+	}{
+		{bytes: 512, expected: "512 B"},
+		{bytes: 1536, expected: "1.5 KB"},
+		{bytes: 20 << 20, expected: "20.0 MB"},
+		{bytes: 3 << 29, expected: "1.5 GB"},
+	}
+
+	for _, tt := range tests {
+		if got := formatBytes(tt.bytes); got != tt.expected {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.bytes, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package review
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MetricsRecorder receives measurements of review runs
+type MetricsRecorder interface {
+	RecordWorkspaceBytes(repository string, bytes int64)
+}
+
+// WorkspaceMetrics keeps the workspace sizes of review runs and serves them in the Prometheus
+// text format
+type WorkspaceMetrics struct {
+	mu      sync.Mutex
+	last    map[string]int64 // Size of the last workspace, by repository
+	total   int64
+	reviews int64
+}
+
+// NewWorkspaceMetrics creates an empty set of workspace metrics
+func NewWorkspaceMetrics() *WorkspaceMetrics {
+	return &WorkspaceMetrics{last: make(map[string]int64)}
+}
+
+// RecordWorkspaceBytes sets the repository's workspace size and adds it to the total
+func (m *WorkspaceMetrics) RecordWorkspaceBytes(repository string, bytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.last[repository] = bytes
+	m.total += bytes
+	m.reviews++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (m *WorkspaceMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write([]byte(m.String()))
+}
+
+// String renders the metrics in the Prometheus text format
+func (m *WorkspaceMetrics) String() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	repositories := make([]string, 0, len(m.last))
+	for repository := range m.last {
+		repositories = append(repositories, repository)
+	}
+	sort.Strings(repositories)
+
+	var out strings.Builder
+	out.WriteString("# HELP review_workspace_bytes Disk space of the last review workspace of a repository.\n")
+	out.WriteString("# TYPE review_workspace_bytes gauge\n")
+	for _, repository := range repositories {
+		out.WriteString(fmt.Sprintf("review_workspace_bytes{repository=\"%s\"} %d\n", escapeLabel(repository), m.last[repository]))
+	}
+	out.WriteString("# HELP review_workspace_bytes_total Disk space of all review workspaces.\n")
+	out.WriteString("# TYPE review_workspace_bytes_total counter\n")
+	out.WriteString(fmt.Sprintf("review_workspace_bytes_total %d\n", m.total))
+	out.WriteString("# HELP review_workspaces_total Review workspaces measured.\n")
+	out.WriteString("# TYPE review_workspaces_total counter\n")
+	out.WriteString(fmt.Sprintf("review_workspaces_total %d\n", m.reviews))
+	return out.String()
+}
+
+// escapeLabel escapes a Prometheus label value
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,49 @@
+package review
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWorkspaceMetrics(t *testing.T) {
+	metrics := NewWorkspaceMetrics()
+	metrics.RecordWorkspaceBytes("owner/api", 100)
+	metrics.RecordWorkspaceBytes("owner/web", 50)
+	metrics.RecordWorkspaceBytes("owner/api", 300)
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+
+	for _, expected := range []string{
+		`review_workspace_bytes{repository="owner/api"} 300`,
+		`review_workspace_bytes{repository="owner/web"} 50`,
+		"review_workspace_bytes_total 450",
+		"review_workspaces_total 3",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in metrics, got:\n%s", expected, body)
+		}
+	}
+	if got := escapeLabel(`a"b\c`); got != `a\"b\\c` {
+		t.Errorf("unexpected escaped label %q", got)
+	}
+}
+
+func TestDefaultReviewOrchestrator_RecordsWorkspaceMetrics(t *testing.T) {
+	metrics := NewWorkspaceMetrics()
+	orchestrator := &DefaultReviewOrchestrator{workspaceManager: &mockWorkspaceManager{}}
+	orchestrator.SetMetrics(metrics)
+
+	event := createTestPullRequestEvent()
+	result, err := orchestrator.HandlePullRequest(event)
+	if err != nil {
+		t.Fatalf("HandlePullRequest failed: %v", err)
+	}
+
+	recorded, ok := metrics.last[event.Repository.FullName]
+	if !ok || recorded != result.WorkspaceBytes || metrics.reviews != 1 {
+		t.Errorf("expected the workspace size of %s to be recorded, got %v", event.Repository.FullName, metrics.last)
+	}
+}
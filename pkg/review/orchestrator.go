@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/GDSources/claude-code-review-agent/pkg/analyzer"
 	"github.com/GDSources/claude-code-review-agent/pkg/github"
 	"github.com/GDSources/claude-code-review-agent/pkg/llm"
+	"github.com/GDSources/claude-code-review-agent/pkg/repoconfig"
 )
 
 // GitHubCommentClient interface for posting comments (to avoid circular imports)
@@ -29,6 +31,20 @@ type DefaultReviewOrchestrator struct {
 	deletionAnalyzer  DeletionAnalyzer
 	llmClient         llm.CodeReviewer
 	githubClient      GitHubCommentClient
+	historyStore      HistoryStore
+	metrics           MetricsRecorder
+	repoConfigReader  repoconfig.FileReader
+	baseFileReader    BaseFileReader
+	includePaths      []string
+	excludePaths      []string
+
+	confidenceThreshold float64
+	showPossibleIssues  bool
+	tokenBudget         int
+	autofix             AutofixSettings
+	budget              BudgetSettings
+	spend               spendLedger
+	routing             *repoconfig.RoutingConfig
 }
 
 func NewDefaultReviewOrchestrator(workspaceManager WorkspaceManager, diffFetcher DiffFetcher, codeAnalyzer CodeAnalyzer) *DefaultReviewOrchestrator {
@@ -95,16 +111,66 @@ func NewReviewOrchestratorWithDeletionAnalysis(workspaceManager WorkspaceManager
 	}
 }
 
+// SetHistoryStore configures where completed review runs are recorded
+func (r *DefaultReviewOrchestrator) SetHistoryStore(store HistoryStore) {
+	r.historyStore = store
+}
+
+// SetMetrics configures where review measurements, such as workspace sizes, are reported
+func (r *DefaultReviewOrchestrator) SetMetrics(metrics MetricsRecorder) {
+	r.metrics = metrics
+}
+
+// SetPathFilters sets the globs of paths to review and to exclude for every repository
+func (r *DefaultReviewOrchestrator) SetPathFilters(include, exclude []string) {
+	r.includePaths = include
+	r.excludePaths = exclude
+}
+
+// SetConfidenceThreshold holds back findings below the given confidence (0-1), optionally
+// listing them as possible issues in the review summary
+func (r *DefaultReviewOrchestrator) SetConfidenceThreshold(threshold float64, showPossibleIssues bool) {
+	r.confidenceThreshold = threshold
+	r.showPossibleIssues = showPossibleIssues
+}
+
+// SetTokenBudget sets the estimated diff tokens reviewed per pull request; 0 keeps the default
+func (r *DefaultReviewOrchestrator) SetTokenBudget(budget int) {
+	r.tokenBudget = budget
+}
+
+// SetRepoConfigReader enables loading .review-agent.yml from the base branch of each workspace
+func (r *DefaultReviewOrchestrator) SetRepoConfigReader(reader repoconfig.FileReader) {
+	r.repoConfigReader = reader
+}
+
+// SetBaseFileReader enables reading files at the base commit from the checkout, to extract the
+// declarations removed by a pull request. Without it files are read through the workspace's
+// content reader, when it has one.
+func (r *DefaultReviewOrchestrator) SetBaseFileReader(reader BaseFileReader) {
+	r.baseFileReader = reader
+}
+
 func (r *DefaultReviewOrchestrator) HandlePullRequest(event *PullRequestEvent) (*ReviewResult, error) {
 	ctx := context.Background()
 
 	log.Printf("Starting review for PR #%d in %s", event.Number, event.Repository.FullName)
 
+	startTime := time.Now()
 	result := &ReviewResult{
 		CommentsPosted: 0,
 		Status:         "success",
 		Summary:        "",
+		Repository:     event.Repository.FullName,
+		PullRequest:    event.Number,
+		BaseSHA:        event.PullRequest.Base.SHA,
+		HeadSHA:        event.PullRequest.Head.SHA,
+		StartedAt:      startTime.UTC(),
 	}
+	defer func() {
+		result.DurationMs = time.Since(startTime).Milliseconds()
+		r.recordHistory(result)
+	}()
 
 	// Initialize progress tracking if GitHub client is available
 	var progressComment *github.IssueComment
@@ -150,7 +216,9 @@ func (r *DefaultReviewOrchestrator) HandlePullRequest(event *PullRequestEvent) (
 		}
 	}
 
-	workspace, err := r.workspaceManager.CreateWorkspace(ctx, event)
+	stageStart := time.Now()
+	workspace, err := r.openWorkspace(ctx, event)
+	result.RecordStage(StageWorkspace, stageStart)
 	if err != nil {
 		// Update progress comment with failure if available
 		if r.githubClient != nil && progressComment != nil && reviewProgress != nil {
@@ -167,17 +235,56 @@ func (r *DefaultReviewOrchestrator) HandlePullRequest(event *PullRequestEvent) (
 			}
 		}
 		result.Status = "failed"
+		result.Summary = "Review failed during workspace setup"
 		return result, fmt.Errorf("failed to create workspace for PR #%d: %w", event.Number, err)
 	}
 
 	defer func() {
+		// Measured before cleanup so failed reviews report their workspace size too
+		result.WorkspaceBytes = workspace.Size()
+		if r.metrics != nil {
+			r.metrics.RecordWorkspaceBytes(event.Repository.FullName, result.WorkspaceBytes)
+		}
 		if cleanupErr := r.workspaceManager.CleanupWorkspace(workspace); cleanupErr != nil {
 			log.Printf("Warning: failed to cleanup workspace: %v", cleanupErr)
 		}
 	}()
 
-	log.Printf("Successfully cloned repository %s to %s", event.Repository.FullName, workspace.Path)
-	log.Printf("Checked out branch %s for PR #%d", event.PullRequest.Head.Ref, event.Number)
+	if workspace.Path != "" {
+		log.Printf("Successfully cloned repository %s to %s", event.Repository.FullName, workspace.Path)
+		log.Printf("Checked out branch %s for PR #%d", event.PullRequest.Head.Ref, event.Number)
+	} else {
+		log.Printf("Opened workspace for %s; the repository is cloned only if a stage needs it", event.Repository.FullName)
+	}
+
+	// Apply the repository config from the base branch on top of the defaults
+	settings := DefaultReviewSettings()
+	settings.Include = r.includePaths
+	settings.Exclude = r.excludePaths
+	settings.ConfidenceThreshold = r.confidenceThreshold
+	settings.ShowPossibleIssues = r.showPossibleIssues
+	if r.tokenBudget > 0 {
+		settings.TokenBudget = r.tokenBudget
+	}
+	settings.Routing = r.routing
+	repoConfig, configErrors, err := r.loadRepoConfig(ctx, event, workspace)
+	if err != nil {
+		log.Printf("Warning: failed to load repository config: %v", err)
+		result.AddWarning("failed to load repository config: %v", err)
+	}
+	if len(configErrors) > 0 {
+		log.Printf("Warning: repository config is invalid and was ignored: %s", strings.Join(configErrors, "; "))
+		for _, problem := range configErrors {
+			result.AddWarning("invalid repository config: %s", problem)
+		}
+		if reviewProgress != nil {
+			reviewProgress.ConfigErrors = configErrors
+		}
+	}
+	if repoConfig != nil {
+		settings.ApplyRepoConfig(repoConfig)
+		result.RepoConfig = repoConfig.Source
+	}
 
 	// Fetch and analyze PR diff if analyzers are available
 	var reviewData *ReviewData
@@ -196,15 +303,24 @@ func (r *DefaultReviewOrchestrator) HandlePullRequest(event *PullRequestEvent) (
 			}
 		}
 
+		stageStart = time.Now()
 		diffResult, err := r.fetchPRDiff(ctx, event)
 		if err != nil {
+			result.RecordStage(StageDiffAnalysis, stageStart)
 			log.Printf("Warning: failed to fetch PR diff: %v", err)
+			result.AddWarning("failed to fetch PR diff: %v", err)
 		} else {
 			log.Printf("Fetched diff for PR #%d: %d files changed", event.Number, diffResult.TotalFiles)
 
-			contextualDiff, err := r.analyzeDiff(diffResult)
+			contextualDiff, skipped, err := r.analyzeDiff(ctx, diffResult, settings, workspace)
+			result.RecordStage(StageDiffAnalysis, stageStart)
+			result.SkippedFiles = append(result.SkippedFiles, skipped...)
 			if err != nil {
 				log.Printf("Warning: failed to analyze diff: %v", err)
+				result.AddWarning("failed to analyze diff: %v", err)
+			} else if len(skipped) > 0 && contextualDiff.ParsedDiff != nil && contextualDiff.TotalFiles == 0 {
+				log.Printf("All %d changed files in PR #%d are excluded, generated or vendored, skipping review",
+					len(skipped), event.Number)
 			} else {
 				log.Printf("Analyzed diff for PR #%d: %d added, %d removed lines",
 					event.Number, contextualDiff.TotalAdded, contextualDiff.TotalRemoved)
@@ -217,20 +333,64 @@ func (r *DefaultReviewOrchestrator) HandlePullRequest(event *PullRequestEvent) (
 				}
 
 				// Perform deletion analysis if available
-				if r.codebaseFlattener != nil && r.deletionAnalyzer != nil {
-					err := r.performDeletionAnalysis(ctx, reviewData)
+				if r.codebaseFlattener != nil && r.deletionAnalyzer != nil && settings.DeletionAnalysis {
+					stageStart = time.Now()
+					err := r.performDeletionAnalysis(ctx, reviewData, settings)
+					result.RecordStage(StageDeletionAnalysis, stageStart)
 					if err != nil {
 						log.Printf("Warning: deletion analysis failed for PR #%d: %v", event.Number, err)
+						result.AddWarning("deletion analysis failed: %v", err)
 					}
+					result.DeletionAnalysis = reviewData.DeletionAnalysis
+				}
+
+				// Show the function around each change, read from the head revision
+				if settings.EnclosingContextLines > 0 {
+					stageStart = time.Now()
+					err := r.expandContext(ctx, reviewData, settings)
+					result.RecordStage(StageContextExpansion, stageStart)
+					if err != nil {
+						log.Printf("Warning: context expansion failed for PR #%d: %v", event.Number, err)
+						result.AddWarning("context expansion failed: %v", err)
+					}
+				}
+
+				// A flattened workspace also tells how widely the changed declarations are used
+				if reviewData.FlattenedCodebase != nil {
+					analyzer.AnnotateImpact(contextualDiff, reviewData.FlattenedCodebase.SymbolIndex())
 				}
+
+				// Triage last, so file sizes include the context added above
+				result.SkippedFiles = append(result.SkippedFiles, triageFiles(contextualDiff, settings)...)
 			}
 		}
 	} else {
 		log.Printf("Diff analysis skipped (analyzers not configured)")
 	}
 
-	// Send reviewData to LLM for analysis if available
+	// Pick the model from the diff, then check the estimated cost against the budget before anything is sent
+	var budget *BudgetReport
 	if reviewData != nil && r.llmClient != nil {
+		if reviewData.ContextualDiff != nil {
+			result.Route = r.routeModel(reviewData.ContextualDiff.ParsedDiff, &settings)
+		}
+		if result.Route != nil && result.Route.Rule != "" {
+			log.Printf("Routing PR #%d to %s (rule %q)", event.Number, result.Route.Model, result.Route.Rule)
+		}
+
+		var overBudget []SkippedFile
+		budget, overBudget = r.applyBudget(ctx, reviewData, &settings)
+		result.Budget = budget
+		result.SkippedFiles = append(result.SkippedFiles, overBudget...)
+	}
+
+	// Send reviewData to LLM for analysis if available
+	var possibleIssues []llm.ReviewComment
+	if budget.Skipped() {
+		log.Printf("Skipping LLM review for PR #%d: %s", event.Number, budget.Reason)
+		result.Status = "skipped"
+		result.AddWarning("LLM review skipped: %s", budget.Reason)
+	} else if reviewData != nil && r.llmClient != nil {
 		// Update progress to reviewing stage
 		if r.githubClient != nil && progressComment != nil && reviewProgress != nil {
 			UpdateProgressStage(reviewProgress, "reviewing", "Generating review comments...")
@@ -247,23 +407,113 @@ func (r *DefaultReviewOrchestrator) HandlePullRequest(event *PullRequestEvent) (
 
 		log.Printf("Sending PR #%d to LLM for analysis", event.Number)
 
-		reviewResponse, err := r.performLLMReview(ctx, reviewData)
+		stageStart = time.Now()
+		reviewResponse, err := r.performLLMReview(ctx, reviewData, settings)
+		result.RecordStage(StageLLMReview, stageStart)
+		var reservation spendReservation
+		if budget != nil {
+			reservation = budget.reservation
+		}
 		if err != nil {
+			r.settleSpend(time.Now(), reservation, 0)
 			log.Printf("Warning: LLM review failed for PR #%d: %v", event.Number, err)
+			result.AddWarning("LLM review failed: %v", err)
 		} else {
 			log.Printf("LLM review completed for PR #%d: %d comments generated",
 				event.Number, len(reviewResponse.Comments))
+			findings := reviewResponse.Comments // Before any are held back, to tell addressed threads apart
+			reviewed := reviewedFiles(reviewData, reviewResponse)
+
+			// Have another model re-check the first pass's most severe findings
+			if result.Route != nil {
+				result.Route.Model = reviewResponse.ModelUsed
+				secondOpinion, err := r.requestSecondOpinion(ctx, reviewData, settings, reviewResponse, &reservation)
+				if err != nil {
+					log.Printf("Warning: second opinion skipped for PR #%d: %v", event.Number, err)
+					result.AddWarning("second opinion skipped: %v", err)
+				}
+				result.Route.SecondOpinion = secondOpinion
+			}
+
+			result.Model = reviewResponse.ModelUsed
+			result.PromptHash = reviewResponse.PromptHash
+			result.TokensUsed = reviewResponse.TokensUsed
+			result.CostUSD = reviewResponse.CostUSD
+			r.settleSpend(time.Now(), reservation, reviewResponse.CostUSD)
+			if reviewResponse.SkippedChunks > 0 {
+				result.AddWarning("%d prompt chunks were not sent to stay within the cost budget", reviewResponse.SkippedChunks)
+			}
+			result.HighestSeverity = highestSeverity(reviewResponse.Comments)
+
+			// Hold back findings below the configured severity threshold
+			var belowThreshold []llm.ReviewComment
+			reviewResponse.Comments, belowThreshold = filterBySeverity(reviewResponse.Comments, settings.SeverityThreshold)
+			for _, comment := range belowThreshold {
+				result.Comments = append(result.Comments, newCommentOutcome(comment, CommentStatusSkipped,
+					fmt.Sprintf("below severity threshold (%s)", settings.SeverityThreshold)))
+			}
+
+			// Hold back findings the model is not confident about
+			var lowConfidence []llm.ReviewComment
+			reviewResponse.Comments, lowConfidence = filterByConfidence(reviewResponse.Comments, settings.ConfidenceThreshold)
+			for _, comment := range lowConfidence {
+				result.Comments = append(result.Comments, newCommentOutcome(comment, CommentStatusSkipped,
+					fmt.Sprintf("below confidence threshold (%.2f)", settings.ConfidenceThreshold)))
+			}
+
+			// Hold back findings quoting code that is not in their file's diff
+			var unsupported []llm.ReviewComment
+			reviewResponse.Comments, unsupported = filterByEvidence(reviewResponse.Comments, reviewData.ContextualDiff)
+			for _, comment := range unsupported {
+				result.Comments = append(result.Comments, newCommentOutcome(comment, CommentStatusSkipped,
+					"evidence not found in the diff"))
+			}
+			if settings.ShowPossibleIssues {
+				possibleIssues = append(lowConfidence, unsupported...)
+			}
+
+			// Push validated suggestions as a commit instead of commenting them
+			if r.autofix.Enabled && r.githubClient != nil {
+				stageStart = time.Now()
+				remaining, fixed, autofix, err := r.applyAutofixes(ctx, reviewData, reviewResponse.Comments)
+				result.RecordStage(StageAutofix, stageStart)
+				if err != nil {
+					log.Printf("Warning: autofix failed for PR #%d, leaving suggestions as comments: %v", event.Number, err)
+					result.AddWarning("autofix failed: %v", err)
+				}
+				reviewResponse.Comments = remaining
+				result.Autofix = autofix
+				for _, comment := range fixed {
+					result.Comments = append(result.Comments, newCommentOutcome(comment, CommentStatusFixed,
+						fmt.Sprintf("applied in %s on %s", shortSHA(autofix.CommitSHA), autofix.Branch)))
+				}
+			}
 
 			// Post generated comments back to GitHub PR
 			if r.githubClient != nil {
-				commentsPosted, err := r.postReviewComments(ctx, reviewData, reviewResponse)
+				resolved, err := r.resolveAddressedThreads(ctx, event, findings, reviewed)
+				result.ThreadsResolved = resolved
+				if err != nil {
+					log.Printf("Warning: failed to resolve addressed review threads for PR #%d: %v", event.Number, err)
+					result.AddWarning("failed to resolve addressed review threads: %v", err)
+				}
+
+				stageStart = time.Now()
+				outcomes, err := r.postReviewComments(ctx, reviewData, reviewResponse)
+				result.RecordStage(StageCommentPosting, stageStart)
+				result.Comments = append(outcomes, result.Comments...)
 				if err != nil {
 					log.Printf("Warning: Failed to post comments to PR #%d: %v", event.Number, err)
+					result.AddWarning("failed to post comments: %v", err)
 				} else {
-					result.CommentsPosted = commentsPosted
+					result.CommentsPosted = result.CountComments(CommentStatusPosted)
 				}
 			} else {
 				log.Printf("GitHub client not configured, skipping comment posting for PR #%d", event.Number)
+				for _, comment := range reviewResponse.Comments {
+					result.Comments = append(result.Comments,
+						newCommentOutcome(comment, CommentStatusSkipped, "GitHub client not configured"))
+				}
 			}
 
 			r.logReviewResults(reviewResponse)
@@ -272,22 +522,48 @@ func (r *DefaultReviewOrchestrator) HandlePullRequest(event *PullRequestEvent) (
 		log.Printf("Review data prepared for PR #%d (LLM not configured)", event.Number)
 	}
 
+	// Generate summary based on results
+	if result.CommentsPosted > 0 {
+		if result.CommentsPosted == 1 {
+			result.Summary = "Posted 1 comment"
+		} else {
+			result.Summary = fmt.Sprintf("Posted %d comments", result.CommentsPosted)
+		}
+	} else if budget.Skipped() {
+		result.Summary = "Review skipped: " + budget.Reason
+	} else {
+		result.Summary = "No issues found"
+	}
+	if len(result.SkippedFiles) == 1 {
+		result.Summary += ", 1 file not reviewed"
+	} else if len(result.SkippedFiles) > 1 {
+		result.Summary += fmt.Sprintf(", %d files not reviewed", len(result.SkippedFiles))
+	}
+	if result.Autofix != nil {
+		if result.Autofix.Applied == 1 {
+			result.Summary += fmt.Sprintf(", pushed 1 fix to %s", result.Autofix.Branch)
+		} else {
+			result.Summary += fmt.Sprintf(", pushed %d fixes to %s", result.Autofix.Applied, result.Autofix.Branch)
+		}
+	}
+	if result.ThreadsResolved == 1 {
+		result.Summary += ", resolved 1 addressed thread"
+	} else if result.ThreadsResolved > 1 {
+		result.Summary += fmt.Sprintf(", resolved %d addressed threads", result.ThreadsResolved)
+	}
+	if result.Route != nil && result.Model != "" {
+		result.Summary += routeSummary(result.Route)
+	}
+
 	// Update progress comment with completion status
 	if r.githubClient != nil && progressComment != nil && reviewProgress != nil {
 		UpdateProgressStage(reviewProgress, "completed", "Review completed successfully")
-
-		// Generate summary based on results
-		var summary string
-		if result.CommentsPosted > 0 {
-			if result.CommentsPosted == 1 {
-				summary = "Posted 1 comment"
-			} else {
-				summary = fmt.Sprintf("Posted %d comments", result.CommentsPosted)
-			}
-		} else {
-			summary = "No issues found"
-		}
-		reviewProgress.Summary = summary
+		reviewProgress.Summary = result.Summary
+		reviewProgress.SkippedFiles = result.SkippedFiles
+		reviewProgress.PossibleIssues = possibleIssues
+		reviewProgress.Autofix = result.Autofix
+		reviewProgress.Budget = result.Budget
+		reviewProgress.WorkspaceBytes = workspace.Size()
 
 		commentBody := GenerateProgressComment(reviewProgress)
 		_, err := r.githubClient.UpdateIssueComment(ctx,
@@ -304,6 +580,17 @@ func (r *DefaultReviewOrchestrator) HandlePullRequest(event *PullRequestEvent) (
 	return result, nil
 }
 
+// recordHistory saves the review result to the history store if one is configured
+func (r *DefaultReviewOrchestrator) recordHistory(result *ReviewResult) {
+	if r.historyStore == nil {
+		return
+	}
+
+	if err := r.historyStore.Save(NewHistoryRecord(result)); err != nil {
+		log.Printf("Warning: failed to record review history for PR #%d: %v", result.PullRequest, err)
+	}
+}
+
 // fetchPRDiff fetches the diff for a pull request
 func (r *DefaultReviewOrchestrator) fetchPRDiff(ctx context.Context, event *PullRequestEvent) (*github.DiffResult, error) {
 	if r.diffFetcher == nil {
@@ -316,37 +603,113 @@ func (r *DefaultReviewOrchestrator) fetchPRDiff(ctx context.Context, event *Pull
 		event.Number)
 }
 
-// analyzeDiff analyzes the fetched diff and extracts context
-func (r *DefaultReviewOrchestrator) analyzeDiff(diffResult *github.DiffResult) (*analyzer.ContextualDiff, error) {
+// reviewableDiff drops files outside the path filters and generated or vendored files,
+// returning the files that were left out and why
+func reviewableDiff(parsedDiff *analyzer.ParsedDiff, settings ReviewSettings, classifier *analyzer.FileClassifier) (*analyzer.ParsedDiff, []SkippedFile) {
+	var skipped []SkippedFile
+
+	parsedDiff, excluded := analyzer.FilterParsedDiff(parsedDiff, settings.Include, settings.Exclude)
+	for _, filename := range excluded {
+		skipped = append(skipped, SkippedFile{Filename: filename, Reason: SkipReasonPathFilter})
+	}
+
+	parsedDiff, classified := classifier.FilterClassified(parsedDiff)
+	for _, filename := range classified[analyzer.ClassificationGenerated] {
+		skipped = append(skipped, SkippedFile{Filename: filename, Reason: SkipReasonGenerated})
+	}
+	for _, filename := range classified[analyzer.ClassificationVendored] {
+		skipped = append(skipped, SkippedFile{Filename: filename, Reason: SkipReasonVendored})
+	}
+
+	return parsedDiff, skipped
+}
+
+// openWorkspace opens a lazy workspace when the workspace manager supports it, and clones the
+// repository up front otherwise
+func (r *DefaultReviewOrchestrator) openWorkspace(ctx context.Context, event *PullRequestEvent) (*Workspace, error) {
+	if lazy, ok := r.workspaceManager.(LazyWorkspaceManager); ok {
+		return lazy.OpenWorkspace(ctx, event)
+	}
+	return r.workspaceManager.CreateWorkspace(ctx, event)
+}
+
+// fileClassifier detects generated and vendored files using the .gitattributes of the base branch,
+// which the pull request cannot change. Headers are read from the checkout, or from the diff for a
+// workspace that is not checked out.
+func (r *DefaultReviewOrchestrator) fileClassifier(ctx context.Context, workspace *Workspace) *analyzer.FileClassifier {
+	if workspace == nil {
+		return analyzer.NewFileClassifier("", nil)
+	}
+	attributes := r.readBaseAttributes(ctx, workspace)
+	if !workspace.CheckedOut() && workspace.files != nil {
+		return analyzer.NewFileClassifierFromAttributes(attributes)
+	}
+
+	repoPath, err := workspace.Checkout(ctx)
+	if err != nil {
+		log.Printf("Warning: classifying files from the diff only: %v", err)
+	}
+	return analyzer.NewFileClassifier(repoPath, attributes)
+}
+
+// analyzeDiff analyzes the fetched diff and extracts context.
+// Files outside the configured path filters are dropped first and returned as excluded.
+// Files are not triaged against the token budget until their context is complete.
+func (r *DefaultReviewOrchestrator) analyzeDiff(ctx context.Context, diffResult *github.DiffResult, settings ReviewSettings, workspace *Workspace) (*analyzer.ContextualDiff, []SkippedFile, error) {
 	if r.codeAnalyzer == nil {
-		return nil, fmt.Errorf("code analyzer not configured")
+		return nil, nil, fmt.Errorf("code analyzer not configured")
 	}
 
 	// Validate input parameters
 	if diffResult == nil {
-		return nil, fmt.Errorf("diff result cannot be nil")
+		return nil, nil, fmt.Errorf("diff result cannot be nil")
 	}
 	if diffResult.RawDiff == "" {
-		return nil, fmt.Errorf("raw diff cannot be empty")
+		return nil, nil, fmt.Errorf("raw diff cannot be empty")
 	}
 
 	// Parse the raw diff
 	parsedDiff, err := r.codeAnalyzer.ParseDiff(diffResult.RawDiff)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse diff: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse diff: %w", err)
 	}
 
-	// Extract context (5 lines as mentioned in CLAUDE.md)
-	contextualDiff, err := r.codeAnalyzer.ExtractContext(parsedDiff, 5)
+	// Drop files outside the configured globs and generated or vendored files
+	parsedDiff, skipped := reviewableDiff(parsedDiff, settings, r.fileClassifier(ctx, workspace))
+	if len(skipped) > 0 {
+		log.Printf("Excluded %d files from review", len(skipped))
+	}
+
+	// Extract context (5 lines by default, as mentioned in CLAUDE.md)
+	contextualDiff, err := r.codeAnalyzer.ExtractContext(parsedDiff, settings.ContextLines)
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract context: %w", err)
+		return nil, skipped, fmt.Errorf("failed to extract context: %w", err)
 	}
 
-	return contextualDiff, nil
+	return contextualDiff, skipped, nil
+}
+
+// triageFiles orders files riskiest first and leaves out whatever does not fit in the token budget
+func triageFiles(contextualDiff *analyzer.ContextualDiff, settings ReviewSettings) []SkippedFile {
+	if contextualDiff == nil || len(contextualDiff.FilesWithContext) == 0 {
+		return nil
+	}
+
+	var overBudget []analyzer.FileRisk
+	contextualDiff.FilesWithContext, overBudget = analyzer.TriageFiles(contextualDiff.FilesWithContext, settings.TokenBudget)
+	if len(overBudget) > 0 {
+		log.Printf("Token budget of %d reached, leaving %d lower-risk files unreviewed", settings.TokenBudget, len(overBudget))
+	}
+
+	var skipped []SkippedFile
+	for _, risk := range overBudget {
+		skipped = append(skipped, SkippedFile{Filename: risk.Filename, Reason: SkipReasonTokenBudget})
+	}
+	return skipped
 }
 
 // performDeletionAnalysis analyzes code deletions for orphaned references
-func (r *DefaultReviewOrchestrator) performDeletionAnalysis(ctx context.Context, reviewData *ReviewData) error {
+func (r *DefaultReviewOrchestrator) performDeletionAnalysis(ctx context.Context, reviewData *ReviewData, settings ReviewSettings) error {
 	if r.codebaseFlattener == nil || r.deletionAnalyzer == nil {
 		return fmt.Errorf("deletion analysis components not configured")
 	}
@@ -361,15 +724,13 @@ func (r *DefaultReviewOrchestrator) performDeletionAnalysis(ctx context.Context,
 	if reviewData.Workspace == nil {
 		return fmt.Errorf("workspace cannot be nil")
 	}
-	if reviewData.Workspace.Path == "" {
-		return fmt.Errorf("workspace path cannot be empty")
-	}
 
 	// Parse the diff to extract deleted content
 	parsedDiff, err := r.codeAnalyzer.ParseDiff(reviewData.DiffResult.RawDiff)
 	if err != nil {
 		return fmt.Errorf("failed to parse diff for deletion analysis: %w", err)
 	}
+	parsedDiff, _ = reviewableDiff(parsedDiff, settings, r.fileClassifier(ctx, reviewData.Workspace))
 
 	// Extract deleted content from the diff
 	deletedContent := extractDeletedContent(parsedDiff)
@@ -383,8 +744,17 @@ func (r *DefaultReviewOrchestrator) performDeletionAnalysis(ctx context.Context,
 	log.Printf("Found %d code deletions in PR #%d, performing safety analysis",
 		len(deletedContent), reviewData.Event.Number)
 
+	// Finding references needs the whole repository, so this is where a lazy workspace is cloned
+	repoPath, err := reviewData.Workspace.Checkout(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check out repository: %w", err)
+	}
+
+	// Parse the changed files so exactly the removed declarations are checked for references
+	r.annotateDeletedDeclarations(ctx, reviewData.Event, reviewData.Workspace, repoPath, parsedDiff, deletedContent)
+
 	// Flatten the codebase for AI analysis
-	flattenedCodebase, err := r.codebaseFlattener.FlattenWorkspace(reviewData.Workspace.Path)
+	flattenedCodebase, err := r.workspaceCodebase(reviewData, repoPath)
 	if err != nil {
 		return fmt.Errorf("failed to flatten codebase: %w", err)
 	}
@@ -406,7 +776,6 @@ func (r *DefaultReviewOrchestrator) performDeletionAnalysis(ctx context.Context,
 	}
 
 	// Store results in review data
-	reviewData.FlattenedCodebase = flattenedCodebase
 	reviewData.DeletionAnalysis = deletionResult
 
 	// Log results
@@ -419,8 +788,70 @@ func (r *DefaultReviewOrchestrator) performDeletionAnalysis(ctx context.Context,
 	return nil
 }
 
+// expandContext adds the enclosing function of each change block, and the signatures of the
+// functions it calls, from the head revision
+func (r *DefaultReviewOrchestrator) expandContext(ctx context.Context, reviewData *ReviewData, settings ReviewSettings) error {
+	if reviewData.Workspace == nil {
+		return fmt.Errorf("workspace cannot be nil")
+	}
+	index, err := r.contextIndex(ctx, reviewData)
+	if err != nil {
+		return err
+	}
+	analyzer.ExpandContext(reviewData.ContextualDiff, index, settings.EnclosingContextLines)
+	return nil
+}
+
+// contextIndex returns the symbol index context is expanded from. A codebase that is already
+// checked out is flattened, so callees are found across the repository. Otherwise only the changed
+// files are read and callees are limited to them, so a lazy workspace is not cloned for this.
+func (r *DefaultReviewOrchestrator) contextIndex(ctx context.Context, reviewData *ReviewData) (*analyzer.SymbolIndex, error) {
+	if reviewData.FlattenedCodebase != nil {
+		return reviewData.FlattenedCodebase.SymbolIndex(), nil
+	}
+	if reviewData.Workspace.CheckedOut() && r.codebaseFlattener != nil {
+		codebase, err := r.workspaceCodebase(reviewData, reviewData.Workspace.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to flatten codebase: %w", err)
+		}
+		return codebase.SymbolIndex(), nil
+	}
+
+	var files []analyzer.FileContent
+	for _, file := range reviewData.ContextualDiff.FilesWithContext {
+		if file.Status == "deleted" || file.Binary {
+			continue
+		}
+		content, err := reviewData.Workspace.ReadFile(ctx, file.Filename)
+		if err != nil {
+			log.Printf("Warning: failed to read %s for context expansion: %v", file.Filename, err)
+			continue
+		}
+		files = append(files, analyzer.FileContent{
+			RelativePath: file.Filename,
+			Language:     file.Language,
+			Content:      string(content),
+		})
+	}
+	return analyzer.NewSymbolIndex(files), nil
+}
+
+// workspaceCodebase flattens the checked-out workspace once per review, so every analysis of it
+// shares one codebase and symbol index
+func (r *DefaultReviewOrchestrator) workspaceCodebase(reviewData *ReviewData, repoPath string) (*analyzer.FlattenedCodebase, error) {
+	if reviewData.FlattenedCodebase != nil {
+		return reviewData.FlattenedCodebase, nil
+	}
+	codebase, err := r.codebaseFlattener.FlattenWorkspace(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	reviewData.FlattenedCodebase = codebase
+	return codebase, nil
+}
+
 // performLLMReview sends the review data to the LLM for analysis
-func (r *DefaultReviewOrchestrator) performLLMReview(ctx context.Context, reviewData *ReviewData) (*llm.ReviewResponse, error) {
+func (r *DefaultReviewOrchestrator) performLLMReview(ctx context.Context, reviewData *ReviewData, settings ReviewSettings) (*llm.ReviewResponse, error) {
 	if r.llmClient == nil {
 		return nil, fmt.Errorf("LLM client not configured")
 	}
@@ -436,29 +867,59 @@ func (r *DefaultReviewOrchestrator) performLLMReview(ctx context.Context, review
 		return nil, fmt.Errorf("pull request data is invalid (missing ID)")
 	}
 
-	// Create LLM review request
-	request := &llm.ReviewRequest{
-		PullRequestInfo: llm.PullRequestInfo{
-			Number:      reviewData.Event.Number,
-			Title:       reviewData.Event.PullRequest.Title,
-			Author:      reviewData.Event.PullRequest.User.Login,
-			Description: "", // Could be added to event structure if needed
-			BaseBranch:  reviewData.Event.PullRequest.Base.Ref,
-			HeadBranch:  reviewData.Event.PullRequest.Head.Ref,
-		},
-		DiffResult:     reviewData.DiffResult,
-		ContextualDiff: reviewData.ContextualDiff,
-		ReviewType:     llm.ReviewTypeGeneral, // Default to general review
-		Instructions:   "",                    // Could be customizable
-	}
-
-	// Perform the review
-	response, err := r.llmClient.ReviewCode(ctx, request)
-	if err != nil {
-		return nil, fmt.Errorf("LLM review failed: %w", err)
+	// Run one review pass per configured review type
+	var responses []*llm.ReviewResponse
+	var spent float64
+	for _, request := range r.reviewRequests(ctx, reviewData, settings) {
+		if settings.MaxCostUSD > 0 {
+			request.MaxCostUSD = settings.MaxCostUSD - spent
+		}
+
+		response, err := r.llmClient.ReviewCode(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("LLM %s review failed: %w", request.ReviewType, err)
+		}
+		for i := range response.Comments {
+			response.Comments[i].ReviewType = request.ReviewType
+		}
+		spent += response.CostUSD
+		responses = append(responses, response)
+	}
+
+	return mergeReviewResponses(responses), nil
+}
+
+// reviewRequests builds one LLM request per configured review type
+func (r *DefaultReviewOrchestrator) reviewRequests(ctx context.Context, reviewData *ReviewData, settings ReviewSettings) []*llm.ReviewRequest {
+	reviewTypes := settings.ReviewTypes
+	if len(reviewTypes) == 0 {
+		reviewTypes = []llm.ReviewType{llm.ReviewTypeGeneral}
+	}
+
+	// The stated intent lets the model flag changes that do not match the description
+	pullRequestInfo := r.pullRequestInfo(ctx, reviewData)
+
+	requests := make([]*llm.ReviewRequest, 0, len(reviewTypes))
+	for _, reviewType := range reviewTypes {
+		requests = append(requests, &llm.ReviewRequest{
+			PullRequestInfo: pullRequestInfo,
+			DiffResult:      reviewData.DiffResult,
+			ContextualDiff:  reviewData.ContextualDiff,
+			ReviewType:      reviewType,
+			Instructions:    settings.Instructions,
+			Model:           settings.Model,
+		})
 	}
+	return requests
+}
 
-	return response, nil
+// pullRequestInfo returns the pull request details sent to the LLM, fetching them once per review
+func (r *DefaultReviewOrchestrator) pullRequestInfo(ctx context.Context, reviewData *ReviewData) llm.PullRequestInfo {
+	if reviewData.PullRequestInfo == nil {
+		info := r.buildPullRequestInfo(ctx, reviewData.Event)
+		reviewData.PullRequestInfo = &info
+	}
+	return *reviewData.PullRequestInfo
 }
 
 // logReviewResults logs the LLM review results
@@ -472,6 +933,9 @@ func (r *DefaultReviewOrchestrator) logReviewResults(response *llm.ReviewRespons
 		response.TokensUsed.TotalTokens,
 		response.TokensUsed.InputTokens,
 		response.TokensUsed.OutputTokens)
+	if response.CachedFiles > 0 {
+		log.Printf("Reused cached review comments for %d file reviews", response.CachedFiles)
+	}
 
 	for i, comment := range response.Comments {
 		log.Printf("Comment %d: %s:%d - %s (%s)",
@@ -483,16 +947,18 @@ func (r *DefaultReviewOrchestrator) logReviewResults(response *llm.ReviewRespons
 	}
 }
 
-// postReviewComments posts LLM-generated comments to the GitHub PR and returns the count of successfully posted comments
-func (r *DefaultReviewOrchestrator) postReviewComments(ctx context.Context, reviewData *ReviewData, reviewResponse *llm.ReviewResponse) (int, error) {
+// postReviewComments posts LLM-generated comments to the GitHub PR and returns the outcome of every comment
+func (r *DefaultReviewOrchestrator) postReviewComments(ctx context.Context, reviewData *ReviewData, reviewResponse *llm.ReviewResponse) ([]CommentOutcome, error) {
 	if r.githubClient == nil {
-		return 0, fmt.Errorf("GitHub client not configured")
+		return nil, fmt.Errorf("GitHub client not configured")
 	}
 
 	// Get the commit SHA from the PR head
 	commitID := reviewData.Event.PullRequest.Head.SHA
 
-	// Convert LLM comments to GitHub format
+	// Convert LLM comments to GitHub format, remembering which outcome each request belongs to
+	outcomes := make([]CommentOutcome, 0, len(reviewResponse.Comments))
+	pending := make(map[string][]int)
 	var githubComments []github.CreatePullRequestCommentRequest
 	for _, llmComment := range reviewResponse.Comments {
 		// Convert using the GitHub package conversion function
@@ -500,20 +966,30 @@ func (r *DefaultReviewOrchestrator) postReviewComments(ctx context.Context, revi
 			Filename:   llmComment.Filename,
 			LineNumber: llmComment.LineNumber,
 			Comment:    llmComment.Comment,
+			Suggestion: llmComment.Suggestion,
+		}
+		if start, end, ok := anchorSuggestion(reviewData.ContextualDiff, llmComment); ok {
+			commentInput.StartLine, commentInput.LineNumber, commentInput.Anchored = start, end, true
 		}
 
 		githubComment, shouldPost := github.ConvertReviewCommentToGitHub(commentInput, commitID)
 		if shouldPost {
+			githubComment.Body = withReviewCommentMarker(githubComment.Body)
+			key := commentKey(githubComment.Path, githubComment.Line, githubComment.Body)
+			pending[key] = append(pending[key], len(outcomes))
+			outcomes = append(outcomes, newCommentOutcome(llmComment, CommentStatusPosted, ""))
 			githubComments = append(githubComments, githubComment)
 		} else {
 			log.Printf("Skipping comment for %s (line %d): not suitable for line-specific posting",
 				llmComment.Filename, llmComment.LineNumber)
+			outcomes = append(outcomes, newCommentOutcome(llmComment, CommentStatusSkipped,
+				"not suitable for line-specific posting"))
 		}
 	}
 
 	if len(githubComments) == 0 {
 		log.Printf("No valid line-specific comments to post for PR #%d", reviewData.Event.Number)
-		return 0, nil
+		return outcomes, nil
 	}
 
 	// Post comments in batch
@@ -525,7 +1001,13 @@ func (r *DefaultReviewOrchestrator) postReviewComments(ctx context.Context, revi
 		githubComments,
 	)
 	if err != nil {
-		return 0, fmt.Errorf("failed to post comments: %w", err)
+		for _, indexes := range pending {
+			for _, idx := range indexes {
+				outcomes[idx].Status = CommentStatusFailed
+				outcomes[idx].Reason = err.Error()
+			}
+		}
+		return outcomes, fmt.Errorf("failed to post comments: %w", err)
 	}
 
 	// Log results
@@ -534,13 +1016,131 @@ func (r *DefaultReviewOrchestrator) postReviewComments(ctx context.Context, revi
 		len(result.FailedComments),
 		reviewData.Event.Number)
 
-	// Log any failed comments
+	// Log any failed comments and record why they failed
 	for _, failed := range result.FailedComments {
 		log.Printf("Failed to post comment for %s:%d - %s",
 			failed.Request.Path, failed.Request.Line, failed.Error)
+
+		key := commentKey(failed.Request.Path, failed.Request.Line, failed.Request.Body)
+		if indexes := pending[key]; len(indexes) > 0 {
+			outcomes[indexes[0]].Status = CommentStatusFailed
+			outcomes[indexes[0]].Reason = failed.Error
+			pending[key] = indexes[1:]
+		}
+	}
+
+	// Attach links to the comments that were posted
+	for _, posted := range result.SuccessfulComments {
+		key := commentKey(posted.Path, posted.Line, posted.Body)
+		if indexes := pending[key]; len(indexes) > 0 {
+			outcomes[indexes[0]].URL = posted.HTMLURL
+			outcomes[indexes[0]].CommentID = posted.ID
+			pending[key] = indexes[1:]
+		}
+	}
+
+	return outcomes, nil
+}
+
+// anchorSuggestion returns the line range a comment's suggestion replaces when it lies inside
+// one hunk of the reviewed diff. The range defaults to the comment's line.
+func anchorSuggestion(diff *analyzer.ContextualDiff, comment llm.ReviewComment) (int, int, bool) {
+	if diff == nil || diff.ParsedDiff == nil || strings.TrimSpace(comment.Suggestion) == "" {
+		return 0, 0, false
 	}
 
-	return len(result.SuccessfulComments), nil
+	start, end := suggestionRange(comment)
+	for _, file := range diff.Files {
+		if file.Filename == comment.Filename && file.HunkSpanning(start, end) != nil {
+			return start, end, true
+		}
+	}
+	return 0, 0, false
+}
+
+// suggestionRange returns the lines a comment's suggestion replaces, defaulting to the comment's line
+func suggestionRange(comment llm.ReviewComment) (int, int) {
+	if comment.LineRange != nil {
+		return comment.LineRange.Start, comment.LineRange.End
+	}
+	return comment.LineNumber, comment.LineNumber
+}
+
+// commentKey identifies a line comment by its location and body
+func commentKey(path string, line int, body string) string {
+	return fmt.Sprintf("%s:%d:%s", path, line, body)
+}
+
+// annotateDeletedDeclarations sets the declarations each deleted section removed, for files in
+// languages with a declaration extractor. The base revision is read at the base commit and the head
+// revision from the checkout; files that cannot be read keep the heuristic extraction.
+func (r *DefaultReviewOrchestrator) annotateDeletedDeclarations(ctx context.Context, event *PullRequestEvent, workspace *Workspace, repoPath string, parsedDiff *analyzer.ParsedDiff, deletedContent []analyzer.DeletedCode) {
+	if r.baseFileReader == nil && (workspace == nil || workspace.contents == nil) {
+		log.Printf("Warning: no base file reader or repository content reader is configured, deleted declarations are found heuristically")
+		return
+	}
+
+	for _, file := range parsedDiff.Files {
+		if !analyzer.HasDeclarationExtractor(file.Language) || !hasRemovedLines(file) {
+			continue
+		}
+
+		basePath := file.Filename
+		if file.OldFilename != "" {
+			basePath = file.OldFilename
+		}
+		base, err := r.readBaseFile(ctx, event, workspace, repoPath, basePath)
+		if err != nil {
+			log.Printf("Warning: failed to read %s at the base commit: %v", basePath, err)
+			continue
+		}
+
+		var head []byte
+		if file.Status != "deleted" {
+			if head, err = workspace.ReadFile(ctx, file.Filename); err != nil {
+				log.Printf("Warning: failed to read %s at the head commit: %v", file.Filename, err)
+				continue
+			}
+		}
+
+		declarations, ok := analyzer.DeletedDeclarations(file, base, head)
+		if !ok {
+			continue
+		}
+		for i := range deletedContent {
+			deleted := &deletedContent[i]
+			if deleted.File != file.Filename {
+				continue
+			}
+			deleted.Entities = []analyzer.Declaration{}
+			for _, declaration := range declarations {
+				if declaration.Line >= deleted.StartLine && declaration.Line <= deleted.EndLine {
+					deleted.Entities = append(deleted.Entities, declaration)
+				}
+			}
+		}
+	}
+}
+
+// hasRemovedLines reports whether a file diff removes any lines
+func hasRemovedLines(file analyzer.FileDiff) bool {
+	for _, hunk := range file.Hunks {
+		for _, line := range hunk.Lines {
+			if line.Type == "removed" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readBaseFile reads a file at the base commit of the pull request: from the checkout through the
+// base file reader when one is set, through the workspace's content reader otherwise
+func (r *DefaultReviewOrchestrator) readBaseFile(ctx context.Context, event *PullRequestEvent, workspace *Workspace, repoPath, path string) ([]byte, error) {
+	if r.baseFileReader != nil {
+		return r.baseFileReader.ReadFileAtRef(ctx, repoPath, event.PullRequest.Base.SHA, path)
+	}
+	return workspace.contents.GetFileContent(ctx, event.Repository.Owner.Login, event.Repository.Name, path, event.PullRequest.Base.SHA)
 }
 
 // extractDeletedContent extracts deleted code from a parsed diff
@@ -548,6 +1148,10 @@ func extractDeletedContent(parsedDiff *analyzer.ParsedDiff) []analyzer.DeletedCo
 	var deletedContent []analyzer.DeletedCode
 
 	for _, file := range parsedDiff.Files {
+		if file.Status == "copied" {
+			// A copy leaves its source in place, so the lines it drops are not deleted from the codebase
+			continue
+		}
 		if file.Status == "deleted" {
 			// Entire file was deleted
 			var content strings.Builder
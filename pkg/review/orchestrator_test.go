@@ -3,11 +3,13 @@ package review
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/GDSources/claude-code-review-agent/pkg/analyzer"
 	"github.com/GDSources/claude-code-review-agent/pkg/github"
+	"github.com/GDSources/claude-code-review-agent/pkg/history"
 	"github.com/GDSources/claude-code-review-agent/pkg/llm"
 )
 
@@ -69,6 +71,7 @@ func createTestPullRequestEvent() *PullRequestEvent {
 			Name:     "test-repo",
 			FullName: "company/test-repo",
 			Private:  false,
+			HTMLURL:  "https://github.com/company/test-repo",
 			Owner: User{
 				ID:    2002,
 				Login: "company",
@@ -235,6 +238,7 @@ type mockCodeAnalyzer struct {
 	contextualDiff *analyzer.ContextualDiff
 	shouldFail     bool
 	error          error
+	contextLines   int
 }
 
 func (m *mockCodeAnalyzer) ParseDiff(rawDiff string) (*analyzer.ParsedDiff, error) {
@@ -245,6 +249,7 @@ func (m *mockCodeAnalyzer) ParseDiff(rawDiff string) (*analyzer.ParsedDiff, erro
 }
 
 func (m *mockCodeAnalyzer) ExtractContext(parsedDiff *analyzer.ParsedDiff, contextLines int) (*analyzer.ContextualDiff, error) {
+	m.contextLines = contextLines
 	if m.shouldFail {
 		return nil, m.error
 	}
@@ -411,9 +416,11 @@ type mockLLMClientWithComments struct {
 	reviewResponse *llm.ReviewResponse
 	shouldFail     bool
 	error          error
+	requests       []*llm.ReviewRequest
 }
 
 func (m *mockLLMClientWithComments) ReviewCode(ctx context.Context, request *llm.ReviewRequest) (*llm.ReviewResponse, error) {
+	m.requests = append(m.requests, request)
 	if m.shouldFail {
 		return nil, m.error
 	}
@@ -515,8 +522,8 @@ func TestDefaultReviewOrchestrator_PostComments_Success(t *testing.T) {
 
 	// Verify comment content
 	firstCall := mockGitHub.createCommentCalls[0]
-	if firstCall.comment.Body != "Consider adding error handling" {
-		t.Errorf("expected first comment body 'Consider adding error handling', got '%s'", firstCall.comment.Body)
+	if firstCall.comment.Body != withReviewCommentMarker("Consider adding error handling") {
+		t.Errorf("expected first comment body 'Consider adding error handling' with marker, got '%s'", firstCall.comment.Body)
 	}
 	if firstCall.comment.Path != "main.go" {
 		t.Errorf("expected first comment path 'main.go', got '%s'", firstCall.comment.Path)
@@ -526,6 +533,71 @@ func TestDefaultReviewOrchestrator_PostComments_Success(t *testing.T) {
 	}
 }
 
+func TestDefaultReviewOrchestrator_PostComments_Suggestions(t *testing.T) {
+	mockLLM := &mockLLMClientWithComments{
+		reviewResponse: &llm.ReviewResponse{
+			Comments: []llm.ReviewComment{
+				{
+					Filename:   "main.go",
+					LineNumber: 12,
+					LineRange:  &llm.LineRange{Start: 11, End: 13},
+					Comment:    "Check the error before using the file",
+					Severity:   llm.SeverityMajor,
+					Suggestion: "f, err := os.Open(name)\nif err != nil {\n\treturn err",
+				},
+				{
+					Filename:   "main.go",
+					LineNumber: 30,
+					Comment:    "Use a constant",
+					Severity:   llm.SeverityMinor,
+					Suggestion: "timeout := defaultTimeout",
+				},
+			},
+		},
+	}
+	parsedDiff := &analyzer.ParsedDiff{
+		Files:      []analyzer.FileDiff{{Filename: "main.go", Hunks: []analyzer.DiffHunk{{NewStart: 10, NewCount: 5}}}},
+		TotalFiles: 1,
+	}
+	mockCA := &mockCodeAnalyzer{
+		parsedDiff:     parsedDiff,
+		contextualDiff: &analyzer.ContextualDiff{ParsedDiff: parsedDiff},
+	}
+	mockGitHub := &mockGitHubCommentClient{}
+
+	orchestrator := &DefaultReviewOrchestrator{
+		workspaceManager: &mockWorkspaceManager{},
+		diffFetcher:      &mockDiffFetcher{diffResult: &github.DiffResult{RawDiff: "test diff", TotalFiles: 1}},
+		codeAnalyzer:     mockCA,
+		llmClient:        mockLLM,
+		githubClient:     mockGitHub,
+	}
+
+	result, err := orchestrator.HandlePullRequest(createTestPullRequestEvent())
+	if err != nil {
+		t.Fatalf("HandlePullRequest failed: %v", err)
+	}
+	if result.CommentsPosted != 2 || len(mockGitHub.createCommentCalls) != 2 {
+		t.Fatalf("expected 2 comments posted, got %d", len(mockGitHub.createCommentCalls))
+	}
+
+	anchored := mockGitHub.createCommentCalls[0].comment
+	if anchored.StartLine != 11 || anchored.Line != 13 || anchored.StartSide != "RIGHT" {
+		t.Errorf("expected suggestion anchored to lines 11-13, got start %d line %d", anchored.StartLine, anchored.Line)
+	}
+	if !strings.Contains(anchored.Body, "```suggestion\nf, err := os.Open(name)\nif err != nil {\n\treturn err\n```") {
+		t.Errorf("expected suggestion block in body, got:\n%s", anchored.Body)
+	}
+
+	fallback := mockGitHub.createCommentCalls[1].comment
+	if fallback.StartLine != 0 || fallback.Line != 30 {
+		t.Errorf("expected unanchored comment on line 30 only, got start %d line %d", fallback.StartLine, fallback.Line)
+	}
+	if strings.Contains(fallback.Body, "```suggestion") || !strings.Contains(fallback.Body, "```\ntimeout := defaultTimeout\n```") {
+		t.Errorf("expected plain code block for unanchored suggestion, got:\n%s", fallback.Body)
+	}
+}
+
 func TestDefaultReviewOrchestrator_PostComments_Failure(t *testing.T) {
 	// Create mock LLM client with review comments
 	mockLLM := &mockLLMClientWithComments{
@@ -588,6 +660,167 @@ func TestDefaultReviewOrchestrator_PostComments_Failure(t *testing.T) {
 	if len(mockGitHub.createCommentCalls) != 1 {
 		t.Errorf("expected 1 comment creation attempt, got %d", len(mockGitHub.createCommentCalls))
 	}
+
+	// Verify the failed comment is reported with its reason
+	if len(result.Comments) != 1 {
+		t.Fatalf("expected 1 comment outcome, got %d", len(result.Comments))
+	}
+	if result.Comments[0].Status != CommentStatusFailed {
+		t.Errorf("expected comment status '%s', got '%s'", CommentStatusFailed, result.Comments[0].Status)
+	}
+	if !strings.Contains(result.Comments[0].Reason, "GitHub API error") {
+		t.Errorf("expected failure reason to mention API error, got '%s'", result.Comments[0].Reason)
+	}
+}
+
+func TestDefaultReviewOrchestrator_ResultDetails(t *testing.T) {
+	mockLLM := &mockLLMClientWithComments{
+		reviewResponse: &llm.ReviewResponse{
+			Comments: []llm.ReviewComment{
+				{Filename: "main.go", LineNumber: 15, Comment: "Nil dereference", Severity: llm.SeverityCritical},
+				{Filename: "main.go", LineNumber: 0, Comment: "File-level remark", Severity: llm.SeverityMinor},
+			},
+			ModelUsed:  "test-model",
+			TokensUsed: llm.TokenUsage{InputTokens: 80, OutputTokens: 20, TotalTokens: 100},
+		},
+	}
+
+	orchestrator := &DefaultReviewOrchestrator{
+		workspaceManager: &mockWorkspaceManager{},
+		diffFetcher: &mockDiffFetcher{
+			diffResult: &github.DiffResult{RawDiff: "test diff", TotalFiles: 1},
+		},
+		codeAnalyzer: &mockCodeAnalyzer{
+			contextualDiff: &analyzer.ContextualDiff{
+				ParsedDiff: &analyzer.ParsedDiff{TotalFiles: 1},
+			},
+		},
+		llmClient:    mockLLM,
+		githubClient: &mockGitHubCommentClient{},
+	}
+
+	result, err := orchestrator.HandlePullRequest(createTestPullRequestEvent())
+	if err != nil {
+		t.Fatalf("HandlePullRequest failed: %v", err)
+	}
+
+	if result.Repository != "company/test-repo" || result.PullRequest != 42 {
+		t.Errorf("unexpected repository/PR: %s #%d", result.Repository, result.PullRequest)
+	}
+	if result.BaseSHA != "def456" || result.HeadSHA != "abc123" {
+		t.Errorf("unexpected SHA range: %s..%s", result.BaseSHA, result.HeadSHA)
+	}
+	if result.Model != "test-model" {
+		t.Errorf("expected model 'test-model', got '%s'", result.Model)
+	}
+	if result.TokensUsed.TotalTokens != 100 {
+		t.Errorf("expected 100 tokens, got %d", result.TokensUsed.TotalTokens)
+	}
+	if result.HighestSeverity != string(llm.SeverityCritical) {
+		t.Errorf("expected highest severity 'critical', got '%s'", result.HighestSeverity)
+	}
+	if result.CommentsPosted != 1 {
+		t.Errorf("expected 1 comment posted, got %d", result.CommentsPosted)
+	}
+	if got := result.CountComments(CommentStatusSkipped); got != 1 {
+		t.Errorf("expected 1 skipped comment, got %d", got)
+	}
+
+	stages := make(map[string]bool)
+	for _, stage := range result.Stages {
+		stages[stage.Stage] = true
+	}
+	for _, expected := range []string{StageWorkspace, StageDiffAnalysis, StageLLMReview, StageCommentPosting} {
+		if !stages[expected] {
+			t.Errorf("expected timing for stage '%s'", expected)
+		}
+	}
+}
+
+type mockHistoryStore struct {
+	records []*history.Record
+	saveErr error
+}
+
+func (m *mockHistoryStore) Save(record *history.Record) error {
+	if m.saveErr != nil {
+		return m.saveErr
+	}
+	m.records = append(m.records, record)
+	return nil
+}
+
+func TestDefaultReviewOrchestrator_RecordsHistory(t *testing.T) {
+	mockLLM := &mockLLMClientWithComments{
+		reviewResponse: &llm.ReviewResponse{
+			Comments: []llm.ReviewComment{
+				{Filename: "main.go", LineNumber: 15, Comment: "Nil dereference", Severity: llm.SeverityMajor},
+			},
+			ModelUsed:  "test-model",
+			PromptHash: "hash123",
+			TokensUsed: llm.TokenUsage{InputTokens: 80, OutputTokens: 20, TotalTokens: 100},
+		},
+	}
+
+	store := &mockHistoryStore{}
+	orchestrator := &DefaultReviewOrchestrator{
+		workspaceManager: &mockWorkspaceManager{},
+		diffFetcher: &mockDiffFetcher{
+			diffResult: &github.DiffResult{RawDiff: "test diff", TotalFiles: 1},
+		},
+		codeAnalyzer: &mockCodeAnalyzer{
+			contextualDiff: &analyzer.ContextualDiff{
+				ParsedDiff: &analyzer.ParsedDiff{TotalFiles: 1},
+			},
+		},
+		llmClient:    mockLLM,
+		githubClient: &mockGitHubCommentClient{},
+	}
+	orchestrator.SetHistoryStore(store)
+
+	if _, err := orchestrator.HandlePullRequest(createTestPullRequestEvent()); err != nil {
+		t.Fatalf("HandlePullRequest failed: %v", err)
+	}
+
+	if len(store.records) != 1 {
+		t.Fatalf("expected 1 history record, got %d", len(store.records))
+	}
+
+	record := store.records[0]
+	if record.Repository != "company/test-repo" || record.PullRequest != 42 || record.HeadSHA != "abc123" {
+		t.Errorf("unexpected record identity: %s #%d @ %s", record.Repository, record.PullRequest, record.HeadSHA)
+	}
+	if record.Model != "test-model" || record.PromptHash != "hash123" {
+		t.Errorf("unexpected model/prompt hash: %s/%s", record.Model, record.PromptHash)
+	}
+	if record.Tokens.Total != 100 {
+		t.Errorf("expected 100 tokens, got %d", record.Tokens.Total)
+	}
+	if len(record.Comments) != 1 || record.Comments[0].Status != CommentStatusPosted {
+		t.Errorf("expected 1 posted comment in record, got %+v", record.Comments)
+	}
+	if record.DurationMs < 0 || record.StartedAt.IsZero() {
+		t.Errorf("expected timing information in record")
+	}
+}
+
+func TestDefaultReviewOrchestrator_HistorySaveFailureIsNonFatal(t *testing.T) {
+	orchestrator := &DefaultReviewOrchestrator{
+		workspaceManager: &mockWorkspaceManager{},
+		diffFetcher: &mockDiffFetcher{
+			diffResult: &github.DiffResult{RawDiff: "test diff", TotalFiles: 1},
+		},
+		codeAnalyzer: &mockCodeAnalyzer{
+			contextualDiff: &analyzer.ContextualDiff{
+				ParsedDiff: &analyzer.ParsedDiff{TotalFiles: 1},
+			},
+		},
+	}
+	orchestrator.SetHistoryStore(&mockHistoryStore{saveErr: fmt.Errorf("disk full")})
+
+	if _, err := orchestrator.HandlePullRequest(createTestPullRequestEvent()); err != nil {
+		t.Errorf("history failures should not fail the review, got: %v", err)
+	}
 }
 
 func TestDefaultReviewOrchestrator_WithoutGitHubClient(t *testing.T) {
@@ -933,3 +1166,87 @@ func TestDefaultReviewOrchestrator_ProgressComments_GitHubFailure(t *testing.T)
 		t.Error("expected attempt to create progress comment")
 	}
 }
+
+func TestDefaultReviewOrchestrator_ExpandContext(t *testing.T) {
+	orchestrator := &DefaultReviewOrchestrator{codebaseFlattener: &mockCodebaseFlattener{}}
+	reviewData := &ReviewData{
+		Event:     &PullRequestEvent{Number: 1},
+		Workspace: &Workspace{Path: "/tmp/test-workspace/repo"},
+		ContextualDiff: &analyzer.ContextualDiff{
+			FilesWithContext: []analyzer.FileWithContext{{
+				FileDiff: analyzer.FileDiff{Filename: "main.go", Language: "go"},
+				ContextBlocks: []analyzer.ContextBlock{{
+					Lines: []analyzer.DiffLine{{Type: "added", Content: "\tSafeFunction() // This is safe", NewLineNo: 9}},
+				}},
+			}},
+		},
+	}
+
+	settings := DefaultReviewSettings()
+	if err := orchestrator.expandContext(context.Background(), reviewData, settings); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	enclosing := reviewData.ContextualDiff.FilesWithContext[0].ContextBlocks[0].Enclosing
+	if enclosing == nil || enclosing.Declaration.Name != "main" || enclosing.StartLine != 5 || enclosing.EndLine != 10 {
+		t.Fatalf("expected the enclosing main function, got %+v", enclosing)
+	}
+	if len(enclosing.Callees) != 1 || enclosing.Callees[0].Signature != "func SafeFunction()" {
+		t.Errorf("expected SafeFunction to be the only callee defined in the repository, got %+v", enclosing.Callees)
+	}
+	if reviewData.FlattenedCodebase == nil {
+		t.Error("expected the flattened codebase to be kept for the other analyses")
+	}
+
+	// Without a workspace there is nothing to expand from
+	reviewData.Workspace = nil
+	if err := orchestrator.expandContext(context.Background(), reviewData, settings); err == nil {
+		t.Error("expected an error without a workspace")
+	}
+}
+
+func TestDefaultReviewOrchestrator_ExpandContext_LazyWorkspace(t *testing.T) {
+	event := createTestPullRequestEvent()
+	contents := &mockContentReader{files: map[string]string{
+		"head456:main.go": "package main\n\nfunc main() {\n\thelper()\n}\n\nfunc helper() {}\n",
+	}}
+	workspace := &Workspace{
+		Repository:  &event.Repository,
+		PullRequest: &event.PullRequest,
+		files:       NewGitHubFileProvider(contents, event.Repository.Owner.Login, event.Repository.Name, "head456"),
+	}
+	orchestrator := &DefaultReviewOrchestrator{codebaseFlattener: &mockCodebaseFlattener{}}
+	reviewData := &ReviewData{
+		Event:     event,
+		Workspace: workspace,
+		ContextualDiff: &analyzer.ContextualDiff{
+			FilesWithContext: []analyzer.FileWithContext{
+				{
+					FileDiff: analyzer.FileDiff{Filename: "main.go", Language: "go"},
+					ContextBlocks: []analyzer.ContextBlock{{
+						Lines: []analyzer.DiffLine{{Type: "added", Content: "\thelper()", NewLineNo: 4}},
+					}},
+				},
+				{FileDiff: analyzer.FileDiff{Filename: "old.go", Language: "go", Status: "deleted"}},
+			},
+		},
+	}
+
+	if err := orchestrator.expandContext(context.Background(), reviewData, DefaultReviewSettings()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	enclosing := reviewData.ContextualDiff.FilesWithContext[0].ContextBlocks[0].Enclosing
+	if enclosing == nil || enclosing.Declaration.Name != "main" {
+		t.Fatalf("expected the enclosing main function, got %+v", enclosing)
+	}
+	if len(enclosing.Callees) != 1 || enclosing.Callees[0].Name != "helper" {
+		t.Errorf("expected helper from the changed file as the callee, got %+v", enclosing.Callees)
+	}
+	if workspace.CheckedOut() || reviewData.FlattenedCodebase != nil {
+		t.Error("expected a lazy workspace to be neither checked out nor flattened")
+	}
+	if expected := []string{"company/test-repo:main.go@head456"}; !reflect.DeepEqual(contents.reads, expected) {
+		t.Errorf("expected only the changed file to be read, got %v", contents.reads)
+	}
+}
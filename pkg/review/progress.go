@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
 )
 
 // ReviewProgress represents the current state of a code review process
@@ -13,6 +15,14 @@ type ReviewProgress struct {
 	StartTime   time.Time `json:"start_time"`   // When the review started
 	LastUpdated time.Time `json:"last_updated"` // When this progress was last updated
 	Summary     string    `json:"summary"`      // Final summary for completed/failed reviews
+
+	ConfigErrors []string      `json:"config_errors,omitempty"` // Problems found in the repository config file
+	SkippedFiles []SkippedFile `json:"skipped_files,omitempty"` // Changed files that were not reviewed
+
+	PossibleIssues []llm.ReviewComment `json:"possible_issues,omitempty"` // Low-confidence findings that were not posted
+	Autofix        *AutofixResult      `json:"autofix,omitempty"`         // Suggested fixes pushed instead of posted
+	Budget         *BudgetReport       `json:"budget,omitempty"`          // Cost budget check of the review
+	WorkspaceBytes int64               `json:"workspace_bytes,omitempty"` // Disk space of the repository checkout
 }
 
 // GenerateProgressComment generates a markdown comment showing the current review progress
@@ -38,12 +48,142 @@ func GenerateProgressComment(progress *ReviewProgress) string {
 		builder.WriteString(fmt.Sprintf("%s\n\n", progress.Summary))
 	}
 
+	// Link to the commit of suggested fixes
+	if progress.Autofix != nil && progress.Stage == "completed" {
+		fixes := "fixes"
+		if progress.Autofix.Applied == 1 {
+			fixes = "fix"
+		}
+		commit := fmt.Sprintf("`%s`", shortSHA(progress.Autofix.CommitSHA))
+		if progress.Autofix.URL != "" {
+			commit = fmt.Sprintf("[%s](%s)", commit, progress.Autofix.URL)
+		}
+		builder.WriteString(fmt.Sprintf("🔧 Applied %d suggested %s in %s on branch `%s`.\n\n",
+			progress.Autofix.Applied, fixes, commit, progress.Autofix.Branch))
+	}
+
+	// Explain what the cost budget changed
+	if progress.Budget != nil && len(progress.Budget.Actions) > 0 && progress.Stage == "completed" {
+		builder.WriteString(generateBudgetNote(progress.Budget))
+	}
+
+	// Disk space of the repository checkout, when the review needed one
+	if progress.WorkspaceBytes > 0 && progress.Stage == "completed" {
+		builder.WriteString(fmt.Sprintf("**Workspace:** %s checked out\n\n", formatBytes(progress.WorkspaceBytes)))
+	}
+
+	// Low-confidence findings that were held back
+	if len(progress.PossibleIssues) > 0 && progress.Stage == "completed" {
+		builder.WriteString(generatePossibleIssuesSection(progress.PossibleIssues))
+	}
+
+	// Changed files that were left out of the review, grouped by reason
+	if len(progress.SkippedFiles) > 0 && (progress.Stage == "completed" || progress.Stage == "failed") {
+		builder.WriteString(generateSkippedFilesSection(progress.SkippedFiles))
+	}
+
+	// Repository config problems are shown at every stage so authors can fix them
+	if len(progress.ConfigErrors) > 0 {
+		builder.WriteString("### ⚠️ Configuration Errors\n\n")
+		builder.WriteString("The repository config file is invalid and was ignored; default settings were used.\n\n")
+		for _, problem := range progress.ConfigErrors {
+			builder.WriteString(fmt.Sprintf("- %s\n", problem))
+		}
+		builder.WriteString("\n")
+	}
+
 	// Progress marker (hidden HTML comment for identification)
 	builder.WriteString("<!-- review-agent:progress-comment -->")
 
 	return builder.String()
 }
 
+// generateBudgetNote explains how the review was changed to fit the cost budget
+func generateBudgetNote(budget *BudgetReport) string {
+	if budget.Skipped() {
+		return fmt.Sprintf("💰 The review was skipped because %s.\n\n", budget.Reason)
+	}
+
+	var changes []string
+	if budget.hasAction(BudgetActionDowngraded) {
+		changes = append(changes, fmt.Sprintf("used `%s`", budget.Model))
+	}
+	if budget.hasAction(BudgetActionReducedContext) {
+		files := "files"
+		if budget.FileCount == 1 {
+			files = "file"
+		}
+		changes = append(changes, fmt.Sprintf("reviewed the %d riskiest %s with less context", budget.FileCount, files))
+	}
+	return fmt.Sprintf("💰 To stay within the %s cost budget, this review %s.\n\n", formatUSD(budget.LimitUSD), strings.Join(changes, " and "))
+}
+
+// generatePossibleIssuesSection renders a collapsed list of low-confidence findings
+func generatePossibleIssuesSection(comments []llm.ReviewComment) string {
+	var builder strings.Builder
+
+	noun := "issues"
+	if len(comments) == 1 {
+		noun = "issue"
+	}
+	builder.WriteString(fmt.Sprintf("<details>\n<summary>%d possible %s (low confidence, not posted)</summary>\n\n", len(comments), noun))
+
+	for _, comment := range comments {
+		location := comment.Filename
+		if comment.LineNumber > 0 {
+			location = fmt.Sprintf("%s:%d", comment.Filename, comment.LineNumber)
+		}
+
+		details := []string{}
+		if comment.Severity != "" {
+			details = append(details, string(comment.Severity))
+		}
+		if comment.Confidence != nil {
+			details = append(details, fmt.Sprintf("confidence %.2f", *comment.Confidence))
+		}
+
+		builder.WriteString(fmt.Sprintf("- `%s`", location))
+		if len(details) > 0 {
+			builder.WriteString(fmt.Sprintf(" (%s)", strings.Join(details, ", ")))
+		}
+		builder.WriteString(fmt.Sprintf(": %s\n", strings.ReplaceAll(comment.Comment, "\n", " ")))
+	}
+
+	builder.WriteString("\n</details>\n\n")
+	return builder.String()
+}
+
+// generateSkippedFilesSection renders a collapsed list of files that were not reviewed
+func generateSkippedFilesSection(files []SkippedFile) string {
+	var builder strings.Builder
+
+	noun := "files"
+	if len(files) == 1 {
+		noun = "file"
+	}
+	builder.WriteString(fmt.Sprintf("<details>\n<summary>%d %s not reviewed</summary>\n\n", len(files), noun))
+
+	var reasons []string
+	byReason := make(map[string][]string)
+	for _, file := range files {
+		if _, ok := byReason[file.Reason]; !ok {
+			reasons = append(reasons, file.Reason)
+		}
+		byReason[file.Reason] = append(byReason[file.Reason], file.Filename)
+	}
+
+	for _, reason := range reasons {
+		builder.WriteString(fmt.Sprintf("**%s%s:**\n", strings.ToUpper(reason[:1]), reason[1:]))
+		for _, filename := range byReason[reason] {
+			builder.WriteString(fmt.Sprintf("- `%s`\n", filename))
+		}
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString("</details>\n\n")
+	return builder.String()
+}
+
 // getStageEmoji returns the appropriate emoji for each review stage
 func getStageEmoji(stage string) string {
 	switch stage {
@@ -144,6 +144,107 @@ func TestGenerateProgressComment_Failed(t *testing.T) {
 	}
 }
 
+func TestGenerateProgressComment_ConfigErrors(t *testing.T) {
+	progress := &ReviewProgress{
+		Stage:        "analyzing",
+		Message:      "Analyzing code changes...",
+		StartTime:    time.Now(),
+		LastUpdated:  time.Now(),
+		ConfigErrors: []string{".review-agent.yml: context_lines: must be between 0 and 50, got 500"},
+	}
+
+	comment := GenerateProgressComment(progress)
+
+	if !strings.Contains(comment, "Configuration Errors") {
+		t.Error("expected comment to contain configuration errors section")
+	}
+	if !strings.Contains(comment, "- .review-agent.yml: context_lines: must be between 0 and 50, got 500") {
+		t.Error("expected comment to list each configuration error")
+	}
+	if !strings.HasSuffix(comment, "<!-- review-agent:progress-comment -->") {
+		t.Error("expected progress marker to remain at the end of the comment")
+	}
+}
+
+func TestGenerateProgressComment_SkippedFiles(t *testing.T) {
+	progress := &ReviewProgress{
+		Stage:       "completed",
+		Message:     "Review completed successfully",
+		StartTime:   time.Now(),
+		LastUpdated: time.Now(),
+		Summary:     "No issues found, 2 files not reviewed",
+		SkippedFiles: []SkippedFile{
+			{Filename: "vendor/lib.go", Reason: SkipReasonPathFilter},
+			{Filename: "go.sum", Reason: SkipReasonPathFilter},
+		},
+	}
+
+	comment := GenerateProgressComment(progress)
+
+	if !strings.Contains(comment, "<summary>2 files not reviewed</summary>") {
+		t.Error("expected collapsed skipped files section")
+	}
+	if !strings.Contains(comment, "**Excluded by path filters:**\n- `vendor/lib.go`\n- `go.sum`") {
+		t.Errorf("expected skipped files grouped by reason, got:\n%s", comment)
+	}
+
+	progress.Stage = "reviewing"
+	if strings.Contains(GenerateProgressComment(progress), "not reviewed") {
+		t.Error("skipped files should only be listed once the review finishes")
+	}
+}
+
+func TestGenerateProgressComment_Autofix(t *testing.T) {
+	progress := &ReviewProgress{
+		Stage:       "completed",
+		Message:     "Review completed successfully",
+		StartTime:   time.Now(),
+		LastUpdated: time.Now(),
+		Summary:     "No issues found, pushed 2 fixes to review-agent/fixes-42",
+		Autofix: &AutofixResult{
+			Branch:    "review-agent/fixes-42",
+			CommitSHA: "0123456789abcdef",
+			URL:       "https://github.com/company/test-repo/commit/0123456789abcdef",
+			Applied:   2,
+		},
+	}
+
+	comment := GenerateProgressComment(progress)
+
+	expected := "🔧 Applied 2 suggested fixes in [`0123456`](https://github.com/company/test-repo/commit/0123456789abcdef) on branch `review-agent/fixes-42`."
+	if !strings.Contains(comment, expected) {
+		t.Errorf("expected link to the fixes commit, got:\n%s", comment)
+	}
+
+	// Without the repository's web URL the commit is named but not linked
+	progress.Autofix.URL = ""
+	comment = GenerateProgressComment(progress)
+	expected = "🔧 Applied 2 suggested fixes in `0123456` on branch `review-agent/fixes-42`."
+	if !strings.Contains(comment, expected) {
+		t.Errorf("expected the fixes commit without a link, got:\n%s", comment)
+	}
+}
+
+func TestGenerateProgressComment_WorkspaceSize(t *testing.T) {
+	progress := &ReviewProgress{
+		Stage:          "completed",
+		Message:        "Review completed successfully",
+		StartTime:      time.Now(),
+		LastUpdated:    time.Now(),
+		WorkspaceBytes: 3 << 29,
+	}
+
+	comment := GenerateProgressComment(progress)
+	if !strings.Contains(comment, "**Workspace:** 1.5 GB checked out") {
+		t.Errorf("expected the workspace size, got:\n%s", comment)
+	}
+
+	progress.WorkspaceBytes = 0
+	if comment := GenerateProgressComment(progress); strings.Contains(comment, "**Workspace:**") {
+		t.Errorf("expected no workspace size without a checkout, got:\n%s", comment)
+	}
+}
+
 func TestCreateProgressFromReviewData_Initial(t *testing.T) {
 	reviewData := &ReviewData{
 		Event: &PullRequestEvent{
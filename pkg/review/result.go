@@ -0,0 +1,195 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/history"
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
+)
+
+// Comment outcome statuses
+const (
+	CommentStatusPosted  = "posted"
+	CommentStatusSkipped = "skipped"
+	CommentStatusFailed  = "failed"
+	CommentStatusFixed   = "fixed" // Applied by autofix instead of posted
+)
+
+// Review stage names used for timing
+const (
+	StageWorkspace        = "workspace"
+	StageDiffAnalysis     = "diff_analysis"
+	StageDeletionAnalysis = "deletion_analysis"
+	StageContextExpansion = "context_expansion"
+	StageLLMReview        = "llm_review"
+	StageCommentPosting   = "comment_posting"
+	StageAutofix          = "autofix"
+)
+
+// CommentOutcome records what happened to a single LLM-generated comment
+type CommentOutcome struct {
+	Filename   string   `json:"filename"`
+	LineNumber int      `json:"line_number,omitempty"`
+	Comment    string   `json:"comment"`
+	Severity   string   `json:"severity,omitempty"`
+	Type       string   `json:"type,omitempty"`
+	Category   string   `json:"category,omitempty"`
+	ReviewType string   `json:"review_type,omitempty"`
+	Confidence *float64 `json:"confidence,omitempty"`
+	Evidence   string   `json:"evidence,omitempty"`
+	Status     string   `json:"status"`               // "posted", "skipped", "failed", "fixed"
+	Reason     string   `json:"reason,omitempty"`     // Why the comment was skipped or failed
+	URL        string   `json:"url,omitempty"`        // Link to the posted comment
+	CommentID  int64    `json:"comment_id,omitempty"` // GitHub ID of the posted comment
+}
+
+// Reasons a changed file was left out of the review
+const (
+	SkipReasonPathFilter  = "excluded by path filters"
+	SkipReasonGenerated   = "generated code"
+	SkipReasonVendored    = "vendored code"
+	SkipReasonTokenBudget = "over the token budget"
+	SkipReasonCostBudget  = "over the cost budget"
+)
+
+// SkippedFile is a changed file that was not sent for review
+type SkippedFile struct {
+	Filename string `json:"filename"`
+	Reason   string `json:"reason"`
+}
+
+// StageTiming records how long a single review stage took
+type StageTiming struct {
+	Stage      string `json:"stage"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// newCommentOutcome creates an outcome entry for an LLM comment
+func newCommentOutcome(comment llm.ReviewComment, status, reason string) CommentOutcome {
+	return CommentOutcome{
+		Filename:   comment.Filename,
+		LineNumber: comment.LineNumber,
+		Comment:    comment.Comment,
+		Severity:   string(comment.Severity),
+		Type:       string(comment.Type),
+		Category:   comment.Category,
+		ReviewType: string(comment.ReviewType),
+		Confidence: comment.Confidence,
+		Evidence:   comment.Evidence,
+		Status:     status,
+		Reason:     reason,
+	}
+}
+
+// AddWarning appends a non-fatal problem encountered during the review
+func (r *ReviewResult) AddWarning(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// AddSkippedFiles records changed files that were left out of the review for the given reason
+func (r *ReviewResult) AddSkippedFiles(filenames []string, reason string) {
+	for _, filename := range filenames {
+		r.SkippedFiles = append(r.SkippedFiles, SkippedFile{Filename: filename, Reason: reason})
+	}
+}
+
+// RecordStage records the duration of a review stage that started at the given time
+func (r *ReviewResult) RecordStage(stage string, start time.Time) {
+	r.Stages = append(r.Stages, StageTiming{
+		Stage:      stage,
+		DurationMs: time.Since(start).Milliseconds(),
+	})
+}
+
+// CountComments returns the number of comments with the given status
+func (r *ReviewResult) CountComments(status string) int {
+	count := 0
+	for _, comment := range r.Comments {
+		if comment.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// highestSeverity returns the most severe severity among the given comments
+func highestSeverity(comments []llm.ReviewComment) string {
+	var highest llm.Severity
+	for _, comment := range comments {
+		if comment.Severity.Rank() > highest.Rank() {
+			highest = comment.Severity
+		}
+	}
+	return string(highest)
+}
+
+// WriteResultFile writes the review result as indented JSON to the given path
+func WriteResultFile(path string, result *ReviewResult) error {
+	if result == nil {
+		return fmt.Errorf("review result cannot be nil")
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal review result: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write review result to %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// NewHistoryRecord converts a review result into a record for the history store
+func NewHistoryRecord(result *ReviewResult) *history.Record {
+	record := &history.Record{
+		Repository:      result.Repository,
+		PullRequest:     result.PullRequest,
+		BaseSHA:         result.BaseSHA,
+		HeadSHA:         result.HeadSHA,
+		Model:           result.Model,
+		PromptHash:      result.PromptHash,
+		Status:          result.Status,
+		Summary:         result.Summary,
+		CommentsPosted:  result.CommentsPosted,
+		HighestSeverity: result.HighestSeverity,
+		Tokens: history.Tokens{
+			Input:  result.TokensUsed.InputTokens,
+			Output: result.TokensUsed.OutputTokens,
+			Total:  result.TokensUsed.TotalTokens,
+		},
+		CostUSD:        result.CostUSD,
+		Warnings:       result.Warnings,
+		WorkspaceBytes: result.WorkspaceBytes,
+		StartedAt:      result.StartedAt,
+		DurationMs:     result.DurationMs,
+	}
+
+	for _, comment := range result.Comments {
+		record.Comments = append(record.Comments, history.Comment{
+			Filename:   comment.Filename,
+			LineNumber: comment.LineNumber,
+			Body:       comment.Comment,
+			Severity:   comment.Severity,
+			Category:   comment.Category,
+			ReviewType: comment.ReviewType,
+			Confidence: comment.Confidence,
+			Status:     comment.Status,
+			Reason:     comment.Reason,
+			CommentID:  comment.CommentID,
+		})
+	}
+
+	if len(result.Stages) > 0 {
+		record.StageDurations = make(map[string]int, len(result.Stages))
+		for _, stage := range result.Stages {
+			record.StageDurations[stage.Stage] += int(stage.DurationMs)
+		}
+	}
+
+	return record
+}
@@ -0,0 +1,102 @@
+package review
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
+)
+
+func TestReviewResult_AddWarningAndRecordStage(t *testing.T) {
+	result := &ReviewResult{}
+
+	result.AddWarning("failed to fetch diff: %s", "timeout")
+	result.RecordStage(StageWorkspace, time.Now().Add(-50*time.Millisecond))
+
+	if len(result.Warnings) != 1 || result.Warnings[0] != "failed to fetch diff: timeout" {
+		t.Errorf("unexpected warnings: %v", result.Warnings)
+	}
+	if len(result.Stages) != 1 {
+		t.Fatalf("expected 1 stage timing, got %d", len(result.Stages))
+	}
+	if result.Stages[0].Stage != StageWorkspace {
+		t.Errorf("expected stage '%s', got '%s'", StageWorkspace, result.Stages[0].Stage)
+	}
+	if result.Stages[0].DurationMs < 50 {
+		t.Errorf("expected duration of at least 50ms, got %d", result.Stages[0].DurationMs)
+	}
+}
+
+func TestHighestSeverity(t *testing.T) {
+	tests := []struct {
+		name     string
+		comments []llm.ReviewComment
+		expected string
+	}{
+		{
+			name:     "no comments",
+			expected: "",
+		},
+		{
+			name: "mixed severities",
+			comments: []llm.ReviewComment{
+				{Severity: llm.SeverityMinor},
+				{Severity: llm.SeverityMajor},
+				{Severity: llm.SeverityInfo},
+			},
+			expected: "major",
+		},
+		{
+			name: "critical wins",
+			comments: []llm.ReviewComment{
+				{Severity: llm.SeverityCritical},
+				{Severity: llm.SeverityMajor},
+			},
+			expected: "critical",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highestSeverity(tt.comments); got != tt.expected {
+				t.Errorf("expected '%s', got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWriteResultFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "result.json")
+	result := &ReviewResult{
+		CommentsPosted: 1,
+		Status:         "success",
+		Model:          "test-model",
+		Comments: []CommentOutcome{
+			{Filename: "main.go", LineNumber: 3, Comment: "Bug", Status: CommentStatusPosted},
+		},
+	}
+
+	if err := WriteResultFile(path, result); err != nil {
+		t.Fatalf("WriteResultFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read result file: %v", err)
+	}
+
+	var decoded ReviewResult
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("result file is not valid JSON: %v", err)
+	}
+	if decoded.Model != "test-model" || len(decoded.Comments) != 1 {
+		t.Errorf("unexpected decoded result: %+v", decoded)
+	}
+
+	if err := WriteResultFile(path, nil); err == nil {
+		t.Error("expected error for nil result")
+	}
+}
@@ -0,0 +1,296 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/analyzer"
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
+	"github.com/GDSources/claude-code-review-agent/pkg/repoconfig"
+)
+
+// secondOpinionLineTolerance is how far apart two findings may be to count as the same issue
+const secondOpinionLineTolerance = 3
+
+// RouteDecision records which model reviewed the pull request and why
+type RouteDecision struct {
+	Model         string               `json:"model"`
+	Rule          string               `json:"rule,omitempty"` // Matching routing rule; empty when no rule matched
+	SecondOpinion *SecondOpinionResult `json:"second_opinion,omitempty"`
+}
+
+// SecondOpinionResult describes the re-check of severe findings by another model
+type SecondOpinionResult struct {
+	Model     string `json:"model"`
+	Checked   int    `json:"checked"`   // Severe findings of the first pass
+	Confirmed int    `json:"confirmed"` // Findings the second model also reported
+	Added     int    `json:"added"`     // Severe findings only the second model reported
+}
+
+// DefaultRouting sends docs-only and small changes to a fast model and large or
+// security-sensitive changes to the most capable one, which also re-checks critical findings
+func DefaultRouting() *repoconfig.RoutingConfig {
+	large, manyFiles, small := 500, 20, 50
+	return &repoconfig.RoutingConfig{
+		Rules: []repoconfig.RoutingRule{
+			{Name: "docs-only", Model: llm.ClaudeHaiku35, When: repoconfig.RoutingCondition{
+				OnlyPaths: []string{"*.md", "*.mdx", "*.rst", "*.txt", "docs/**", "LICENSE*", "CHANGELOG*"},
+			}},
+			{Name: "security-sensitive", Model: llm.ClaudeSonnet4, When: repoconfig.RoutingCondition{
+				Paths: []string{"*auth*", "**/*auth*/**", "*crypt*", "**/*crypt*/**", "*secret*", "*session*",
+					"*token*", "*passw*", "*permission*", "**/migrations/**", "*.sql"},
+			}},
+			{Name: "large", Model: llm.ClaudeSonnet4, When: repoconfig.RoutingCondition{MinChangedLines: &large}},
+			{Name: "many-files", Model: llm.ClaudeSonnet4, When: repoconfig.RoutingCondition{MinFiles: &manyFiles}},
+			{Name: "small", Model: llm.ClaudeHaiku35, When: repoconfig.RoutingCondition{MaxChangedLines: &small}},
+		},
+		SecondOpinion: &repoconfig.SecondOpinion{Model: llm.ClaudeSonnet4, MinSeverity: string(llm.SeverityCritical)},
+	}
+}
+
+// SetModelRouting sets the routing rules used when a repository config has none; nil disables routing
+func (r *DefaultReviewOrchestrator) SetModelRouting(routing *repoconfig.RoutingConfig) {
+	r.routing = routing
+}
+
+// routeModel picks the model for the review from the first routing rule matching the diff.
+// Returns nil when routing is off or the model is pinned by the repository config.
+func (r *DefaultReviewOrchestrator) routeModel(diff *analyzer.ParsedDiff, settings *ReviewSettings) *RouteDecision {
+	if settings.Routing == nil || settings.Model != "" {
+		return nil
+	}
+
+	for _, rule := range settings.Routing.Rules {
+		if routingConditionMatches(rule.When, diff) {
+			settings.Model = rule.Model
+			return &RouteDecision{Model: rule.Model, Rule: rule.Name}
+		}
+	}
+
+	return &RouteDecision{Model: r.llmClient.GetModelInfo().Name}
+}
+
+// routingConditionMatches reports whether the diff satisfies every set field of the condition
+func routingConditionMatches(when repoconfig.RoutingCondition, diff *analyzer.ParsedDiff) bool {
+	var files []analyzer.FileDiff
+	if diff != nil {
+		files = diff.Files
+	}
+
+	changedLines := 0
+	for _, file := range files {
+		changedLines += file.Additions + file.Deletions
+	}
+
+	if when.MinChangedLines != nil && changedLines < *when.MinChangedLines {
+		return false
+	}
+	if when.MaxChangedLines != nil && changedLines > *when.MaxChangedLines {
+		return false
+	}
+	if when.MinFiles != nil && len(files) < *when.MinFiles {
+		return false
+	}
+	if when.MaxFiles != nil && len(files) > *when.MaxFiles {
+		return false
+	}
+
+	if len(when.Languages) > 0 && !anyFile(files, func(file analyzer.FileDiff) bool { return containsFold(when.Languages, file.Language) }) {
+		return false
+	}
+	if len(when.OnlyLanguages) > 0 && !everyFile(files, func(file analyzer.FileDiff) bool { return containsFold(when.OnlyLanguages, file.Language) }) {
+		return false
+	}
+	if len(when.Paths) > 0 && !anyFile(files, func(file analyzer.FileDiff) bool { return analyzer.MatchesAnyGlob(when.Paths, file.Filename) }) {
+		return false
+	}
+	if len(when.OnlyPaths) > 0 && !everyFile(files, func(file analyzer.FileDiff) bool { return analyzer.MatchesAnyGlob(when.OnlyPaths, file.Filename) }) {
+		return false
+	}
+
+	return true
+}
+
+// anyFile reports whether at least one file satisfies the predicate
+func anyFile(files []analyzer.FileDiff, predicate func(analyzer.FileDiff) bool) bool {
+	for _, file := range files {
+		if predicate(file) {
+			return true
+		}
+	}
+	return false
+}
+
+// everyFile reports whether there are files and all of them satisfy the predicate
+func everyFile(files []analyzer.FileDiff, predicate func(analyzer.FileDiff) bool) bool {
+	for _, file := range files {
+		if !predicate(file) {
+			return false
+		}
+	}
+	return len(files) > 0
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestSecondOpinion asks the second-opinion model to re-review the files with severe findings.
+// Findings it also reports are kept; the rest are lowered one severity level, and severe findings
+// only it reports are added. Its token usage and cost are added to the response, and its
+// estimate is added to the review's daily budget reservation.
+func (r *DefaultReviewOrchestrator) requestSecondOpinion(ctx context.Context, reviewData *ReviewData, settings ReviewSettings, response *llm.ReviewResponse, reservation *spendReservation) (*SecondOpinionResult, error) {
+	if settings.Routing == nil || settings.Routing.SecondOpinion == nil {
+		return nil, nil
+	}
+	second := settings.Routing.SecondOpinion
+	if second.Model == response.ModelUsed {
+		return nil, nil // The same model would not add anything
+	}
+	minSeverity := llm.Severity(second.MinSeverity)
+	if minSeverity.Rank() == 0 {
+		minSeverity = llm.SeverityCritical
+	}
+
+	var severe []int
+	files := make(map[string]bool)
+	var reviewTypes []llm.ReviewType
+	for i, comment := range response.Comments {
+		if comment.Severity.Rank() < minSeverity.Rank() {
+			continue
+		}
+		severe = append(severe, i)
+		files[comment.Filename] = true
+		if comment.ReviewType != "" && !containsReviewType(reviewTypes, comment.ReviewType) {
+			reviewTypes = append(reviewTypes, comment.ReviewType)
+		}
+	}
+	if len(severe) == 0 || reviewData.ContextualDiff == nil {
+		return nil, nil
+	}
+
+	if settings.MaxCostUSD > 0 {
+		settings.MaxCostUSD -= response.CostUSD
+		if settings.MaxCostUSD <= 0 {
+			return nil, fmt.Errorf("no cost budget left for a second opinion")
+		}
+	}
+
+	// Re-review only the files with severe findings
+	diff := *reviewData.ContextualDiff
+	diff.FilesWithContext = nil
+	for _, file := range reviewData.ContextualDiff.FilesWithContext {
+		if files[file.Filename] {
+			diff.FilesWithContext = append(diff.FilesWithContext, file)
+		}
+	}
+	secondData := *reviewData
+	secondData.ContextualDiff = &diff
+
+	var instructions strings.Builder
+	instructions.WriteString(settings.Instructions)
+	instructions.WriteString("\n\nAnother reviewer reported the issues below. Check each one independently. ")
+	instructions.WriteString("Report the ones that are real at the same file and line, leave out the ones that are not, and add any severe issue that was missed:\n")
+	for _, i := range severe {
+		comment := response.Comments[i]
+		instructions.WriteString(fmt.Sprintf("- %s:%d (%s): %s\n", comment.Filename, comment.LineNumber, comment.Severity,
+			strings.ReplaceAll(comment.Comment, "\n", " ")))
+	}
+	settings.Instructions = strings.TrimSpace(instructions.String())
+	settings.Model = second.Model
+	if len(reviewTypes) > 0 {
+		settings.ReviewTypes = reviewTypes
+	}
+	if err := r.reserveSecondOpinion(ctx, &secondData, settings, response.CostUSD, reservation); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Requesting a second opinion from %s on %d severe findings", second.Model, len(severe))
+	opinion, err := r.performLLMReview(ctx, &secondData, settings)
+	if err != nil {
+		return nil, fmt.Errorf("second opinion failed: %w", err)
+	}
+
+	result := &SecondOpinionResult{Model: second.Model, Checked: len(severe)}
+	matched := make(map[int]bool)
+	for _, i := range severe {
+		comment := &response.Comments[i]
+		if j := findSameIssue(opinion.Comments, *comment); j >= 0 {
+			matched[j] = true
+			result.Confirmed++
+			continue
+		}
+		comment.Severity = lowerSeverity(comment.Severity)
+	}
+	for j, comment := range opinion.Comments {
+		if matched[j] || comment.Severity.Rank() < minSeverity.Rank() || findSameIssue(response.Comments, comment) >= 0 {
+			continue
+		}
+		response.Comments = append(response.Comments, comment)
+		result.Added++
+	}
+
+	response.TokensUsed.InputTokens += opinion.TokensUsed.InputTokens
+	response.TokensUsed.OutputTokens += opinion.TokensUsed.OutputTokens
+	response.TokensUsed.TotalTokens += opinion.TokensUsed.TotalTokens
+	response.CostUSD += opinion.CostUSD
+
+	return result, nil
+}
+
+// routeSummary describes the routed model and the second opinion for the review summary
+func routeSummary(route *RouteDecision) string {
+	summary := fmt.Sprintf(", reviewed with %s", route.Model)
+	if route.Rule != "" {
+		summary += fmt.Sprintf(" (rule %q)", route.Rule)
+	}
+	if opinion := route.SecondOpinion; opinion != nil {
+		summary += fmt.Sprintf(", %s confirmed %d of %d severe findings", opinion.Model, opinion.Confirmed, opinion.Checked)
+		if opinion.Added > 0 {
+			summary += fmt.Sprintf(" and added %d", opinion.Added)
+		}
+	}
+	return summary
+}
+
+// findSameIssue returns the index of a comment on the same file near the same line, or -1
+func findSameIssue(comments []llm.ReviewComment, target llm.ReviewComment) int {
+	for i, comment := range comments {
+		if comment.Filename != target.Filename {
+			continue
+		}
+		distance := comment.LineNumber - target.LineNumber
+		if distance >= -secondOpinionLineTolerance && distance <= secondOpinionLineTolerance {
+			return i
+		}
+	}
+	return -1
+}
+
+// lowerSeverity returns the next less severe level
+func lowerSeverity(severity llm.Severity) llm.Severity {
+	switch severity {
+	case llm.SeverityCritical:
+		return llm.SeverityMajor
+	case llm.SeverityMajor:
+		return llm.SeverityMinor
+	default:
+		return llm.SeverityInfo
+	}
+}
+
+func containsReviewType(reviewTypes []llm.ReviewType, reviewType llm.ReviewType) bool {
+	for _, candidate := range reviewTypes {
+		if candidate == reviewType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,347 @@
+package review
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/analyzer"
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
+	"github.com/GDSources/claude-code-review-agent/pkg/repoconfig"
+)
+
+// mockSequentialLLMClient returns its responses in order, one per review request
+type mockSequentialLLMClient struct {
+	mockLLMClientWithComments
+	responses []*llm.ReviewResponse
+}
+
+func (m *mockSequentialLLMClient) ReviewCode(ctx context.Context, request *llm.ReviewRequest) (*llm.ReviewResponse, error) {
+	m.requests = append(m.requests, request)
+	response := m.responses[0]
+	m.responses = m.responses[1:]
+	return response, nil
+}
+
+func routingDiff(files ...analyzer.FileDiff) *analyzer.ParsedDiff {
+	return &analyzer.ParsedDiff{Files: files}
+}
+
+func changedFile(filename, language string, changedLines int) analyzer.FileDiff {
+	return analyzer.FileDiff{Filename: filename, Language: language, Additions: changedLines}
+}
+
+func TestRoutingConditionMatches(t *testing.T) {
+	ten, hundred := 10, 100
+	tests := []struct {
+		name     string
+		when     repoconfig.RoutingCondition
+		diff     *analyzer.ParsedDiff
+		expected bool
+	}{
+		{
+			name:     "empty condition matches everything",
+			diff:     routingDiff(changedFile("main.go", "go", 5)),
+			expected: true,
+		},
+		{
+			name:     "changed lines at or above the minimum",
+			when:     repoconfig.RoutingCondition{MinChangedLines: &hundred},
+			diff:     routingDiff(changedFile("a.go", "go", 60), changedFile("b.go", "go", 40)),
+			expected: true,
+		},
+		{
+			name:     "changed lines below the minimum",
+			when:     repoconfig.RoutingCondition{MinChangedLines: &hundred},
+			diff:     routingDiff(changedFile("a.go", "go", 99)),
+			expected: false,
+		},
+		{
+			name:     "changed lines over the maximum",
+			when:     repoconfig.RoutingCondition{MaxChangedLines: &ten},
+			diff:     routingDiff(changedFile("a.go", "go", 11)),
+			expected: false,
+		},
+		{
+			name:     "too many files",
+			when:     repoconfig.RoutingCondition{MaxFiles: &ten},
+			diff:     routingDiff(make([]analyzer.FileDiff, 11)...),
+			expected: false,
+		},
+		{
+			name:     "any file in a language, case-insensitive",
+			when:     repoconfig.RoutingCondition{Languages: []string{"Go"}},
+			diff:     routingDiff(changedFile("README.md", "markdown", 1), changedFile("main.go", "go", 1)),
+			expected: true,
+		},
+		{
+			name:     "only languages with one other file",
+			when:     repoconfig.RoutingCondition{OnlyLanguages: []string{"markdown"}},
+			diff:     routingDiff(changedFile("README.md", "markdown", 1), changedFile("main.go", "go", 1)),
+			expected: false,
+		},
+		{
+			name:     "any file matching a path",
+			when:     repoconfig.RoutingCondition{Paths: []string{"**/auth/**"}},
+			diff:     routingDiff(changedFile("pkg/auth/login.go", "go", 1), changedFile("main.go", "go", 1)),
+			expected: true,
+		},
+		{
+			name:     "every file matching the paths",
+			when:     repoconfig.RoutingCondition{OnlyPaths: []string{"docs/**", "*.md"}},
+			diff:     routingDiff(changedFile("docs/guide/setup.txt", "plaintext", 1), changedFile("README.md", "markdown", 1)),
+			expected: true,
+		},
+		{
+			name:     "only paths do not match an empty diff",
+			when:     repoconfig.RoutingCondition{OnlyPaths: []string{"*.md"}},
+			diff:     routingDiff(),
+			expected: false,
+		},
+		{
+			name:     "every set field must hold",
+			when:     repoconfig.RoutingCondition{Languages: []string{"go"}, MaxChangedLines: &ten},
+			diff:     routingDiff(changedFile("main.go", "go", 20)),
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if matched := routingConditionMatches(tt.when, tt.diff); matched != tt.expected {
+				t.Errorf("expected match %v, got %v", tt.expected, matched)
+			}
+		})
+	}
+}
+
+func TestDefaultReviewOrchestrator_RouteModel(t *testing.T) {
+	manyFiles := make([]analyzer.FileDiff, 25)
+	for i := range manyFiles {
+		manyFiles[i] = changedFile("pkg/file"+strings.Repeat("x", i)+".go", "go", 3)
+	}
+
+	tests := []struct {
+		name          string
+		diff          *analyzer.ParsedDiff
+		expectedModel string
+		expectedRule  string
+	}{
+		{
+			name:          "docs only",
+			diff:          routingDiff(changedFile("README.md", "markdown", 300), changedFile("docs/setup.rst", "plaintext", 10)),
+			expectedModel: llm.ClaudeHaiku35,
+			expectedRule:  "docs-only",
+		},
+		{
+			name:          "security-sensitive path",
+			diff:          routingDiff(changedFile("internal/auth/token.go", "go", 4)),
+			expectedModel: llm.ClaudeSonnet4,
+			expectedRule:  "security-sensitive",
+		},
+		{
+			name:          "database migration",
+			diff:          routingDiff(changedFile("db/migrations/0042_users.sql", "sql", 4)),
+			expectedModel: llm.ClaudeSonnet4,
+			expectedRule:  "security-sensitive",
+		},
+		{
+			name:          "large change",
+			diff:          routingDiff(changedFile("pkg/server/handler.go", "go", 800)),
+			expectedModel: llm.ClaudeSonnet4,
+			expectedRule:  "large",
+		},
+		{
+			name:          "many files",
+			diff:          routingDiff(manyFiles...),
+			expectedModel: llm.ClaudeSonnet4,
+			expectedRule:  "many-files",
+		},
+		{
+			name:          "small change",
+			diff:          routingDiff(changedFile("pkg/server/handler.go", "go", 12)),
+			expectedModel: llm.ClaudeHaiku35,
+			expectedRule:  "small",
+		},
+		{
+			name:          "medium change keeps the default model",
+			diff:          routingDiff(changedFile("pkg/server/handler.go", "go", 200)),
+			expectedModel: "",
+			expectedRule:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orchestrator := &DefaultReviewOrchestrator{llmClient: &mockLLMClientWithComments{}}
+			settings := DefaultReviewSettings()
+			settings.Routing = DefaultRouting()
+
+			route := orchestrator.routeModel(tt.diff, &settings)
+
+			if route == nil {
+				t.Fatal("expected a route decision")
+			}
+			if route.Rule != tt.expectedRule {
+				t.Errorf("expected rule %q, got %q", tt.expectedRule, route.Rule)
+			}
+			if settings.Model != tt.expectedModel {
+				t.Errorf("expected model %q, got %q", tt.expectedModel, settings.Model)
+			}
+			if tt.expectedModel == "" && route.Model != "test-model" {
+				t.Errorf("expected the default model to be reported, got %q", route.Model)
+			}
+		})
+	}
+}
+
+func TestDefaultReviewOrchestrator_RouteModel_Disabled(t *testing.T) {
+	orchestrator := &DefaultReviewOrchestrator{llmClient: &mockLLMClientWithComments{}}
+	diff := routingDiff(changedFile("README.md", "markdown", 1))
+
+	settings := DefaultReviewSettings()
+	if route := orchestrator.routeModel(diff, &settings); route != nil {
+		t.Errorf("expected no routing without rules, got %+v", route)
+	}
+
+	// A model pinned in the repository config wins over routing
+	settings.Routing = DefaultRouting()
+	settings.Model = llm.ClaudeSonnet37
+	if route := orchestrator.routeModel(diff, &settings); route != nil || settings.Model != llm.ClaudeSonnet37 {
+		t.Errorf("expected the pinned model to be kept, got route %+v and model %q", route, settings.Model)
+	}
+}
+
+func TestDefaultReviewOrchestrator_RequestSecondOpinion(t *testing.T) {
+	firstPass := func() *llm.ReviewResponse {
+		return &llm.ReviewResponse{
+			ModelUsed: llm.ClaudeHaiku35,
+			CostUSD:   0.01,
+			Comments: []llm.ReviewComment{
+				{Filename: "auth.go", LineNumber: 10, Severity: llm.SeverityCritical, Comment: "SQL injection"},
+				{Filename: "auth.go", LineNumber: 40, Severity: llm.SeverityCritical, Comment: "Token is logged"},
+				{Filename: "util.go", LineNumber: 5, Severity: llm.SeverityMinor, Comment: "Typo"},
+			},
+		}
+	}
+	secondPass := &llm.ReviewResponse{
+		ModelUsed: llm.ClaudeSonnet4,
+		CostUSD:   0.05,
+		TokensUsed: llm.TokenUsage{
+			InputTokens: 100, OutputTokens: 20, TotalTokens: 120,
+		},
+		Comments: []llm.ReviewComment{
+			{Filename: "auth.go", LineNumber: 12, Severity: llm.SeverityCritical, Comment: "SQL injection in query"},
+			{Filename: "auth.go", LineNumber: 70, Severity: llm.SeverityCritical, Comment: "Missing authorization check"},
+			{Filename: "auth.go", LineNumber: 90, Severity: llm.SeverityMinor, Comment: "Naming"},
+		},
+	}
+
+	client := &mockSequentialLLMClient{responses: []*llm.ReviewResponse{secondPass}}
+	orchestrator := &DefaultReviewOrchestrator{llmClient: client}
+	reviewData := &ReviewData{
+		Event: createTestPullRequestEvent(),
+		ContextualDiff: &analyzer.ContextualDiff{FilesWithContext: []analyzer.FileWithContext{
+			{FileDiff: analyzer.FileDiff{Filename: "auth.go"}},
+			{FileDiff: analyzer.FileDiff{Filename: "util.go"}},
+		}},
+	}
+	settings := DefaultReviewSettings()
+	settings.Routing = DefaultRouting()
+	response := firstPass()
+
+	result, err := orchestrator.requestSecondOpinion(context.Background(), reviewData, settings, response, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := SecondOpinionResult{Model: llm.ClaudeSonnet4, Checked: 2, Confirmed: 1, Added: 1}
+	if result == nil || *result != expected {
+		t.Fatalf("expected %+v, got %+v", expected, result)
+	}
+
+	if len(client.requests) != 1 {
+		t.Fatalf("expected one second-opinion request, got %d", len(client.requests))
+	}
+	request := client.requests[0]
+	if request.Model != llm.ClaudeSonnet4 {
+		t.Errorf("expected the second opinion from %s, got %q", llm.ClaudeSonnet4, request.Model)
+	}
+	if files := request.ContextualDiff.FilesWithContext; len(files) != 1 || files[0].Filename != "auth.go" {
+		t.Errorf("expected only auth.go to be re-reviewed, got %v", files)
+	}
+	if !strings.Contains(request.Instructions, "auth.go:40 (critical): Token is logged") {
+		t.Errorf("expected the findings in the instructions, got %q", request.Instructions)
+	}
+
+	severities := make(map[int]llm.Severity)
+	for _, comment := range response.Comments {
+		severities[comment.LineNumber] = comment.Severity
+	}
+	if severities[10] != llm.SeverityCritical {
+		t.Errorf("expected the confirmed finding to stay critical, got %q", severities[10])
+	}
+	if severities[40] != llm.SeverityMajor {
+		t.Errorf("expected the unconfirmed finding to be lowered to major, got %q", severities[40])
+	}
+	if severities[70] != llm.SeverityCritical {
+		t.Errorf("expected the missed finding to be added, got %q", severities[70])
+	}
+	if _, ok := severities[90]; ok {
+		t.Error("expected minor second-opinion findings to be left out")
+	}
+	if math.Abs(response.CostUSD-0.06) > 1e-9 || response.TokensUsed.TotalTokens != 120 {
+		t.Errorf("expected the second opinion's usage to be added, got $%.2f and %d tokens", response.CostUSD, response.TokensUsed.TotalTokens)
+	}
+}
+
+func TestDefaultReviewOrchestrator_RequestSecondOpinion_NotNeeded(t *testing.T) {
+	tests := []struct {
+		name     string
+		response *llm.ReviewResponse
+	}{
+		{
+			name: "no critical findings",
+			response: &llm.ReviewResponse{ModelUsed: llm.ClaudeHaiku35, Comments: []llm.ReviewComment{
+				{Filename: "auth.go", LineNumber: 10, Severity: llm.SeverityMajor},
+			}},
+		},
+		{
+			name: "first pass already used the second-opinion model",
+			response: &llm.ReviewResponse{ModelUsed: llm.ClaudeSonnet4, Comments: []llm.ReviewComment{
+				{Filename: "auth.go", LineNumber: 10, Severity: llm.SeverityCritical},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockSequentialLLMClient{}
+			orchestrator := &DefaultReviewOrchestrator{llmClient: client}
+			settings := DefaultReviewSettings()
+			settings.Routing = DefaultRouting()
+
+			result, err := orchestrator.requestSecondOpinion(context.Background(), budgetReviewData(1), settings, tt.response, nil)
+			if err != nil || result != nil {
+				t.Errorf("expected no second opinion, got %+v (err %v)", result, err)
+			}
+			if len(client.requests) != 0 {
+				t.Errorf("expected no LLM request, got %d", len(client.requests))
+			}
+		})
+	}
+}
+
+func TestRouteSummary(t *testing.T) {
+	route := &RouteDecision{
+		Model:         llm.ClaudeHaiku35,
+		Rule:          "small",
+		SecondOpinion: &SecondOpinionResult{Model: llm.ClaudeSonnet4, Checked: 2, Confirmed: 1, Added: 1},
+	}
+	expected := `, reviewed with claude-3-5-haiku-20241022 (rule "small"), claude-sonnet-4-20250514 confirmed 1 of 2 severe findings and added 1`
+
+	if summary := routeSummary(route); summary != expected {
+		t.Errorf("expected %q, got %q", expected, summary)
+	}
+}
@@ -0,0 +1,300 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/analyzer"
+	"github.com/GDSources/claude-code-review-agent/pkg/github"
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
+	"github.com/GDSources/claude-code-review-agent/pkg/repoconfig"
+)
+
+// DefaultContextLines is the number of context lines extracted around each change
+const DefaultContextLines = 5
+
+// DefaultEnclosingContextLines is the number of lines shown of the function around each change
+const DefaultEnclosingContextLines = 80
+
+// DefaultTokenBudget is the estimated number of diff tokens reviewed before low-risk files are left out
+const DefaultTokenBudget = 100000
+
+// ReviewSettings are the effective settings for a single review
+type ReviewSettings struct {
+	ReviewTypes       []llm.ReviewType
+	Instructions      string
+	Include           []string
+	Exclude           []string
+	SeverityThreshold llm.Severity
+	// ConfidenceThreshold holds back findings the model is less sure of (0 disables it)
+	ConfidenceThreshold float64
+	// ShowPossibleIssues lists held-back findings in the review summary
+	ShowPossibleIssues bool
+	Model              string
+	ContextLines       int
+	DeletionAnalysis   bool
+	// EnclosingContextLines bounds the lines shown of the function around each change (0 disables it)
+	EnclosingContextLines int
+	// TokenBudget caps the estimated diff tokens sent for review (0 is unlimited)
+	TokenBudget int
+	// MaxCostUSD caps the LLM spend of the review, set by the cost budget (0 is unlimited)
+	MaxCostUSD float64
+	// Routing picks the model from the diff when no model is set (nil disables routing)
+	Routing *repoconfig.RoutingConfig
+}
+
+// DefaultReviewSettings returns the settings used when a repository has no config file
+func DefaultReviewSettings() ReviewSettings {
+	return ReviewSettings{
+		ReviewTypes:           []llm.ReviewType{llm.ReviewTypeGeneral},
+		ContextLines:          DefaultContextLines,
+		DeletionAnalysis:      true,
+		TokenBudget:           DefaultTokenBudget,
+		EnclosingContextLines: DefaultEnclosingContextLines,
+	}
+}
+
+// ApplyRepoConfig overrides settings with the values set in a repository config
+func (s *ReviewSettings) ApplyRepoConfig(config *repoconfig.Config) {
+	if config == nil {
+		return
+	}
+
+	if len(config.ReviewTypes) > 0 {
+		s.ReviewTypes = config.ReviewTypeList()
+	}
+	if config.Instructions != "" {
+		s.Instructions = strings.TrimSpace(config.Instructions)
+	}
+	if len(config.Include) > 0 {
+		s.Include = config.Include
+	}
+	if len(config.Exclude) > 0 {
+		s.Exclude = append(s.Exclude, config.Exclude...)
+	}
+	if config.SeverityThreshold != "" {
+		s.SeverityThreshold = llm.Severity(config.SeverityThreshold)
+	}
+	if config.ConfidenceThreshold != nil {
+		s.ConfidenceThreshold = *config.ConfidenceThreshold
+	}
+	if config.ShowPossibleIssues != nil {
+		s.ShowPossibleIssues = *config.ShowPossibleIssues
+	}
+	if config.Model != "" {
+		s.Model = config.Model
+	}
+	if config.ContextLines != nil {
+		s.ContextLines = *config.ContextLines
+	}
+	if config.DeletionAnalysis != nil {
+		s.DeletionAnalysis = *config.DeletionAnalysis
+	}
+	if config.EnclosingContextLines != nil {
+		s.EnclosingContextLines = *config.EnclosingContextLines
+	}
+	if config.TokenBudget != nil {
+		s.TokenBudget = *config.TokenBudget
+	}
+	if config.Routing != nil {
+		s.Routing = config.Routing
+	}
+}
+
+// loadRepoConfig reads the repository config from the base branch of the workspace.
+// Validation errors are returned as a list so they can be reported without failing the review.
+func (r *DefaultReviewOrchestrator) loadRepoConfig(ctx context.Context, event *PullRequestEvent, workspace *Workspace) (*repoconfig.Config, []string, error) {
+	if r.repoConfigReader == nil || workspace == nil {
+		return nil, nil, nil
+	}
+
+	reader, repoPath, refs, err := r.baseBranchReader(ctx, &event.Repository, event.PullRequest.Base, workspace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config, err := repoconfig.Load(ctx, reader, repoPath, refs)
+	if err != nil {
+		var validationErr *repoconfig.ValidationError
+		if errors.As(err, &validationErr) {
+			problems := make([]string, 0, len(validationErr.Problems))
+			for _, problem := range validationErr.Problems {
+				problems = append(problems, fmt.Sprintf("%s: %s", validationErr.File, problem))
+			}
+			return nil, problems, nil
+		}
+		return nil, nil, err
+	}
+
+	if config != nil {
+		log.Printf("Loaded repository config %s for PR #%d", config.Source, event.Number)
+	}
+
+	return config, nil, nil
+}
+
+// baseBranchReader returns the reader, checkout path and refs that files of the base branch are read
+// from, like the repository config: the base commit, then the base branch. A workspace that is not
+// checked out is read through the API rather than cloning the repository.
+func (r *DefaultReviewOrchestrator) baseBranchReader(ctx context.Context, repository *Repository, base Branch, workspace *Workspace) (repoconfig.FileReader, string, []string, error) {
+	refs := []string{base.SHA}
+	if !workspace.CheckedOut() && workspace.contents != nil {
+		reader := &contentsFileReader{contents: workspace.contents, owner: repository.Owner.Login, repo: repository.Name}
+		return reader, "", append(refs, base.Ref), nil
+	}
+
+	path, err := workspace.Checkout(ctx)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if base.Ref != "" {
+		refs = append(refs, "origin/"+base.Ref)
+	}
+	return r.repoConfigReader, path, refs, nil
+}
+
+// readBaseAttributes reads the root .gitattributes of the base branch, so a pull request cannot
+// mark its own files generated or vendored. Returns nil when there is none or it cannot be read.
+func (r *DefaultReviewOrchestrator) readBaseAttributes(ctx context.Context, workspace *Workspace) []byte {
+	if r.repoConfigReader == nil || workspace.Repository == nil || workspace.PullRequest == nil {
+		return nil
+	}
+
+	reader, repoPath, refs, err := r.baseBranchReader(ctx, workspace.Repository, workspace.PullRequest.Base, workspace)
+	if err != nil {
+		log.Printf("Warning: failed to read .gitattributes of the base branch: %v", err)
+		return nil
+	}
+	for _, ref := range refs {
+		if ref == "" {
+			continue
+		}
+		data, err := reader.ReadFileAtRef(ctx, repoPath, ref, ".gitattributes")
+		if errors.Is(err, github.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Warning: failed to read .gitattributes of the base branch: %v", err)
+			return nil
+		}
+		return data
+	}
+	return nil // Most repositories have none
+}
+
+// contentsFileReader reads repository config files through the API instead of a checkout
+type contentsFileReader struct {
+	contents RepositoryContentReader
+	owner    string
+	repo     string
+}
+
+// ReadFileAtRef reads a file of the repository at ref; repoPath is ignored
+func (c *contentsFileReader) ReadFileAtRef(ctx context.Context, repoPath, ref, path string) ([]byte, error) {
+	return c.contents.GetFileContent(ctx, c.owner, c.repo, path, ref)
+}
+
+// filterBySeverity splits comments into those meeting the severity threshold and those below it
+func filterBySeverity(comments []llm.ReviewComment, threshold llm.Severity) ([]llm.ReviewComment, []llm.ReviewComment) {
+	if threshold.Rank() == 0 {
+		return comments, nil
+	}
+
+	var kept, below []llm.ReviewComment
+	for _, comment := range comments {
+		if comment.Severity.Rank() >= threshold.Rank() {
+			kept = append(kept, comment)
+		} else {
+			below = append(below, comment)
+		}
+	}
+	return kept, below
+}
+
+// filterByConfidence splits comments into those meeting the confidence threshold and those below it
+func filterByConfidence(comments []llm.ReviewComment, threshold float64) ([]llm.ReviewComment, []llm.ReviewComment) {
+	if threshold <= 0 {
+		return comments, nil
+	}
+
+	var kept, below []llm.ReviewComment
+	for _, comment := range comments {
+		if comment.ConfidenceBelow(threshold) {
+			below = append(below, comment)
+		} else {
+			kept = append(kept, comment)
+		}
+	}
+	return kept, below
+}
+
+// filterByEvidence splits comments into those whose evidence quote appears in their file's diff and
+// those quoting code that is not there. Comments without evidence are kept.
+func filterByEvidence(comments []llm.ReviewComment, diff *analyzer.ContextualDiff) ([]llm.ReviewComment, []llm.ReviewComment) {
+	if diff == nil {
+		return comments, nil
+	}
+
+	diffText := make(map[string]string, len(diff.FilesWithContext))
+	for _, file := range diff.FilesWithContext {
+		var lines []string
+		for _, block := range file.ContextBlocks {
+			for _, line := range block.Lines {
+				lines = append(lines, line.Content)
+			}
+		}
+		diffText[file.Filename] = collapseSpace(strings.Join(lines, "\n"))
+	}
+
+	var kept, unsupported []llm.ReviewComment
+	for _, comment := range comments {
+		evidence := collapseSpace(strings.TrimSuffix(comment.Evidence, "...")) // Long quotes are cut and end in "..."
+		if evidence == "" || strings.Contains(diffText[comment.Filename], evidence) {
+			kept = append(kept, comment)
+		} else {
+			unsupported = append(unsupported, comment)
+		}
+	}
+	return kept, unsupported
+}
+
+// collapseSpace replaces each run of whitespace with a single space
+func collapseSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// mergeReviewResponses combines the responses of several review passes into one
+func mergeReviewResponses(responses []*llm.ReviewResponse) *llm.ReviewResponse {
+	if len(responses) == 1 {
+		return responses[0]
+	}
+
+	merged := &llm.ReviewResponse{}
+	var summaries, hashes []string
+	for _, response := range responses {
+		merged.Comments = append(merged.Comments, response.Comments...)
+		merged.TokensUsed.InputTokens += response.TokensUsed.InputTokens
+		merged.TokensUsed.OutputTokens += response.TokensUsed.OutputTokens
+		merged.TokensUsed.TotalTokens += response.TokensUsed.TotalTokens
+		merged.CostUSD += response.CostUSD
+		merged.SkippedChunks += response.SkippedChunks
+		merged.UnreviewedFiles = append(merged.UnreviewedFiles, response.UnreviewedFiles...)
+		merged.CachedFiles += response.CachedFiles
+		if response.Summary != "" {
+			summaries = append(summaries, response.Summary)
+		}
+		if response.PromptHash != "" {
+			hashes = append(hashes, response.PromptHash)
+		}
+		merged.ModelUsed = response.ModelUsed
+		merged.ReviewID = response.ReviewID
+		merged.GeneratedAt = response.GeneratedAt
+	}
+	merged.Summary = strings.Join(summaries, "\n\n")
+	merged.PromptHash = strings.Join(hashes, ",")
+
+	return merged
+}
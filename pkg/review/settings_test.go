@@ -0,0 +1,548 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/analyzer"
+	"github.com/GDSources/claude-code-review-agent/pkg/github"
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
+	"github.com/GDSources/claude-code-review-agent/pkg/repoconfig"
+)
+
+type mockRepoConfigReader struct {
+	files map[string]string // "ref:path" -> content
+}
+
+func (m *mockRepoConfigReader) ReadFileAtRef(ctx context.Context, repoPath, ref, path string) ([]byte, error) {
+	content, ok := m.files[ref+":"+path]
+	if !ok {
+		return nil, fmt.Errorf("path '%s' does not exist in '%s': %w", path, ref, github.ErrNotFound)
+	}
+	return []byte(content), nil
+}
+
+func TestReviewSettings_ApplyRepoConfig(t *testing.T) {
+	contextLines := 12
+	deletionAnalysis := false
+	enclosingContextLines := 0
+
+	settings := DefaultReviewSettings()
+	settings.Exclude = []string{"vendor/**"}
+	settings.ApplyRepoConfig(&repoconfig.Config{
+		ReviewTypes:           []string{"security", "bugs"},
+		Instructions:          "  Check SQL queries.\n",
+		Include:               []string{"src/**"},
+		Exclude:               []string{"*.lock"},
+		SeverityThreshold:     "major",
+		Model:                 llm.ClaudeSonnet4,
+		ContextLines:          &contextLines,
+		DeletionAnalysis:      &deletionAnalysis,
+		EnclosingContextLines: &enclosingContextLines,
+	})
+
+	if !reflect.DeepEqual(settings.ReviewTypes, []llm.ReviewType{llm.ReviewTypeSecurity, llm.ReviewTypeBugs}) {
+		t.Errorf("unexpected review types: %v", settings.ReviewTypes)
+	}
+	if settings.Instructions != "Check SQL queries." {
+		t.Errorf("unexpected instructions: %q", settings.Instructions)
+	}
+	if !reflect.DeepEqual(settings.Exclude, []string{"vendor/**", "*.lock"}) {
+		t.Errorf("expected excludes to be combined, got %v", settings.Exclude)
+	}
+	if settings.SeverityThreshold != llm.SeverityMajor || settings.Model != llm.ClaudeSonnet4 {
+		t.Errorf("unexpected threshold/model: %s/%s", settings.SeverityThreshold, settings.Model)
+	}
+	if settings.ContextLines != 12 || settings.DeletionAnalysis {
+		t.Errorf("unexpected context lines/deletion analysis: %d/%v", settings.ContextLines, settings.DeletionAnalysis)
+	}
+	if settings.EnclosingContextLines != 0 {
+		t.Errorf("expected enclosing context to be disabled, got %d", settings.EnclosingContextLines)
+	}
+
+	defaults := DefaultReviewSettings()
+	defaults.ApplyRepoConfig(&repoconfig.Config{})
+	if !reflect.DeepEqual(defaults, DefaultReviewSettings()) {
+		t.Errorf("empty config should keep defaults, got %+v", defaults)
+	}
+}
+
+func TestFilterBySeverity(t *testing.T) {
+	comments := []llm.ReviewComment{
+		{Comment: "a", Severity: llm.SeverityInfo},
+		{Comment: "b", Severity: llm.SeverityMajor},
+		{Comment: "c", Severity: llm.SeverityCritical},
+		{Comment: "d", Severity: llm.SeverityMinor},
+	}
+
+	kept, below := filterBySeverity(comments, llm.SeverityMajor)
+	if len(kept) != 2 || kept[0].Comment != "b" || kept[1].Comment != "c" {
+		t.Errorf("unexpected kept comments: %v", kept)
+	}
+	if len(below) != 2 {
+		t.Errorf("expected 2 comments below threshold, got %d", len(below))
+	}
+
+	kept, below = filterBySeverity(comments, "")
+	if len(kept) != 4 || below != nil {
+		t.Errorf("no threshold should keep all comments")
+	}
+}
+
+func TestFilterByConfidence(t *testing.T) {
+	confidence := func(v float64) *float64 { return &v }
+	comments := []llm.ReviewComment{
+		{Comment: "sure", Confidence: confidence(0.9)},
+		{Comment: "unsure", Confidence: confidence(0.4)},
+		{Comment: "unscored"},
+		{Comment: "borderline", Confidence: confidence(0.7)},
+	}
+
+	kept, below := filterByConfidence(comments, 0.7)
+	if len(kept) != 2 || kept[0].Comment != "sure" || kept[1].Comment != "borderline" {
+		t.Errorf("unexpected kept comments: %v", kept)
+	}
+	if len(below) != 2 || below[0].Comment != "unsure" || below[1].Comment != "unscored" {
+		t.Errorf("unexpected held back comments: %v", below)
+	}
+
+	kept, below = filterByConfidence(comments, 0)
+	if len(kept) != 4 || below != nil {
+		t.Errorf("no threshold should keep all comments")
+	}
+}
+
+func TestFilterByEvidence(t *testing.T) {
+	diff := &analyzer.ContextualDiff{FilesWithContext: []analyzer.FileWithContext{{
+		FileDiff: analyzer.FileDiff{Filename: "main.go"},
+		ContextBlocks: []analyzer.ContextBlock{{Lines: []analyzer.DiffLine{
+			{Type: "context", Content: "func load(path string) error {"},
+			{Type: "added", Content: "\tdata, _ := os.ReadFile(path)"},
+		}}},
+	}}}
+
+	tests := []struct {
+		name     string
+		comment  llm.ReviewComment
+		expected bool
+	}{
+		{name: "quoted line", comment: llm.ReviewComment{Filename: "main.go", Evidence: "data, _ := os.ReadFile(path)"}, expected: true},
+		{name: "spacing differs", comment: llm.ReviewComment{Filename: "main.go", Evidence: "func load(path string)  error {\n data, _ :="}, expected: true},
+		{name: "truncated quote", comment: llm.ReviewComment{Filename: "main.go", Evidence: "data, _ := os.Read..."}, expected: true},
+		{name: "no evidence", comment: llm.ReviewComment{Filename: "main.go"}, expected: true},
+		{name: "code not in the diff", comment: llm.ReviewComment{Filename: "main.go", Evidence: "defer file.Close()"}, expected: false},
+		{name: "quote from another file", comment: llm.ReviewComment{Filename: "other.go", Evidence: "os.ReadFile(path)"}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, unsupported := filterByEvidence([]llm.ReviewComment{tt.comment}, diff)
+			if got := len(kept) == 1 && len(unsupported) == 0; got != tt.expected {
+				t.Errorf("expected kept=%v, got kept %v and held back %v", tt.expected, kept, unsupported)
+			}
+		})
+	}
+}
+
+func TestDefaultReviewOrchestrator_ConfidenceThreshold(t *testing.T) {
+	confidence := func(v float64) *float64 { return &v }
+	mockLLM := &mockLLMClientWithComments{
+		reviewResponse: &llm.ReviewResponse{
+			Comments: []llm.ReviewComment{
+				{Filename: "main.go", LineNumber: 10, Comment: "Nil dereference", Severity: llm.SeverityMajor, Confidence: confidence(0.95)},
+				{Filename: "main.go", LineNumber: 20, Comment: "Maybe racy", Severity: llm.SeverityMinor, Confidence: confidence(0.3)},
+			},
+		},
+	}
+	mockCA := &mockCodeAnalyzer{
+		contextualDiff: &analyzer.ContextualDiff{
+			ParsedDiff: &analyzer.ParsedDiff{TotalFiles: 1},
+		},
+	}
+	mockGitHub := &mockGitHubCommentClient{}
+
+	orchestrator := newConfiguredOrchestrator(mockLLM, mockGitHub, mockCA, "show_possible_issues: true\n")
+	orchestrator.SetConfidenceThreshold(0.7, false)
+
+	result, err := orchestrator.HandlePullRequest(createTestPullRequestEvent())
+	if err != nil {
+		t.Fatalf("HandlePullRequest failed: %v", err)
+	}
+
+	if len(mockGitHub.createCommentCalls) != 1 {
+		t.Fatalf("expected 1 comment posted, got %d", len(mockGitHub.createCommentCalls))
+	}
+	var heldBack *CommentOutcome
+	for i := range result.Comments {
+		if result.Comments[i].Status == CommentStatusSkipped {
+			heldBack = &result.Comments[i]
+		}
+	}
+	if heldBack == nil || heldBack.Reason != "below confidence threshold (0.70)" || heldBack.Confidence == nil {
+		t.Errorf("expected low-confidence comment to be recorded as skipped, got %+v", result.Comments)
+	}
+
+	finalBody := mockGitHub.updateIssueCommentCalls[len(mockGitHub.updateIssueCommentCalls)-1].body
+	if !strings.Contains(finalBody, "1 possible issue (low confidence, not posted)") ||
+		!strings.Contains(finalBody, "- `main.go:20` (minor, confidence 0.30): Maybe racy") {
+		t.Errorf("expected possible issues section in progress comment, got:\n%s", finalBody)
+	}
+}
+
+func newConfiguredOrchestrator(mockLLM *mockLLMClientWithComments, mockGitHub *mockGitHubCommentClient, mockCA *mockCodeAnalyzer, config string) *DefaultReviewOrchestrator {
+	orchestrator := &DefaultReviewOrchestrator{
+		workspaceManager: &mockWorkspaceManager{},
+		diffFetcher: &mockDiffFetcher{
+			diffResult: &github.DiffResult{RawDiff: "test diff", TotalFiles: 1},
+		},
+		codeAnalyzer: mockCA,
+		llmClient:    mockLLM,
+		githubClient: mockGitHub,
+	}
+	orchestrator.SetRepoConfigReader(&mockRepoConfigReader{files: map[string]string{
+		"def456:.review-agent.yml": config,
+	}})
+	return orchestrator
+}
+
+func TestDefaultReviewOrchestrator_AppliesRepoConfig(t *testing.T) {
+	mockLLM := &mockLLMClientWithComments{
+		reviewResponse: &llm.ReviewResponse{
+			Comments: []llm.ReviewComment{
+				{Filename: "main.go", LineNumber: 10, Comment: "SQL injection", Severity: llm.SeverityCritical},
+				{Filename: "main.go", LineNumber: 20, Comment: "Nit", Severity: llm.SeverityInfo},
+			},
+		},
+	}
+	mockCA := &mockCodeAnalyzer{
+		contextualDiff: &analyzer.ContextualDiff{
+			ParsedDiff: &analyzer.ParsedDiff{TotalFiles: 1},
+		},
+	}
+	mockGitHub := &mockGitHubCommentClient{}
+
+	config := `
+review_types: [security, bugs]
+instructions: Check SQL queries.
+severity_threshold: major
+model: claude-sonnet-4-20250514
+context_lines: 8
+`
+	orchestrator := newConfiguredOrchestrator(mockLLM, mockGitHub, mockCA, config)
+
+	result, err := orchestrator.HandlePullRequest(createTestPullRequestEvent())
+	if err != nil {
+		t.Fatalf("HandlePullRequest failed: %v", err)
+	}
+
+	if result.RepoConfig != ".review-agent.yml" {
+		t.Errorf("expected repo config to be recorded, got %q", result.RepoConfig)
+	}
+	if mockCA.contextLines != 8 {
+		t.Errorf("expected 8 context lines, got %d", mockCA.contextLines)
+	}
+	if len(mockLLM.requests) != 2 {
+		t.Fatalf("expected one LLM request per review type, got %d", len(mockLLM.requests))
+	}
+	for i, reviewType := range []llm.ReviewType{llm.ReviewTypeSecurity, llm.ReviewTypeBugs} {
+		request := mockLLM.requests[i]
+		if request.ReviewType != reviewType || request.Instructions != "Check SQL queries." || request.Model != llm.ClaudeSonnet4 {
+			t.Errorf("unexpected request %d: type=%s instructions=%q model=%s",
+				i, request.ReviewType, request.Instructions, request.Model)
+		}
+	}
+
+	// Each pass returned both comments; only the critical ones pass the threshold
+	if len(mockGitHub.createCommentCalls) != 2 {
+		t.Errorf("expected 2 comments posted, got %d", len(mockGitHub.createCommentCalls))
+	}
+	if got := result.CountComments(CommentStatusSkipped); got != 2 {
+		t.Errorf("expected 2 comments held back by the threshold, got %d", got)
+	}
+}
+
+func TestDefaultReviewOrchestrator_InvalidRepoConfig(t *testing.T) {
+	mockLLM := &mockLLMClientWithComments{reviewResponse: &llm.ReviewResponse{}}
+	mockCA := &mockCodeAnalyzer{
+		contextualDiff: &analyzer.ContextualDiff{
+			ParsedDiff: &analyzer.ParsedDiff{TotalFiles: 1},
+		},
+	}
+	mockGitHub := &mockGitHubCommentClient{}
+
+	orchestrator := newConfiguredOrchestrator(mockLLM, mockGitHub, mockCA, "severity_threshold: urgent\ncontext_lines: 8\n")
+
+	result, err := orchestrator.HandlePullRequest(createTestPullRequestEvent())
+	if err != nil {
+		t.Fatalf("invalid config should not fail the review, got: %v", err)
+	}
+
+	if mockCA.contextLines != DefaultContextLines {
+		t.Errorf("expected defaults when config is invalid, got %d context lines", mockCA.contextLines)
+	}
+	if len(result.Warnings) == 0 || !strings.Contains(result.Warnings[0], "severity_threshold") {
+		t.Errorf("expected config warning, got %v", result.Warnings)
+	}
+
+	if len(mockGitHub.updateIssueCommentCalls) == 0 {
+		t.Fatal("expected progress comment updates")
+	}
+	finalBody := mockGitHub.updateIssueCommentCalls[len(mockGitHub.updateIssueCommentCalls)-1].body
+	if !strings.Contains(finalBody, "Configuration Errors") || !strings.Contains(finalBody, `unknown severity "urgent"`) {
+		t.Errorf("expected config errors in progress comment, got:\n%s", finalBody)
+	}
+}
+
+func TestDefaultReviewOrchestrator_PathFilters(t *testing.T) {
+	parsedDiff := &analyzer.ParsedDiff{
+		Files: []analyzer.FileDiff{
+			{Filename: "main.go", Additions: 3},
+			{Filename: "vendor/lib/lib.go", Additions: 40},
+			{Filename: "go.sum", Additions: 2},
+		},
+		TotalFiles: 3,
+	}
+
+	t.Run("excluded files are not analyzed and are reported", func(t *testing.T) {
+		var analyzedFiles []string
+		mockCA := &filteringCodeAnalyzer{parsedDiff: parsedDiff, analyzed: &analyzedFiles}
+		mockLLM := &mockLLMClientWithComments{reviewResponse: &llm.ReviewResponse{}}
+		mockGitHub := &mockGitHubCommentClient{}
+
+		orchestrator := &DefaultReviewOrchestrator{
+			workspaceManager: &mockWorkspaceManager{},
+			diffFetcher:      &mockDiffFetcher{diffResult: &github.DiffResult{RawDiff: "test diff", TotalFiles: 3}},
+			codeAnalyzer:     mockCA,
+			llmClient:        mockLLM,
+			githubClient:     mockGitHub,
+		}
+		orchestrator.SetPathFilters(nil, []string{"vendor/**", "*.sum"})
+
+		result, err := orchestrator.HandlePullRequest(createTestPullRequestEvent())
+		if err != nil {
+			t.Fatalf("HandlePullRequest failed: %v", err)
+		}
+
+		if !reflect.DeepEqual(analyzedFiles, []string{"main.go"}) {
+			t.Errorf("expected only main.go to be analyzed, got %v", analyzedFiles)
+		}
+		expected := []SkippedFile{
+			{Filename: "vendor/lib/lib.go", Reason: SkipReasonPathFilter},
+			{Filename: "go.sum", Reason: SkipReasonPathFilter},
+		}
+		if !reflect.DeepEqual(result.SkippedFiles, expected) {
+			t.Errorf("unexpected skipped files: %+v", result.SkippedFiles)
+		}
+		if !strings.Contains(result.Summary, "2 files not reviewed") {
+			t.Errorf("expected summary to mention skipped files, got %q", result.Summary)
+		}
+
+		finalBody := mockGitHub.updateIssueCommentCalls[len(mockGitHub.updateIssueCommentCalls)-1].body
+		if !strings.Contains(finalBody, "- `vendor/lib/lib.go`") || !strings.Contains(finalBody, "Excluded by path filters") {
+			t.Errorf("expected excluded files in progress comment, got:\n%s", finalBody)
+		}
+	})
+
+	t.Run("review is skipped when every file is excluded", func(t *testing.T) {
+		var analyzedFiles []string
+		mockCA := &filteringCodeAnalyzer{parsedDiff: parsedDiff, analyzed: &analyzedFiles}
+		mockLLM := &mockLLMClientWithComments{reviewResponse: &llm.ReviewResponse{}}
+
+		orchestrator := &DefaultReviewOrchestrator{
+			workspaceManager: &mockWorkspaceManager{},
+			diffFetcher:      &mockDiffFetcher{diffResult: &github.DiffResult{RawDiff: "test diff", TotalFiles: 3}},
+			codeAnalyzer:     mockCA,
+			llmClient:        mockLLM,
+		}
+		orchestrator.SetPathFilters([]string{"docs/**"}, nil)
+
+		result, err := orchestrator.HandlePullRequest(createTestPullRequestEvent())
+		if err != nil {
+			t.Fatalf("HandlePullRequest failed: %v", err)
+		}
+		if len(mockLLM.requests) != 0 {
+			t.Errorf("expected no LLM requests, got %d", len(mockLLM.requests))
+		}
+		if len(result.SkippedFiles) != 3 {
+			t.Errorf("expected 3 skipped files, got %d", len(result.SkippedFiles))
+		}
+	})
+}
+
+func TestDefaultReviewOrchestrator_GeneratedFiles(t *testing.T) {
+	parsedDiff := &analyzer.ParsedDiff{
+		Files: []analyzer.FileDiff{
+			{Filename: "main.go", Additions: 3},
+			{Filename: "api/user.pb.go", Additions: 400},
+			{Filename: "third_party/lib/lib.go", Additions: 40},
+			{Filename: "docs/notes.md", Additions: 2},
+		},
+		TotalFiles: 4,
+	}
+
+	var analyzedFiles []string
+	mockCA := &filteringCodeAnalyzer{parsedDiff: parsedDiff, analyzed: &analyzedFiles}
+	mockLLM := &mockLLMClientWithComments{reviewResponse: &llm.ReviewResponse{}}
+
+	orchestrator := &DefaultReviewOrchestrator{
+		workspaceManager: &mockWorkspaceManager{},
+		diffFetcher:      &mockDiffFetcher{diffResult: &github.DiffResult{RawDiff: "test diff", TotalFiles: 4}},
+		codeAnalyzer:     mockCA,
+		llmClient:        mockLLM,
+	}
+	orchestrator.SetPathFilters(nil, []string{"docs/**"})
+
+	result, err := orchestrator.HandlePullRequest(createTestPullRequestEvent())
+	if err != nil {
+		t.Fatalf("HandlePullRequest failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(analyzedFiles, []string{"main.go"}) {
+		t.Errorf("expected only main.go to be analyzed, got %v", analyzedFiles)
+	}
+	expected := []SkippedFile{
+		{Filename: "docs/notes.md", Reason: SkipReasonPathFilter},
+		{Filename: "api/user.pb.go", Reason: SkipReasonGenerated},
+		{Filename: "third_party/lib/lib.go", Reason: SkipReasonVendored},
+	}
+	if !reflect.DeepEqual(result.SkippedFiles, expected) {
+		t.Errorf("unexpected skipped files: %+v", result.SkippedFiles)
+	}
+	if !strings.Contains(result.Summary, "3 files not reviewed") {
+		t.Errorf("expected summary to count generated and vendored files, got %q", result.Summary)
+	}
+}
+
+func TestDefaultReviewOrchestrator_TokenBudget(t *testing.T) {
+	largeBlock := func(filename string) analyzer.FileWithContext {
+		block := analyzer.ContextBlock{StartLine: 1, EndLine: 50}
+		for i := 0; i < 50; i++ {
+			block.Lines = append(block.Lines, analyzer.DiffLine{Type: "added", Content: strings.Repeat("x", 80)})
+		}
+		return analyzer.FileWithContext{
+			FileDiff:      analyzer.FileDiff{Filename: filename, Additions: 50},
+			ContextBlocks: []analyzer.ContextBlock{block},
+		}
+	}
+	mockCA := &mockCodeAnalyzer{
+		contextualDiff: &analyzer.ContextualDiff{
+			ParsedDiff: &analyzer.ParsedDiff{TotalFiles: 3},
+			FilesWithContext: []analyzer.FileWithContext{
+				largeBlock("docs/notes.md"),
+				largeBlock("internal/auth/token.go"),
+				largeBlock("internal/render/page.go"),
+			},
+		},
+	}
+	mockLLM := &mockLLMClientWithComments{reviewResponse: &llm.ReviewResponse{}}
+
+	orchestrator := &DefaultReviewOrchestrator{
+		workspaceManager: &mockWorkspaceManager{},
+		diffFetcher:      &mockDiffFetcher{diffResult: &github.DiffResult{RawDiff: "test diff", TotalFiles: 3}},
+		codeAnalyzer:     mockCA,
+		llmClient:        mockLLM,
+	}
+	orchestrator.SetTokenBudget(2500)
+
+	result, err := orchestrator.HandlePullRequest(createTestPullRequestEvent())
+	if err != nil {
+		t.Fatalf("HandlePullRequest failed: %v", err)
+	}
+
+	if len(mockLLM.requests) != 1 {
+		t.Fatalf("expected 1 LLM request, got %d", len(mockLLM.requests))
+	}
+	var reviewed []string
+	for _, file := range mockLLM.requests[0].ContextualDiff.FilesWithContext {
+		reviewed = append(reviewed, file.Filename)
+	}
+	if !reflect.DeepEqual(reviewed, []string{"internal/auth/token.go", "internal/render/page.go"}) {
+		t.Errorf("expected the riskiest files to be reviewed first, got %v", reviewed)
+	}
+	expected := []SkippedFile{{Filename: "docs/notes.md", Reason: SkipReasonTokenBudget}}
+	if !reflect.DeepEqual(result.SkippedFiles, expected) {
+		t.Errorf("unexpected skipped files: %+v", result.SkippedFiles)
+	}
+}
+
+// filteringCodeAnalyzer builds the contextual diff from whatever parsed diff it is given
+type filteringCodeAnalyzer struct {
+	parsedDiff *analyzer.ParsedDiff
+	analyzed   *[]string
+}
+
+func (m *filteringCodeAnalyzer) ParseDiff(rawDiff string) (*analyzer.ParsedDiff, error) {
+	return m.parsedDiff, nil
+}
+
+func (m *filteringCodeAnalyzer) ExtractContext(parsedDiff *analyzer.ParsedDiff, contextLines int) (*analyzer.ContextualDiff, error) {
+	contextualDiff := &analyzer.ContextualDiff{ParsedDiff: parsedDiff}
+	for _, file := range parsedDiff.Files {
+		*m.analyzed = append(*m.analyzed, file.Filename)
+		contextualDiff.FilesWithContext = append(contextualDiff.FilesWithContext, analyzer.FileWithContext{FileDiff: file})
+	}
+	return contextualDiff, nil
+}
+
+func TestDefaultReviewOrchestrator_LazyWorkspaceReadsConfigThroughAPI(t *testing.T) {
+	mockCloner := &mockGitHubCloner{shouldFail: true, error: fmt.Errorf("clone should not be needed")}
+	workspaceManager := NewDefaultWorkspaceManager(mockCloner, &mockFileSystemManager{})
+	contents := &mockContentReader{files: map[string]string{"def456:.review-agent.yml": "context_lines: 8\n"}}
+	workspaceManager.SetContentReader(contents)
+
+	mockCA := &mockCodeAnalyzer{
+		contextualDiff: &analyzer.ContextualDiff{
+			ParsedDiff: &analyzer.ParsedDiff{TotalFiles: 1},
+		},
+	}
+	orchestrator := newConfiguredOrchestrator(&mockLLMClientWithComments{reviewResponse: &llm.ReviewResponse{}}, &mockGitHubCommentClient{}, mockCA, "")
+	orchestrator.workspaceManager = workspaceManager
+
+	result, err := orchestrator.HandlePullRequest(createTestPullRequestEvent())
+	if err != nil {
+		t.Fatalf("HandlePullRequest failed: %v", err)
+	}
+
+	if result.RepoConfig != ".review-agent.yml" || mockCA.contextLines != 8 {
+		t.Errorf("expected the config from the contents API, got %q with %d context lines", result.RepoConfig, mockCA.contextLines)
+	}
+	if len(mockCloner.clonedRepos) != 0 {
+		t.Errorf("expected no clone when no stage needs the checkout, got %v", mockCloner.clonedRepos)
+	}
+	if len(result.Warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", result.Warnings)
+	}
+}
+
+func TestDefaultReviewOrchestrator_FileClassifierUsesBaseAttributes(t *testing.T) {
+	event := createTestPullRequestEvent()
+	event.PullRequest.Base.SHA = "base123"
+	contents := &mockContentReader{files: map[string]string{
+		"base123:.gitattributes": "gen/** linguist-generated\n",
+		// The pull request tries to have every file skipped
+		"head456:.gitattributes": "* linguist-generated\n",
+	}}
+	workspace := &Workspace{
+		Repository:  &event.Repository,
+		PullRequest: &event.PullRequest,
+		contents:    contents,
+		files:       NewGitHubFileProvider(contents, event.Repository.Owner.Login, event.Repository.Name, "head456"),
+	}
+
+	orchestrator := &DefaultReviewOrchestrator{}
+	orchestrator.SetRepoConfigReader(&mockRepoConfigReader{})
+	classifier := orchestrator.fileClassifier(context.Background(), workspace)
+
+	if got := classifier.Classify(analyzer.FileDiff{Filename: "main.go"}); got != analyzer.ClassificationNone {
+		t.Errorf("expected the head .gitattributes to be ignored, got %s", got)
+	}
+	if got := classifier.Classify(analyzer.FileDiff{Filename: "gen/api.go"}); got != analyzer.ClassificationGenerated {
+		t.Errorf("expected the base .gitattributes to be honored, got %s", got)
+	}
+	if workspace.CheckedOut() {
+		t.Error("expected .gitattributes to be read without a checkout")
+	}
+}
@@ -0,0 +1,179 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/github"
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
+)
+
+// Hidden markers identifying comments written by the review agent
+const (
+	ReviewCommentMarker    = "<!-- review-agent:review-comment -->"
+	AddressedCommentMarker = "<!-- review-agent:addressed -->"
+)
+
+// findingLineWindow is how many lines a new finding may drift from an old thread and still count as the same finding
+const findingLineWindow = 3
+
+// ReviewThreadClient manages review threads left on earlier commits
+type ReviewThreadClient interface {
+	GetReviewThreads(ctx context.Context, owner, repo string, prNumber int) ([]github.ReviewThread, error)
+	ResolveReviewThread(ctx context.Context, threadID string) error
+	ReplyToPullRequestComment(ctx context.Context, owner, repo string, prNumber int, commentID int64, body string) (*github.PullRequestComment, error)
+}
+
+// withReviewCommentMarker appends the agent marker to a comment body
+func withReviewCommentMarker(body string) string {
+	return body + "\n\n" + ReviewCommentMarker
+}
+
+// stripMarkers removes agent markers from a comment body
+func stripMarkers(body string) string {
+	body = strings.ReplaceAll(body, ReviewCommentMarker, "")
+	body = strings.ReplaceAll(body, AddressedCommentMarker, "")
+	return strings.TrimSpace(body)
+}
+
+// isAgentThread reports whether a thread was started by the review agent
+func isAgentThread(thread github.ReviewThread) bool {
+	return len(thread.Comments) > 0 && strings.Contains(thread.Comments[0].Body, ReviewCommentMarker)
+}
+
+// isMarkedAddressed reports whether the agent already replied that the thread was addressed
+func isMarkedAddressed(thread github.ReviewThread) bool {
+	for _, comment := range thread.Comments[1:] {
+		if strings.Contains(comment.Body, AddressedCommentMarker) {
+			return true
+		}
+	}
+	return false
+}
+
+// findingText returns a comment body without agent markers or the code block a suggested fix is
+// posted in, so a posted comment compares equal to the finding it was made from
+func findingText(body string) string {
+	body = stripMarkers(body)
+	lines := strings.Split(body, "\n")
+	fence := strings.TrimSpace(lines[len(lines)-1])
+	if len(lines) < 2 || len(fence) < 3 || strings.Trim(fence, "`") != "" {
+		return body
+	}
+	// The fence is longer than any backtick run in the code, so the first line above that
+	// starts with it opens the block
+	for i := len(lines) - 2; i > 0; i-- {
+		if strings.HasPrefix(lines[i], fence) {
+			return strings.TrimSpace(strings.Join(lines[:i], "\n"))
+		}
+	}
+	return body
+}
+
+// findingReproduces reports whether any new comment repeats the finding of an old thread. Only the
+// thread's current line is compared: its original line belongs to an older commit, and the
+// findings are on the head commit.
+func findingReproduces(thread github.ReviewThread, comments []llm.ReviewComment) bool {
+	previous := findingText(thread.Comments[0].Body)
+
+	for _, comment := range comments {
+		if comment.Filename != thread.Path {
+			continue
+		}
+		if findingText(comment.Comment) == previous {
+			return true
+		}
+		if thread.Line > 0 && comment.LineNumber > 0 && abs(comment.LineNumber-thread.Line) <= findingLineWindow {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findAddressedThreads returns open agent threads whose lines changed and whose finding no longer
+// reproduces. Only threads on reviewed files are considered, since a file the model did not see
+// cannot reproduce anything.
+func findAddressedThreads(threads []github.ReviewThread, comments []llm.ReviewComment, reviewed map[string]bool) []github.ReviewThread {
+	var addressed []github.ReviewThread
+
+	for _, thread := range threads {
+		if thread.IsResolved || !isAgentThread(thread) || isMarkedAddressed(thread) {
+			continue
+		}
+		if !reviewed[thread.Path] {
+			continue
+		}
+		// Threads on lines that did not change since the comment was made are left alone
+		if !thread.IsOutdated {
+			continue
+		}
+		if findingReproduces(thread, comments) {
+			continue
+		}
+		addressed = append(addressed, thread)
+	}
+
+	return addressed
+}
+
+// resolveAddressedThreads resolves earlier agent threads that the new head commit addressed,
+// replying on the thread instead when it cannot be resolved. Comments are all findings of this
+// run, including those held back, and reviewed holds the files sent to the model. Returns the
+// number of threads handled.
+func (r *DefaultReviewOrchestrator) resolveAddressedThreads(ctx context.Context, event *PullRequestEvent, comments []llm.ReviewComment, reviewed map[string]bool) (int, error) {
+	threadClient, ok := r.githubClient.(ReviewThreadClient)
+	if !ok {
+		return 0, nil
+	}
+
+	owner := event.Repository.Owner.Login
+	repo := event.Repository.Name
+
+	threads, err := threadClient.GetReviewThreads(ctx, owner, repo, event.Number)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get review threads: %w", err)
+	}
+
+	handled := 0
+	for _, thread := range findAddressedThreads(threads, comments, reviewed) {
+		err := threadClient.ResolveReviewThread(ctx, thread.ID)
+		if err == nil {
+			handled++
+			continue
+		}
+		log.Printf("Warning: failed to resolve review thread on %s, replying instead: %v", thread.Path, err)
+
+		reply := fmt.Sprintf("Addressed in %s.\n\n%s", event.PullRequest.Head.SHA, AddressedCommentMarker)
+		if _, err := threadClient.ReplyToPullRequestComment(ctx, owner, repo, event.Number, thread.Comments[0].ID, reply); err != nil {
+			return handled, fmt.Errorf("failed to mark review thread on %s as addressed: %w", thread.Path, err)
+		}
+		handled++
+	}
+
+	return handled, nil
+}
+
+// reviewedFiles returns the files whose diff the model saw in full in this run
+func reviewedFiles(reviewData *ReviewData, response *llm.ReviewResponse) map[string]bool {
+	reviewed := make(map[string]bool)
+	if reviewData == nil || reviewData.ContextualDiff == nil {
+		return reviewed
+	}
+	for _, file := range reviewData.ContextualDiff.FilesWithContext {
+		reviewed[file.Filename] = true
+	}
+	for _, filename := range response.UnreviewedFiles {
+		delete(reviewed, filename)
+	}
+	return reviewed
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
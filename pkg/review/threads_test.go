@@ -0,0 +1,252 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GDSources/claude-code-review-agent/pkg/analyzer"
+	"github.com/GDSources/claude-code-review-agent/pkg/github"
+	"github.com/GDSources/claude-code-review-agent/pkg/llm"
+)
+
+func agentThread(id, path string, line, originalLine int, outdated bool, body string) github.ReviewThread {
+	return github.ReviewThread{
+		ID:           id,
+		IsOutdated:   outdated,
+		Path:         path,
+		Line:         line,
+		OriginalLine: originalLine,
+		Comments: []github.PullRequestComment{
+			{ID: 100, Body: withReviewCommentMarker(body)},
+		},
+	}
+}
+
+// suggestionBody renders a finding with a suggested fix as it is posted
+func suggestionBody(comment, suggestion string) string {
+	request, _ := github.ConvertReviewCommentToGitHub(github.ReviewCommentInput{
+		Filename: "main.go", LineNumber: 10, Comment: comment, Suggestion: suggestion, Anchored: true,
+	}, "abc")
+	return request.Body
+}
+
+func TestFindAddressedThreads(t *testing.T) {
+	resolved := agentThread("resolved", "main.go", 0, 10, true, "Nil check missing")
+	resolved.IsResolved = true
+
+	humanThread := github.ReviewThread{
+		ID: "human", IsOutdated: true, Path: "main.go", OriginalLine: 10,
+		Comments: []github.PullRequestComment{{ID: 1, Body: "Please rename this"}},
+	}
+
+	alreadyReplied := agentThread("replied", "main.go", 0, 10, true, "Nil check missing")
+	alreadyReplied.Comments = append(alreadyReplied.Comments,
+		github.PullRequestComment{ID: 2, Body: "Addressed in abc.\n\n" + AddressedCommentMarker})
+
+	tests := []struct {
+		name     string
+		threads  []github.ReviewThread
+		comments []llm.ReviewComment
+		expected []string
+	}{
+		{
+			name:     "outdated thread no longer reproduced is addressed",
+			threads:  []github.ReviewThread{agentThread("t1", "main.go", 0, 10, true, "Nil check missing")},
+			expected: []string{"t1"},
+		},
+		{
+			name:     "unchanged lines are left open",
+			threads:  []github.ReviewThread{agentThread("t1", "main.go", 10, 10, false, "Nil check missing")},
+			expected: nil,
+		},
+		{
+			name:    "finding reproduced near the thread's current line stays open",
+			threads: []github.ReviewThread{agentThread("t1", "main.go", 10, 4, true, "Nil check missing")},
+			comments: []llm.ReviewComment{
+				{Filename: "main.go", LineNumber: 12, Comment: "Still missing a nil check"},
+			},
+			expected: nil,
+		},
+		{
+			name:    "original line from an older commit is not compared",
+			threads: []github.ReviewThread{agentThread("t1", "main.go", 0, 10, true, "Nil check missing")},
+			comments: []llm.ReviewComment{
+				{Filename: "main.go", LineNumber: 12, Comment: "Unused variable"},
+			},
+			expected: []string{"t1"},
+		},
+		{
+			name:    "finding posted with a suggestion reproduced elsewhere stays open",
+			threads: []github.ReviewThread{agentThread("t1", "main.go", 0, 10, true, suggestionBody("Nil check missing", "if x == nil {\n\treturn\n}"))},
+			comments: []llm.ReviewComment{
+				{Filename: "main.go", LineNumber: 80, Comment: "Nil check missing", Suggestion: "if y == nil {\n\treturn\n}"},
+			},
+			expected: nil,
+		},
+		{
+			name:    "finding reproduced with identical text elsewhere stays open",
+			threads: []github.ReviewThread{agentThread("t1", "main.go", 0, 10, true, "Nil check missing")},
+			comments: []llm.ReviewComment{
+				{Filename: "main.go", LineNumber: 80, Comment: "Nil check missing"},
+			},
+			expected: nil,
+		},
+		{
+			name:    "findings in other files do not count",
+			threads: []github.ReviewThread{agentThread("t1", "main.go", 0, 10, true, "Nil check missing")},
+			comments: []llm.ReviewComment{
+				{Filename: "other.go", LineNumber: 10, Comment: "Nil check missing"},
+			},
+			expected: []string{"t1"},
+		},
+		{
+			name:     "resolved, human and already answered threads are ignored",
+			threads:  []github.ReviewThread{resolved, humanThread, alreadyReplied},
+			expected: nil,
+		},
+		{
+			name:     "threads on files not sent to the model stay open",
+			threads:  []github.ReviewThread{agentThread("t1", "skipped.go", 0, 10, true, "Nil check missing")},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addressed := findAddressedThreads(tt.threads, tt.comments, map[string]bool{"main.go": true})
+			if len(addressed) != len(tt.expected) {
+				t.Fatalf("expected %d addressed threads, got %d", len(tt.expected), len(addressed))
+			}
+			for i, id := range tt.expected {
+				if addressed[i].ID != id {
+					t.Errorf("expected thread %s, got %s", id, addressed[i].ID)
+				}
+			}
+		})
+	}
+}
+
+type mockThreadClient struct {
+	mockGitHubCommentClient
+	threads         []github.ReviewThread
+	getErr          error
+	resolveErr      error
+	resolvedIDs     []string
+	replies         []string
+	replyCommentIDs []int64
+}
+
+func (m *mockThreadClient) GetReviewThreads(ctx context.Context, owner, repo string, prNumber int) ([]github.ReviewThread, error) {
+	return m.threads, m.getErr
+}
+
+func (m *mockThreadClient) ResolveReviewThread(ctx context.Context, threadID string) error {
+	if m.resolveErr != nil {
+		return m.resolveErr
+	}
+	m.resolvedIDs = append(m.resolvedIDs, threadID)
+	return nil
+}
+
+func (m *mockThreadClient) ReplyToPullRequestComment(ctx context.Context, owner, repo string, prNumber int, commentID int64, body string) (*github.PullRequestComment, error) {
+	m.replies = append(m.replies, body)
+	m.replyCommentIDs = append(m.replyCommentIDs, commentID)
+	return &github.PullRequestComment{ID: 999, Body: body}, nil
+}
+
+func TestFindingText(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{name: "plain", body: withReviewCommentMarker("Nil check missing"), expected: "Nil check missing"},
+		{name: "suggestion", body: withReviewCommentMarker(suggestionBody("Nil check missing", "x := 1")), expected: "Nil check missing"},
+		{name: "longer fence", body: "Quote it\n\n````suggestion\ns := \"```\"\n````", expected: "Quote it"},
+		{name: "only a code block", body: "```\nx := 1\n```", expected: "```\nx := 1\n```"},
+		{name: "unclosed fence", body: "See\n```go\nx := 1", expected: "See\n```go\nx := 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findingText(tt.body); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestResolveAddressedThreads(t *testing.T) {
+	threads := []github.ReviewThread{
+		agentThread("t1", "main.go", 0, 10, true, "Nil check missing"),
+		agentThread("t2", "main.go", 30, 30, false, "Unused variable"),
+	}
+
+	t.Run("resolves addressed threads", func(t *testing.T) {
+		client := &mockThreadClient{threads: threads}
+		orchestrator := &DefaultReviewOrchestrator{githubClient: client}
+
+		handled, err := orchestrator.resolveAddressedThreads(context.Background(), createTestPullRequestEvent(), nil, map[string]bool{"main.go": true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if handled != 1 || len(client.resolvedIDs) != 1 || client.resolvedIDs[0] != "t1" {
+			t.Errorf("expected thread t1 to be resolved, got %v", client.resolvedIDs)
+		}
+		if len(client.replies) != 0 {
+			t.Errorf("expected no replies, got %d", len(client.replies))
+		}
+	})
+
+	t.Run("replies when resolving fails", func(t *testing.T) {
+		client := &mockThreadClient{threads: threads, resolveErr: fmt.Errorf("forbidden")}
+		orchestrator := &DefaultReviewOrchestrator{githubClient: client}
+
+		handled, err := orchestrator.resolveAddressedThreads(context.Background(), createTestPullRequestEvent(), nil, map[string]bool{"main.go": true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if handled != 1 || len(client.replies) != 1 {
+			t.Fatalf("expected 1 reply, got %d", len(client.replies))
+		}
+		if !strings.Contains(client.replies[0], "Addressed in abc123") || !strings.Contains(client.replies[0], AddressedCommentMarker) {
+			t.Errorf("unexpected reply body: %s", client.replies[0])
+		}
+		if client.replyCommentIDs[0] != 100 {
+			t.Errorf("expected reply to comment 100, got %d", client.replyCommentIDs[0])
+		}
+	})
+
+	t.Run("clients without thread support are skipped", func(t *testing.T) {
+		orchestrator := &DefaultReviewOrchestrator{githubClient: &mockGitHubCommentClient{}}
+
+		handled, err := orchestrator.resolveAddressedThreads(context.Background(), createTestPullRequestEvent(), nil, map[string]bool{"main.go": true})
+		if err != nil || handled != 0 {
+			t.Errorf("expected no-op, got handled=%d err=%v", handled, err)
+		}
+	})
+
+	t.Run("thread lookup errors are returned", func(t *testing.T) {
+		client := &mockThreadClient{getErr: fmt.Errorf("GraphQL error")}
+		orchestrator := &DefaultReviewOrchestrator{githubClient: client}
+
+		if _, err := orchestrator.resolveAddressedThreads(context.Background(), createTestPullRequestEvent(), nil, map[string]bool{"main.go": true}); err == nil {
+			t.Error("expected error when threads cannot be fetched")
+		}
+	})
+}
+
+func TestReviewedFiles(t *testing.T) {
+	reviewData := &ReviewData{ContextualDiff: &analyzer.ContextualDiff{FilesWithContext: []analyzer.FileWithContext{
+		{FileDiff: analyzer.FileDiff{Filename: "main.go"}},
+		{FileDiff: analyzer.FileDiff{Filename: "large.go"}},
+	}}}
+	response := &llm.ReviewResponse{UnreviewedFiles: []string{"large.go"}}
+
+	reviewed := reviewedFiles(reviewData, response)
+	if !reviewed["main.go"] || reviewed["large.go"] || len(reviewed) != 1 {
+		t.Errorf("expected only main.go to be reviewed, got %v", reviewed)
+	}
+}
@@ -3,12 +3,17 @@ package review
 import (
 	"context"
 	"fmt"
+	"log"
+	"os"
 	"path/filepath"
 )
 
 type DefaultWorkspaceManager struct {
-	cloner GitHubCloner
-	fs     FileSystemManager
+	cloner   GitHubCloner
+	fs       FileSystemManager
+	mirrors  MirrorCache
+	contents RepositoryContentReader
+	janitor  *WorkspaceJanitor
 }
 
 func NewDefaultWorkspaceManager(cloner GitHubCloner, fs FileSystemManager) *DefaultWorkspaceManager {
@@ -18,34 +23,139 @@ func NewDefaultWorkspaceManager(cloner GitHubCloner, fs FileSystemManager) *Defa
 	}
 }
 
+// SetMirrorCache creates workspaces from a persistent repository mirror instead of a full clone.
+// The cloner must provide authenticated repository URLs; nil turns the cache off.
+func (w *DefaultWorkspaceManager) SetMirrorCache(mirrors MirrorCache) {
+	w.mirrors = mirrors
+}
+
+// SetContentReader lets lazy workspaces read single files through the API instead of cloning the
+// repository; nil turns it off, so reading any file checks the repository out
+func (w *DefaultWorkspaceManager) SetContentReader(contents RepositoryContentReader) {
+	w.contents = contents
+}
+
+// SetJanitor keeps running workspaces from being swept and waits for the janitor's disk quota
+// before checking a repository out; nil turns both off
+func (w *DefaultWorkspaceManager) SetJanitor(janitor *WorkspaceJanitor) {
+	w.janitor = janitor
+}
+
 func (w *DefaultWorkspaceManager) CreateWorkspace(ctx context.Context, event *PullRequestEvent) (*Workspace, error) {
-	tempDir, err := w.fs.CreateTempDir("review-agent-")
+	workspace, err := w.OpenWorkspace(ctx, event)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+		return nil, err
+	}
+	if _, err := workspace.Checkout(ctx); err != nil {
+		return nil, err
+	}
+
+	return workspace, nil
+}
+
+// OpenWorkspace returns a workspace that clones the repository on its first Checkout. Until then
+// files are read at the head SHA through the content reader, when one is set.
+func (w *DefaultWorkspaceManager) OpenWorkspace(ctx context.Context, event *PullRequestEvent) (*Workspace, error) {
+	workspace := &Workspace{
+		Repository:  &event.Repository,
+		PullRequest: &event.PullRequest,
+		contents:    w.contents,
+		checkout: func(ctx context.Context, ws *Workspace) error {
+			return w.checkout(ctx, event, ws)
+		},
+	}
+	if w.contents != nil && event.PullRequest.Head.SHA != "" {
+		workspace.files = NewGitHubFileProvider(w.contents, event.Repository.Owner.Login, event.Repository.Name, event.PullRequest.Head.SHA)
+	}
+
+	return workspace, nil
+}
+
+// checkout clones the repository into a new temporary directory and checks out the pull request
+func (w *DefaultWorkspaceManager) checkout(ctx context.Context, event *PullRequestEvent, workspace *Workspace) error {
+	var reservation *SpaceReservation
+	if w.janitor != nil {
+		// GitHub reports the repository size in kilobytes
+		reserved, err := w.janitor.WaitForSpace(ctx, int64(event.Repository.Size)*1024)
+		if err != nil {
+			return fmt.Errorf("failed to reserve disk space for workspace: %w", err)
+		}
+		reservation = reserved
+	}
+
+	tempDir, err := w.fs.CreateTempDir(WorkspacePrefix)
+	if err != nil {
+		if reservation != nil {
+			w.janitor.cancel(reservation)
+		}
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	if w.janitor != nil {
+		w.janitor.Track(tempDir)
+		w.janitor.assign(reservation, tempDir)
 	}
 
 	repoPath := filepath.Join(tempDir, event.Repository.Name)
 
+	if release, ok := w.createFromMirror(ctx, event, repoPath); ok {
+		workspace.Path = repoPath
+		workspace.release = release
+		return nil
+	}
+
 	if err := w.cloner.CloneRepository(ctx, event.Repository.Owner.Login, event.Repository.Name, repoPath); err != nil {
-		_ = w.fs.RemoveAll(tempDir)
-		return nil, fmt.Errorf("failed to clone repository %s/%s: %w",
+		w.removeTempDir(tempDir)
+		return fmt.Errorf("failed to clone repository %s/%s: %w",
 			event.Repository.Owner.Login, event.Repository.Name, err)
 	}
 
-	// Checkout the PR branch
+	// Check out the head commit through refs/pull, which also works for forks, or else the PR branch
 	branchName := event.PullRequest.Head.Ref
-	if err := w.cloner.CheckoutBranch(ctx, repoPath, branchName); err != nil {
-		_ = w.fs.RemoveAll(tempDir)
-		return nil, fmt.Errorf("failed to checkout branch %s: %w", branchName, err)
+	if checkout, ok := w.cloner.(PullRequestCheckout); ok {
+		if err := checkout.CheckoutPullRequest(ctx, repoPath, event.Number, branchName, event.PullRequest.Head.SHA); err != nil {
+			w.removeTempDir(tempDir)
+			return fmt.Errorf("failed to checkout pull request #%d: %w", event.Number, err)
+		}
+	} else if err := w.cloner.CheckoutBranch(ctx, repoPath, branchName); err != nil {
+		w.removeTempDir(tempDir)
+		return fmt.Errorf("failed to checkout branch %s: %w", branchName, err)
 	}
 
-	workspace := &Workspace{
-		Path:        repoPath,
-		Repository:  &event.Repository,
-		PullRequest: &event.PullRequest,
+	workspace.Path = repoPath
+	return nil
+}
+
+// createFromMirror checks out the pull request head from the repository mirror.
+// Returns false when there is no mirror cache or it fails, so the repository is cloned instead.
+func (w *DefaultWorkspaceManager) createFromMirror(ctx context.Context, event *PullRequestEvent, repoPath string) (func(), bool) {
+	if w.mirrors == nil {
+		return nil, false
+	}
+	provider, ok := w.cloner.(RemoteURLProvider)
+	if !ok {
+		return nil, false
+	}
+	owner, name := event.Repository.Owner.Login, event.Repository.Name
+	remoteURL := provider.AuthenticatedCloneURL(owner, name)
+	if remoteURL == "" {
+		return nil, false
 	}
 
-	return workspace, nil
+	// Check out the exact head commit the review is for, on the pull request's branch name. The pull
+	// ref is fetched into the mirror so heads that only exist in a fork are available.
+	ref := event.PullRequest.Head.SHA
+	if ref == "" {
+		ref = event.PullRequest.Head.Ref
+	}
+	pullRef := fmt.Sprintf("refs/pull/%d/head", event.Number)
+	release, err := w.mirrors.CreateWorktree(ctx, owner, name, remoteURL, repoPath, ref, event.PullRequest.Head.Ref, pullRef)
+	if err != nil {
+		log.Printf("Warning: repository cache failed for %s/%s, cloning instead: %v", owner, name, err)
+		_ = w.fs.RemoveAll(repoPath)
+		return nil, false
+	}
+
+	return release, true
 }
 
 func (w *DefaultWorkspaceManager) CleanupWorkspace(workspace *Workspace) error {
@@ -54,9 +164,97 @@ func (w *DefaultWorkspaceManager) CleanupWorkspace(workspace *Workspace) error {
 	}
 
 	tempDir := filepath.Dir(workspace.Path)
-	if err := w.fs.RemoveAll(tempDir); err != nil {
+	err := w.fs.RemoveAll(tempDir)
+	if w.janitor != nil {
+		w.janitor.Release(tempDir)
+	}
+	if workspace.release != nil {
+		workspace.release()
+		workspace.release = nil
+	}
+	if err != nil {
 		return fmt.Errorf("failed to cleanup workspace at %s: %w", tempDir, err)
 	}
 
 	return nil
 }
+
+// removeTempDir removes the temporary directory of a failed checkout
+func (w *DefaultWorkspaceManager) removeTempDir(tempDir string) {
+	_ = w.fs.RemoveAll(tempDir)
+	if w.janitor != nil {
+		w.janitor.Release(tempDir)
+	}
+}
+
+// Checkout clones the repository the first time it is called and returns the checkout path.
+// A failed checkout is not retried.
+func (w *Workspace) Checkout(ctx context.Context) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.Path != "" || w.err != nil {
+		return w.Path, w.err
+	}
+	if w.checkout == nil {
+		return "", fmt.Errorf("workspace has no checkout")
+	}
+
+	if err := w.checkout(ctx, w); err != nil {
+		w.err = err
+		return "", err
+	}
+	log.Printf("Checked out %s at %s", w.PullRequest.Head.Ref, w.Path)
+	return w.Path, nil
+}
+
+// Size returns the disk space used by the checkout, 0 when the repository is not checked out
+func (w *Workspace) Size() int64 {
+	if w == nil || !w.CheckedOut() {
+		return 0
+	}
+	return directorySize(filepath.Dir(w.Path))
+}
+
+// CheckedOut reports whether the repository has been cloned
+func (w *Workspace) CheckedOut() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.Path != ""
+}
+
+// ReadFile reads a file at the pull request head: from the checkout when there is one, through the
+// file provider otherwise, and by checking the repository out when there is neither
+func (w *Workspace) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	if !filepath.IsLocal(filepath.FromSlash(path)) {
+		return nil, fmt.Errorf("path %s is outside the repository", path)
+	}
+
+	if !w.CheckedOut() && w.files != nil {
+		return w.files.ReadFile(ctx, path)
+	}
+
+	repoPath, err := w.Checkout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(repoPath, filepath.FromSlash(path)))
+}
+
+// GitHubFileProvider reads files of a repository at a commit through the GitHub contents API
+type GitHubFileProvider struct {
+	contents RepositoryContentReader
+	owner    string
+	repo     string
+	ref      string
+}
+
+// NewGitHubFileProvider creates a provider for the files of owner/repo at ref
+func NewGitHubFileProvider(contents RepositoryContentReader, owner, repo, ref string) *GitHubFileProvider {
+	return &GitHubFileProvider{contents: contents, owner: owner, repo: repo, ref: ref}
+}
+
+// ReadFile fetches a file at the provider's ref
+func (p *GitHubFileProvider) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	return p.contents.GetFileContent(ctx, p.owner, p.repo, path, p.ref)
+}
@@ -34,14 +34,20 @@ type PullRequestEvent struct {
 }
 
 type PullRequest struct {
-	ID     int    `json:"id"`
-	Number int    `json:"number"`
-	Title  string `json:"title"`
-	Body   string `json:"body"`
-	State  string `json:"state"`
-	Head   Branch `json:"head"`
-	Base   Branch `json:"base"`
-	User   User   `json:"user"`
+	ID     int     `json:"id"`
+	Number int     `json:"number"`
+	Title  string  `json:"title"`
+	Body   string  `json:"body"`
+	State  string  `json:"state"`
+	Draft  bool    `json:"draft"`
+	Labels []Label `json:"labels"`
+	Head   Branch  `json:"head"`
+	Base   Branch  `json:"base"`
+	User   User    `json:"user"`
+}
+
+type Label struct {
+	Name string `json:"name"`
 }
 
 type Repository struct {
@@ -50,6 +56,8 @@ type Repository struct {
 	FullName string `json:"full_name"`
 	Private  bool   `json:"private"`
 	Owner    User   `json:"owner"`
+	Size     int    `json:"size,omitempty"` // Disk usage in kilobytes, as reported by GitHub
+	HTMLURL  string `json:"html_url,omitempty"`
 }
 
 type Branch struct {
@@ -79,13 +79,53 @@ if [ -n "$ACTION_SKIP_LABELS" ] && [ -f "$GITHUB_EVENT_PATH" ]; then
 fi
 
 # Prepare review command
-REVIEW_CMD="/app/review-agent review --owner $OWNER --repo $REPO --pr $PR_NUMBER"
+RESULT_FILE="${RUNNER_TEMP:-/tmp}/review-result.json"
+REVIEW_CMD="/app/review-agent review --owner $OWNER --repo $REPO --pr $PR_NUMBER --output-file $RESULT_FILE"
 
 # Add model if specified
 if [ -n "$CLAUDE_MODEL" ]; then
     REVIEW_CMD="$REVIEW_CMD --claude-model $CLAUDE_MODEL"
 fi
 
+# Path filters are passed through the environment so globs are not expanded by the shell
+if [ -n "$ACTION_REVIEW_PATHS" ]; then
+    export REVIEW_INCLUDE_PATHS="$ACTION_REVIEW_PATHS"
+    echo "📂 Reviewing paths: $ACTION_REVIEW_PATHS"
+fi
+if [ -n "$ACTION_EXCLUDE_PATHS" ]; then
+    export REVIEW_EXCLUDE_PATHS="$ACTION_EXCLUDE_PATHS"
+    echo "🚫 Excluding paths: $ACTION_EXCLUDE_PATHS"
+fi
+
+# Findings below the confidence threshold are held back instead of posted
+if [ -n "$ACTION_COMMENT_THRESHOLD" ]; then
+    export REVIEW_COMMENT_THRESHOLD="$ACTION_COMMENT_THRESHOLD"
+    echo "🎯 Comment confidence threshold: $ACTION_COMMENT_THRESHOLD"
+fi
+if [ "$ACTION_SHOW_POSSIBLE_ISSUES" = "true" ]; then
+    export REVIEW_SHOW_POSSIBLE_ISSUES=true
+fi
+if [ -n "$ACTION_TOKEN_BUDGET" ]; then
+    export REVIEW_TOKEN_BUDGET="$ACTION_TOKEN_BUDGET"
+fi
+if [ -n "$ACTION_BUDGET_PER_REVIEW" ]; then
+    export REVIEW_BUDGET_PER_REVIEW="$ACTION_BUDGET_PER_REVIEW"
+    echo "💰 Cost budget per review: \$$ACTION_BUDGET_PER_REVIEW"
+fi
+if [ "$ACTION_MODEL_ROUTING" = "true" ]; then
+    export REVIEW_MODEL_ROUTING=true
+    echo "🧭 Model routing enabled"
+fi
+
+# Suggested fixes are pushed to a branch instead of posted as comments
+if [ "$ACTION_AUTOFIX" = "true" ]; then
+    export REVIEW_AUTOFIX=true
+    echo "🔧 Autofix enabled"
+fi
+if [ -n "$ACTION_AUTOFIX_VERIFY" ]; then
+    export REVIEW_AUTOFIX_VERIFY="$ACTION_AUTOFIX_VERIFY"
+fi
+
 # Debug: Show environment for troubleshooting
 if [ "$RUNNER_DEBUG" = "1" ]; then
     echo "🔍 Debug: Environment variables"
@@ -101,21 +141,42 @@ REVIEW_OUTPUT=$($REVIEW_CMD 2>&1)
 REVIEW_EXIT_CODE=$?
 set -e
 
+# Expose structured fields from the result file written by the review command
+write_result_outputs() {
+    if [ ! -f "$RESULT_FILE" ] || ! command -v jq >/dev/null 2>&1; then
+        return
+    fi
+
+    echo "result-file=${RESULT_FILE}" >> $GITHUB_OUTPUT
+    echo "highest-severity=$(jq -r '.highest_severity // "none"' "$RESULT_FILE")" >> $GITHUB_OUTPUT
+    echo "tokens-used=$(jq -r '.tokens_used.total_tokens // 0' "$RESULT_FILE")" >> $GITHUB_OUTPUT
+    echo "model-used=$(jq -r '.model // empty' "$RESULT_FILE")" >> $GITHUB_OUTPUT
+    echo "comments-skipped=$(jq -r '[.comments[]? | select(.status == "skipped")] | length' "$RESULT_FILE")" >> $GITHUB_OUTPUT
+    echo "comments-failed=$(jq -r '[.comments[]? | select(.status == "failed")] | length' "$RESULT_FILE")" >> $GITHUB_OUTPUT
+    echo "warnings=$(jq -r '.warnings // [] | length' "$RESULT_FILE")" >> $GITHUB_OUTPUT
+    echo "files-skipped=$(jq -r '.skipped_files // [] | length' "$RESULT_FILE")" >> $GITHUB_OUTPUT
+}
+
 # Parse review results from output
 COMMENTS_POSTED=0
 if [ $REVIEW_EXIT_CODE -eq 0 ]; then
     echo "✅ Review completed successfully"
     echo "review-status=success" >> $GITHUB_OUTPUT
+    write_result_outputs
     
-    # Parse JSON output from the review command
-    REVIEW_JSON=$(echo "$REVIEW_OUTPUT" | grep "REVIEW_RESULT_JSON:" | sed 's/REVIEW_RESULT_JSON://')
+    # Prefer the result file, falling back to the JSON line printed by the review command
+    if [ -f "$RESULT_FILE" ]; then
+        REVIEW_JSON=$(cat "$RESULT_FILE")
+    else
+        REVIEW_JSON=$(echo "$REVIEW_OUTPUT" | grep "REVIEW_RESULT_JSON:" | sed 's/REVIEW_RESULT_JSON://')
+    fi
     if [ -n "$REVIEW_JSON" ]; then
         # Extract comments_posted from JSON using jq if available, otherwise use grep/sed
         if command -v jq >/dev/null 2>&1; then
             COMMENTS_POSTED=$(echo "$REVIEW_JSON" | jq -r '.comments_posted // 0')
         else
             # Fallback parsing without jq
-            COMMENTS_POSTED=$(echo "$REVIEW_JSON" | sed -n 's/.*"comments_posted":\([0-9]*\).*/\1/p')
+            COMMENTS_POSTED=$(echo "$REVIEW_JSON" | tr -d '\n ' | sed -n 's/.*"comments_posted":\([0-9]*\).*/\1/p')
             # If no match found, default to 0
             if [ -z "$COMMENTS_POSTED" ]; then
                 COMMENTS_POSTED=0
@@ -134,6 +195,7 @@ else
     echo "❌ Review failed with exit code: $REVIEW_EXIT_CODE"
     echo "review-status=failed" >> $GITHUB_OUTPUT
     echo "comments-posted=0" >> $GITHUB_OUTPUT
+    write_result_outputs
     # Still output the error for debugging
     echo "$REVIEW_OUTPUT"
     exit $REVIEW_EXIT_CODE